@@ -0,0 +1,97 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditCapture  = "capture"
+	AuditDelete   = "delete"
+	AuditRollback = "rollback"
+	AuditRestore  = "restore"
+)
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Path    string    `json:"path"`
+	Version int       `json:"version"`
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"size"`
+}
+
+// AuditLog appends a JSON line for every version captured, deleted, rolled
+// back to or restored. Unlike the app log it is never rotated or
+// compressed, and the daemon and the CLI can append to the same file.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditLog returns an audit log writing to path, or nil when path is
+// empty. Recording to a nil audit log does nothing.
+func NewAuditLog(path string) *AuditLog {
+	if path == "" {
+		return nil
+	}
+	return &AuditLog{path: path}
+}
+
+// Record appends an entry for a version of the file at path. A failure is
+// logged rather than returned: the operation being audited has already
+// happened.
+func (a *AuditLog) Record(action, path string, version int, hash string, size int64) {
+	if a == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Path:    path,
+		Version: version,
+		Hash:    hash,
+		Size:    size,
+	}
+	if err := a.append(entry); err != nil {
+		Logger.WithError(err).WithField("audit_log", a.path).Error("Failed to write audit log entry")
+	}
+}
+
+func (a *AuditLog) append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	// Opened for each entry with O_APPEND, so lines written by the daemon
+	// and a rollback at the same time are never interleaved
+	file, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return file.Close()
+}
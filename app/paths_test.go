@@ -0,0 +1,30 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+	t.Setenv("WORK", "/srv/work")
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", "/home/dev"},
+		{"~/projects/app", filepath.Join("/home/dev", "projects", "app")},
+		{"$HOME/projects/app", "/home/dev/projects/app"},
+		{"${WORK}/repo", "/srv/work/repo"},
+		{"${WORK}/$UNSET_REWIND_VAR/repo", "/srv/work//repo"},
+		{"/abs/path", "/abs/path"},
+		{"~other/path", "~other/path"},
+	}
+
+	for _, tt := range tests {
+		if got := ExpandPath(tt.path); got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+// addCmd represents the add command
+var addCmd = &cobra.Command{
+	Use:   "add <file_path>",
+	Short: "Capture the current content of a file as a new version",
+	Long: `Capture the current content of a single file as a new version, without
+relying on the daemon.
+
+If the content matches the latest stored version nothing is captured and
+"unchanged" is printed.
+
+A file outside any rewind project is captured in the per-user global store
+(~/.local/share/rewind/global.db), so single files such as system configs can
+be versioned without a project. rollback and diff find it there.
+
+Examples:
+  rewind add src/main.go               # Capture src/main.go now
+  rewind add /etc/nginx/nginx.conf     # Capture a file outside any project`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runAdd(args[0]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+}
+
+func runAdd(filePath string) error {
+	// Get absolute path
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return withExitCode(exitNotFound, fmt.Errorf("file does not exist: %s", filePath))
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("not a regular file: %s", filePath)
+	}
+
+	// Files outside any project go to the global store
+	db, err := openAddDatabase(absPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	latestVersion, err := db.GetLatestFileVersion(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to get latest version: %w", err)
+	}
+
+	if latestVersion != nil && !latestVersion.Deleted {
+		currentHash, err := latestVersion.HashFile(absPath)
+		if err != nil {
+			return fmt.Errorf("failed to calculate file hash: %w", err)
+		}
+
+		if currentHash == latestVersion.FileHash {
+			fmt.Printf("unchanged (version %d)\n", latestVersion.VersionNumber)
+			return nil
+		}
+	}
+
+	versionNumber, err := captureFileVersion(db, absPath, database.EventOpWrite)
+	if err != nil {
+		return err
+	}
+
+	if db.IsGlobal() {
+		fmt.Printf("✓ Saved %s as version %d in the global store\n", absPath, versionNumber)
+	} else {
+		fmt.Printf("✓ Saved %s as version %d\n", filePath, versionNumber)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/viper"
+)
+
+// auditLogPath returns the audit_log setting with a leading ~ and any
+// environment variables expanded, or an empty string when no audit log is
+// configured
+func auditLogPath() string {
+	return app.ExpandPath(viper.GetString("audit_log"))
+}
+
+// recordAudit adds an entry for version of the file at path to the audit
+// log, when one is configured
+func recordAudit(action, path string, version *database.FileVersion) {
+	app.NewAuditLog(auditLogPath()).Record(action, path, version.VersionNumber, version.FileHash, version.FileSize)
+}
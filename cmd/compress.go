@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var compressOlderThanFlag string
+var compressDryRunFlag bool
+var compressVerboseFlag bool
+
+// compressCmd represents the compress command
+var compressCmd = &cobra.Command{
+	Use:   "compress",
+	Short: "Compress stored versions to reclaim space",
+	Long: `Compress versions stored as full copies with gzip, reclaiming space without
+losing any history. Compressed versions are decompressed transparently by
+rollback, diff, restore and the HTTP API.
+
+Each version is checked against the hash recorded when it was captured before
+it is compressed, and the compressed copy is read back and checked again before
+the original is deleted. Versions that would not get smaller are left alone,
+as are deltas, which are already small.
+
+The daemon can compress old versions in the background: set compress_after in
+the config file (e.g. 30d).
+
+Examples:
+  rewind compress                    # Compress every uncompressed version
+  rewind compress --older-than 30d   # Only versions captured over 30 days ago
+  rewind compress --dry-run          # Show how much space would be saved`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runCompress(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compressCmd)
+	compressCmd.Flags().StringVar(&compressOlderThanFlag, "older-than", "", "Only compress versions older than this (e.g. 7d, 2w, 3M)")
+	compressCmd.Flags().BoolVar(&compressDryRunFlag, "dry-run", false, "Show the space that would be saved without compressing anything")
+	compressCmd.Flags().BoolVarP(&compressVerboseFlag, "verbose", "V", false, "List each version as it is compressed")
+	addBytesFlag(compressCmd)
+}
+
+func runCompress() error {
+	cutoff := time.Now()
+	if compressOlderThanFlag != "" {
+		age, err := parseDuration(compressOlderThanFlag)
+		if err != nil {
+			return fmt.Errorf("invalid --older-than: %w", err)
+		}
+		cutoff = cutoff.Add(-age)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	versions, err := db.GetCompressibleVersions(cutoff)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		fmt.Println("No versions to compress")
+		return nil
+	}
+
+	var compressed, skipped, failed int
+	var originalBytes, compressedBytes int64
+	for _, version := range versions {
+		result, err := db.CompressVersion(version, compressDryRunFlag)
+		if err != nil {
+			fmt.Printf("Error: %s version %d: %v\n", version.FilePath, version.VersionNumber, err)
+			failed++
+			continue
+		}
+		if result.Skipped {
+			skipped++
+			continue
+		}
+
+		compressed++
+		originalBytes += result.OriginalSize
+		compressedBytes += result.CompressedSize
+		if compressVerboseFlag {
+			fmt.Printf("%s version %d: %s -> %s\n", version.FilePath, version.VersionNumber,
+				formatSize(result.OriginalSize), formatSize(result.CompressedSize))
+		}
+	}
+
+	verb := "Compressed"
+	if compressDryRunFlag {
+		verb = "Would compress"
+	}
+	fmt.Printf("%s %d versions: %s -> %s, saving %s\n", verb, compressed,
+		formatSize(originalBytes), formatSize(compressedBytes),
+		formatSize(originalBytes-compressedBytes))
+	if skipped > 0 {
+		fmt.Printf("Left %d versions uncompressed as they would not get smaller\n", skipped)
+	}
+	if compressDryRunFlag {
+		fmt.Println("Dry run - no files were changed")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d versions could not be compressed", failed)
+	}
+	return nil
+}
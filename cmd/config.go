@@ -0,0 +1,405 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/internal/api"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configKey describes a setting that can be managed with 'rewind config'
+type configKey struct {
+	name        string
+	description string
+	// parse validates a value given on the command line and returns it in
+	// the form written to the config file
+	parse func(value string) (any, error)
+}
+
+// configKeys lists every setting rewind reads from the config file
+var configKeys = []configKey{
+	{"fsync", "Flush every stored version to disk as it is written", parseBoolValue},
+	{"auto_ignore_git", "Ignore .git directories that the ignore patterns miss", parseBoolValue},
+	{"ignore_editor_artifacts", "Ignore editors' swap, lock and backup files", parseBoolValue},
+	{"storage_mode", "How versions are stored: copy, hardlink or delta", parseStorageMode},
+	{"delta_keyframe_interval", "In delta mode, store a full copy at least every this many versions", parsePositiveCountValue},
+	{"hash_algorithm", "How new versions are hashed to detect changes: sha256, blake3 or xxhash", parseHashAlgorithm},
+	{"include", "Comma-separated patterns to limit versioning to", parseListValue},
+	{"notify_on_delete", "Show a desktop notification when a file is deleted", parseBoolValue},
+	{"create_grace_period", "Wait this long before capturing a new file (e.g. 500ms)", parseGoDuration},
+	{"capture.on", "Capture on every write, or once a save has finished: write or close", parseCaptureOn},
+	{"capture.quiet_period", "With capture.on close, how long a file must go unchanged to count as saved (e.g. 1s)", parseGoDuration},
+	{"max_versions_per_file", "Keep at most this many untagged versions per file (0 = unlimited)", parseCountValue},
+	{"max_total_size", "Purge the oldest untagged versions to keep each project's stored size under this (e.g. 5GB, 0 = unlimited)", parseSizeValue},
+	{"track_directories", "Record directories so bulk restore can recreate empty ones", parseBoolValue},
+	{"latest_links", "Keep a symlink to each file's latest stored copy under .rewind/latest", parseBoolValue},
+	{"max_captures_per_minute", "Skip a file's changes for capture_cooldown once it is captured this often in a minute (0 = unlimited)", parseCountValue},
+	{"capture_cooldown", "How long a file changing too often is left uncaptured (e.g. 5m)", parseGoDuration},
+	{"capture_ownership", "Record file owners (uid/gid) so rollback and restore can reapply them", parseBoolValue},
+	{"capture.xattrs", "Record extended attributes (SELinux contexts, macOS tags) so rollback and restore can reapply them", parseBoolValue},
+	{"audit_log", "Append every capture, deletion, rollback and restore to this file as JSON lines (empty = off)", parseAuditLogPath},
+	{"case_insensitive_paths", "Match file paths regardless of case: auto (detect per filesystem), true or false", parseCaseInsensitivePaths},
+	{"max_depth", "Refuse to watch trees nested deeper than this (0 = unlimited)", parseCountValue},
+	{"max_dirs", "Refuse to watch trees with more directories than this (0 = unlimited)", parseCountValue},
+	{"max_files", "Refuse to watch trees with more files than this (0 = unlimited)", parseCountValue},
+	{"min_file_size", "Only version files at least this size (e.g. 1, 10KB)", parseSizeValue},
+	{"max_file_size", "Only version files up to this size (e.g. 50MB, 0 = unlimited)", parseSizeValue},
+	{"baseline_older_than", "Record files older than this as a baseline on the initial scan (e.g. 90d)", parseDurationValue},
+	{"compress_after", "Have the daemon compress versions older than this (e.g. 30d)", parseDurationValue},
+	{"vacuum_interval", "Have the daemon compact project databases this often when purges left them bloated (e.g. 7d)", parseDurationValue},
+	{"rescan_interval", "Have the daemon rescan watched projects this often for changes it missed (e.g. 30m)", parseDurationValue},
+	{"http_addr", "Serve the read-only HTTP API on this address", parseHTTPAddr},
+	{"exact_sizes", "Show sizes in listings as exact byte counts, like --bytes", parseBoolValue},
+	{"thinning.keep_all", "purge --thin keeps every version younger than this", parseDurationValue},
+	{"thinning.hourly_for", "purge --thin keeps one version per hour up to this age", parseDurationValue},
+	{"thinning.daily_for", "purge --thin keeps one version per day up to this age", parseDurationValue},
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get and set rewind configuration values",
+	Long: `Read and change settings in ~/.config/rewind/config.yaml.
+
+Values are validated before they are written, and unknown keys are rejected.
+'rewind config list' shows every setting with its current value.
+
+Examples:
+  rewind config list                       # Show all settings
+  rewind config get storage_mode           # Show one setting
+  rewind config set storage_mode hardlink  # Change a setting
+  rewind config set include "*.go,docs/"   # Set a list`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Show the current value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runConfigGet(args[0]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Validate and save a setting",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runConfigSet(args[0], args[1]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show all settings and their current values",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runConfigList(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configListCmd)
+}
+
+func runConfigGet(name string) error {
+	key, err := findConfigKey(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(formatConfigValue(viper.Get(key.name)))
+	return nil
+}
+
+func runConfigSet(name, value string) error {
+	key, err := findConfigKey(name)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := key.parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key.name, err)
+	}
+
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+
+	// Use a separate instance so defaults and environment variables are not
+	// written into the file
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(path)
+	fileConfig.SetConfigType("yaml")
+	if _, err := os.Stat(path); err == nil {
+		if err := fileConfig.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	fileConfig.Set(key.name, parsed)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := fileConfig.WriteConfigAs(path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	fmt.Printf("✓ Set %s to %s in %s\n", key.name, formatConfigValue(parsed), path)
+	switch {
+	case restartConfigKey(key.name):
+		fmt.Println("Restart the daemon for the change to take effect")
+	case daemonConfigKey(key.name):
+		fmt.Println("Reload the daemon (send it SIGHUP, or 'systemctl --user reload rewind') or restart it for the change to take effect")
+	}
+	return nil
+}
+
+func runConfigList() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tDESCRIPTION")
+	fmt.Fprintln(w, "---\t-----\t-----------")
+
+	for _, key := range configKeys {
+		value := "(not set)"
+		if viper.IsSet(key.name) {
+			value = formatConfigValue(viper.Get(key.name))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key.name, value, key.description)
+	}
+
+	return w.Flush()
+}
+
+// findConfigKey looks up a known setting, suggesting the closest match for
+// an unknown one
+func findConfigKey(name string) (configKey, error) {
+	name = strings.ToLower(name)
+	for _, key := range configKeys {
+		if key.name == name {
+			return key, nil
+		}
+	}
+
+	if suggestion := suggestConfigKey(name); suggestion != "" {
+		return configKey{}, fmt.Errorf("unknown config key %q, did you mean %q?", name, suggestion)
+	}
+	return configKey{}, fmt.Errorf("unknown config key %q (run 'rewind config list' to see all keys)", name)
+}
+
+// suggestConfigKey returns the known key closest to name, or "" if none is
+// close enough to be a likely typo
+func suggestConfigKey(name string) string {
+	best := ""
+	bestDistance := 4
+	for _, key := range configKeys {
+		if distance := editDistance(name, key.name); distance < bestDistance {
+			best, bestDistance = key.name, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// configFilePath returns the config file that 'rewind config set' writes to
+func configFilePath() (string, error) {
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "rewind", "config.yaml"), nil
+}
+
+// daemonConfigKey reports whether a setting is read by the daemon, which picks
+// up changes when it reloads or restarts
+func daemonConfigKey(name string) bool {
+	return !strings.HasPrefix(name, "thinning.")
+}
+
+// restartConfigKey reports whether the daemon only picks up a change to the
+// setting when it restarts, not when it reloads
+func restartConfigKey(name string) bool {
+	return name == "http_addr" || slices.Contains(watcher.RestartSettings, name)
+}
+
+func formatConfigValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		// Numbers in the daemon's JSON status, which would otherwise print
+		// large sizes in exponent form
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, ",")
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseBoolValue(value string) (any, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("must be true or false")
+	}
+	return b, nil
+}
+
+func parseStorageMode(value string) (any, error) {
+	switch value {
+	case watcher.StorageModeCopy, watcher.StorageModeHardlink, watcher.StorageModeDelta:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("must be %s, %s or %s", watcher.StorageModeCopy, watcher.StorageModeHardlink, watcher.StorageModeDelta)
+	}
+}
+
+func parseHashAlgorithm(value string) (any, error) {
+	if !slices.Contains(database.HashAlgorithms, value) {
+		return nil, fmt.Errorf("must be %s", strings.Join(database.HashAlgorithms, ", "))
+	}
+	return value, nil
+}
+
+func parseCaptureOn(value string) (any, error) {
+	switch value {
+	case watcher.CaptureOnWrite, watcher.CaptureOnClose:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("must be %s or %s", watcher.CaptureOnWrite, watcher.CaptureOnClose)
+	}
+}
+
+func parseCaseInsensitivePaths(value string) (any, error) {
+	switch value {
+	case database.PathCaseAuto, database.PathCaseInsensitive, database.PathCaseSensitive:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("must be %s, %s or %s", database.PathCaseAuto, database.PathCaseInsensitive, database.PathCaseSensitive)
+	}
+}
+
+func parseListValue(value string) (any, error) {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func parseGoDuration(value string) (any, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("must be a duration such as 500ms or 2s")
+	}
+	return value, nil
+}
+
+func parseCountValue(value string) (any, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("must be a whole number of 0 or more")
+	}
+	return n, nil
+}
+
+func parsePositiveCountValue(value string) (any, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("must be a whole number of 1 or more")
+	}
+	return n, nil
+}
+
+func parseSizeValue(value string) (any, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if n < 0 {
+			return nil, fmt.Errorf("must not be negative")
+		}
+		return n, nil
+	}
+	if _, err := parseSize(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func parseDurationValue(value string) (any, error) {
+	if _, err := parseDuration(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func parseAuditLogPath(value string) (any, error) {
+	if value != "" && !filepath.IsAbs(value) && !strings.HasPrefix(value, "~/") && !strings.HasPrefix(value, "$") {
+		return nil, fmt.Errorf("must be an absolute path, or start with ~/ or an environment variable")
+	}
+	return value, nil
+}
+
+func parseHTTPAddr(value string) (any, error) {
+	if value == "" {
+		return value, nil
+	}
+	if _, err := api.ListenAddress(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import "testing"
+
+func TestFindConfigKey(t *testing.T) {
+	if _, err := findConfigKey("storage_mode"); err != nil {
+		t.Errorf("findConfigKey(storage_mode) error = %v", err)
+	}
+
+	if got := suggestConfigKey("storage_mod"); got != "storage_mode" {
+		t.Errorf("suggestConfigKey(storage_mod) = %q, want storage_mode", got)
+	}
+
+	if got := suggestConfigKey("completely_unrelated"); got != "" {
+		t.Errorf("suggestConfigKey(completely_unrelated) = %q, want no suggestion", got)
+	}
+}
+
+func TestConfigKeyValidation(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"fsync", "false", false},
+		{"fsync", "maybe", true},
+		{"storage_mode", "hardlink", false},
+		{"storage_mode", "symlink", true},
+		{"storage_mode", "delta", false},
+		{"delta_keyframe_interval", "20", false},
+		{"delta_keyframe_interval", "0", true},
+		{"create_grace_period", "250ms", false},
+		{"create_grace_period", "1d", true},
+		{"max_versions_per_file", "50", false},
+		{"max_versions_per_file", "-1", true},
+		{"max_file_size", "50MB", false},
+		{"max_file_size", "lots", true},
+		{"max_total_size", "5GiB", false},
+		{"max_total_size", "-1GB", true},
+		{"baseline_older_than", "3M", false},
+		{"baseline_older_than", "3x", true},
+		{"http_addr", "7373", false},
+		{"http_addr", "localhost", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			key, err := findConfigKey(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := key.parse(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("parse(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/hexops/gotextdiff"
@@ -16,26 +17,78 @@ import (
 )
 
 var diffVersionFlag int
+var diffTagFlag string
+var diffFromTagFlag string
+var diffToTagFlag string
+var diffProjectFlag string
+var diffLastFlag bool
 var noColorFlag bool
+var diffNameOnlyFlag bool
+var diffNoEOLNormalizeFlag bool
+var diffExcludeFlag []string
+var diffIgnoreBlankLinesFlag bool
+
+// diffExcludePatterns are the compiled --exclude patterns
+var diffExcludePatterns []*regexp.Regexp
 
 // diffCmd represents the diff command
 var diffCmd = &cobra.Command{
-	Use:   "diff <file_path> [--version <version_number>]",
+	Use:   "diff <file_path> [--version <version_number> | --tag <tag_name> | --from-tag <tag> --to-tag <tag> | --project <path> | --last] | diff --name-only",
 	Short: "Show colored diff between current file and a previous version",
 	Long: `Show a colored diff between the current file and a previous version.
 
 By default, compares the current file with the previous version (latest - 1).
-Use --version to specify a different version to compare against.
+Use --version to specify a different version to compare against, or --tag
+to compare against the version carrying that tag.
+
+With --project, the current file is compared against the latest stored
+version of the same relative path in another rewind project.
+
+With --last, the two most recent stored versions are compared with each
+other, ignoring the working file. This shows the last captured change even
+when the file hasn't been modified since.
+
+With --from-tag and --to-tag, the versions carrying the two tags are compared
+with each other, also ignoring the working file. This shows what changed
+between two labelled milestones without looking up their version numbers.
 
 Examples:
   rewind diff src/main.go                    # Compare current with previous version
   rewind diff src/main.go --version 3        # Compare current with version 3
-  rewind diff src/main.go --version 3 --no-color # Plain diff output`,
-	Args: cobra.ExactArgs(1),
+  rewind diff src/main.go --tag v1.0         # Compare current with version tagged v1.0
+  rewind diff src/main.go --project ../fork  # Compare current with ../fork's latest src/main.go
+  rewind diff src/main.go --last             # Compare the last two stored versions
+  rewind diff src/main.go --from-tag v1.0 --to-tag v2.0 # Compare two tagged versions
+  rewind diff src/main.go --version 3 --no-color # Plain diff output
+  rewind diff 'cmd/*.go'                     # Compare every matching file
+  rewind diff --name-only                    # List files changed since their latest version
+  rewind diff build.txt --exclude '^Built at' # Ignore lines starting "Built at"
+
+A quoted glob pattern compares each matching file in turn, printing its path
+before its diff. Patterns are matched against the working tree, and against
+tracked files when nothing on disk matches.
+
+With --name-only and no file, the paths of every tracked file whose contents
+differ from its latest stored version are listed instead.
+
+Windows (CRLF) line endings are compared as Unix (LF) ones, so a file whose
+line endings were converted only shows the lines that really changed. Use
+--no-eol-normalize to compare line endings as well.
+
+--exclude drops every line matching a regular expression from both sides
+before they are compared, and --ignore-blank-lines drops empty lines. Use
+them to hide volatile sections such as timestamps or build hashes. They
+only change what is compared: the stored versions and the working file are
+untouched, and excluded lines are not shown at all, even as context.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if diffNameOnlyFlag {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := runDiff(args[0]); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		if err := runDiffCommand(args); err != nil {
+			exitWithError(cmd, err)
 		}
 	},
 }
@@ -44,7 +97,37 @@ func init() {
 	rootCmd.AddCommand(diffCmd)
 	
 	diffCmd.Flags().IntVarP(&diffVersionFlag, "version", "v", 0, "Version to compare against (default: previous version)")
+	diffCmd.Flags().StringVarP(&diffTagFlag, "tag", "t", "", "Tag name of the version to compare against")
+	diffCmd.Flags().StringVar(&diffFromTagFlag, "from-tag", "", "Tag of the older version to compare, with --to-tag")
+	diffCmd.Flags().StringVar(&diffToTagFlag, "to-tag", "", "Tag of the newer version to compare, with --from-tag")
+	diffCmd.Flags().StringVarP(&diffProjectFlag, "project", "p", "", "Another rewind project to compare against")
+	diffCmd.Flags().BoolVarP(&diffLastFlag, "last", "l", false, "Compare the two most recent stored versions")
 	diffCmd.Flags().BoolVarP(&noColorFlag, "no-color", "n", false, "Disable colored output")
+	diffCmd.Flags().BoolVar(&diffNameOnlyFlag, "name-only", false, "List tracked files that differ from their latest version")
+	diffCmd.Flags().StringArrayVarP(&diffExcludeFlag, "exclude", "x", nil, "Ignore lines matching this regular expression (repeatable)")
+	diffCmd.Flags().BoolVar(&diffIgnoreBlankLinesFlag, "ignore-blank-lines", false, "Ignore empty and whitespace-only lines")
+	diffCmd.Flags().BoolVar(&diffNoEOLNormalizeFlag, "no-eol-normalize", false, "Show line ending (CRLF/LF) differences instead of ignoring them")
+}
+
+// runDiffCommand checks the line filters, then diffs the file or files given,
+// or lists changed files with --name-only
+func runDiffCommand(args []string) error {
+	var err error
+	diffExcludePatterns, err = compileExcludePatterns(diffExcludeFlag)
+	if err != nil {
+		return err
+	}
+
+	if diffNameOnlyFlag {
+		if len(diffExcludePatterns) > 0 || diffIgnoreBlankLinesFlag {
+			return fmt.Errorf("--exclude and --ignore-blank-lines cannot be used with --name-only")
+		}
+		return runDirty(true, false)
+	}
+	if isFileGlob(args[0]) {
+		return runForEachFile(args[0], "Compared", true, runDiff)
+	}
+	return runDiff(args[0])
 }
 
 func runDiff(filePath string) error {
@@ -54,37 +137,62 @@ func runDiff(filePath string) error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
+	if diffLastFlag {
+		if diffVersionFlag > 0 || diffTagFlag != "" || diffFromTagFlag != "" || diffToTagFlag != "" || diffProjectFlag != "" {
+			return fmt.Errorf("cannot combine --last with --version, --tag, --from-tag, --to-tag or --project")
+		}
+		return runLastDiff(filePath, absPath)
+	}
+
+	if diffFromTagFlag != "" || diffToTagFlag != "" {
+		if diffFromTagFlag == "" || diffToTagFlag == "" {
+			return fmt.Errorf("--from-tag and --to-tag must be used together")
+		}
+		if diffVersionFlag > 0 || diffTagFlag != "" || diffProjectFlag != "" {
+			return fmt.Errorf("cannot combine --from-tag and --to-tag with --version, --tag or --project")
+		}
+		return runTagDiff(filePath, absPath)
+	}
+
 	// Check if current file exists
 	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", filePath)
+		return withExitCode(exitNotFound, fmt.Errorf("file does not exist: %s", filePath))
 	}
 
-	// Initialize database
-	wd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get working directory: %w", err)
+	if diffProjectFlag != "" {
+		if diffVersionFlag > 0 || diffTagFlag != "" {
+			return fmt.Errorf("cannot combine --project with --version or --tag")
+		}
+		return runProjectDiff(filePath, absPath)
 	}
 
-	db, err := database.NewDatabaseManager(wd)
+	// Connect to the project's database, or the global store
+	db, err := openFileDatabase(absPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return err
 	}
+	defer db.Close()
 
-	if err := db.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	if diffVersionFlag > 0 && diffTagFlag != "" {
+		return fmt.Errorf("cannot specify both --version and --tag")
 	}
-	defer db.Close()
 
 	// Get the version to compare against
 	var compareVersion *database.FileVersion
-	if diffVersionFlag > 0 {
+	if diffTagFlag != "" {
+		// Use the version carrying the specified tag
+		compareVersion, err = db.GetVersionByTag(absPath, diffTagFlag)
+		if err != nil {
+			return err
+		}
+	} else if diffVersionFlag > 0 {
 		// Use specified version
 		compareVersion, err = db.GetFileVersion(absPath, diffVersionFlag)
 		if err != nil {
 			return fmt.Errorf("failed to get version %d: %w", diffVersionFlag, err)
 		}
 		if compareVersion == nil {
-			return fmt.Errorf("version %d not found", diffVersionFlag)
+			return withExitCode(exitNotFound, fmt.Errorf("version %d not found", diffVersionFlag))
 		}
 	} else {
 		// Get previous version (latest - 1)
@@ -106,7 +214,7 @@ func runDiff(filePath string) error {
 	}
 
 	// Read compare version content
-	compareContent, err := readVersionContent(wd, compareVersion)
+	compareContent, err := db.ReadVersionContent(compareVersion)
 	if err != nil {
 		return fmt.Errorf("failed to read version %d content: %w", compareVersion.VersionNumber, err)
 	}
@@ -115,7 +223,150 @@ func runDiff(filePath string) error {
 	return displayDiff(filePath, string(compareContent), string(currentContent), compareVersion.VersionNumber)
 }
 
+// runLastDiff compares the two most recent stored versions of a file. The
+// working file is not read, so this also works after it has been deleted.
+func runLastDiff(filePath, absPath string) error {
+	db, err := openFileDatabase(absPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	activeVersions, err := getActiveVersions(db, absPath)
+	if err != nil {
+		return err
+	}
+
+	if len(activeVersions) < 2 {
+		return fmt.Errorf("need at least 2 stored versions to show the last change (found %d active versions)", len(activeVersions))
+	}
+
+	latest, previous := activeVersions[0], activeVersions[1]
+
+	latestContent, err := db.ReadVersionContent(latest)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d content: %w", latest.VersionNumber, err)
+	}
+
+	previousContent, err := db.ReadVersionContent(previous)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d content: %w", previous.VersionNumber, err)
+	}
+
+	return displayLabeledDiff(filePath,
+		fmt.Sprintf("version %d", previous.VersionNumber),
+		fmt.Sprintf("version %d", latest.VersionNumber),
+		string(previousContent), string(latestContent))
+}
+
+// runTagDiff compares the versions of a file carrying --from-tag and
+// --to-tag. Like runLastDiff, the working file is not read.
+func runTagDiff(filePath, absPath string) error {
+	db, err := openFileDatabase(absPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	fromVersion, err := db.GetVersionByTag(absPath, diffFromTagFlag)
+	if err != nil {
+		return err
+	}
+
+	toVersion, err := db.GetVersionByTag(absPath, diffToTagFlag)
+	if err != nil {
+		return err
+	}
+
+	fromContent, err := db.ReadVersionContent(fromVersion)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d content: %w", fromVersion.VersionNumber, err)
+	}
+
+	toContent, err := db.ReadVersionContent(toVersion)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d content: %w", toVersion.VersionNumber, err)
+	}
+
+	return displayLabeledDiff(filePath,
+		fmt.Sprintf("version %d (%s)", fromVersion.VersionNumber, diffFromTagFlag),
+		fmt.Sprintf("version %d (%s)", toVersion.VersionNumber, diffToTagFlag),
+		string(fromContent), string(toContent))
+}
+
+// runProjectDiff compares a file against the latest version of the same
+// relative path stored in another rewind project
+func runProjectDiff(filePath, absPath string) error {
+	rewindRoot, err := findRewindRoot(absPath)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	relPath, err := filepath.Rel(rewindRoot, absPath)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	otherRoot, err := filepath.Abs(diffProjectFlag)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	otherDB, err := database.NewDatabaseManager(otherRoot)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	if !otherDB.DatabaseExists() {
+		return withExitCode(exitNotInitialized, fmt.Errorf("%s is not a rewind project (no .rewind/versions.db)", diffProjectFlag))
+	}
+
+	if err := otherDB.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database of %s: %w", diffProjectFlag, err)
+	}
+	defer otherDB.Close()
+
+	otherVersion, err := otherDB.GetLatestFileVersion(filepath.Join(otherRoot, relPath))
+	if err != nil {
+		return fmt.Errorf("failed to get latest version from %s: %w", diffProjectFlag, err)
+	}
+	if otherVersion == nil {
+		return fmt.Errorf("%s is not tracked in %s", relPath, diffProjectFlag)
+	}
+	if otherVersion.Deleted {
+		return fmt.Errorf("%s is deleted in %s", relPath, diffProjectFlag)
+	}
+
+	currentContent, err := os.ReadFile(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to read current file: %w", err)
+	}
+
+	otherContent, err := readVersionContent(otherRoot, otherVersion)
+	if err != nil {
+		return fmt.Errorf("failed to read version %d content from %s: %w", otherVersion.VersionNumber, diffProjectFlag, err)
+	}
+
+	oldLabel := fmt.Sprintf("%s version %d", filepath.Join(otherRoot, relPath), otherVersion.VersionNumber)
+	return displayDiffWithLabel(filePath, oldLabel, string(otherContent), string(currentContent))
+}
+
 func getPreviousVersion(db *database.DatabaseManager, filePath string) (*database.FileVersion, error) {
+	activeVersions, err := getActiveVersions(db, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(activeVersions) < 2 {
+		return nil, fmt.Errorf("need at least 2 versions to show diff (found %d active versions)", len(activeVersions))
+	}
+
+	// Return the second most recent version (previous version)
+	return activeVersions[1], nil
+}
+
+// getActiveVersions returns the non-deleted versions of a file, newest first
+func getActiveVersions(db *database.DatabaseManager, filePath string) ([]*database.FileVersion, error) {
 	// Get all versions for the file
 	versions, err := db.GetFileVersions(filePath)
 	if err != nil {
@@ -123,7 +374,7 @@ func getPreviousVersion(db *database.DatabaseManager, filePath string) (*databas
 	}
 
 	if len(versions) == 0 {
-		return nil, fmt.Errorf("no versions found for file")
+		return nil, withExitCode(exitNotFound, fmt.Errorf("no versions found for file"))
 	}
 
 	// Filter out deleted versions
@@ -134,24 +385,53 @@ func getPreviousVersion(db *database.DatabaseManager, filePath string) (*databas
 		}
 	}
 
-	if len(activeVersions) < 2 {
-		return nil, fmt.Errorf("need at least 2 versions to show diff (found %d active versions)", len(activeVersions))
-	}
-
-	// Return the second most recent version (previous version)
-	return activeVersions[1], nil
+	return activeVersions, nil
 }
 
 func readVersionContent(rootDir string, version *database.FileVersion) ([]byte, error) {
-	storagePath := filepath.Join(rootDir, ".rewind", "versions", version.StoragePath)
-	return os.ReadFile(storagePath)
+	if version.IsBaseline() {
+		return nil, fmt.Errorf("version %d is a baseline with no stored content", version.VersionNumber)
+	}
+	if !version.IsDelta() && !version.IsCompressed() {
+		storagePath := filepath.Join(rootDir, ".rewind", "versions", version.StoragePath)
+		return os.ReadFile(storagePath)
+	}
+
+	// Deltas are rebuilt from the earlier versions recorded in the database,
+	// and compressed versions decompressed
+	db, err := database.NewDatabaseManager(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return db.ReadVersionContent(version)
 }
 
 func displayDiff(filename, oldContent, newContent string, oldVersion int) error {
-	// Generate unified diff
-	edits := myers.ComputeEdits(span.URIFromPath(""), oldContent, newContent)
-	unified := gotextdiff.ToUnified(fmt.Sprintf("version %d", oldVersion), "current", oldContent, edits)
-	diffText := fmt.Sprint(unified)
+	return displayDiffWithLabel(filename, fmt.Sprintf("version %d", oldVersion), oldContent, newContent)
+}
+
+// displayDiffWithLabel shows the diff from oldContent to the current content,
+// naming the old side oldLabel
+func displayDiffWithLabel(filename, oldLabel, oldContent, newContent string) error {
+	return displayLabeledDiff(filename, oldLabel, "current", oldContent, newContent)
+}
+
+// displayLabeledDiff shows the diff from oldContent to newContent, naming the
+// two sides oldLabel and newLabel
+func displayLabeledDiff(filename, oldLabel, newLabel, oldContent, newContent string) error {
+	oldContent = filterDiffLines(oldContent, diffExcludePatterns, diffIgnoreBlankLinesFlag)
+	newContent = filterDiffLines(newContent, diffExcludePatterns, diffIgnoreBlankLinesFlag)
+
+	diffText := unifiedDiff(oldLabel, newLabel, oldContent, newContent, !diffNoEOLNormalizeFlag)
+	if diffText == "" && oldContent != newContent {
+		fmt.Println("Only line endings differ (use --no-eol-normalize to show them)")
+		return nil
+	}
 
 	if noColorFlag {
 		// Output plain diff
@@ -163,6 +443,64 @@ func displayDiff(filename, oldContent, newContent string, oldVersion int) error
 	return displayColoredDiff(diffText, filename)
 }
 
+// unifiedDiff returns the unified diff from oldContent to newContent, or ""
+// when they match. With normalizeEOL, CRLF line endings are compared as LF so
+// that converted line endings don't turn every line into a change.
+func unifiedDiff(oldLabel, newLabel, oldContent, newContent string, normalizeEOL bool) string {
+	if normalizeEOL {
+		oldContent = strings.ReplaceAll(oldContent, "\r\n", "\n")
+		newContent = strings.ReplaceAll(newContent, "\r\n", "\n")
+	}
+
+	edits := myers.ComputeEdits(span.URIFromPath(""), oldContent, newContent)
+	unified := gotextdiff.ToUnified(oldLabel, newLabel, oldContent, edits)
+	return fmt.Sprint(unified)
+}
+
+// compileExcludePatterns compiles the regular expressions given with --exclude
+func compileExcludePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// filterDiffLines removes the lines matching any of patterns, and blank lines
+// when ignoreBlank is set, from content before it is diffed. Patterns are
+// matched against each line without its line ending.
+func filterDiffLines(content string, patterns []*regexp.Regexp, ignoreBlank bool) string {
+	if len(patterns) == 0 && !ignoreBlank {
+		return content
+	}
+
+	var kept strings.Builder
+	for _, line := range strings.SplitAfter(content, "\n") {
+		text := strings.TrimRight(line, "\r\n")
+		if ignoreBlank && strings.TrimSpace(text) == "" {
+			continue
+		}
+		if matchesAny(text, patterns) {
+			continue
+		}
+		kept.WriteString(line)
+	}
+	return kept.String()
+}
+
+func matchesAny(line string, patterns []*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
 func displayColoredDiff(diffText, filename string) error {
 	// ANSI color codes
 	const (
@@ -191,6 +529,9 @@ func displayColoredDiff(diffText, filename string) error {
 		} else if strings.HasPrefix(line, "-") {
 			// Removed lines in red
 			fmt.Printf("%s%s%s\n", red, line, reset)
+		} else if strings.HasPrefix(line, "\\") {
+			// Missing newline markers in gray
+			fmt.Printf("%s%s%s\n", gray, line, reset)
 		} else {
 			// Context lines (unchanged)
 			fmt.Println(line)
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff_LineEndings(t *testing.T) {
+	lf := "one\ntwo\nthree\n"
+	crlf := "one\r\ntwo\r\nTHREE\r\n"
+
+	diff := unifiedDiff("old", "new", lf, crlf, true)
+	want := "--- old\n+++ new\n@@ -1,3 +1,3 @@\n one\n two\n-three\n+THREE\n"
+	if diff != want {
+		t.Errorf("normalized diff =\n%s\nwant\n%s", diff, want)
+	}
+
+	if diff := unifiedDiff("old", "new", lf, strings.ReplaceAll(lf, "\n", "\r\n"), true); diff != "" {
+		t.Errorf("diff of converted line endings =\n%s\nwant none", diff)
+	}
+
+	// Without normalization every line differs
+	diff = unifiedDiff("old", "new", lf, crlf, false)
+	if !strings.Contains(diff, "\n-one\n") || !strings.Contains(diff, "\n+one\r\n") {
+		t.Errorf("unnormalized diff =\n%s\nwant every line replaced", diff)
+	}
+}
+
+func TestUnifiedDiff_MissingFinalNewline(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "newline added",
+			old:  "one\ntwo",
+			new:  "one\ntwo\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n",
+		},
+		{
+			name: "newline removed",
+			old:  "one\ntwo\n",
+			new:  "one\ntwo",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n one\n-two\n+two\n\\ No newline at end of file\n",
+		},
+		{
+			name: "line appended",
+			old:  "one\ntwo",
+			new:  "one\ntwo\nthree",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n one\n-two\n\\ No newline at end of file\n+two\n+three\n\\ No newline at end of file\n",
+		},
+		{
+			name: "earlier change",
+			old:  "one\ntwo",
+			new:  "ONE\r\ntwo",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-one\n+ONE\n two\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := unifiedDiff("old", "new", tt.old, tt.new, true); got != tt.want {
+			t.Errorf("%s: diff =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterDiffLines(t *testing.T) {
+	patterns, err := compileExcludePatterns([]string{`^// Generated at `, `hash: [0-9a-f]+$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "// Generated at 2025-01-01\r\npackage main\r\n\r\n  \nbuild hash: 1a2b\nfunc main() {}"
+	want := "package main\r\nfunc main() {}"
+	if got := filterDiffLines(content, patterns, true); got != want {
+		t.Errorf("filterDiffLines() = %q, want %q", got, want)
+	}
+
+	if got := filterDiffLines(content, nil, false); got != content {
+		t.Errorf("filterDiffLines() with no filters = %q, want content unchanged", got)
+	}
+
+	if _, err := compileExcludePatterns([]string{"("}); err == nil {
+		t.Error("compileExcludePatterns() accepted an invalid pattern")
+	}
+}
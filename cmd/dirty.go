@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// dirtyFile is a tracked file whose working copy differs from its latest
+// captured version
+type dirtyFile struct {
+	Path    string `json:"path"`
+	State   string `json:"state"`
+	Version int    `json:"latest_version"`
+}
+
+const (
+	dirtyModified = "modified"
+	dirtyMissing  = "missing"
+)
+
+// findDirtyFiles hashes every tracked file and returns those that differ from
+// their latest version: changes the daemon hasn't captured yet, or was not
+// running to see. Files whose latest version records their deletion are
+// skipped.
+func findDirtyFiles(rewindRoot string) ([]dirtyFile, error) {
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	latestFiles, err := db.GetAllLatestFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked files: %w", err)
+	}
+
+	var dirty []dirtyFile
+	for _, file := range latestFiles {
+		if file.Deleted {
+			continue
+		}
+
+		state := ""
+		hash, err := file.HashFile(filepath.Join(rewindRoot, file.FilePath))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			state = dirtyMissing
+		case err != nil:
+			return nil, fmt.Errorf("failed to hash %s: %w", file.FilePath, err)
+		case hash != file.FileHash:
+			state = dirtyModified
+		}
+
+		if state != "" {
+			dirty = append(dirty, dirtyFile{Path: file.FilePath, State: state, Version: file.VersionNumber})
+		}
+	}
+
+	return dirty, nil
+}
+
+// runDirty lists the tracked files in the current project that differ from
+// their latest version. With nameOnly only their paths are printed.
+func runDirty(nameOnly, jsonOutput bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	dirty, err := findDirtyFiles(rewindRoot)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if dirty == nil {
+			dirty = []dirtyFile{}
+		}
+		return emitJSON("status", dirty)
+	}
+
+	for _, file := range dirty {
+		if nameOnly {
+			fmt.Println(file.Path)
+		} else {
+			fmt.Printf("%-9s %s (latest version %d)\n", file.State, file.Path, file.Version)
+		}
+	}
+
+	if !nameOnly {
+		if len(dirty) == 0 {
+			fmt.Println("All tracked files match their latest version")
+		} else {
+			fmt.Printf("\n%d files differ from their latest version\n", len(dirty))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/spf13/cobra"
+)
+
+// minInotifyWatches is the smallest inotify watch limit doctor considers
+// adequate, regardless of how many directories are currently watched
+const minInotifyWatches = 8192
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose problems with the rewind environment",
+	Long: `Run a series of checks on the rewind environment and print a checklist
+with a remediation hint for anything that fails:
+- the daemon socket accepts connections
+- the watchlist is readable
+- no watched project lies inside another
+- inotify limits are high enough for the watched directories (Linux)
+- the project database opens and passes an integrity check
+- the version store is writable
+- no file has gaps in its version numbers (a warning, as purging versions
+  from the middle of a file's history leaves them)
+
+The project checks run against the rewind project containing the current
+directory.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDoctor(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	addInstanceFlag(doctorCmd)
+}
+
+// doctorResult is the outcome of a single doctor check
+type doctorResult struct {
+	name    string
+	passed  bool
+	skipped bool
+	// warning marks a failed check that is worth knowing about but does not
+	// make doctor fail
+	warning bool
+	detail  string
+	hint    string
+}
+
+func runDoctor() error {
+	var results []doctorResult
+
+	daemonResult, watchedDirs := checkDaemon()
+	results = append(results, daemonResult)
+	results = append(results, checkWatchlist())
+	results = append(results, checkNestedWatches())
+	if runtime.GOOS == "linux" {
+		results = append(results, checkInotifyLimits(watchedDirs))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		results = append(results, doctorResult{
+			name:   "Project",
+			detail: "not in a rewind project",
+			hint:   "Run 'rewind init' in your project, or run doctor from inside one",
+		})
+	} else {
+		results = append(results, doctorResult{name: "Project", passed: true, detail: rewindRoot})
+		results = append(results, checkDatabase(rewindRoot))
+		results = append(results, checkStoreWritable(rewindRoot))
+		results = append(results, checkVersionNumbering(rewindRoot))
+	}
+
+	fmt.Println("Rewind Doctor")
+	fmt.Println("=============")
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.skipped:
+			fmt.Printf("- %s: %s\n", result.name, result.detail)
+		case result.passed:
+			fmt.Printf("✓ %s: %s\n", result.name, result.detail)
+		case result.warning:
+			fmt.Printf("! %s: %s\n", result.name, result.detail)
+		default:
+			failed++
+			fmt.Printf("✗ %s: %s\n", result.name, result.detail)
+		}
+		if result.hint != "" && !result.passed {
+			fmt.Printf("    → %s\n", result.hint)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+
+	fmt.Println("\n✓ No problems found")
+	return nil
+}
+
+// checkDaemon connects to the daemon socket and returns the number of
+// directories it is watching, or -1 if that could not be determined
+func checkDaemon() (doctorResult, int) {
+	result := doctorResult{name: "Daemon"}
+	socketPath := ipcSocketPath()
+
+	response, err := sendIPCMessageWithResponse(protocol.ActionStatus, "")
+	if err != nil {
+		result.detail = fmt.Sprintf("cannot connect to %s: %v", socketPath, err)
+		switch {
+		case errors.Is(err, os.ErrPermission):
+			result.hint = fmt.Sprintf("The socket belongs to another user; check the permissions of %s or start your own daemon with --instance", socketPath)
+		case errors.Is(err, os.ErrNotExist):
+			result.hint = "Start the daemon with 'rewind watch'"
+		default:
+			result.hint = "The daemon is not running or has hung; start it with 'rewind watch' (a stale socket is replaced automatically)"
+		}
+		return result, -1
+	}
+
+	result.passed = true
+	result.detail = fmt.Sprintf("running, connected to %s", socketPath)
+
+	var status watcher.WatchManagerStatus
+	if err := json.Unmarshal([]byte(response), &status); err != nil {
+		return result, -1
+	}
+	return result, status.TotalWatchedDirs
+}
+
+func checkWatchlist() doctorResult {
+	result := doctorResult{name: "Watchlist"}
+
+	listPath, err := watcher.WatchListPath(instanceFlag)
+	if err != nil {
+		result.detail = fmt.Sprintf("cannot locate watchlist: %v", err)
+		result.hint = "Make sure $HOME is set"
+		return result
+	}
+
+	data, err := os.ReadFile(listPath)
+	if os.IsNotExist(err) {
+		result.skipped = true
+		result.detail = fmt.Sprintf("%s does not exist yet", listPath)
+		return result
+	}
+	if err != nil {
+		result.detail = fmt.Sprintf("cannot read %s: %v", listPath, err)
+		result.hint = fmt.Sprintf("Check the ownership and permissions of %s", listPath)
+		return result
+	}
+
+	var watches []watcher.Watch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		result.detail = fmt.Sprintf("cannot parse %s: %v", listPath, err)
+		result.hint = "Fix or remove the file, then re-run 'rewind init' in each project"
+		return result
+	}
+
+	result.passed = true
+	result.detail = fmt.Sprintf("%d projects in %s", len(watches), listPath)
+	return result
+}
+
+// checkNestedWatches looks for watched projects inside other watched
+// projects, whose files could be claimed by either
+func checkNestedWatches() doctorResult {
+	result := doctorResult{name: "Nested projects"}
+
+	listPath, err := watcher.WatchListPath(instanceFlag)
+	if err != nil {
+		result.skipped = true
+		result.detail = "watchlist not found"
+		return result
+	}
+	data, err := os.ReadFile(listPath)
+	if err != nil {
+		result.skipped = true
+		result.detail = "watchlist not readable"
+		return result
+	}
+	var watches []watcher.Watch
+	if err := json.Unmarshal(data, &watches); err != nil {
+		result.skipped = true
+		result.detail = "watchlist not readable"
+		return result
+	}
+
+	paths := make([]string, len(watches))
+	for i, watch := range watches {
+		paths[i] = watch.Path
+	}
+	conflicts := watcher.FindWatchConflicts(paths)
+	if len(conflicts) == 0 {
+		result.passed = true
+		result.detail = "no watched project lies inside another"
+		return result
+	}
+
+	nested := make([]string, len(conflicts))
+	for i, conflict := range conflicts {
+		nested[i] = fmt.Sprintf("%s is inside %s", conflict.Path, conflict.Within)
+	}
+	result.detail = strings.Join(nested, "; ")
+	result.hint = "Files under a nested project are captured by that project only; remove one of the watches with 'rewind remove' to avoid confusion"
+	return result
+}
+
+func checkInotifyLimits(watchedDirs int) doctorResult {
+	result := doctorResult{name: "Inotify limits"}
+
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		result.skipped = true
+		result.detail = fmt.Sprintf("cannot read max_user_watches: %v", err)
+		return result
+	}
+
+	maxWatches, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		result.skipped = true
+		result.detail = fmt.Sprintf("cannot parse max_user_watches: %v", err)
+		return result
+	}
+
+	result.hint = "Raise the limit with 'sudo sysctl fs.inotify.max_user_watches=524288' (persist it in /etc/sysctl.d)"
+
+	if maxWatches < minInotifyWatches {
+		result.detail = fmt.Sprintf("max_user_watches is %d, below the recommended %d", maxWatches, minInotifyWatches)
+		return result
+	}
+
+	// Other programs share the limit, so leave headroom
+	if watchedDirs >= 0 && watchedDirs > maxWatches*8/10 {
+		result.detail = fmt.Sprintf("%d directories watched, close to max_user_watches of %d", watchedDirs, maxWatches)
+		return result
+	}
+
+	result.passed = true
+	if watchedDirs >= 0 {
+		result.detail = fmt.Sprintf("%d directories watched, max_user_watches is %d", watchedDirs, maxWatches)
+	} else {
+		result.detail = fmt.Sprintf("max_user_watches is %d", maxWatches)
+	}
+	return result
+}
+
+func checkDatabase(rewindRoot string) doctorResult {
+	result := doctorResult{name: "Database"}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		result.detail = fmt.Sprintf("failed to create database manager: %v", err)
+		return result
+	}
+
+	if err := db.Connect(); err != nil {
+		result.detail = err.Error()
+		result.hint = "Remove the incomplete .rewind directory and run 'rewind init' again"
+		return result
+	}
+	defer db.Close()
+
+	if err := db.IntegrityCheck(); err != nil {
+		result.detail = err.Error()
+		result.hint = fmt.Sprintf("Back up %s and try recovering it with 'sqlite3 versions.db .recover'", db.GetDatabasePath())
+		return result
+	}
+
+	result.passed = true
+	result.detail = fmt.Sprintf("%s passed integrity check", db.GetDatabasePath())
+	return result
+}
+
+func checkStoreWritable(rewindRoot string) doctorResult {
+	result := doctorResult{name: "Version store"}
+	storeDir := filepath.Join(rewindRoot, ".rewind", "versions")
+	result.hint = fmt.Sprintf("Check the ownership and permissions of %s and that the disk is not full or read-only", storeDir)
+
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		result.detail = fmt.Sprintf("cannot create %s: %v", storeDir, err)
+		return result
+	}
+
+	probe, err := os.CreateTemp(storeDir, ".doctor-*")
+	if err != nil {
+		result.detail = fmt.Sprintf("cannot write to %s: %v", storeDir, err)
+		return result
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	result.passed = true
+	result.detail = fmt.Sprintf("%s is writable", storeDir)
+	return result
+}
+
+// checkVersionNumbering looks for files whose version numbers have gaps
+func checkVersionNumbering(rewindRoot string) doctorResult {
+	result := doctorResult{name: "Version numbering"}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		result.skipped = true
+		result.detail = "database not readable"
+		return result
+	}
+	if err := db.Connect(); err != nil {
+		result.skipped = true
+		result.detail = "database not readable"
+		return result
+	}
+	defer db.Close()
+
+	gaps, err := db.FindVersionGaps("")
+	if err != nil {
+		result.skipped = true
+		result.detail = err.Error()
+		return result
+	}
+	if len(gaps) == 0 {
+		result.passed = true
+		result.detail = "every file's version numbers are contiguous"
+		return result
+	}
+
+	const listed = 3
+	files := make([]string, 0, listed)
+	for i, gap := range gaps {
+		if i == listed {
+			files = append(files, fmt.Sprintf("and %d more", len(gaps)-listed))
+			break
+		}
+		files = append(files, fmt.Sprintf("%s (%s)", gap.FilePath, formatVersionRanges(gap.Versions)))
+	}
+	result.warning = true
+	result.detail = fmt.Sprintf("%d files have gaps in their version numbers: %s", len(gaps), strings.Join(files, ", "))
+	result.hint = "Version numbers still refer to the same versions; close the gaps with 'rewind renumber <file>' if you prefer contiguous numbers"
+	return result
+}
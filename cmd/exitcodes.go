@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// Exit codes let scripts tell why a command failed
+const (
+	exitFailure           = 1 // Any other error
+	exitNotInitialized    = 2 // Not inside a rewind project
+	exitNotFound          = 3 // The file, version or tag does not exist
+	exitDaemonUnreachable = 4 // The daemon is not running or not answering
+	exitIntegrity         = 5 // A stored version failed verification
+)
+
+// exitCodeError gives an error a specific exit code without changing its
+// message
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string { return e.err.Error() }
+func (e *exitCodeError) Unwrap() error { return e.err }
+
+func withExitCode(code int, err error) error {
+	return &exitCodeError{code: code, err: err}
+}
+
+// exitCode returns the exit code a command should use for err
+func exitCode(err error) int {
+	var coded *exitCodeError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, database.ErrNotInitialized):
+		return exitNotInitialized
+	case errors.Is(err, database.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, database.ErrCorrupt):
+		return exitIntegrity
+	default:
+		return exitFailure
+	}
+}
+
+// errorCodes name each exit code in the JSON error output
+var errorCodes = map[int]string{
+	exitFailure:           "failure",
+	exitNotInitialized:    "not_initialized",
+	exitNotFound:          "not_found",
+	exitDaemonUnreachable: "daemon_unreachable",
+	exitIntegrity:         "integrity",
+}
+
+// errorCode returns the name of the exit code a command should use for err
+func errorCode(err error) string {
+	if code, ok := errorCodes[exitCode(err)]; ok {
+		return code
+	}
+	return errorCodes[exitFailure]
+}
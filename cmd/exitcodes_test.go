@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+func TestWriteError(t *testing.T) {
+	err := fmt.Errorf("failed to find version: %w", database.ErrNotFound)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().BoolP("json", "j", false, "")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+
+	// Plain text without --json
+	writeError(cmd, err)
+	if got, want := out.String(), "Error: "+err.Error()+"\n"; got != want {
+		t.Errorf("plain error = %q, want %q", got, want)
+	}
+
+	// A JSON error object with --json
+	out.Reset()
+	cmd.Flags().Set("json", "true")
+	writeError(cmd, err)
+	var parsed jsonError
+	if decodeErr := json.Unmarshal(out.Bytes(), &parsed); decodeErr != nil {
+		t.Fatalf("JSON error %q does not parse: %v", out.String(), decodeErr)
+	}
+	if parsed.Error.Code != "not_found" || parsed.Error.Message != err.Error() {
+		t.Errorf("JSON error = %+v, want code not_found and message %q", parsed.Error, err.Error())
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.New("boom"), "failure"},
+		{fmt.Errorf("open: %w", database.ErrNotInitialized), "not_initialized"},
+		{withExitCode(exitDaemonUnreachable, errors.New("no daemon")), "daemon_unreachable"},
+		{fmt.Errorf("verify: %w", database.ErrCorrupt), "integrity"},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var exportAllFlag bool
+var exportOutputFlag string
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export --all [--output <archive>]",
+	Short: "Export a project's complete history to an archive",
+	Long: `Export the complete history of the current project - its database and every
+stored version - to a gzipped tar archive, for moving it to another machine
+with 'rewind import --all'.
+
+Unlike copying the .rewind directory, every stored version is checked
+against the hash recorded for it as it is exported, and files in the
+version store that no version refers to are left out. If any version fails
+its check nothing is exported; the failures are listed instead.
+
+The archive holds a .rewind directory, so it can also be unpacked by hand
+with tar.
+
+Examples:
+  rewind export --all                                 # Write <project>-history.tar.gz
+  rewind export --all --output project-history.tar.gz`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runExport(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().BoolVar(&exportAllFlag, "all", false, "Export the whole project: its database and every stored version")
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "", "Archive to write (default: <project>-history.tar.gz)")
+}
+
+func runExport() error {
+	if !exportAllFlag {
+		return fmt.Errorf("only whole-project export is supported; use --all")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	output := exportOutputFlag
+	if output == "" {
+		output = filepath.Base(rewindRoot) + "-history.tar.gz"
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Export from a copy of the database so versions captured meanwhile
+	// don't end up half in the archive
+	tempDir, err := os.MkdirTemp("", "rewind-export-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	snapshot, err := db.CopyTo(filepath.Join(tempDir, "versions.db"))
+	if err != nil {
+		return err
+	}
+	defer snapshot.Close()
+
+	versions, err := snapshot.GetStoredVersions()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Verifying %d stored versions...\n", len(versions))
+	if err := verifyExportVersions(snapshot, versions); err != nil {
+		return err
+	}
+
+	// The copy is closed so the archive holds everything written to it
+	if err := snapshot.Close(); err != nil {
+		return fmt.Errorf("failed to close database copy: %w", err)
+	}
+
+	stored := make(map[string]bool, len(versions))
+	for _, version := range versions {
+		stored[version.StoragePath] = true
+	}
+
+	if err := writeExportArchive(output, rewindRoot, filepath.Join(tempDir, "versions.db"), db.VersionsDir(), versions); err != nil {
+		return err
+	}
+
+	if orphans := countOrphanedFiles(db.VersionsDir(), stored); orphans > 0 {
+		fmt.Printf("Skipped %d files in the version store that no version refers to\n", orphans)
+	}
+	fmt.Printf("✓ Exported %d versions to %s\n", len(versions), output)
+	return nil
+}
+
+// verifyExportVersions checks every version against its recorded hash,
+// listing each one that fails
+func verifyExportVersions(db *database.DatabaseManager, versions []*database.FileVersion) error {
+	failed := 0
+	for _, version := range versions {
+		if err := db.VerifyVersion(version); err != nil {
+			fmt.Printf("  ✗ %s version %d: %v\n", version.FilePath, version.VersionNumber, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return withExitCode(exitIntegrity, fmt.Errorf("%d stored versions failed verification, nothing was exported (see 'rewind doctor', or purge the damaged versions)", failed))
+	}
+	return nil
+}
+
+// writeExportArchive writes the database copy, the project's ignore file and
+// every stored version to a gzipped tar at output. A partly written archive
+// is removed.
+func writeExportArchive(output, rewindRoot, dbCopy, versionsDir string, versions []*database.FileVersion) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(output), ".rewind-export-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	gz := gzip.NewWriter(tmp)
+	archive := tar.NewWriter(gz)
+
+	if err := addArchiveFile(archive, dbCopy, ".rewind/versions.db"); err != nil {
+		return err
+	}
+
+	ignorePath := filepath.Join(rewindRoot, ".rewind", "ignore")
+	if _, statErr := os.Stat(ignorePath); statErr == nil {
+		if err := addArchiveFile(archive, ignorePath, ".rewind/ignore"); err != nil {
+			return err
+		}
+	}
+
+	added := make(map[string]bool)
+	for _, version := range versions {
+		name := ".rewind/versions/" + filepath.ToSlash(version.StoragePath)
+		// Content-addressable objects are shared by versions with the same content
+		if added[name] {
+			continue
+		}
+		added[name] = true
+		if err := addArchiveFile(archive, filepath.Join(versionsDir, version.StoragePath), name); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), output); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// addArchiveFile adds the file at path to archive as name
+func addArchiveFile(archive *tar.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	header.Name = name
+
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	if _, err := io.Copy(archive, file); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	return nil
+}
+
+// countOrphanedFiles counts the files in the version store that no stored
+// version refers to
+func countOrphanedFiles(versionsDir string, stored map[string]bool) int {
+	orphans := 0
+	filepath.WalkDir(versionsDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if rel, relErr := filepath.Rel(versionsDir, path); relErr == nil && !stored[rel] {
+			orphans++
+		}
+		return nil
+	})
+	return orphans
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	source := t.TempDir()
+	db, err := database.NewDatabaseManager(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	filePath := filepath.Join(source, "notes.txt")
+	storagePath := db.CreateStoragePath(filePath, 1)
+	stored := filepath.Join(db.VersionsDir(), storagePath)
+	if err := os.MkdirAll(filepath.Dir(stored), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stored, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := database.CalculateFileHash(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddFileVersion(&database.FileVersion{
+		FilePath:      "notes.txt",
+		VersionNumber: 1,
+		Timestamp:     time.Now(),
+		FileHash:      hash,
+		FileSize:      6,
+		StoragePath:   storagePath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := db.CopyTo(filepath.Join(t.TempDir(), "versions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := snapshot.GetStoredVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot.Close()
+
+	archivePath := filepath.Join(t.TempDir(), "history.tar.gz")
+	if err := writeExportArchive(archivePath, source, snapshot.GetDatabasePath(), db.VersionsDir(), versions); err != nil {
+		t.Fatal(err)
+	}
+
+	target := t.TempDir()
+	imported, err := importProject(archivePath, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 {
+		t.Errorf("imported %d versions, want 1", imported)
+	}
+	content, err := os.ReadFile(filepath.Join(target, ".rewind", "versions", storagePath))
+	if err != nil || string(content) != "hello\n" {
+		t.Errorf("imported version content = %q, %v", content, err)
+	}
+}
+
+func TestExtractExportArchive_RefusesEscapes(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "evil.tar.gz")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(file)
+	archive := tar.NewWriter(gz)
+	body := []byte("x")
+	if err := archive.WriteHeader(&tar.Header{Name: ".rewind/../escaped", Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	archive.Write(body)
+	archive.Close()
+	gz.Close()
+	file.Close()
+
+	root := t.TempDir()
+	if err := extractExportArchive(archivePath, root); err == nil {
+		t.Fatal("extracted an entry outside .rewind")
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); err == nil {
+		t.Error("entry was written outside .rewind")
+	}
+}
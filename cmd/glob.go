@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// isFileGlob reports whether a file argument is a glob pattern rather than a
+// single path. A path that exists is never treated as a pattern, so files
+// with brackets or asterisks in their names still work.
+func isFileGlob(path string) bool {
+	if !strings.ContainsAny(path, "*?[") {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err != nil
+}
+
+// expandFileGlob returns the files matching pattern, relative to the working
+// directory where possible. Files in the working tree are matched first; when
+// none match, the pattern is matched against the paths tracked in the
+// database so deleted files can still be found.
+func expandFileGlob(pattern string) ([]string, error) {
+	absPattern, err := filepath.Abs(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	matches, err := filepath.Glob(absPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+	}
+
+	var files []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			files = append(files, match)
+		}
+	}
+
+	if len(files) == 0 {
+		files, err = matchTrackedFiles(absPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %w", err)
+	}
+	for i, file := range files {
+		if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			files[i] = rel
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// matchTrackedFiles returns the absolute paths of tracked files matching an
+// absolute pattern
+func matchTrackedFiles(absPattern string) ([]string, error) {
+	rewindRoot, err := findRewindRoot(absPattern)
+	if err != nil {
+		return nil, fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	latestFiles, err := db.GetAllLatestFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked files: %w", err)
+	}
+
+	var files []string
+	for _, file := range latestFiles {
+		path := filepath.Join(rewindRoot, file.FilePath)
+		if matched, _ := filepath.Match(absPattern, path); matched {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// runForEachFile runs fn on every file matching pattern and prints a summary.
+// Failures are reported as they happen without stopping the remaining files.
+// With header set, each file's output is preceded by its path.
+func runForEachFile(pattern, action string, header bool, fn func(filePath string) error) error {
+	files, err := expandFileGlob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return withExitCode(exitNotFound, fmt.Errorf("no files match %s", pattern))
+	}
+
+	var failed []string
+	for i, file := range files {
+		if header {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("==> %s <==\n", file)
+		}
+		if err := fn(file); err != nil {
+			fmt.Printf("Error: %s: %v\n", file, err)
+			failed = append(failed, file)
+		}
+	}
+
+	fmt.Printf("\n%s %d of %d files matching %s\n", action, len(files)-len(failed), len(files), pattern)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
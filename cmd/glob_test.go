@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsFileGlob(t *testing.T) {
+	dir := t.TempDir()
+	literal := filepath.Join(dir, "notes[1].txt")
+	if err := os.WriteFile(literal, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"src/main.go", false},
+		{"src/*.go", true},
+		{"cmd/?.go", true},
+		{"[ab].txt", true},
+		{literal, false},
+	}
+
+	for _, tt := range tests {
+		if got := isFileGlob(tt.path); got != tt.want {
+			t.Errorf("isFileGlob(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/dustin/go-humanize"
+)
+
+// globalRecentVersions is how many of a file's newest versions are listed
+// alongside its latest one
+const globalRecentVersions = 5
+
+// globalFileJSON is a file in the --global --json listing
+type globalFileJSON struct {
+	FilePath       string `json:"file_path"`
+	LatestVersion  int    `json:"latest_version"`
+	Timestamp      string `json:"timestamp"`
+	TimestampUnix  int64  `json:"timestamp_unix"`
+	SizeBytes      int64  `json:"size_bytes"`
+	EventOp        string `json:"event_op"`
+	Deleted        bool   `json:"deleted"`
+	Versions       int    `json:"versions"`
+	RecentVersions []int  `json:"recent_versions"`
+}
+
+// globalFile is a file's versions, newest first, matching the --op filter
+type globalFile struct {
+	versions []*database.FileVersion
+}
+
+// parseEventOpFilter converts an --op name, as shown in the EVENT column, to
+// the event op it filters on. "deleted" matches deletion records.
+func parseEventOpFilter(op string) (eventOp string, deleted bool, err error) {
+	switch strings.ToLower(op) {
+	case "":
+		return "", false, nil
+	case "created":
+		return database.EventOpCreate, false, nil
+	case "modified":
+		return database.EventOpWrite, false, nil
+	case "renamed":
+		return database.EventOpRename, false, nil
+	case "rollback":
+		return database.EventOpRollback, false, nil
+	case "deleted":
+		return "", true, nil
+	default:
+		return "", false, fmt.Errorf("invalid --op %q (use created, modified, renamed, rollback or deleted)", op)
+	}
+}
+
+// displayGlobalVersions lists every file in the project with its latest and
+// recent versions, most recently changed first
+func displayGlobalVersions() error {
+	eventOp, deleted, err := parseEventOpFilter(opFlag)
+	if err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	versions, err := db.GetRecentVersions(0, eventOp)
+	if err != nil {
+		return err
+	}
+
+	// Versions arrive newest first, so files are ordered by their latest change
+	var files []*globalFile
+	byPath := make(map[string]*globalFile)
+	for _, version := range versions {
+		if opFlag != "" && version.Deleted != deleted {
+			continue
+		}
+		file, ok := byPath[version.FilePath]
+		if !ok {
+			file = &globalFile{}
+			byPath[version.FilePath] = file
+			files = append(files, file)
+		}
+		file.versions = append(file.versions, version)
+	}
+
+	if limitFlag > 0 && len(files) > limitFlag {
+		files = files[:limitFlag]
+	}
+
+	if jsonFlag {
+		return emitJSON("rollback", globalFilesJSON(files))
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No versions recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tLATEST\tTIME\tEVENT\tSIZE\tVERSIONS\tRECENT")
+	fmt.Fprintln(w, "----\t------\t----\t-----\t----\t--------\t------")
+	for _, file := range files {
+		latest := file.versions[0]
+
+		event := eventOpLabel(latest.EventOp)
+		if latest.Deleted {
+			event = "deleted"
+		}
+
+		var recent []string
+		for _, version := range file.versions[:min(len(file.versions), globalRecentVersions)] {
+			recent = append(recent, fmt.Sprintf("v%d", version.VersionNumber))
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%d\t%s\n",
+			latest.FilePath,
+			latest.VersionNumber,
+			humanize.Time(latest.Timestamp),
+			event,
+			formatSize(latest.FileSize),
+			len(file.versions),
+			strings.Join(recent, ", "))
+	}
+
+	return w.Flush()
+}
+
+// globalFilesJSON converts the --global listing for JSON output
+func globalFilesJSON(files []*globalFile) []globalFileJSON {
+	result := make([]globalFileJSON, len(files))
+	for i, file := range files {
+		latest := file.versions[0]
+
+		recent := make([]int, 0, globalRecentVersions)
+		for _, version := range file.versions[:min(len(file.versions), globalRecentVersions)] {
+			recent = append(recent, version.VersionNumber)
+		}
+
+		result[i] = globalFileJSON{
+			FilePath:       latest.FilePath,
+			LatestVersion:  latest.VersionNumber,
+			Timestamp:      latest.Timestamp.Format("2006-01-02 15:04:05"),
+			TimestampUnix:  latest.Timestamp.Unix(),
+			SizeBytes:      latest.FileSize,
+			EventOp:        latest.EventOp,
+			Deleted:        latest.Deleted,
+			Versions:       len(file.versions),
+			RecentVersions: recent,
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// openFileDatabase connects to the database recording absPath: that of the
+// rewind project containing it, or the global store for a file outside any
+// project that was captured with rewind add
+func openFileDatabase(absPath string) (*database.DatabaseManager, error) {
+	rewindRoot, err := findRewindRoot(absPath)
+	if err != nil {
+		if db := openGlobalStoreFor(absPath); db != nil {
+			return db, nil
+		}
+		return nil, fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// openGlobalStoreFor connects to the global store if it has versions of
+// absPath, and returns nil otherwise
+func openGlobalStoreFor(absPath string) *database.DatabaseManager {
+	db, err := database.NewGlobalDatabaseManager()
+	if err != nil || !db.DatabaseExists() {
+		return nil
+	}
+
+	if err := db.Connect(); err != nil {
+		return nil
+	}
+
+	latest, err := db.GetLatestFileVersion(absPath)
+	if err != nil || latest == nil {
+		db.Close()
+		return nil
+	}
+	return db
+}
+
+// openAddDatabase connects to the database rewind add captures absPath into:
+// the project containing it or, outside any project, the global store, which
+// is created on first use
+func openAddDatabase(absPath string) (*database.DatabaseManager, error) {
+	if _, err := findRewindRoot(absPath); err == nil {
+		return openFileDatabase(absPath)
+	}
+
+	db, err := database.NewGlobalDatabaseManager()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.InitDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to open global store: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/spf13/cobra"
+)
+
+var importAllFlag bool
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import --all <archive>",
+	Short: "Import a project's history exported with 'rewind export --all'",
+	Long: `Import the complete history of a project from an archive written by
+'rewind export --all', making the current directory a rewind project with
+that history.
+
+The directory must not already be inside a rewind project. Every imported
+version is checked against its recorded hash before the project is handed to
+the daemon, which then watches it and captures any files that differ from
+their latest imported version. If anything fails, the imported history is
+removed again.
+
+Examples:
+  rewind import --all project-history.tar.gz
+  rewind import --all project-history.tar.gz --instance work`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runImport(args[0]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	addInstanceFlag(importCmd)
+	importCmd.Flags().BoolVar(&importAllFlag, "all", false, "Import a whole project exported with 'rewind export --all'")
+}
+
+func runImport(archivePath string) error {
+	if !importAllFlag {
+		return fmt.Errorf("only whole-project import is supported; use --all")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	if err := checkExistingRewind(cwd); err != nil {
+		return err
+	}
+
+	rewindDir := filepath.Join(cwd, ".rewind")
+	versions, err := importProject(archivePath, cwd)
+	if err != nil {
+		os.RemoveAll(rewindDir)
+		return err
+	}
+
+	// The daemon scans the project and replies with what it captured
+	response, err := sendIPCRequest(protocol.ActionAdd, cwd)
+	if err != nil {
+		os.RemoveAll(rewindDir)
+		return fmt.Errorf("failed to notify rewind daemon, removed the imported history: %w", err)
+	}
+
+	fmt.Printf("✓ Imported %d versions into %s\n", versions, cwd)
+	displayScanStats(response)
+	return nil
+}
+
+// importProject unpacks an exported archive into root's .rewind directory
+// and checks every version in it, returning how many there are
+func importProject(archivePath, root string) (int, error) {
+	if err := extractExportArchive(archivePath, root); err != nil {
+		return 0, err
+	}
+
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if !db.DatabaseExists() {
+		return 0, fmt.Errorf("%s is not a rewind export: it has no database", archivePath)
+	}
+	if err := db.Connect(); err != nil {
+		return 0, fmt.Errorf("failed to connect to imported database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.IntegrityCheck(); err != nil {
+		return 0, err
+	}
+
+	versions, err := db.GetStoredVersions()
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, version := range versions {
+		if err := db.VerifyVersion(version); err != nil {
+			fmt.Printf("  ✗ %s version %d: %v\n", version.FilePath, version.VersionNumber, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return 0, withExitCode(exitIntegrity, fmt.Errorf("%d imported versions failed verification, nothing was imported", failed))
+	}
+
+	return len(versions), nil
+}
+
+// extractExportArchive unpacks the .rewind directory in an exported archive
+// into root. Entries outside .rewind, and anything but regular files and
+// directories, are refused.
+func extractExportArchive(archivePath, root string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("%s is not a rewind export: %w", archivePath, err)
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		name := path.Clean(header.Name)
+		if name != ".rewind" && !strings.HasPrefix(name, ".rewind/") {
+			return fmt.Errorf("%s is not a rewind export: unexpected entry %s", archivePath, header.Name)
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", name, err)
+			}
+		case tar.TypeReg:
+			if err := extractArchiveFile(archive, target, header.FileInfo().Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("%s is not a rewind export: unsupported entry %s", archivePath, header.Name)
+		}
+	}
+}
+
+// extractArchiveFile writes the current archive entry to target
+func extractArchiveFile(archive *tar.Reader, target string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, archive); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
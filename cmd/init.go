@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,22 +10,11 @@ import (
 
 	"github.com/davenicholson-xyz/rewind/app"
 	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
 	"github.com/davenicholson-xyz/rewind/internal/watcher"
 	"github.com/spf13/cobra"
 )
 
-// Message represents the IPC message structure
-type Message struct {
-	Action string `json:"action"`
-	Path   string `json:"path"`
-}
-
-// Response represents the IPC response structure
-type Response struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-}
-
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -40,8 +30,15 @@ The initialization process:
 - Notifies the daemon to start monitoring
 
 Examples:
-  rewind init          # Initialize in current directory
-  rewind init ./path   # Initialize in specified directory`,
+  rewind init                   # Initialize in current directory
+  rewind init ./path            # Initialize in specified directory
+  rewind init --instance work   # Register with the "work" daemon instance
+  rewind init --yes             # Don't confirm very large projects
+  rewind init --include '*.conf' --include 'nginx/'  # Only version matching files
+
+--include adds its patterns to the project's .rwinclude, so only matching
+files are versioned. Every directory is still watched, so matching files
+created later are picked up too.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		app.Logger.Info("Starting new rewind app")
@@ -49,42 +46,45 @@ Examples:
 		targetDir, err := determineTargetDirectory(args)
 		app.Logger.WithField("directory", targetDir).Debug("Target directory")
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
 		if err := validateDirectory(targetDir); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
 		absTargetDir, err := filepath.Abs(targetDir)
 		app.Logger.WithField("abs_directory", absTargetDir).Debug("Absolute target directory")
 		if err != nil {
 			fmt.Printf("Error getting absolute path: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		if err := checkExistingRewind(absTargetDir); err != nil {
 			app.Logger.Error("Already inside rewind project")
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
 		if err := initializeRewindProject(absTargetDir); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
-		// Perform initial scan of all files
-		if err := performInitialScan(absTargetDir); err != nil {
-			app.Logger.WithField("error", err).Error("Failed to perform initial scan")
-			fmt.Printf("Warning: Failed to perform initial scan: %v\n", err)
-			// Don't exit here as the project is still initialized
+		if err := addIncludePatterns(absTargetDir, initIncludeFlag); err != nil {
+			os.RemoveAll(filepath.Join(absTargetDir, ".rewind"))
+			exitWithError(cmd, err)
 		}
 
-		// Send IPC message after successful initialization
-		if err := sendIPCMessage("add", absTargetDir); err != nil {
+		if !initYesFlag {
+			if err := confirmLargeTree(absTargetDir); err != nil {
+				os.RemoveAll(filepath.Join(absTargetDir, ".rewind"))
+				exitWithError(cmd, err)
+			}
+		}
+
+		// Send IPC message after successful initialization. The daemon scans
+		// the new project and replies with what it captured.
+		response, err := sendIPCRequest(protocol.ActionAdd, absTargetDir)
+		if err != nil {
 			app.Logger.WithField("error", err).Error("Failed to send IPC message")
 
 			// Clean up the .rewind directory since daemon notification failed
@@ -96,17 +96,31 @@ Examples:
 				app.Logger.Info("Cleaned up .rewind directory after IPC failure")
 				fmt.Printf("Error: Failed to notify rewind daemon, cleaned up .rewind directory: %v\n", err)
 			}
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		} else {
 			app.Logger.Info("Successfully notified rewind daemon")
 			fmt.Printf("✓ Rewind project initialized successfully in %s\n", absTargetDir)
+			displayScanStats(response)
 		}
 
 	},
 }
 
+var initYesFlag bool
+var initIncludeFlag []string
+
+// Projects at least this large are confirmed before they are watched, in case
+// init was run in the wrong directory
+const (
+	largeTreeDirs  = 1000
+	largeTreeFiles = 10000
+)
+
 func init() {
 	rootCmd.AddCommand(initCmd)
+	addInstanceFlag(initCmd)
+	initCmd.Flags().BoolVarP(&initYesFlag, "yes", "y", false, "Don't ask for confirmation when the project is very large")
+	initCmd.Flags().StringArrayVar(&initIncludeFlag, "include", nil, "Only version files matching this pattern, added to .rwinclude (repeatable)")
 }
 
 func determineTargetDirectory(args []string) (string, error) {
@@ -170,6 +184,63 @@ func initializeRewindProject(absTargetDir string) error {
 	return nil
 }
 
+// addIncludePatterns appends patterns to the project's .rwinclude, creating
+// it if needed, so that only matching files are versioned
+func addIncludePatterns(absTargetDir string, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil {
+			return fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+	}
+
+	includePath := filepath.Join(absTargetDir, ".rwinclude")
+	existing, err := os.ReadFile(includePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read .rwinclude: %w", err)
+	}
+
+	var content strings.Builder
+	content.Write(existing)
+	if len(existing) > 0 && !strings.HasSuffix(string(existing), "\n") {
+		content.WriteString("\n")
+	}
+	for _, pattern := range patterns {
+		content.WriteString(pattern + "\n")
+	}
+
+	if err := os.WriteFile(includePath, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write .rwinclude: %w", err)
+	}
+	return nil
+}
+
+// confirmLargeTree checks the project is within the watch size limits and asks
+// before watching one large enough that every file being versioned may be a
+// surprise
+func confirmLargeTree(absTargetDir string) error {
+	size, err := watcher.MeasureTree(absTargetDir, loadWatcherConfig())
+	if err != nil {
+		return err
+	}
+	if size.Dirs < largeTreeDirs && size.Files < largeTreeFiles {
+		return nil
+	}
+
+	fmt.Printf("%s contains %d files in %d directories, all of which will be versioned.\n", absTargetDir, size.Files, size.Dirs)
+	fmt.Print("Continue? [y/N]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	confirm, _ := reader.ReadString('\n')
+	if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+		return fmt.Errorf("initialization cancelled - add ignore patterns to .rwignore or initialize a smaller directory")
+	}
+	return nil
+}
+
 func createRewindDirectory(dir string) error {
 	if err := os.Mkdir(dir, 0755); err != nil {
 		return err
@@ -215,129 +286,37 @@ node_modules/*
 	return os.WriteFile(ignoreFile, []byte(ignoreContent), 0644)
 }
 
-// performInitialScan scans all files in the target directory and adds them to the database
-func performInitialScan(targetDir string) error {
-	app.Logger.Info("Performing initial scan of all files")
-	
-	// Create a watch for the target directory
-	watch := &watcher.Watch{
-		Path:   targetDir,
-		Active: true,
-	}
-	
-	// Load ignore patterns manually
-	ignorePatterns, err := loadIgnorePatterns(targetDir)
-	if err != nil {
-		return fmt.Errorf("failed to load ignore patterns: %w", err)
-	}
-	watch.IgnorePatterns = ignorePatterns
-	
-	// Discover watch directories manually
-	watchDirs, err := discoverWatchDirectories(watch)
-	if err != nil {
-		return fmt.Errorf("failed to discover watch directories: %w", err)
-	}
-	watch.WatchDirs = watchDirs
-	
-	// Create a temporary watch list with just this prepared watch
-	tempWatchList := &watcher.WatchList{
-		Watches: []*watcher.Watch{watch},
-	}
-	
-	// Create a watch manager
-	watchManager, err := watcher.NewWatchManager(tempWatchList)
-	if err != nil {
-		return fmt.Errorf("failed to create watch manager: %w", err)
-	}
-	
-	// Perform the initial scan
-	if err := watchManager.PerformInitialScan(); err != nil {
-		return fmt.Errorf("failed to perform initial scan: %w", err)
+// displayScanStats prints the summary of the daemon's scan of a new project
+func displayScanStats(response *protocol.Response) {
+	if len(response.Data) == 0 {
+		return
 	}
-	
-	app.Logger.Info("Initial scan completed successfully")
-	return nil
-}
 
-// loadIgnorePatterns loads ignore patterns from .rewind/ignore and .rwignore files
-func loadIgnorePatterns(rootDir string) ([]string, error) {
-	app.Logger.WithField("rootDir", rootDir).Debug("Loading ignore patterns")
-	
-	patterns := []string{".rewind", ".rewind/*"}
-	
-	// Check for .rewind/ignore file
-	rewindIgnorePath := filepath.Join(rootDir, ".rewind", "ignore")
-	if rewindPatterns, err := readIgnoreFile(rewindIgnorePath); err == nil {
-		patterns = append(patterns, rewindPatterns...)
-		app.Logger.WithField("count", len(rewindPatterns)).Debug("Loaded patterns from .rewind/ignore")
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error reading .rewind/ignore: %w", err)
+	var stats watcher.ScanStats
+	if err := json.Unmarshal(response.Data, &stats); err != nil {
+		app.Logger.WithError(err).Warn("Failed to parse scan stats")
+		return
 	}
-	
-	// Check for .rwignore file
-	rwIgnorePath := filepath.Join(rootDir, ".rwignore")
-	if rwPatterns, err := readIgnoreFile(rwIgnorePath); err == nil {
-		patterns = append(patterns, rwPatterns...)
-		app.Logger.WithField("count", len(rwPatterns)).Debug("Loaded patterns from .rwignore")
-	} else if !os.IsNotExist(err) {
-		return nil, fmt.Errorf("error reading .rwignore: %w", err)
-	}
-	
-	app.Logger.WithField("totalCount", len(patterns)).Info("Ignore patterns loaded")
-	return patterns, nil
+
+	printScanStats(stats)
 }
 
-// readIgnoreFile reads patterns from an ignore file
-func readIgnoreFile(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+// printScanStats prints a one-line summary of a scan and any warnings it raised
+func printScanStats(stats watcher.ScanStats) {
+	fmt.Printf("✓ Scanned %d files: %d new, %d changed, %d unchanged",
+		stats.TotalFiles, stats.NewFiles, stats.ChangedFiles, stats.UnchangedFiles)
+	if stats.BaselineFiles > 0 {
+		fmt.Printf(", %d baseline", stats.BaselineFiles)
 	}
-	defer file.Close()
-	
-	var patterns []string
-	scanner := bufio.NewScanner(file)
-	
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			patterns = append(patterns, line)
-		}
+	if stats.SkippedFiles > 0 {
+		fmt.Printf(", %d skipped by size", stats.SkippedFiles)
 	}
-	
-	return patterns, scanner.Err()
-}
+	if stats.VanishedFiles > 0 {
+		fmt.Printf(", %d deleted during the scan", stats.VanishedFiles)
+	}
+	fmt.Println()
 
-// discoverWatchDirectories discovers all directories that should be watched
-func discoverWatchDirectories(watch *watcher.Watch) ([]string, error) {
-	app.Logger.WithField("rootDir", watch.Path).Debug("Discovering watch directories")
-	var watchDirs []string
-	
-	err := filepath.WalkDir(watch.Path, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			app.Logger.WithField("path", path).WithField("error", err).Warn("Error walking directory")
-			return nil // Continue with other directories
-		}
-		
-		if !d.IsDir() {
-			return nil
-		}
-		
-		// Use the Watch's ShouldIgnore method
-		if watch.ShouldIgnore(path) {
-			relPath, _ := filepath.Rel(watch.Path, path)
-			app.Logger.WithField("path", relPath).Debug("Ignoring directory")
-			return filepath.SkipDir
-		}
-		
-		watchDirs = append(watchDirs, path)
-		return nil
-	})
-	
-	if err != nil {
-		return nil, fmt.Errorf("error walking directory tree: %w", err)
+	for _, warning := range stats.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
 	}
-	
-	app.Logger.WithField("totalDirectories", len(watchDirs)).Info("Directory discovery completed")
-	return watchDirs, nil
 }
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cmd
+
+// unlinkSharedFile is a no-op where link counts aren't available
+func unlinkSharedFile(path string) error {
+	return nil
+}
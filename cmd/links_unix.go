@@ -0,0 +1,35 @@
+//go:build unix
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// unlinkSharedFile removes path if it has other hard links, so that the next
+// write creates a fresh inode. A file with the same mode is created in its place.
+func unlinkSharedFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || stat.Nlink <= 1 {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to unlink hardlinked file: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("failed to recreate file: %w", err)
+	}
+	file.Close()
+
+	return os.Chmod(path, info.Mode().Perm())
+}
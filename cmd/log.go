@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var logLimitFlag int
+var logOnlyCreatesFlag bool
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show recently captured versions across the project",
+	Long: `Show a timeline of the versions captured in the current project, newest first.
+
+Each entry shows what caused the capture: a file being created, modified,
+renamed into place, or saved by a rollback before it overwrote the file.
+A version whose content matches an earlier version of the same file, such as
+after a manual revert, names that version.
+
+Examples:
+  rewind log                   # Show the 20 most recent versions
+  rewind log --limit 100       # Show the 100 most recent versions
+  rewind log --only-creates    # Show only newly created files
+  rewind log --format '{{.FilePath}} v{{.VersionNumber}} {{ago .Timestamp}}'
+  rewind log --limit 0 --jsonl | jq -r .file_path  # Stream the whole history
+
+--format prints each version with a Go text/template instead of the table.
+The template is run against the version's fields: FilePath, VersionNumber,
+Timestamp, FileHash, FileSize, StorageType, EventOp and MatchesVersion. The
+functions size, ago, date and short format a size, a time as "3 hours ago", a
+time as a date and a hash as its first 8 characters.
+
+--jsonl prints each version as a JSON object on its own line as it is read
+from the database, so the full history (--limit 0) of a large project can be
+piped into jq or another tool without being held in memory.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runLog(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().IntVarP(&logLimitFlag, "limit", "n", 20, "Number of versions to show (0 shows all)")
+	logCmd.Flags().BoolVar(&logOnlyCreatesFlag, "only-creates", false, "Only show the first version of newly created files")
+	addBytesFlag(logCmd)
+	addFormatFlag(logCmd, "version")
+	addJSONLFlag(logCmd, "version")
+}
+
+// logEntryJSON is a version as printed by log --jsonl
+type logEntryJSON struct {
+	FilePath       string `json:"file_path"`
+	Version        int    `json:"version"`
+	Timestamp      string `json:"timestamp"`
+	TimestampUnix  int64  `json:"timestamp_unix"`
+	SizeBytes      int64  `json:"size_bytes"`
+	Hash           string `json:"hash"`
+	StorageType    string `json:"storage_type"`
+	EventOp        string `json:"event_op"`
+	MatchesVersion int    `json:"matches_version,omitempty"`
+}
+
+func runLog() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	eventOp := ""
+	if logOnlyCreatesFlag {
+		eventOp = database.EventOpCreate
+	}
+
+	if jsonlFlag {
+		if formatFlag != "" {
+			return fmt.Errorf("--format cannot be combined with --jsonl")
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		return db.EachRecentVersion(logLimitFlag, eventOp, func(version *database.FileVersion) error {
+			return encoder.Encode(logEntryJSON{
+				FilePath:       version.FilePath,
+				Version:        version.VersionNumber,
+				Timestamp:      version.Timestamp.Format("2006-01-02 15:04:05"),
+				TimestampUnix:  version.Timestamp.Unix(),
+				SizeBytes:      version.FileSize,
+				Hash:           version.FileHash,
+				StorageType:    version.StorageType,
+				EventOp:        version.EventOp,
+				MatchesVersion: version.MatchesVersion,
+			})
+		})
+	}
+
+	versions, err := db.GetRecentVersions(logLimitFlag, eventOp)
+	if err != nil {
+		return err
+	}
+	if formatFlag != "" {
+		rows := make([]any, len(versions))
+		for i, version := range versions {
+			rows[i] = version
+		}
+		return printFormatted(rows)
+	}
+	if len(versions) == 0 {
+		fmt.Println("No versions recorded")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tFILE\tVERSION\tSIZE")
+	fmt.Fprintln(w, "----\t-----\t----\t-------\t----")
+	for _, version := range versions {
+		event := eventOpLabel(version.EventOp)
+		if version.MatchesVersion > 0 {
+			event += fmt.Sprintf(", same as v%d", version.MatchesVersion)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			humanize.Time(version.Timestamp),
+			event,
+			version.FilePath,
+			version.VersionNumber,
+			formatSize(version.FileSize))
+	}
+
+	return w.Flush()
+}
+
+// eventOpLabel describes the operation that captured a version
+func eventOpLabel(op string) string {
+	switch op {
+	case database.EventOpCreate:
+		return "created"
+	case database.EventOpRename:
+		return "renamed"
+	case database.EventOpRollback:
+		return "rollback"
+	default:
+		return "modified"
+	}
+}
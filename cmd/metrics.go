@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/spf13/cobra"
+)
+
+// metricsCmd represents the metrics command
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Show capture latency metrics from the rewind daemon",
+	Long: `Display capture latency statistics collected by the running daemon.
+
+Each capture is broken down into stages so slowness can be attributed:
+- hash:  calculating the SHA256 of the file
+- copy:  copying the file into .rewind/versions
+- db:    inserting the version into the SQLite database
+- total: the whole capture, from version lookup to database insert
+
+Percentiles are estimated from a fixed histogram, so p50/p95 report the
+upper bound of the bucket the percentile falls in.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if err := runMetrics(jsonOutput); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(metricsCmd)
+	addInstanceFlag(metricsCmd)
+	metricsCmd.Flags().BoolP("json", "j", false, "Output metrics as JSON")
+}
+
+func runMetrics(jsonOutput bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "."
+	}
+
+	response, err := sendIPCMessageWithResponse(protocol.ActionMetrics, cwd)
+	if err != nil {
+		if jsonOutput {
+			return err
+		}
+		fmt.Printf("Cannot connect to rewind daemon: %v\n", err)
+		fmt.Println("The rewind daemon may not be running. Try 'rewind watch' to start it.")
+		return nil
+	}
+
+	if jsonOutput {
+		return emitJSON("metrics", json.RawMessage(response))
+	}
+
+	var metrics watcher.CaptureMetrics
+	if err := json.Unmarshal([]byte(response), &metrics); err != nil {
+		return fmt.Errorf("failed to parse metrics JSON: %w", err)
+	}
+
+	return displayMetrics(metrics)
+}
+
+func displayMetrics(metrics watcher.CaptureMetrics) error {
+	fmt.Println("Capture Latency")
+	fmt.Println("===============")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tCOUNT\tP50\tP95\tMEAN\tMAX")
+	fmt.Fprintln(w, "-----\t-----\t---\t---\t----\t---")
+
+	for _, stage := range metrics.Stages {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n",
+			stage.Stage,
+			stage.Count,
+			formatMs(stage.P50Ms),
+			formatMs(stage.P95Ms),
+			formatMs(stage.MeanMs),
+			formatMs(stage.MaxMs),
+		)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if metrics.FailedCaptures > 0 {
+		fmt.Printf("\nFailed captures: %d (see 'rewind status' for recent errors)\n", metrics.FailedCaptures)
+	}
+	return nil
+}
+
+func formatMs(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.2fs", ms/1000)
+	}
+	return fmt.Sprintf("%.1fms", ms)
+}
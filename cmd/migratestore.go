@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/spf13/cobra"
+)
+
+var migrateStoreToFlag string
+var migrateStoreDryRunFlag bool
+
+// migrateStoreCmd represents the migrate-store command
+var migrateStoreCmd = &cobra.Command{
+	Use:   "migrate-store",
+	Short: "Move the version store to another storage layout",
+	Long: `Move the current project's stored versions to another storage layout.
+
+Layouts:
+  paths                 Each version is stored under a path mirroring the
+                        project tree (the default)
+  content-addressable   Versions are stored once per content under
+                        .rewind/versions/objects, named by their hash, so
+                        versions with the same content share one file
+
+Every stored copy is checked against the hash recorded when it was captured as
+it is written to its new place, and the database is only pointed at the new
+copies, in a single transaction, once all of them are written. The old copies
+are removed afterwards, so an interrupted migration leaves the store as it was.
+Deltas and compressed versions are left where they are.
+
+The layout is recorded in the project database, and new versions are stored in
+it from then on.
+
+When the daemon is running, it does the work between captures. Otherwise the
+store is migrated directly.
+
+Examples:
+  rewind migrate-store --to content-addressable --dry-run   # Show the work and space saved
+  rewind migrate-store --to content-addressable
+  rewind migrate-store --to paths                           # Move back`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runMigrateStore(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateStoreCmd)
+	migrateStoreCmd.Flags().StringVar(&migrateStoreToFlag, "to", "", "Layout to move the store to ("+strings.Join(database.StorageLayouts, ", ")+")")
+	migrateStoreCmd.Flags().BoolVar(&migrateStoreDryRunFlag, "dry-run", false, "Show the work and space impact without moving anything")
+	migrateStoreCmd.MarkFlagRequired("to")
+	addBytesFlag(migrateStoreCmd)
+	addInstanceFlag(migrateStoreCmd)
+}
+
+func runMigrateStore() error {
+	if migrateStoreToFlag != database.LayoutPaths && migrateStoreToFlag != database.LayoutContentAddressable {
+		return fmt.Errorf("unknown layout %q (expected %s)", migrateStoreToFlag, strings.Join(database.StorageLayouts, " or "))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	var migration *database.StoreMigration
+	if migrateStoreDryRunFlag {
+		// Nothing is written, so there is no need to hold off the daemon
+		migration, err = migrateStoreDirectly(rewindRoot, true)
+	} else {
+		migration, err = migrateStoreWithDaemon(rewindRoot)
+		if exitCode(err) == exitDaemonUnreachable {
+			fmt.Println("Daemon not running, migrating the store directly")
+			migration, err = migrateStoreDirectly(rewindRoot, false)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	displayStoreMigration(migration)
+	return nil
+}
+
+// migrateStoreWithDaemon asks the daemon to migrate the project's version
+// store between captures
+func migrateStoreWithDaemon(rewindRoot string) (*database.StoreMigration, error) {
+	response, err := sendIPC(protocol.Message{
+		Action: protocol.ActionMigrateStore,
+		Path:   rewindRoot,
+		Layout: migrateStoreToFlag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var migration database.StoreMigration
+	if err := json.Unmarshal(response.Data, &migration); err != nil {
+		return nil, fmt.Errorf("failed to decode migration result: %w", err)
+	}
+	return &migration, nil
+}
+
+// migrateStoreDirectly migrates the project's version store without the daemon
+func migrateStoreDirectly(rewindRoot string, dryRun bool) (*database.StoreMigration, error) {
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return db.MigrateStore(migrateStoreToFlag, dryRun)
+}
+
+// displayStoreMigration prints the work and space impact of a migration
+func displayStoreMigration(migration *database.StoreMigration) {
+	verb := "Moved"
+	if migration.DryRun {
+		verb = "Would move"
+	}
+
+	fmt.Printf("Storage layout: %s -> %s\n", migration.From, migration.To)
+	fmt.Printf("%s %d versions into %d files: %s -> %s", verb, migration.Versions, migration.Files,
+		formatSize(migration.SizeBefore), formatSize(migration.SizeAfter))
+	if saved := migration.SizeBefore - migration.SizeAfter; saved > 0 {
+		fmt.Printf(", saving %s", formatSize(saved))
+	} else if saved < 0 {
+		fmt.Printf(", using %s more", formatSize(-saved))
+	}
+	fmt.Println()
+
+	if migration.Skipped > 0 {
+		fmt.Printf("Left %d deltas and compressed versions where they are\n", migration.Skipped)
+	}
+	if migration.DryRun {
+		fmt.Println("Dry run - no files were changed")
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// jsonSchemaVersion is bumped whenever the shape of any command's JSON output
+// changes, so tooling can detect formats it doesn't understand
+const jsonSchemaVersion = 1
+
+// jsonEnvelope wraps the JSON output of every command
+type jsonEnvelope struct {
+	RewindVersion string `json:"rewind_version"`
+	Command       string `json:"command"`
+	Schema        int    `json:"schema"`
+	Data          any    `json:"data"`
+}
+
+var exactSizesFlag bool
+var formatFlag string
+var jsonlFlag bool
+
+// addBytesFlag adds --bytes to a command that lists file sizes
+func addBytesFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&exactSizesFlag, "bytes", false, "Show sizes as exact byte counts")
+}
+
+// formatSize formats a size for display. Sizes are humanized unless exact byte
+// counts were asked for with --bytes or the exact_sizes setting, as rounding
+// can make versions a few bytes apart look identical.
+func formatSize(size int64) string {
+	if exactSizesFlag || viper.GetBool("exact_sizes") {
+		return fmt.Sprintf("%d B", size)
+	}
+	return humanize.Bytes(uint64(size))
+}
+
+// formatSizeDiff formats a signed size difference with formatSize
+func formatSizeDiff(diff int64) string {
+	if diff < 0 {
+		return "-" + formatSize(-diff)
+	}
+	return "+" + formatSize(diff)
+}
+
+// emitJSON writes data to stdout wrapped in the versioned JSON envelope
+func emitJSON(command string, data any) error {
+	version := appVersion
+	if version == "" {
+		version = "unknown"
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	return encoder.Encode(jsonEnvelope{
+		RewindVersion: version,
+		Command:       command,
+		Schema:        jsonSchemaVersion,
+		Data:          data,
+	})
+}
+
+// jsonError is the JSON output of a command that failed
+type jsonError struct {
+	Error jsonErrorDetail `json:"error"`
+}
+
+type jsonErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError prints err the way cmd's output is printed: as a JSON error
+// object when --json is set, and as a plain message otherwise
+func writeError(cmd *cobra.Command, err error) {
+	if flag := cmd.Flags().Lookup("json"); flag != nil && flag.Value.String() == "true" {
+		json.NewEncoder(cmd.OutOrStdout()).Encode(jsonError{Error: jsonErrorDetail{
+			Code:    errorCode(err),
+			Message: err.Error(),
+		}})
+		return
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Error: %v\n", err)
+}
+
+// exitWithError reports err and exits with the code for it. Every command's
+// Run function ends this way when it fails, so scripts using --json can parse
+// failures as well as results.
+func exitWithError(cmd *cobra.Command, err error) {
+	writeError(cmd, err)
+	os.Exit(exitCode(err))
+}
+
+// addJSONLFlag adds --jsonl to a command that lists rows. Each row is printed
+// as a JSON object on its own line as soon as it is read, without the
+// envelope of --json, so long listings can be streamed into other tools.
+func addJSONLFlag(cmd *cobra.Command, row string) {
+	cmd.Flags().BoolVar(&jsonlFlag, "jsonl", false, "Print each "+row+" as a JSON object on its own line (JSON Lines)")
+}
+
+// addFormatFlag adds --format to a command that lists rows
+func addFormatFlag(cmd *cobra.Command, row string) {
+	cmd.Flags().StringVar(&formatFlag, "format", "", "Print each "+row+" with a Go template, e.g. '{{.VersionNumber}} {{.FileHash}}'")
+}
+
+// formatFuncs are the functions available to --format templates
+var formatFuncs = template.FuncMap{
+	"size": formatSize,
+	"ago":  humanize.Time,
+	"date": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"short": func(hash string) string {
+		if len(hash) > 8 {
+			return hash[:8]
+		}
+		return hash
+	},
+}
+
+// printFormatted prints each row with the --format template, one per line
+func printFormatted(rows []any) error {
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(formatFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --format template: %w", err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	for _, row := range rows {
+		// Render the row first so a failing template prints no partial line
+		var line strings.Builder
+		if err := tmpl.Execute(&line, row); err != nil {
+			w.Flush()
+			return fmt.Errorf("failed to apply --format: %w", err)
+		}
+		fmt.Fprintln(w, line.String())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// restoreOwnership gives path back the owner recorded for version, when
+// capture_ownership recorded one. Changing the owner usually needs root, so
+// a failure is a warning: the contents have already been restored.
+func restoreOwnership(db *database.DatabaseManager, version *database.FileVersion, path string) {
+	owner, err := db.GetVersionOwner(version.ID)
+	if err != nil || owner == nil {
+		return
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if current := database.FileOwner(info); current != nil && *current == *owner {
+			return
+		}
+	}
+
+	if err := os.Chown(path, owner.UID, owner.GID); err != nil {
+		fmt.Printf("Warning: could not restore owner %d:%d of %s: %v\n", owner.UID, owner.GID, path, err)
+	}
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// withWritableFile runs write, which replaces the contents of path. If path
+// is a read-only file it is made writable for the write and given its
+// original mode back afterwards, so rollback and restore work on files such
+// as generated sources that are kept read-only.
+func withWritableFile(path string, write func() error) error {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0200 != 0 {
+		return write()
+	}
+
+	mode := info.Mode().Perm()
+	if err := os.Chmod(path, mode|0200); err != nil {
+		return fmt.Errorf("%s is read-only and could not be made writable (is it owned by another user?): %w", path, err)
+	}
+
+	writeErr := write()
+
+	if err := os.Chmod(path, mode); err != nil {
+		fmt.Printf("Warning: could not make %s read-only again (mode %04o): %v\n", path, mode, err)
+	}
+	return writeErr
+}
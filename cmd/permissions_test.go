@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithWritableFile_ReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "generated.go")
+	if err := os.WriteFile(path, []byte("old"), 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	err := withWritableFile(path, func() error {
+		return os.WriteFile(path, []byte("new"), 0644)
+	})
+	if err != nil {
+		t.Fatalf("withWritableFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "new" {
+		t.Errorf("content = %q, %v, want %q", content, err, "new")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0444 {
+		t.Errorf("mode = %04o, want 0444", info.Mode().Perm())
+	}
+}
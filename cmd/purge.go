@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/davenicholson-xyz/rewind/internal/database"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // purgeCmd represents the purge command
@@ -16,50 +20,131 @@ var purgeCmd = &cobra.Command{
 	Use:   "purge",
 	Short: "Remove old versions to free up space",
 	Long: `Remove old versions from the .rewind directory to free up space.
-Uses either keep-last or older-than strategy to determine which versions to remove.
-Tagged versions are always preserved.
+Uses one of the keep-last, older-than, max-size or thin strategies to determine
+which versions to remove. Tagged versions are always preserved.
+
+The thin strategy decays history gradually: every version from the last hour
+is kept, then one version per hour for the last day, one per day for the last
+month, and one per week beyond that. The periods can be changed in the config
+file under thinning (keep_all, hourly_for, daily_for).
+
+Sizes for --max-size are case-insensitive. KB, MB, GB and TB are decimal
+(1KB = 1000 bytes); KiB, MiB, GiB and TiB are binary (1KiB = 1024 bytes).
 
 Examples:
   rewind purge --keep-last 10        # Keep last 10 versions per file
   rewind purge --older-than 7d       # Remove versions older than 7 days
   rewind purge --older-than 2w       # Remove versions older than 2 weeks  
   rewind purge --older-than 1h       # Remove versions older than 1 hour
+  rewind purge --older-than 1w3d     # Remove versions older than 10 days
+  rewind purge --older-than 3M       # Remove versions older than ~3 months (M = 30 days, y = 365 days)
   rewind purge --max-size 1GB        # Keep total size under 1GB
   rewind purge --max-size 500MB      # Keep total size under 500MB
-  rewind purge --dry-run --keep-last 3  # Show what would be removed`,
+  rewind purge --max-size 2GiB       # Keep total size under 2GiB (2 x 1024^3 bytes)
+  rewind purge --thin                # Thin old versions to hourly/daily/weekly
+  rewind purge --keep-last 3 --under build/  # Only purge versions of files under build/
+  rewind purge --dry-run --keep-last 3  # Show what would be removed
+  rewind purge --dry-run --verbose --max-size 1GB  # Show the space reclaimed per file
+  rewind purge --thin --force --json # Purge without prompting and report the result as JSON
+  rewind purge --plan --max-size 1GB # Check the purge would reach 1GB before running it
+  rewind purge --interactive --thin  # Approve the purge file by file
+
+--json and --quiet never prompt, so they need --force to remove anything
+(or --dry-run to only report the candidates).
+
+--plan never deletes anything. It shows the project's stored size now and
+after the purge, whether that meets the --max-size target, and how many
+versions and how much space each affected file would lose. With --json the
+plan is printed as JSON.
+
+--interactive goes through the files with versions to purge one at a time,
+showing how many versions each would lose and the space reclaimed, and asks
+whether to purge them: y purges the file's versions, k or s keeps them, a
+purges this file and every remaining one, and q keeps this file and every
+remaining one. The versions approved are then purged.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		keepLast, _ := cmd.Flags().GetInt("keep-last")
-		olderThan, _ := cmd.Flags().GetString("older-than")
-		maxSize, _ := cmd.Flags().GetString("max-size")
-		dryRun, _ := cmd.Flags().GetBool("dry-run")
-		force, _ := cmd.Flags().GetBool("force")
+		var opts purgeOptions
+		opts.keepLast, _ = cmd.Flags().GetInt("keep-last")
+		opts.olderThan, _ = cmd.Flags().GetString("older-than")
+		opts.maxSize, _ = cmd.Flags().GetString("max-size")
+		opts.thin, _ = cmd.Flags().GetBool("thin")
+		opts.under, _ = cmd.Flags().GetString("under")
+		opts.dryRun, _ = cmd.Flags().GetBool("dry-run")
+		opts.verbose, _ = cmd.Flags().GetBool("verbose")
+		opts.force, _ = cmd.Flags().GetBool("force")
+		opts.jsonOutput, _ = cmd.Flags().GetBool("json")
+		opts.quiet, _ = cmd.Flags().GetBool("quiet")
+		opts.plan, _ = cmd.Flags().GetBool("plan")
+		opts.interactive, _ = cmd.Flags().GetBool("interactive")
 		
-		if err := runPurge(keepLast, olderThan, maxSize, dryRun, force); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		if err := runPurge(opts); err != nil {
+			exitWithError(cmd, err)
 		}
 	},
 }
 
-func runPurge(keepLast int, olderThan string, maxSize string, dryRun bool, force bool) error {
+// purgeOptions are the purge command's flags
+type purgeOptions struct {
+	keepLast    int
+	olderThan   string
+	maxSize     string
+	thin        bool
+	under       string
+	dryRun      bool
+	verbose     bool
+	force       bool
+	jsonOutput  bool
+	quiet       bool
+	plan        bool
+	interactive bool
+}
+
+// purgeResult is the --json output of the purge command
+type purgeResult struct {
+	Strategy         string  `json:"strategy"`
+	DryRun           bool    `json:"dry_run"`
+	CandidateCount   int     `json:"candidate_count"`
+	ReclaimableBytes int64   `json:"reclaimable_bytes"`
+	RemovedCount     int     `json:"removed_count"`
+	ReclaimedBytes   int64   `json:"reclaimed_bytes"`
+	VersionIDs       []int64 `json:"version_ids"`
+}
+
+func runPurge(opts purgeOptions) error {
 	// Count how many strategies are specified
 	strategyCount := 0
-	if keepLast > 0 {
+	if opts.keepLast > 0 {
+		strategyCount++
+	}
+	if opts.olderThan != "" {
 		strategyCount++
 	}
-	if olderThan != "" {
+	if opts.maxSize != "" {
 		strategyCount++
 	}
-	if maxSize != "" {
+	if opts.thin {
 		strategyCount++
 	}
 	
 	// Validate that exactly one strategy is specified
 	if strategyCount == 0 {
-		return fmt.Errorf("must specify one of --keep-last, --older-than, or --max-size")
+		return fmt.Errorf("must specify one of --keep-last, --older-than, --max-size, or --thin")
 	}
 	if strategyCount > 1 {
-		return fmt.Errorf("can only specify one of --keep-last, --older-than, or --max-size")
+		return fmt.Errorf("can only specify one of --keep-last, --older-than, --max-size, or --thin")
+	}
+
+	if opts.plan && opts.quiet {
+		return fmt.Errorf("--plan prints the plan, so it can't be used with --quiet")
+	}
+
+	if opts.interactive && (opts.force || opts.jsonOutput || opts.quiet || opts.dryRun || opts.plan) {
+		return fmt.Errorf("--interactive prompts for each file, so it can't be used with --force, --json, --quiet, --dry-run or --plan")
+	}
+
+	// JSON and quiet output are for scripts, which can't answer the prompt
+	if (opts.jsonOutput || opts.quiet) && !opts.force && !opts.dryRun && !opts.plan {
+		return fmt.Errorf("--json and --quiet require --force (or --dry-run)")
 	}
 
 	// Find .rewind directory
@@ -71,6 +156,11 @@ func runPurge(keepLast int, olderThan string, maxSize string, dryRun bool, force
 	// Get project root (parent of .rewind)
 	projectRoot := filepath.Dir(rewindDir)
 
+	relPrefix, err := subtreePrefix(projectRoot, opts.under)
+	if err != nil {
+		return err
+	}
+
 	// Initialize database manager
 	dbManager, err := database.NewDatabaseManager(projectRoot)
 	if err != nil {
@@ -86,59 +176,140 @@ func runPurge(keepLast int, olderThan string, maxSize string, dryRun bool, force
 	// Get versions to purge based on strategy
 	var versionIDs []int64
 	var strategy string
+	target := int64(-1)
 
-	if keepLast > 0 {
-		if keepLast < 1 {
+	if opts.keepLast > 0 {
+		if opts.keepLast < 1 {
 			return fmt.Errorf("keep-last must be at least 1")
 		}
-		versionIDs, err = dbManager.GetVersionsForPurge(keepLast)
+		versionIDs, err = dbManager.GetVersionsForPurge(opts.keepLast, relPrefix)
 		if err != nil {
 			return fmt.Errorf("failed to get versions for purge: %w", err)
 		}
-		strategy = fmt.Sprintf("keeping last %d per file", keepLast)
-	} else if olderThan != "" {
+		strategy = fmt.Sprintf("keeping last %d per file", opts.keepLast)
+	} else if opts.olderThan != "" {
 		// Parse older-than duration
-		duration, err := parseDuration(olderThan)
+		duration, err := parseDuration(opts.olderThan)
 		if err != nil {
 			return fmt.Errorf("invalid older-than duration: %w", err)
 		}
 		
 		cutoffTime := time.Now().Add(-duration)
-		versionIDs, err = dbManager.GetVersionsForPurgeByAge(cutoffTime)
+		versionIDs, err = dbManager.GetVersionsForPurgeByAge(cutoffTime, relPrefix)
 		if err != nil {
 			return fmt.Errorf("failed to get versions for purge by age: %w", err)
 		}
-		strategy = fmt.Sprintf("older than %s", olderThan)
-	} else if maxSize != "" {
+		strategy = fmt.Sprintf("older than %s", opts.olderThan)
+	} else if opts.maxSize != "" {
 		// Parse max-size limit
-		sizeLimit, err := parseSize(maxSize)
+		sizeLimit, err := parseSize(opts.maxSize)
 		if err != nil {
 			return fmt.Errorf("invalid max-size: %w", err)
 		}
 		
-		versionIDs, err = dbManager.GetVersionsForPurgeBySize(sizeLimit)
+		versionIDs, err = dbManager.GetVersionsForPurgeBySize(sizeLimit, relPrefix)
 		if err != nil {
 			return fmt.Errorf("failed to get versions for purge by size: %w", err)
 		}
-		strategy = fmt.Sprintf("keeping total size under %s", maxSize)
+		strategy = fmt.Sprintf("keeping total size under %s", opts.maxSize)
+		target = sizeLimit
+	} else if opts.thin {
+		policy, err := loadThinningPolicy()
+		if err != nil {
+			return fmt.Errorf("invalid thinning policy: %w", err)
+		}
+
+		versionIDs, err = dbManager.GetVersionsForPurgeByThinning(policy, relPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to get versions for purge by thinning: %w", err)
+		}
+		strategy = fmt.Sprintf("thinning to hourly after %s, daily after %s, weekly after %s",
+			formatPeriod(policy.KeepAll), formatPeriod(policy.HourlyFor), formatPeriod(policy.DailyFor))
+	}
+
+	if relPrefix != "" {
+		strategy += fmt.Sprintf(", under %s", relPrefix)
+	}
+
+	if opts.plan {
+		purgePlan, err := buildPurgePlan(dbManager, strategy, relPrefix, target, versionIDs)
+		if err != nil {
+			return err
+		}
+		if opts.jsonOutput {
+			return emitJSON("purge plan", purgePlan)
+		}
+		return displayPurgePlan(purgePlan)
 	}
 
+	result := purgeResult{
+		Strategy:       strategy,
+		DryRun:         opts.dryRun,
+		CandidateCount: len(versionIDs),
+		VersionIDs:     versionIDs,
+	}
+	if result.VersionIDs == nil {
+		result.VersionIDs = []int64{}
+	}
+	opts.verbose = opts.verbose && !opts.jsonOutput && !opts.quiet
+
 	if len(versionIDs) == 0 {
-		fmt.Println("No versions to purge.")
+		if opts.jsonOutput {
+			return emitJSON("purge", result)
+		}
+		if !opts.quiet {
+			fmt.Println("No versions to purge.")
+		}
 		return nil
 	}
 
+	reclaimable, err := dbManager.GetVersionsSizeSum(versionIDs)
+	if err != nil {
+		return fmt.Errorf("failed to calculate reclaimable space: %w", err)
+	}
+	result.ReclaimableBytes = reclaimable
+
 	// Show what will be removed
-	fmt.Printf("Found %d versions to purge (%s, preserving tagged versions)\n", 
-		len(versionIDs), strategy)
+	if !opts.jsonOutput && !opts.quiet {
+		fmt.Printf("Found %d versions to purge (%s, preserving tagged versions)\n", 
+			len(versionIDs), strategy)
+		fmt.Printf("Reclaimable space: %s\n", formatSize(reclaimable))
+	}
+
+	if opts.verbose {
+		if err := displayPurgeBreakdown(dbManager, versionIDs); err != nil {
+			return err
+		}
+	}
 
-	if dryRun {
-		fmt.Println("Dry run - no files will be deleted")
+	if opts.dryRun {
+		if opts.jsonOutput {
+			return emitJSON("purge", result)
+		}
+		if !opts.quiet {
+			fmt.Println("Dry run - no files will be deleted")
+		}
 		return nil
 	}
 
-	// Confirm deletion unless force flag is used
-	if !force {
+	if opts.interactive {
+		groups, err := groupPurgeCandidates(dbManager, versionIDs)
+		if err != nil {
+			return err
+		}
+		versionIDs = selectPurgeCandidates(groups, os.Stdin)
+		if len(versionIDs) == 0 {
+			fmt.Println("\nNo versions purged")
+			return nil
+		}
+
+		reclaimable, err = dbManager.GetVersionsSizeSum(versionIDs)
+		if err != nil {
+			return fmt.Errorf("failed to calculate reclaimable space: %w", err)
+		}
+		fmt.Println()
+	} else if !opts.force {
+		// Confirm deletion unless force flag is used
 		fmt.Print("Continue with purge? (y/N): ")
 		var response string
 		fmt.Scanln(&response)
@@ -153,99 +324,211 @@ func runPurge(keepLast int, olderThan string, maxSize string, dryRun bool, force
 		return fmt.Errorf("failed to remove versions: %w", err)
 	}
 
-	fmt.Printf("Successfully purged %d versions\n", len(versionIDs))
+	result.RemovedCount = len(versionIDs)
+	result.ReclaimedBytes = reclaimable
+	if opts.jsonOutput {
+		return emitJSON("purge", result)
+	}
+	if !opts.quiet {
+		fmt.Printf("Successfully purged %d versions\n", len(versionIDs))
+		if opts.interactive {
+			fmt.Printf("Reclaimed %s\n", formatSize(reclaimable))
+		}
+	}
 	return nil
 }
 
-// parseDuration parses duration strings like "7d", "2w", "1h", "30m"
-func parseDuration(s string) (time.Duration, error) {
-	if len(s) < 2 {
-		return 0, fmt.Errorf("invalid duration format")
+// displayPurgeBreakdown lists the number and size of the versions to purge
+// for each file
+func displayPurgeBreakdown(dbManager *database.DatabaseManager, versionIDs []int64) error {
+	summaries, err := dbManager.GetVersionsSizeByFile(versionIDs)
+	if err != nil {
+		return fmt.Errorf("failed to calculate reclaimable space per file: %w", err)
 	}
-	
-	unit := s[len(s)-1:]
-	valueStr := s[:len(s)-1]
-	
-	var value int
-	if _, err := fmt.Sscanf(valueStr, "%d", &value); err != nil {
-		return 0, fmt.Errorf("invalid duration value: %w", err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nFILE\tVERSIONS\tSIZE")
+	for _, summary := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", summary.FilePath, summary.Versions, formatSize(summary.Bytes))
 	}
-	
-	switch unit {
-	case "s":
-		return time.Duration(value) * time.Second, nil
-	case "m":
-		return time.Duration(value) * time.Minute, nil
-	case "h":
-		return time.Duration(value) * time.Hour, nil
-	case "d":
-		return time.Duration(value) * 24 * time.Hour, nil
-	case "w":
-		return time.Duration(value) * 7 * 24 * time.Hour, nil
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Println()
+	return nil
+}
+
+// subtreePrefix converts the --under directory into a prefix of the relative
+// file paths stored in the database. An empty directory means the whole project.
+func subtreePrefix(projectRoot, dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	relDir, err := filepath.Rel(projectRoot, absDir)
+	if err != nil || relDir == ".." || strings.HasPrefix(relDir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside the rewind project at %s", dir, projectRoot)
+	}
+
+	if relDir == "." {
+		return "", nil
+	}
+
+	// Match whole directory names, so build/ doesn't also match builder/
+	return relDir + string(filepath.Separator), nil
+}
+
+// loadThinningPolicy builds the thinning policy, overriding the defaults with
+// any periods set in the config file (same format as --older-than)
+func loadThinningPolicy() (database.ThinningPolicy, error) {
+	policy := database.DefaultThinningPolicy()
+
+	periods := []struct {
+		key    string
+		period *time.Duration
+	}{
+		{"thinning.keep_all", &policy.KeepAll},
+		{"thinning.hourly_for", &policy.HourlyFor},
+		{"thinning.daily_for", &policy.DailyFor},
+	}
+
+	for _, p := range periods {
+		if !viper.IsSet(p.key) {
+			continue
+		}
+		duration, err := parseDuration(viper.GetString(p.key))
+		if err != nil {
+			return policy, fmt.Errorf("%s: %w", p.key, err)
+		}
+		*p.period = duration
+	}
+
+	if policy.KeepAll > policy.HourlyFor || policy.HourlyFor > policy.DailyFor {
+		return policy, fmt.Errorf("periods must satisfy keep_all <= hourly_for <= daily_for")
+	}
+
+	return policy, nil
+}
+
+// formatPeriod formats a thinning period in the largest whole unit
+func formatPeriod(d time.Duration) string {
+	switch {
+	case d > 0 && d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d > 0 && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
 	default:
-		return 0, fmt.Errorf("invalid duration unit: %s (use s, m, h, d, or w)", unit)
+		return d.String()
+	}
+}
+
+// durationUnits maps the units accepted by parseDuration to their length.
+// Months and years are approximate (30 and 365 days). Units are
+// case-sensitive so that m (minutes) and M (months) can be told apart.
+var durationUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+	'M': 30 * 24 * time.Hour,
+	'y': 365 * 24 * time.Hour,
+}
+
+// parseDuration parses duration strings like "7d", "2w", "3M" or "1w3d". Each
+// unit may appear at most once.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration format")
 	}
+
+	var total time.Duration
+	seen := make(map[byte]bool)
+
+	for i := 0; i < len(s); {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if start == i {
+			return 0, fmt.Errorf("invalid duration %q: expected a number at %q", s, s[i:])
+		}
+		if i == len(s) {
+			return 0, fmt.Errorf("invalid duration %q: missing unit after %s (use s, m, h, d, w, M, or y)", s, s[start:])
+		}
+
+		value, err := strconv.Atoi(s[start:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration value: %w", err)
+		}
+
+		unit := s[i]
+		length, ok := durationUnits[unit]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration unit: %c (use s, m, h, d, w, M, or y)", unit)
+		}
+		if seen[unit] {
+			return 0, fmt.Errorf("invalid duration %q: unit %c given more than once", s, unit)
+		}
+		seen[unit] = true
+		i++
+
+		if time.Duration(value) > (math.MaxInt64-total)/length {
+			return 0, fmt.Errorf("invalid duration %q: too long", s)
+		}
+		total += time.Duration(value) * length
+	}
+
+	return total, nil
+}
+
+// sizeUnits maps the lower-cased units accepted by parseSize to their size in
+// bytes. KB, MB, GB and TB are decimal (SI); KiB, MiB, GiB and TiB are binary.
+var sizeUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
 }
 
-// parseSize parses size strings like "1GB", "500MB", "2TB"
+// parseSize parses size strings like "1GB", "500 MiB" or "1.5tb". Units are
+// case-insensitive and may be separated from the value by a space.
 func parseSize(s string) (int64, error) {
-	if len(s) < 2 {
-		return 0, fmt.Errorf("invalid size format")
+	s = strings.TrimSpace(s)
+
+	// Split the numeric value from the unit
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
 	}
-	
-	// Handle different unit lengths (B, KB, MB, GB, TB)
-	var unit string
-	var valueStr string
-	
-	if len(s) >= 3 && s[len(s)-2:] == "KB" {
-		unit = "KB"
-		valueStr = s[:len(s)-2]
-	} else if len(s) >= 3 && s[len(s)-2:] == "MB" {
-		unit = "MB"
-		valueStr = s[:len(s)-2]
-	} else if len(s) >= 3 && s[len(s)-2:] == "GB" {
-		unit = "GB"
-		valueStr = s[:len(s)-2]
-	} else if len(s) >= 3 && s[len(s)-2:] == "TB" {
-		unit = "TB"
-		valueStr = s[:len(s)-2]
-	} else if len(s) >= 2 && s[len(s)-1:] == "B" {
-		// Check if the part before B contains only digits/decimal
-		valueStr = s[:len(s)-1]
-		var testValue float64
-		n, err := fmt.Sscanf(valueStr, "%f", &testValue)
-		if err != nil || n != 1 {
-			return 0, fmt.Errorf("invalid size unit: %s (use B, KB, MB, GB, or TB)", s)
-		}
-		// Additional check: make sure the entire string was consumed
-		testStr := fmt.Sprintf("%g", testValue)
-		if testStr != valueStr && fmt.Sprintf("%.0f", testValue) != valueStr {
-			return 0, fmt.Errorf("invalid size unit: %s (use B, KB, MB, GB, or TB)", s)
-		}
-		unit = "B"
-	} else {
-		return 0, fmt.Errorf("invalid size unit (use B, KB, MB, GB, or TB)")
+	valueStr := s[:i]
+	unit := strings.ToLower(strings.TrimSpace(s[i:]))
+
+	if valueStr == "" || unit == "" {
+		return 0, fmt.Errorf("invalid size format: %q (e.g. 500MB, 1GiB)", s)
 	}
-	
-	var value float64
-	if _, err := fmt.Sscanf(valueStr, "%f", &value); err != nil {
-		return 0, fmt.Errorf("invalid size value: %w", err)
+
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size value: %s", valueStr)
 	}
-	
-	switch unit {
-	case "B":
-		return int64(value), nil
-	case "KB":
-		return int64(value * 1024), nil
-	case "MB":
-		return int64(value * 1024 * 1024), nil
-	case "GB":
-		return int64(value * 1024 * 1024 * 1024), nil
-	case "TB":
-		return int64(value * 1024 * 1024 * 1024 * 1024), nil
-	default:
-		return 0, fmt.Errorf("invalid size unit: %s (use B, KB, MB, GB, or TB)", unit)
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit: %s (use B, KB, MB, GB, TB, KiB, MiB, GiB or TiB)", s[i:])
 	}
+
+	return int64(value * multiplier), nil
 }
 
 func findRewindDirectory() (string, error) {
@@ -268,15 +551,23 @@ func findRewindDirectory() (string, error) {
 		dir = parent
 	}
 
-	return "", fmt.Errorf(".rewind directory not found")
+	return "", withExitCode(exitNotInitialized, fmt.Errorf(".rewind directory not found"))
 }
 
 func init() {
 	rootCmd.AddCommand(purgeCmd)
 
 	purgeCmd.Flags().IntP("keep-last", "k", 0, "Number of versions to keep per file")
-	purgeCmd.Flags().StringP("older-than", "t", "", "Remove versions older than specified duration (e.g., 7d, 2w, 1h)")
-	purgeCmd.Flags().StringP("max-size", "s", "", "Keep total size under specified limit (e.g., 1GB, 500MB)")
+	purgeCmd.Flags().StringP("older-than", "t", "", "Remove versions older than specified duration (e.g., 7d, 2w, 1w3d, 3M)")
+	purgeCmd.Flags().StringP("max-size", "s", "", "Keep total size under specified limit (e.g., 1GB, 500MiB)")
+	purgeCmd.Flags().Bool("thin", false, "Thin old versions to one per hour, day and week as they age")
+	purgeCmd.Flags().StringP("under", "u", "", "Only purge versions of files under this directory")
 	purgeCmd.Flags().BoolP("dry-run", "n", false, "Show what would be removed without actually deleting")
+	purgeCmd.Flags().Bool("verbose", false, "List the versions and space reclaimed per file")
 	purgeCmd.Flags().BoolP("force", "f", false, "Skip confirmation prompt")
+	purgeCmd.Flags().BoolP("json", "j", false, "Output the purge result as JSON")
+	purgeCmd.Flags().BoolP("quiet", "q", false, "Print nothing but errors")
+	purgeCmd.Flags().BoolP("interactive", "i", false, "Choose for each file whether to purge its versions")
+	purgeCmd.Flags().Bool("plan", false, "Show the size before and after the purge and its effect on each file, without deleting")
+	addBytesFlag(purgeCmd)
 }
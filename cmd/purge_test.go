@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"1GB", 1000000000, false},
+		{"1GiB", 1 << 30, false},
+		{"500mb", 500000000, false},
+		{"500MiB", 500 << 20, false},
+		{"1.5 TB", 1500000000000, false},
+		{"2kib", 2048, false},
+		{"100B", 100, false},
+		{" 10 KB ", 10000, false},
+		{"", 0, true},
+		{"GB", 0, true},
+		{"100", 0, true},
+		{"10XB", 0, true},
+		{"1..5GB", 0, true},
+		{"-1GB", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90d", 90 * day, false},
+		{"3M", 90 * day, false},
+		{"1y", 365 * day, false},
+		{"2w3d", 17 * day, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"10", 0, true},
+		{"1d2", 0, true},
+		{"1x", 0, true},
+		{"1d1d", 0, true},
+		{"-1d", 0, true},
+		{"1.5d", 0, true},
+		{"1 d", 0, true},
+		{"9999999999y", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatVersionRanges(t *testing.T) {
+	tests := []struct {
+		versions []int
+		want     string
+	}{
+		{nil, ""},
+		{[]int{4}, "v4"},
+		{[]int{1, 2, 3}, "v1-3"},
+		{[]int{1, 2, 4, 6, 7}, "v1-2, v4, v6-7"},
+	}
+
+	for _, tt := range tests {
+		if got := formatVersionRanges(tt.versions); got != tt.want {
+			t.Errorf("formatVersionRanges(%v) = %q, want %q", tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestSelectPurgeCandidates(t *testing.T) {
+	groups := []*purgeFileCandidates{
+		{path: "a.txt", versionIDs: []int64{1, 2}, versions: []int{1, 2}},
+		{path: "b.txt", versionIDs: []int64{3}, versions: []int{1}},
+		{path: "c.txt", versionIDs: []int64{4}, versions: []int{1}},
+		{path: "d.txt", versionIDs: []int64{5, 6}, versions: []int{2, 3}},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []int64
+	}{
+		{"purge and keep", "y\nk\nyes\ns\n", []int64{1, 2, 4}},
+		{"all remaining", "n\na\n", []int64{3, 4, 5, 6}},
+		{"quit keeps the rest", "y\nq\n", []int64{1, 2}},
+		{"invalid answer asks again", "maybe\ny\nk\nk\nk\n", []int64{1, 2}},
+		{"end of input quits", "y\n", []int64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectPurgeCandidates(groups, strings.NewReader(tt.input))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectPurgeCandidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// purgeFileCandidates is the versions of one file a purge would remove,
+// approved or kept together by --interactive
+type purgeFileCandidates struct {
+	path       string
+	versionIDs []int64
+	versions   []int
+	bytes      int64
+}
+
+// groupPurgeCandidates groups the versions to purge by file, in path order
+func groupPurgeCandidates(db *database.DatabaseManager, versionIDs []int64) ([]*purgeFileCandidates, error) {
+	versions, err := db.GetVersionsByIDs(versionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*purgeFileCandidates
+	for _, version := range versions {
+		path := filepath.FromSlash(version.FilePath)
+		if len(groups) == 0 || groups[len(groups)-1].path != path {
+			groups = append(groups, &purgeFileCandidates{path: path})
+		}
+
+		group := groups[len(groups)-1]
+		group.versionIDs = append(group.versionIDs, version.ID)
+		group.versions = append(group.versions, version.VersionNumber)
+		// Baselines have nothing stored
+		if !version.IsBaseline() {
+			group.bytes += version.FileSize
+		}
+	}
+
+	return groups, nil
+}
+
+// selectPurgeCandidates asks, file by file, whether to purge its candidates
+// and returns the version IDs approved. "all" approves the file and every
+// one after it; "quit" keeps the file and every one after it.
+func selectPurgeCandidates(groups []*purgeFileCandidates, in io.Reader) []int64 {
+	reader := bufio.NewReader(in)
+	var approved []int64
+	all := false
+
+	for i, group := range groups {
+		if !all {
+			fmt.Printf("\n[%d/%d] %s: %d versions (%s), %s\n", i+1, len(groups), group.path,
+				len(group.versionIDs), formatVersionRanges(group.versions), formatSize(group.bytes))
+
+			switch promptPurgeFile(reader) {
+			case "quit":
+				return approved
+			case "keep":
+				continue
+			case "all":
+				all = true
+			}
+		}
+
+		approved = append(approved, group.versionIDs...)
+	}
+
+	return approved
+}
+
+// promptPurgeFile asks whether to purge one file's candidates until it gets
+// a valid answer: purge, keep, all or quit. The end of input quits.
+func promptPurgeFile(reader *bufio.Reader) string {
+	for {
+		fmt.Print("Purge these versions? [y]es, [k]eep/[s]kip, [a]ll remaining, [q]uit: ")
+		input, err := reader.ReadString('\n')
+		if err != nil && input == "" {
+			fmt.Println()
+			return "quit"
+		}
+
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "y", "yes":
+			return "purge"
+		case "k", "keep", "s", "skip", "n", "no":
+			return "keep"
+		case "a", "all":
+			return "all"
+		case "q", "quit":
+			return "quit"
+		}
+		fmt.Println("Please answer y, k, s, a or q")
+	}
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// purgePlan is what a purge would do to the size of the project, shown by
+// --plan
+type purgePlan struct {
+	Strategy        string          `json:"strategy"`
+	TargetBytes     *int64          `json:"target_bytes,omitempty"`
+	MeetsTarget     *bool           `json:"meets_target,omitempty"`
+	CurrentBytes    int64           `json:"current_bytes"`
+	CurrentVersions int             `json:"current_versions"`
+	RemovedBytes    int64           `json:"removed_bytes"`
+	RemovedVersions int             `json:"removed_versions"`
+	ResultingBytes  int64           `json:"resulting_bytes"`
+	VersionIDs      []int64         `json:"version_ids"`
+	Files           []purgePlanFile `json:"files"`
+}
+
+// purgePlanFile is the effect of a purge on one file
+type purgePlanFile struct {
+	Path            string `json:"path"`
+	Versions        int    `json:"versions"`
+	RemovedVersions []int  `json:"removed_versions"`
+	Bytes           int64  `json:"bytes"`
+	RemovedBytes    int64  `json:"removed_bytes"`
+}
+
+// buildPurgePlan works out the size of the project under relPrefix before
+// and after removing versionIDs. target is the --max-size limit, or -1.
+func buildPurgePlan(db *database.DatabaseManager, strategy, relPrefix string, target int64, versionIDs []int64) (*purgePlan, error) {
+	plan := &purgePlan{
+		Strategy:        strategy,
+		RemovedVersions: len(versionIDs),
+		VersionIDs:      versionIDs,
+		Files:           []purgePlanFile{},
+	}
+	if plan.VersionIDs == nil {
+		plan.VersionIDs = []int64{}
+	}
+
+	current, err := db.GetStoredSizeByFile(relPrefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*purgePlanFile)
+	for _, summary := range current {
+		plan.CurrentVersions += summary.Versions
+		files[summary.FilePath] = &purgePlanFile{
+			Path:     summary.FilePath,
+			Versions: summary.Versions,
+			Bytes:    summary.Bytes,
+		}
+	}
+
+	// The totals are measured from the store, as --max-size is, so shared
+	// and compressed files count as the space they take
+	plan.CurrentBytes, err = db.GetStoredSize(relPrefix)
+	if err != nil {
+		return nil, err
+	}
+	plan.ResultingBytes, err = db.GetStoredSizeWithout(versionIDs, relPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate reclaimable space: %w", err)
+	}
+	plan.RemovedBytes = plan.CurrentBytes - plan.ResultingBytes
+
+	if target >= 0 {
+		meets := plan.ResultingBytes <= target
+		plan.TargetBytes = &target
+		plan.MeetsTarget = &meets
+	}
+
+	versions, err := db.GetVersionsByIDs(versionIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		file, ok := files[filepath.FromSlash(version.FilePath)]
+		if !ok {
+			continue
+		}
+		file.RemovedVersions = append(file.RemovedVersions, version.VersionNumber)
+		if !version.IsBaseline() {
+			file.RemovedBytes += version.FileSize
+		}
+	}
+
+	// Files losing versions, those losing the most space first
+	summaries, err := db.GetVersionsSizeByFile(versionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate reclaimable space per file: %w", err)
+	}
+	for _, summary := range summaries {
+		if file, ok := files[summary.FilePath]; ok {
+			plan.Files = append(plan.Files, *file)
+		}
+	}
+
+	return plan, nil
+}
+
+// displayPurgePlan prints a purge plan with the versions removed from each file
+func displayPurgePlan(plan *purgePlan) error {
+	fmt.Printf("Purge plan (%s, preserving tagged versions)\n\n", plan.Strategy)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Current size:\t%s\t(%d versions)\n", formatSize(plan.CurrentBytes), plan.CurrentVersions)
+	fmt.Fprintf(w, "To remove:\t%s\t(%d versions)\n", formatSize(plan.RemovedBytes), plan.RemovedVersions)
+	fmt.Fprintf(w, "After purge:\t%s\t(%d versions)\n", formatSize(plan.ResultingBytes), plan.CurrentVersions-plan.RemovedVersions)
+	if plan.TargetBytes != nil {
+		if *plan.MeetsTarget {
+			fmt.Fprintf(w, "Target:\t%s\tmet\n", formatSize(*plan.TargetBytes))
+		} else {
+			fmt.Fprintf(w, "Target:\t%s\tnot met - tagged versions and the latest version of each file are kept\n", formatSize(*plan.TargetBytes))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(plan.Files) == 0 {
+		fmt.Println("\nNo versions to purge.")
+		return nil
+	}
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nFILE\tVERSIONS\tSIZE\tREMOVED")
+	for _, file := range plan.Files {
+		fmt.Fprintf(w, "%s\t%d → %d\t%s → %s\t%s\n", file.Path,
+			file.Versions, file.Versions-len(file.RemovedVersions),
+			formatSize(file.Bytes), formatSize(file.Bytes-file.RemovedBytes),
+			formatVersionRanges(file.RemovedVersions))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nPlan only - no files will be deleted")
+	return nil
+}
+
+// formatVersionRanges lists sorted version numbers, collapsing runs such as
+// 1, 2, 3 into 1-3
+func formatVersionRanges(versions []int) string {
+	result := ""
+	for i := 0; i < len(versions); {
+		j := i
+		for j+1 < len(versions) && versions[j+1] == versions[j]+1 {
+			j++
+		}
+		if result != "" {
+			result += ", "
+		}
+		result += "v" + strconv.Itoa(versions[i])
+		if j > i {
+			result += "-" + strconv.Itoa(versions[j])
+		}
+		i = j + 1
+	}
+	return result
+}
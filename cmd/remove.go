@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
 	"github.com/spf13/cobra"
 )
 
@@ -37,7 +38,7 @@ Examples:
 			return
 		}
 
-		err = sendIPCMessage("remove", cwd)
+		err = sendIPCMessage(protocol.ActionRemove, cwd)
 		if err != nil {
 			return
 		}
@@ -45,20 +46,18 @@ Examples:
 		targetDir, err := determineTargetDirectory(args)
 		app.Logger.WithField("directory", targetDir).Debug("Target directory")
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
 		if err := validateDirectory(targetDir); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 
 		absTargetDir, err := filepath.Abs(targetDir)
 		app.Logger.WithField("abs_directory", absTargetDir).Debug("Absolute target directory")
 		if err != nil {
 			fmt.Printf("Error getting absolute path: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 
 		force, _ := cmd.Flags().GetBool("force")
@@ -69,7 +68,7 @@ Examples:
 			response, err := reader.ReadString('\n')
 			if err != nil {
 				fmt.Printf("Error reading input: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 
 			response = strings.TrimSpace(strings.ToLower(response))
@@ -101,4 +100,5 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	removeCmd.Flags().BoolP("force", "f", false, "Don't confirm deleting of .rewind")
+	addInstanceFlag(removeCmd)
 }
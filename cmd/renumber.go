@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renumberDryRunFlag bool
+	renumberForceFlag  bool
+)
+
+// renumberCmd represents the renumber command
+var renumberCmd = &cobra.Command{
+	Use:   "renumber <file_path>",
+	Short: "Close gaps in a file's version numbers",
+	Long: `Renumber the versions of a file so its version numbers have no gaps,
+such as those left by purging versions from the middle of its history
+(1, 2, 5, 6 becomes 1, 2, 3, 4).
+
+The first version keeps its number and the order of versions is unchanged.
+Stored copies, timestamps and tags stay with their versions; only the numbers
+used to refer to them change. 'rewind doctor' lists the files with gaps.
+
+Examples:
+  rewind renumber src/main.go             # Show the changes and ask before renumbering
+  rewind renumber src/main.go --dry-run   # Only show the changes`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runRenumber(args[0]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renumberCmd)
+	renumberCmd.Flags().BoolVar(&renumberDryRunFlag, "dry-run", false, "Show the new version numbers without changing anything")
+	renumberCmd.Flags().BoolVarP(&renumberForceFlag, "force", "f", false, "Renumber without asking for confirmation")
+}
+
+func runRenumber(filePath string) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(absPath)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	plan, err := db.RenumberVersions(absPath, true)
+	if err != nil {
+		return err
+	}
+	if len(plan.Changes) == 0 {
+		fmt.Printf("✓ Version numbers of %s have no gaps\n", plan.FilePath)
+		return nil
+	}
+
+	fmt.Printf("Renumbering %s\n", plan.FilePath)
+	for _, change := range plan.Changes {
+		fmt.Printf("  v%d → v%d\n", change.From, change.To)
+	}
+
+	if renumberDryRunFlag {
+		fmt.Printf("\nDry run: %d versions would be renumbered\n", len(plan.Changes))
+		return nil
+	}
+
+	if !renumberForceFlag {
+		fmt.Print("\nContinue with renumber? (y/N): ")
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response != "y" && response != "yes" {
+			fmt.Println("Renumber cancelled")
+			return nil
+		}
+	}
+
+	result, err := db.RenumberVersions(absPath, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Renumbered %d versions of %s\n", len(result.Changes), result.FilePath)
+	return nil
+}
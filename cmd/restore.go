@@ -10,32 +10,48 @@ import (
 	"strings"
 	"text/tabwriter"
 
-	"github.com/dustin/go-humanize"
+	"github.com/davenicholson-xyz/rewind/app"
 	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var confirmFlag bool
+var restoreForceFlag bool
+var restoreUnderFlag string
+var restoreDryRunFlag bool
 
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
-	Use:   "restore [file_path]",
+	Use:   "restore [file_path | --under <dir>]",
 	Short: "Restore deleted files",
 	Long: `Restore files that have been deleted and are tracked in the database.
 
 When called without arguments, lists all deleted files for selection.
 When called with a file path, restores that specific deleted file.
+With --under, restores every deleted file below a directory at once,
+recreating the directory tree (e.g. after an accidental rm -rf).
 
 Examples:
   rewind restore                         # List all deleted files for selection
   rewind restore src/deleted.go          # Restore specific deleted file
   rewind restore --confirm               # List deleted files with confirmation prompts
-  rewind restore src/deleted.go --confirm # Restore with confirmation`,
+  rewind restore src/deleted.go --confirm # Restore with confirmation
+  rewind restore --under src/old         # Restore everything deleted under src/old
+  rewind restore --under src/old --dry-run # Show what would be restored
+
+Stored versions are checked against their recorded hash before being
+restored; use --force to restore a version that fails the check.
+
+With --dry-run, nothing is written: each file that would be restored is
+listed with the version it would come from, that version's size, when the
+file was deleted, and whether a file now at that path would be overwritten
+(or, with --under, skipped).`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := runRestore(args); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			exitWithError(cmd, err)
 		}
 	},
 }
@@ -43,6 +59,10 @@ Examples:
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 	restoreCmd.Flags().BoolVarP(&confirmFlag, "confirm", "c", false, "Prompt for confirmation before restoring files")
+	restoreCmd.Flags().StringVarP(&restoreUnderFlag, "under", "u", "", "Restore all deleted files under this directory")
+	restoreCmd.Flags().BoolVar(&restoreForceFlag, "force", false, "Restore even if the stored version fails checksum verification")
+	restoreCmd.Flags().BoolVarP(&restoreDryRunFlag, "dry-run", "n", false, "Show what would be restored without changing anything")
+	addBytesFlag(restoreCmd)
 }
 
 func runRestore(args []string) error {
@@ -62,6 +82,13 @@ func runRestore(args []string) error {
 	}
 	defer db.Close()
 
+	if restoreUnderFlag != "" {
+		if len(args) == 1 {
+			return fmt.Errorf("cannot combine a file path with --under")
+		}
+		return restoreUnder(db, wd, restoreUnderFlag)
+	}
+
 	// If file path provided, restore that specific file
 	if len(args) == 1 {
 		return restoreSpecificFile(db, args[0])
@@ -78,6 +105,10 @@ func restoreSpecificFile(db *database.DatabaseManager, filePath string) error {
 		return fmt.Errorf("failed to resolve absolute path: %w", err)
 	}
 
+	if restoreDryRunFlag {
+		return previewRestoreFile(db, absPath)
+	}
+
 	// Restore the file in database
 	fileVersion, err := db.RestoreFile(absPath)
 	if err != nil {
@@ -85,14 +116,149 @@ func restoreSpecificFile(db *database.DatabaseManager, filePath string) error {
 	}
 
 	// Copy the file from storage back to original location
-	if err := copyFromStorage(fileVersion, absPath); err != nil {
+	if err := copyFromStorage(db, fileVersion, absPath); err != nil {
+		// Keep the file listed as deleted so the restore can be retried
+		db.MarkFileDeleted(absPath)
 		return fmt.Errorf("failed to copy file from storage: %w", err)
 	}
+	restoreOwnership(db, fileVersion, absPath)
+	restoreXattrs(db, fileVersion, absPath)
+	recordAudit(app.AuditRestore, absPath, fileVersion)
 
 	fmt.Printf("Successfully restored: %s (version %d)\n", filePath, fileVersion.VersionNumber)
 	return nil
 }
 
+// restoreUnder restores every deleted file below dir, recreating the
+// directory tree, including directories left empty when track_directories is
+// enabled. Files that fail are reported and the rest still restored.
+func restoreUnder(db *database.DatabaseManager, projectRoot, dir string) error {
+	prefix, err := subtreePrefix(projectRoot, dir)
+	if err != nil {
+		return err
+	}
+
+	deletedFiles, err := db.GetDeletedFilesUnder(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to get deleted files: %w", err)
+	}
+
+	deletedDirs, err := db.GetDeletedDirectoriesUnder(prefix)
+	if err != nil {
+		return fmt.Errorf("failed to get deleted directories: %w", err)
+	}
+
+	if len(deletedFiles) == 0 && len(deletedDirs) == 0 {
+		fmt.Printf("No deleted files found under %s.\n", dir)
+		return nil
+	}
+
+	var totalSize int64
+	for _, fv := range deletedFiles {
+		totalSize += fv.FileSize
+	}
+
+	fmt.Printf("Found %d deleted files under %s (%s)\n", len(deletedFiles), dir, formatSize(totalSize))
+
+	if restoreDryRunFlag {
+		var plans []restorePlan
+		for _, fv := range deletedFiles {
+			plans = append(plans, restorePlan{version: fv, target: filepath.Join(projectRoot, fv.FilePath)})
+		}
+		displayRestorePlan(plans, false)
+		if len(deletedDirs) > 0 {
+			fmt.Printf("Would recreate %d tracked directories\n", len(deletedDirs))
+		}
+		fmt.Println("Dry run: nothing was restored.")
+		return nil
+	}
+
+	if confirmFlag {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "File Path\tVersion\tDeleted\tSize")
+		fmt.Fprintln(w, "---------\t-------\t-------\t----")
+		for _, fv := range deletedFiles {
+			fmt.Fprintf(w, "%s\tv%d\t%s\t%s\n",
+				fv.FilePath,
+				fv.VersionNumber,
+				fv.Timestamp.Format("2006-01-02 15:04:05"),
+				formatSize(fv.FileSize))
+		}
+		w.Flush()
+
+		fmt.Printf("\nRestore all %d files? [y/N]: ", len(deletedFiles))
+		reader := bufio.NewReader(os.Stdin)
+		confirm, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read confirmation: %w", err)
+		}
+
+		if strings.ToLower(strings.TrimSpace(confirm)) != "y" {
+			fmt.Println("Restore cancelled.")
+			return nil
+		}
+	}
+
+	var failures []string
+
+	// Recreate tracked directories first so empty ones come back too
+	recreated := 0
+	for _, relDir := range deletedDirs {
+		dirPath := filepath.Join(projectRoot, relDir)
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			failures = append(failures, fmt.Sprintf("%s/: %v", relDir, err))
+			continue
+		}
+		recreated++
+		if err := db.RecordDirectory(dirPath); err != nil {
+			failures = append(failures, fmt.Sprintf("%s/: %v", relDir, err))
+		}
+	}
+
+	restored := 0
+	for _, fv := range deletedFiles {
+		originalPath := filepath.Join(projectRoot, fv.FilePath)
+
+		// Never overwrite a file that has been recreated since
+		if _, err := os.Lstat(originalPath); err == nil {
+			failures = append(failures, fmt.Sprintf("%s: file already exists", fv.FilePath))
+			continue
+		}
+
+		fileVersion, err := db.RestoreFile(originalPath)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", fv.FilePath, err))
+			continue
+		}
+
+		if err := copyFromStorage(db, fileVersion, originalPath); err != nil {
+			// Keep the file listed as deleted so the restore can be retried
+			db.MarkFileDeleted(originalPath)
+			failures = append(failures, fmt.Sprintf("%s: %v", fv.FilePath, err))
+			continue
+		}
+		restoreOwnership(db, fileVersion, originalPath)
+		restoreXattrs(db, fileVersion, originalPath)
+		recordAudit(app.AuditRestore, originalPath, fileVersion)
+
+		restored++
+	}
+
+	fmt.Printf("Restored %d of %d files under %s\n", restored, len(deletedFiles), dir)
+	if len(deletedDirs) > 0 {
+		fmt.Printf("Recreated %d of %d tracked directories\n", recreated, len(deletedDirs))
+	}
+
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Printf("  ✗ %s\n", failure)
+		}
+		return fmt.Errorf("%d files could not be restored", len(failures))
+	}
+
+	return nil
+}
+
 func listAndSelectDeletedFile(db *database.DatabaseManager) error {
 	// Get all deleted files
 	deletedFiles, err := db.GetAllDeletedFiles()
@@ -116,7 +282,7 @@ func listAndSelectDeletedFile(db *database.DatabaseManager) error {
 			fv.FilePath,
 			fv.VersionNumber,
 			fv.Timestamp.Format("2006-01-02 15:04:05"),
-			humanize.Bytes(uint64(fv.FileSize)))
+			formatSize(fv.FileSize))
 	}
 	w.Flush()
 
@@ -143,6 +309,16 @@ func listAndSelectDeletedFile(db *database.DatabaseManager) error {
 
 	selectedFile := deletedFiles[selection-1]
 	
+	if restoreDryRunFlag {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		displayRestorePlan([]restorePlan{{version: selectedFile, target: filepath.Join(wd, selectedFile.FilePath)}}, true)
+		fmt.Println("Dry run: nothing was restored.")
+		return nil
+	}
+
 	// Confirm restoration only if --confirm flag is set
 	if confirmFlag {
 		fmt.Printf("Restore %s (version %d)? [y/N]: ", selectedFile.FilePath, selectedFile.VersionNumber)
@@ -173,15 +349,95 @@ func listAndSelectDeletedFile(db *database.DatabaseManager) error {
 	}
 
 	// Copy the file from storage back to original location
-	if err := copyFromStorage(fileVersion, originalPath); err != nil {
+	if err := copyFromStorage(db, fileVersion, originalPath); err != nil {
+		// Keep the file listed as deleted so the restore can be retried
+		db.MarkFileDeleted(originalPath)
 		return fmt.Errorf("failed to copy file from storage: %w", err)
 	}
+	restoreOwnership(db, fileVersion, originalPath)
+	restoreXattrs(db, fileVersion, originalPath)
+	recordAudit(app.AuditRestore, originalPath, fileVersion)
 
 	fmt.Printf("Successfully restored: %s (version %d)\n", selectedFile.FilePath, fileVersion.VersionNumber)
 	return nil
 }
 
-func copyFromStorage(fv *database.FileVersion, targetPath string) error {
+// restorePlan is a deleted file and the path it would be restored to
+type restorePlan struct {
+	version *database.FileVersion
+	target  string
+}
+
+// previewRestoreFile reports what restoring the deleted file at absPath
+// would do, without changing anything
+func previewRestoreFile(db *database.DatabaseManager, absPath string) error {
+	fileVersion, err := db.GetLatestFileVersion(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to get latest file version: %w", err)
+	}
+	if fileVersion == nil {
+		return withExitCode(exitNotFound, fmt.Errorf("no versions found for file: %s", absPath))
+	}
+	if !fileVersion.Deleted {
+		return fmt.Errorf("file is not deleted: %s", fileVersion.FilePath)
+	}
+
+	displayRestorePlan([]restorePlan{{version: fileVersion, target: absPath}}, true)
+	fmt.Println("Dry run: nothing was restored.")
+	return nil
+}
+
+// displayRestorePlan lists the files a restore would write. overwrite says
+// whether a file already at the target would be replaced or left alone.
+func displayRestorePlan(plans []restorePlan, overwrite bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Target\tVersion\tDeleted\tSize\tExisting File")
+	fmt.Fprintln(w, "------\t-------\t-------\t----\t-------------")
+	for _, plan := range plans {
+		existing := "none"
+		if _, err := os.Lstat(plan.target); err == nil {
+			existing = "would be skipped"
+			if overwrite {
+				existing = "would be overwritten"
+			}
+		}
+		fmt.Fprintf(w, "%s\tv%d\t%s\t%s\t%s\n",
+			plan.target,
+			plan.version.VersionNumber,
+			plan.version.Timestamp.Format("2006-01-02 15:04:05"),
+			formatSize(plan.version.FileSize),
+			existing)
+	}
+	w.Flush()
+}
+
+// copyFromStorage writes a stored version to targetPath, replacing any file
+// already there even if it is read-only
+func copyFromStorage(db *database.DatabaseManager, fv *database.FileVersion, targetPath string) error {
+	// Lock the file so a running daemon doesn't capture the restored file as
+	// a new version. The daemon doesn't watch files in the global store.
+	if !db.IsGlobal() {
+		lock, err := watcher.AcquireFileLock(db.RootDir(), targetPath)
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+		defer func() {
+			if err := lock.Release(); err != nil {
+				app.Logger.WithError(err).Warn("Failed to release file lock")
+			}
+		}()
+	}
+
+	return withWritableFile(targetPath, func() error {
+		return writeFromStorage(fv, targetPath)
+	})
+}
+
+func writeFromStorage(fv *database.FileVersion, targetPath string) error {
+	if fv.IsBaseline() {
+		return fmt.Errorf("version %d of %s is a baseline with no stored content", fv.VersionNumber, fv.FilePath)
+	}
+
 	// Ensure target directory exists
 	targetDir := filepath.Dir(targetPath)
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
@@ -195,6 +451,24 @@ func copyFromStorage(fv *database.FileVersion, targetPath string) error {
 	}
 	
 	storagePath := filepath.Join(wd, ".rewind", "versions", fv.StoragePath)
+
+	// Deltas and compressed versions are checked against their hash as they
+	// are read
+	if fv.IsDelta() || fv.IsCompressed() {
+		content, err := readVersionContent(wd, fv)
+		if err != nil {
+			return err
+		}
+		return writeFileContent(targetPath, content)
+	}
+
+	// Make sure the stored version is not corrupted
+	if !restoreForceFlag {
+		if err := verifyStoredVersion(storagePath, fv); err != nil {
+			return err
+		}
+	}
+
 	srcFile, err := os.Open(storagePath)
 	if err != nil {
 		return fmt.Errorf("failed to open storage file: %w", err)
@@ -214,5 +488,34 @@ func copyFromStorage(fv *database.FileVersion, targetPath string) error {
 		return fmt.Errorf("failed to copy file content: %w", err)
 	}
 
+	// Sync to ensure data is written to disk
+	if viper.GetBool("fsync") {
+		if err := dstFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync target file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeFileContent writes rebuilt version content to targetPath
+func writeFileContent(targetPath string, content []byte) error {
+	dstFile, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("failed to create target file: %w", err)
+	}
+	defer dstFile.Close()
+
+	if _, err := dstFile.Write(content); err != nil {
+		return fmt.Errorf("failed to write file content: %w", err)
+	}
+
+	// Sync to ensure data is written to disk
+	if viper.GetBool("fsync") {
+		if err := dstFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync target file: %w", err)
+		}
+	}
+
 	return nil
 }
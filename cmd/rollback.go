@@ -9,14 +9,18 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/davenicholson-xyz/rewind/app"
 	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // rollbackCmd represents the rollback command
@@ -32,20 +36,69 @@ When called with --time-ago but no file path, rolls back ALL tracked files to th
 
 Examples:
   rewind rollback src/main.go                      # Show all versions
+  rewind rollback src/main.go --limit 10           # Show the 10 most recent versions
+  rewind rollback src/main.go --since-version 40   # Show versions 40 and newer
   rewind rollback src/main.go --version 3          # Rollback to version 3
   rewind rollback src/main.go --time-ago 2h        # Rollback to last version before 2 hours ago
   rewind rollback src/main.go --time-ago 30m       # Rollback to last version before 30 minutes ago
   rewind rollback --time-ago 2h                    # Rollback ALL files to 2 hours ago
-  rewind rollback src/main.go --version 3 --confirm # Rollback with confirmation prompt`,
+  rewind rollback src/main.go --version 3 --confirm # Rollback with confirmation prompt
+  rewind rollback src/main.go --follow-renames     # Include history from the file's former names
+  rewind rollback src/main.go --version 3 --lines 40:80 --confirm # Rollback lines 40-80 only
+  rewind rollback src/main.go --version 3 --preview-only | git apply  # Rollback by applying a patch
+  rewind rollback 'src/*.go' --time-ago 2h         # Rollback every matching file
+  rewind rollback --global                         # List every file's latest and recent versions
+  rewind rollback --global --op created --limit 20 # The 20 most recently created files
+
+--format prints each version in the listing with a Go text/template instead of
+the table, e.g. --format '{{.VersionNumber}} {{date .Timestamp}} {{.FileHash}}'.
+The template is run against the version's fields: FilePath, VersionNumber,
+Timestamp, FileHash, FileSize, StorageType and EventOp. The functions size,
+ago, date and short format a size, a time as "3 hours ago", a time as a date
+and a hash as its first 8 characters.
+
+A quoted glob pattern runs the rollback (or lists the versions) of each
+matching file in turn. Patterns are matched against the working tree, and
+against tracked files when nothing on disk matches.
+
+Before a rollback, the stored version is re-hashed and compared with the hash
+recorded when it was captured. A mismatch means the stored copy is corrupted
+and the rollback is aborted; --force restores it anyway.
+
+With --global and no file path, every file in the project is listed with its
+latest version and a few recent ones, most recently changed first. --op limits
+the listing to versions captured by one kind of event (created, modified,
+renamed, rollback or deleted) and --limit to the first N files.
+
+With --lines start:end, only that range of the version's lines is rolled back
+and the rest of the working file is kept. The range is placed by context: the
+lines replaced in the working file are those between the nearest lines before
+and after the range that are the same in the version and the working file, as
+matched by the same diff that rewind diff shows. If a side of the range has
+no such line, other than at the start or end of the file, the rollback is
+refused. The change is shown for review, so --confirm is required.
+
+--preview-only prints the change a rollback to the chosen version would make
+as a unified patch from the working file to that version, and leaves the file
+alone. Paths in the patch are relative to the project root with a/ and b/
+prefixes, so it applies with 'git apply' or 'patch -p1' from there. With
+--json the patch is wrapped in an object with the file, version and both
+hashes. A file already matching the version gives an empty patch.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		var filePath string
 		if len(args) > 0 {
 			filePath = args[0]
 		}
-		if err := runRollback(filePath); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+
+		var err error
+		if filePath != "" && isFileGlob(filePath) {
+			err = runRollbackGlob(filePath)
+		} else {
+			err = runRollback(filePath)
+		}
+		if err != nil {
+			exitWithError(cmd, err)
 		}
 	},
 }
@@ -56,6 +109,15 @@ var timeAgoFlag string
 var csvFlag bool
 var jsonFlag bool
 var rollbackConfirmFlag bool
+var sinceVersionFlag int
+var limitFlag int
+var allVersionsFlag bool
+var rollbackForceFlag bool
+var followRenamesFlag bool
+var globalFlag bool
+var opFlag string
+var rollbackLinesFlag string
+var rollbackPreviewOnlyFlag bool
 
 func init() {
 	rootCmd.AddCommand(rollbackCmd)
@@ -66,9 +128,31 @@ func init() {
 	rollbackCmd.Flags().BoolVarP(&csvFlag, "csv", "c", false, "List file versions as CSV")
 	rollbackCmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "List file versions as json")
 	rollbackCmd.Flags().BoolVarP(&rollbackConfirmFlag, "confirm", "f", false, "Prompt for confirmation before rollback")
+	rollbackCmd.Flags().IntVar(&sinceVersionFlag, "since-version", 0, "Only list versions with this version number or newer")
+	rollbackCmd.Flags().IntVar(&limitFlag, "limit", 0, "Only list the most recent N versions")
+	rollbackCmd.Flags().BoolVar(&allVersionsFlag, "all", false, "List all versions, ignoring --since-version and --limit")
+	rollbackCmd.Flags().BoolVar(&rollbackForceFlag, "force", false, "Rollback even if the stored version fails checksum verification")
+	rollbackCmd.Flags().BoolVar(&followRenamesFlag, "follow-renames", false, "List versions from before the file was renamed too")
+	rollbackCmd.Flags().BoolVarP(&globalFlag, "global", "g", false, "List the latest and recent versions of every file in the project")
+	rollbackCmd.Flags().StringVar(&opFlag, "op", "", "With --global, only list versions from created, modified, renamed, rollback or deleted events")
+	rollbackCmd.Flags().StringVar(&rollbackLinesFlag, "lines", "", "Only roll back this range of the version's lines (start:end), keeping other edits; requires --confirm")
+	addBytesFlag(rollbackCmd)
+	addFormatFlag(rollbackCmd, "version")
+	addJSONLFlag(rollbackCmd, "version")
+	rollbackCmd.Flags().BoolVar(&rollbackPreviewOnlyFlag, "preview-only", false, "Print the rollback as a unified patch (or JSON with --json) without applying it")
 }
 
 func runRollback(filePath string) error {
+	if globalFlag {
+		if filePath != "" || rollbackPreviewOnlyFlag || versionFlag > 0 || tagFlag != "" || timeAgoFlag != "" || csvFlag || jsonlFlag || followRenamesFlag || formatFlag != "" {
+			return fmt.Errorf("--global lists every file and cannot be combined with a file path, --version, --tag, --time-ago, --csv, --jsonl, --format, --preview-only or --follow-renames")
+		}
+		return displayGlobalVersions()
+	}
+	if opFlag != "" {
+		return fmt.Errorf("--op only applies to --global")
+	}
+
 	// Handle filesystem-wide rollback when no file path is provided
 	if filePath == "" {
 		if timeAgoFlag != "" {
@@ -83,20 +167,10 @@ func runRollback(filePath string) error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	// Find the rewind project root
-	rewindRoot, err := findRewindRoot(absPath)
+	// Connect to the project's database, or the global store
+	db, err := openFileDatabase(absPath)
 	if err != nil {
-		return fmt.Errorf("not in a rewind project: %w", err)
-	}
-
-	// Connect to database
-	db, err := database.NewDatabaseManager(rewindRoot)
-	if err != nil {
-		return fmt.Errorf("failed to create database manager: %w", err)
-	}
-
-	if err := db.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -114,6 +188,22 @@ func runRollback(filePath string) error {
 	if flagCount > 1 {
 		return fmt.Errorf("cannot specify multiple rollback flags (--version, --tag, --time-ago)")
 	}
+	if followRenamesFlag && (flagCount > 0 || jsonFlag || jsonlFlag || csvFlag) {
+		return fmt.Errorf("--follow-renames only applies to the version table; roll back a former name's version using that name")
+	}
+
+	// If preview-only is set, print the change as a patch instead
+	if rollbackPreviewOnlyFlag {
+		if flagCount == 0 || rollbackLinesFlag != "" || rollbackConfirmFlag {
+			return fmt.Errorf("--preview-only needs the version to compare with, given with --version, --tag or --time-ago, and cannot be combined with --lines or --confirm")
+		}
+		return previewRollbackPatch(db, absPath)
+	}
+
+	// If lines flag is set, roll back just those lines
+	if rollbackLinesFlag != "" {
+		return runLineRollback(db, absPath)
+	}
 
 	// If version flag is set, perform rollback
 	if versionFlag > 0 {
@@ -134,6 +224,45 @@ func runRollback(filePath string) error {
 	return displayFileVersions(db, absPath)
 }
 
+// runLineRollback checks the flags given with --lines and rolls the range
+// back to the version chosen with --version or --tag
+func runLineRollback(db *database.DatabaseManager, absPath string) error {
+	if timeAgoFlag != "" || (versionFlag == 0 && tagFlag == "") {
+		return fmt.Errorf("--lines needs the version to take the lines from, given with --version or --tag")
+	}
+	if !rollbackConfirmFlag {
+		return fmt.Errorf("--lines splices part of an old version into the current file; review the change with --confirm")
+	}
+	start, end, err := parseLineRange(rollbackLinesFlag)
+	if err != nil {
+		return err
+	}
+
+	targetVersion := versionFlag
+	if tagFlag != "" {
+		version, err := db.GetVersionByTag(absPath, tagFlag)
+		if err != nil {
+			return err
+		}
+		targetVersion = version.VersionNumber
+	}
+	return performLineRollback(db, absPath, targetVersion, start, end)
+}
+
+// runRollbackGlob rolls back, or lists the versions of, every file matching
+// a glob pattern
+func runRollbackGlob(pattern string) error {
+	if jsonFlag || jsonlFlag || csvFlag || rollbackPreviewOnlyFlag {
+		return fmt.Errorf("--json, --jsonl, --csv and --preview-only cannot be used with a glob pattern")
+	}
+
+	action := "Rolled back"
+	if versionFlag == 0 && tagFlag == "" && timeAgoFlag == "" {
+		action = "Listed"
+	}
+	return runForEachFile(pattern, action, true, runRollback)
+}
+
 // parseTimeAgo parses a time duration string like "2h", "30m", "1d" and returns a duration
 func parseTimeAgo(timeAgoStr string) (time.Duration, error) {
 	// Regular expression to match time patterns
@@ -276,10 +405,27 @@ func performFilesystemRollbackByTimeAgo(timeAgoStr string) error {
 
 // performRollbackByTimeAgo finds the last version before the specified time ago and rolls back to it
 func performRollbackByTimeAgo(db *database.DatabaseManager, filePath string, timeAgoStr string) error {
+	targetVersion, err := findVersionBefore(db, filePath, timeAgoStr)
+	if err != nil {
+		return err
+	}
+	
+	fmt.Printf("Found version %d from %s (before %s ago)\n", 
+		targetVersion.VersionNumber, 
+		humanize.Time(targetVersion.Timestamp), 
+		timeAgoStr)
+	
+	// Delegate to regular rollback with the version number
+	return performRollback(db, filePath, targetVersion.VersionNumber)
+}
+
+// findVersionBefore returns the last version of a file captured before the
+// --time-ago duration
+func findVersionBefore(db *database.DatabaseManager, filePath string, timeAgoStr string) (*database.FileVersion, error) {
 	// Parse the time duration
 	duration, err := parseTimeAgo(timeAgoStr)
 	if err != nil {
-		return fmt.Errorf("failed to parse time: %w", err)
+		return nil, fmt.Errorf("failed to parse time: %w", err)
 	}
 	
 	// Calculate the target time
@@ -288,11 +434,11 @@ func performRollbackByTimeAgo(db *database.DatabaseManager, filePath string, tim
 	// Get all versions for the file
 	versions, err := db.GetFileVersions(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to get file versions: %w", err)
+		return nil, fmt.Errorf("failed to get file versions: %w", err)
 	}
 	
 	if len(versions) == 0 {
-		return fmt.Errorf("no versions found for file: %s", filePath)
+		return nil, withExitCode(exitNotFound, fmt.Errorf("no versions found for file: %s", filePath))
 	}
 	
 	// Find the last version before the target time
@@ -308,16 +454,10 @@ func performRollbackByTimeAgo(db *database.DatabaseManager, filePath string, tim
 	}
 	
 	if targetVersion == nil {
-		return fmt.Errorf("no version found before %s ago (%s)", timeAgoStr, targetTime.Format("2006-01-02 15:04:05"))
+		return nil, withExitCode(exitNotFound, fmt.Errorf("no version found before %s ago (%s)", timeAgoStr, targetTime.Format("2006-01-02 15:04:05")))
 	}
 	
-	fmt.Printf("Found version %d from %s (before %s ago)\n", 
-		targetVersion.VersionNumber, 
-		humanize.Time(targetVersion.Timestamp), 
-		timeAgoStr)
-	
-	// Delegate to regular rollback with the version number
-	return performRollback(db, filePath, targetVersion.VersionNumber)
+	return targetVersion, nil
 }
 
 func findRewindRoot(startPath string) (string, error) {
@@ -330,6 +470,11 @@ func findRewindRoot(startPath string) (string, error) {
 		}
 	}
 
+	// --root names the project outright rather than leaving it to be found
+	if rootDirFlag != "" {
+		return explicitRewindRoot(currentPath)
+	}
+
 	// If it's a file, start from its directory
 	if info, err := os.Stat(currentPath); err == nil && !info.IsDir() {
 		currentPath = filepath.Dir(currentPath)
@@ -348,11 +493,30 @@ func findRewindRoot(startPath string) (string, error) {
 		currentPath = parent
 	}
 
-	return "", fmt.Errorf("no .rewind directory found")
+	return "", withExitCode(exitNotInitialized, fmt.Errorf("no .rewind directory found"))
+}
+
+// explicitRewindRoot returns the project given with --root, once absPath is
+// known to be inside it
+func explicitRewindRoot(absPath string) (string, error) {
+	rel, err := filepath.Rel(rootDirFlag, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the project %s", absPath, rootDirFlag)
+	}
+
+	if info, err := os.Stat(filepath.Join(rootDirFlag, ".rewind")); err != nil || !info.IsDir() {
+		return "", withExitCode(exitNotInitialized, fmt.Errorf("no .rewind directory found in %s", rootDirFlag))
+	}
+	return rootDirFlag, nil
 }
 
 func displayFileVersions(db *database.DatabaseManager, filePath string) error {
-	versions, err := db.GetFileVersions(filePath)
+	getVersions := db.GetFileVersions
+	if followRenamesFlag {
+		getVersions = db.GetFileVersionsFollowingRenames
+	}
+
+	versions, err := getVersions(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get file versions: %w", err)
 	}
@@ -375,7 +539,26 @@ func displayFileVersions(db *database.DatabaseManager, filePath string) error {
 		return nil
 	}
 
+	if !allVersionsFlag {
+		activeVersions = filterVersionRange(activeVersions, sinceVersionFlag, limitFlag)
+		if len(activeVersions) == 0 {
+			fmt.Printf("No versions found for file %s since version %d\n", filePath, sinceVersionFlag)
+			return nil
+		}
+	}
+
 	// Display in requested format
+	if formatFlag != "" {
+		if csvFlag || jsonFlag || jsonlFlag {
+			return fmt.Errorf("--format cannot be combined with --csv, --json or --jsonl")
+		}
+		rows := make([]any, len(activeVersions))
+		for i, version := range activeVersions {
+			rows[i] = version
+		}
+		return printFormatted(rows)
+	}
+
 	if csvFlag {
 		return displayAsCSV(activeVersions, filePath)
 	}
@@ -384,10 +567,35 @@ func displayFileVersions(db *database.DatabaseManager, filePath string) error {
 		return displayAsJSON(activeVersions, filePath)
 	}
 
+	if jsonlFlag {
+		return displayAsJSONL(activeVersions, filePath)
+	}
+
 	// Default to table format
 	return displayAsTable(activeVersions, filePath)
 }
 
+// filterVersionRange keeps versions numbered minVersion or newer, capped at
+// the newest limit of them. Versions must be ordered newest first; zero
+// disables either filter.
+func filterVersionRange(versions []*database.FileVersion, minVersion, limit int) []*database.FileVersion {
+	filtered := versions
+	if minVersion > 0 {
+		filtered = make([]*database.FileVersion, 0, len(versions))
+		for _, version := range versions {
+			if version.VersionNumber >= minVersion {
+				filtered = append(filtered, version)
+			}
+		}
+	}
+
+	if limit > 0 && len(filtered) > limit {
+		filtered = filtered[:limit]
+	}
+
+	return filtered
+}
+
 func displayAsTable(versions []*database.FileVersion, filePath string) error {
 	fmt.Printf("File versions for: %s\n\n", filePath)
 
@@ -403,49 +611,59 @@ func displayAsTable(versions []*database.FileVersion, filePath string) error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	rewindRoot, err := findRewindRoot(absPath)
+	db, err := openFileDatabase(absPath)
 	if err != nil {
-		return fmt.Errorf("not in a rewind project: %w", err)
-	}
-
-	db, err := database.NewDatabaseManager(rewindRoot)
-	if err != nil {
-		return fmt.Errorf("failed to create database manager: %w", err)
-	}
-
-	if err := db.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
 	defer db.Close()
 
-	// Get all tags for this file
-	allTags, err := db.GetAllTagsForFile(absPath)
+	// Get all tags for this file, and for any former names when following renames
+	rewindRoot := db.RootDir()
+	relPath, err := filepath.Rel(rewindRoot, absPath)
 	if err != nil {
-		return fmt.Errorf("failed to get tags: %w", err)
+		relPath = absPath
+	}
+	tagsByPath := make(map[string]map[int][]*database.Tag)
+	formerNames := false
+	for _, version := range versions {
+		if _, loaded := tagsByPath[version.FilePath]; loaded {
+			continue
+		}
+		tags, err := db.GetAllTagsForFile(filepath.Join(rewindRoot, version.FilePath))
+		if err != nil {
+			return fmt.Errorf("failed to get tags: %w", err)
+		}
+		tagsByPath[version.FilePath] = tags
+		if version.FilePath != relPath {
+			formerNames = true
+		}
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "VERSION\tTIME\tSIZE\tSIZE DIFF\tHASH\tTAGS")
-	fmt.Fprintln(w, "-------\t----\t----\t---------\t----\t----")
+	if formerNames {
+		fmt.Fprintln(w, "VERSION\tTIME\tSIZE\tSIZE DIFF\tHASH\tTAGS\tFORMER NAME")
+		fmt.Fprintln(w, "-------\t----\t----\t---------\t----\t----\t-----------")
+	} else {
+		fmt.Fprintln(w, "VERSION\tTIME\tSIZE\tSIZE DIFF\tHASH\tTAGS")
+		fmt.Fprintln(w, "-------\t----\t----\t---------\t----\t----")
+	}
 
 	for _, version := range versions {
 		// Format time as relative
 		timeStr := humanize.Time(version.Timestamp)
 
 		// Format size
-		sizeStr := humanize.Bytes(uint64(version.FileSize))
+		sizeStr := formatSize(version.FileSize)
+		if version.IsBaseline() {
+			sizeStr += " (baseline)"
+		}
 
 		// Calculate and format size difference
 		var sizeDiffStr string
 		if version.FileSize == currentSize {
 			sizeDiffStr = "(current)"
 		} else {
-			diff := currentSize - version.FileSize
-			if diff > 0 {
-				sizeDiffStr = "+" + humanize.Bytes(uint64(diff))
-			} else {
-				sizeDiffStr = "-" + humanize.Bytes(uint64(-diff))
-			}
+			sizeDiffStr = formatSizeDiff(currentSize - version.FileSize)
 		}
 
 		// Format hash (first 8 characters)
@@ -456,7 +674,7 @@ func displayAsTable(versions []*database.FileVersion, filePath string) error {
 
 		// Format tags
 		var tagsStr string
-		if tags, exists := allTags[version.VersionNumber]; exists && len(tags) > 0 {
+		if tags, exists := tagsByPath[version.FilePath][version.VersionNumber]; exists && len(tags) > 0 {
 			tagNames := make([]string, len(tags))
 			for i, tag := range tags {
 				tagNames[i] = tag.TagName
@@ -466,7 +684,7 @@ func displayAsTable(versions []*database.FileVersion, filePath string) error {
 			tagsStr = ""
 		}
 
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n",
+		row := fmt.Sprintf("%d\t%s\t%s\t%s\t%s\t%s",
 			version.VersionNumber,
 			timeStr,
 			sizeStr,
@@ -474,6 +692,10 @@ func displayAsTable(versions []*database.FileVersion, filePath string) error {
 			hashStr,
 			tagsStr,
 		)
+		if formerNames && version.FilePath != relPath {
+			row += "\t" + version.FilePath
+		}
+		fmt.Fprintln(w, row)
 	}
 
 	return w.Flush()
@@ -492,18 +714,9 @@ func displayAsCSV(versions []*database.FileVersion, filePath string) error {
 		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	rewindRoot, err := findRewindRoot(absPath)
-	if err != nil {
-		return fmt.Errorf("not in a rewind project: %w", err)
-	}
-
-	db, err := database.NewDatabaseManager(rewindRoot)
+	db, err := openFileDatabase(absPath)
 	if err != nil {
-		return fmt.Errorf("failed to create database manager: %w", err)
-	}
-
-	if err := db.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
 	defer db.Close()
 
@@ -583,6 +796,7 @@ type FileVersionJSON struct {
 	Tags          []string `json:"tags"`
 	FilePath      string   `json:"file_path"`
 	StoragePath   string   `json:"storage_path"`
+	EventOp       string   `json:"event_op"`
 }
 
 type FileVersionsResponse struct {
@@ -592,91 +806,107 @@ type FileVersionsResponse struct {
 }
 
 func displayAsJSON(versions []*database.FileVersion, filePath string) error {
-	// Get current file size for size difference calculation
-	var currentSize int64
-	if len(versions) > 0 {
-		currentSize = versions[0].FileSize
+	// Get all tags for this file
+	allTags, err := loadFileTags(filePath)
+	if err != nil {
+		return err
 	}
 
-	// Get database manager to fetch tags
-	absPath, err := filepath.Abs(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+	jsonVersions := make([]FileVersionJSON, len(versions))
+	for i, version := range versions {
+		jsonVersions[i] = newFileVersionJSON(version, versions[0].FileSize, allTags, filePath)
+	}
+
+	response := FileVersionsResponse{
+		FilePath: filePath,
+		Count:    len(jsonVersions),
+		Versions: jsonVersions,
 	}
 
-	rewindRoot, err := findRewindRoot(absPath)
+	return emitJSON("rollback", response)
+}
+
+// displayAsJSONL prints each version as a JSON object on its own line, in the
+// form of the entries of --json's versions array
+func displayAsJSONL(versions []*database.FileVersion, filePath string) error {
+	allTags, err := loadFileTags(filePath)
 	if err != nil {
-		return fmt.Errorf("not in a rewind project: %w", err)
+		return err
 	}
 
-	db, err := database.NewDatabaseManager(rewindRoot)
+	encoder := json.NewEncoder(os.Stdout)
+	for _, version := range versions {
+		if err := encoder.Encode(newFileVersionJSON(version, versions[0].FileSize, allTags, filePath)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFileTags returns the tags of each version of a file
+func loadFileTags(filePath string) (map[int][]*database.Tag, error) {
+	absPath, err := filepath.Abs(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to create database manager: %w", err)
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	if err := db.Connect(); err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	db, err := openFileDatabase(absPath)
+	if err != nil {
+		return nil, err
 	}
 	defer db.Close()
 
-	// Get all tags for this file
 	allTags, err := db.GetAllTagsForFile(absPath)
 	if err != nil {
-		return fmt.Errorf("failed to get tags: %w", err)
+		return nil, fmt.Errorf("failed to get tags: %w", err)
 	}
+	return allTags, nil
+}
 
-	jsonVersions := make([]FileVersionJSON, len(versions))
-	for i, version := range versions {
-		// Calculate size difference
-		var sizeDiffStr string
-		var sizeDiffBytes int64
-		if version.FileSize == currentSize {
-			sizeDiffStr = "0"
-			sizeDiffBytes = 0
-		} else {
-			diff := currentSize - version.FileSize
-			sizeDiffBytes = diff
-			if diff > 0 {
-				sizeDiffStr = "+" + humanize.Bytes(uint64(diff))
-			} else {
-				sizeDiffStr = "-" + humanize.Bytes(uint64(-diff))
-			}
-		}
-
-		// Get tags for this version
-		var tags []string
-		if versionTags, exists := allTags[version.VersionNumber]; exists && len(versionTags) > 0 {
-			tags = make([]string, len(versionTags))
-			for j, tag := range versionTags {
-				tags[j] = tag.TagName
-			}
+// newFileVersionJSON describes a version for JSON output, with its size
+// compared to currentSize, the size of the latest version
+func newFileVersionJSON(version *database.FileVersion, currentSize int64, allTags map[int][]*database.Tag, filePath string) FileVersionJSON {
+	// Calculate size difference
+	var sizeDiffStr string
+	var sizeDiffBytes int64
+	if version.FileSize == currentSize {
+		sizeDiffStr = "0"
+		sizeDiffBytes = 0
+	} else {
+		diff := currentSize - version.FileSize
+		sizeDiffBytes = diff
+		if diff > 0 {
+			sizeDiffStr = "+" + humanize.Bytes(uint64(diff))
 		} else {
-			tags = []string{}
-		}
-
-		jsonVersions[i] = FileVersionJSON{
-			Version:       version.VersionNumber,
-			Timestamp:     version.Timestamp.Format("2006-01-02 15:04:05"),
-			TimestampUnix: version.Timestamp.Unix(),
-			Size:          humanize.Bytes(uint64(version.FileSize)),
-			SizeBytes:     version.FileSize,
-			SizeDiff:      sizeDiffStr,
-			SizeDiffBytes: sizeDiffBytes,
-			Hash:          version.FileHash,
-			Tags:          tags,
-			FilePath:      filePath,
-			StoragePath:   version.StoragePath,
+			sizeDiffStr = "-" + humanize.Bytes(uint64(-diff))
 		}
 	}
 
-	response := FileVersionsResponse{
-		FilePath: filePath,
-		Count:    len(jsonVersions),
-		Versions: jsonVersions,
+	// Get tags for this version
+	var tags []string
+	if versionTags, exists := allTags[version.VersionNumber]; exists && len(versionTags) > 0 {
+		tags = make([]string, len(versionTags))
+		for j, tag := range versionTags {
+			tags[j] = tag.TagName
+		}
+	} else {
+		tags = []string{}
+	}
+
+	return FileVersionJSON{
+		Version:       version.VersionNumber,
+		Timestamp:     version.Timestamp.Format("2006-01-02 15:04:05"),
+		TimestampUnix: version.Timestamp.Unix(),
+		Size:          humanize.Bytes(uint64(version.FileSize)),
+		SizeBytes:     version.FileSize,
+		SizeDiff:      sizeDiffStr,
+		SizeDiffBytes: sizeDiffBytes,
+		Hash:          version.FileHash,
+		Tags:          tags,
+		FilePath:      filePath,
+		StoragePath:   version.StoragePath,
+		EventOp:       version.EventOp,
 	}
-
-	encoder := json.NewEncoder(os.Stdout)
-	return encoder.Encode(response)
 }
 
 func performRollback(db *database.DatabaseManager, filePath string, targetVersion int) error {
@@ -686,13 +916,16 @@ func performRollback(db *database.DatabaseManager, filePath string, targetVersio
 		return fmt.Errorf("failed to get target version: %w", err)
 	}
 	if targetVersionData == nil {
-		return fmt.Errorf("version %d not found for file", targetVersion)
+		return withExitCode(exitNotFound, fmt.Errorf("version %d not found for file", targetVersion))
 	}
 
 	// Sanity check 2: Ensure target version is not deleted
 	if targetVersionData.Deleted {
 		return fmt.Errorf("cannot rollback to deleted version %d", targetVersion)
 	}
+	if targetVersionData.IsBaseline() {
+		return fmt.Errorf("cannot rollback to version %d: it is a baseline with no stored content", targetVersion)
+	}
 
 	// Sanity check 3: Get current latest version
 	latestVersion, err := db.GetLatestFileVersion(filePath)
@@ -700,7 +933,7 @@ func performRollback(db *database.DatabaseManager, filePath string, targetVersio
 		return fmt.Errorf("failed to get latest version: %w", err)
 	}
 	if latestVersion == nil {
-		return fmt.Errorf("no versions found for file")
+		return withExitCode(exitNotFound, fmt.Errorf("no versions found for file"))
 	}
 
 	// Sanity check 4: Check if we're already at the target version
@@ -713,16 +946,25 @@ func performRollback(db *database.DatabaseManager, filePath string, targetVersio
 		return fmt.Errorf("current file does not exist: %s", filePath)
 	}
 
-	// Sanity check 6: Find the rewind root to get storage path
-	rewindRoot, err := findRewindRoot(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to find rewind root: %w", err)
+	// Sanity check 6: Check if stored version file exists
+	storedVersionPath := filepath.Join(db.VersionsDir(), targetVersionData.StoragePath)
+	if _, err := os.Stat(storedVersionPath); os.IsNotExist(err) {
+		return withExitCode(exitIntegrity, fmt.Errorf("stored version file not found: %s", storedVersionPath))
 	}
 
-	// Sanity check 7: Check if stored version file exists
-	storedVersionPath := filepath.Join(rewindRoot, ".rewind", "versions", targetVersionData.StoragePath)
-	if _, err := os.Stat(storedVersionPath); os.IsNotExist(err) {
-		return fmt.Errorf("stored version file not found: %s", storedVersionPath)
+	// Sanity check 7: Make sure the stored version is not corrupted. Deltas
+	// and compressed versions are read now and checked against their hash as
+	// they are read.
+	var storedContent []byte
+	if targetVersionData.IsDelta() || targetVersionData.IsCompressed() {
+		storedContent, err = db.ReadVersionContent(targetVersionData)
+		if err != nil {
+			return err
+		}
+	} else if !rollbackForceFlag {
+		if err := verifyStoredVersion(storedVersionPath, targetVersionData); err != nil {
+			return err
+		}
 	}
 
 	// Show confirmation prompt only if --confirm is used
@@ -734,7 +976,7 @@ func performRollback(db *database.DatabaseManager, filePath string, targetVersio
 	}
 
 	// Check if current file differs from latest database version and version it if needed
-	currentHash, err := database.CalculateFileHash(filePath)
+	currentHash, err := latestVersion.HashFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to calculate current file hash: %w", err)
 	}
@@ -742,28 +984,68 @@ func performRollback(db *database.DatabaseManager, filePath string, targetVersio
 	// If current file is different from latest version, save it first
 	if currentHash != latestVersion.FileHash {
 		fmt.Println("Current file differs from latest version, saving current state...")
-		if err := saveCurrentFileAsNewVersion(db, filePath, rewindRoot); err != nil {
+		if err := saveCurrentFileAsNewVersion(db, filePath); err != nil {
 			return fmt.Errorf("failed to save current file state: %w", err)
 		}
 	}
 
+	// Lock the file so a running daemon ignores the writes made by the
+	// rollback. The daemon doesn't watch files in the global store.
+	var lock *watcher.FileLock
+	if !db.IsGlobal() {
+		lock, err = watcher.AcquireFileLock(db.RootDir(), filePath)
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+	}
+
 	// Perform the rollback by copying the stored version
-	if err := copyFile(storedVersionPath, filePath); err != nil {
-		return fmt.Errorf("failed to restore file: %w", err)
+	copyErr := withWritableFile(filePath, func() error {
+		if storedContent != nil {
+			return writeRebuiltVersion(filePath, storedContent)
+		}
+		return copyFile(storedVersionPath, filePath)
+	})
+	if lock != nil {
+		if err := lock.Release(); err != nil {
+			app.Logger.WithError(err).Warn("Failed to release file lock")
+		}
+	}
+	if copyErr != nil {
+		return fmt.Errorf("failed to restore file: %w", copyErr)
 	}
+	restoreOwnership(db, targetVersionData, filePath)
+	restoreXattrs(db, targetVersionData, filePath)
+	recordAudit(app.AuditRollback, filePath, targetVersionData)
 
 	fmt.Printf("✓ File restored to version %d\n", targetVersion)
 	fmt.Printf("✓ Rollback completed successfully\n")
 	return nil
 }
 
+// verifyStoredVersion checks that a stored version still matches the hash
+// recorded when it was captured, so a corrupted copy is never restored
+func verifyStoredVersion(storedPath string, fv *database.FileVersion) error {
+	storedHash, err := fv.HashFile(storedPath)
+	if err != nil {
+		return fmt.Errorf("failed to verify stored version: %w", err)
+	}
+
+	if storedHash != fv.FileHash {
+		return withExitCode(exitIntegrity, fmt.Errorf("stored version %d of %s is corrupted (expected hash %s, got %s); use --force to restore it anyway",
+			fv.VersionNumber, fv.FilePath, fv.FileHash, storedHash))
+	}
+
+	return nil
+}
+
 func confirmRollback(currentVersion, targetVersion *database.FileVersion, filePath string) bool {
 	fmt.Printf("Rolling back %s from version %d to version %d\n", 
 		filepath.Base(filePath), currentVersion.VersionNumber, targetVersion.VersionNumber)
 	fmt.Printf("  Current: %s (modified %s)\n", 
-		humanize.Bytes(uint64(currentVersion.FileSize)), humanize.Time(currentVersion.Timestamp))
+		formatSize(currentVersion.FileSize), humanize.Time(currentVersion.Timestamp))
 	fmt.Printf("  Target:  %s (modified %s)\n", 
-		humanize.Bytes(uint64(targetVersion.FileSize)), humanize.Time(targetVersion.Timestamp))
+		formatSize(targetVersion.FileSize), humanize.Time(targetVersion.Timestamp))
 	fmt.Printf("\nContinue? [y/N]: ")
 
 	reader := bufio.NewReader(os.Stdin)
@@ -810,42 +1092,113 @@ func confirmFilesystemRollback(eligibleFiles []*database.FileVersion, timeAgoStr
 	return response == "y" || response == "yes"
 }
 
-func saveCurrentFileAsNewVersion(db *database.DatabaseManager, filePath, rewindRoot string) error {
+func saveCurrentFileAsNewVersion(db *database.DatabaseManager, filePath string) error {
+	versionNumber, err := captureFileVersion(db, filePath, database.EventOpRollback)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Current state saved as version %d\n", versionNumber)
+	return nil
+}
+
+// storeObject stores filePath as the content-addressable object for
+// fileHash, unless that object already exists. The file may have changed
+// since it was hashed, so the hash of the content actually copied is
+// returned and the object is stored under it.
+func storeObject(db *database.DatabaseManager, filePath, fileHash string) (string, error) {
+	objectPath := filepath.Join(db.VersionsDir(), database.ObjectPath(fileHash))
+	if _, err := os.Stat(objectPath); err == nil {
+		return fileHash, nil
+	}
+
+	if err := os.MkdirAll(db.ObjectsDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	tempPath := filepath.Join(db.ObjectsDir(), "incoming-"+fileHash)
+	if err := copyFile(filePath, tempPath); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	hash, err := database.CalculateFileHash(tempPath)
+	if err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+
+	objectPath = filepath.Join(db.VersionsDir(), database.ObjectPath(hash))
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0755); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := os.Rename(tempPath, objectPath); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return hash, nil
+}
+
+// captureFileVersion stores the current content of a file as a new version
+// caused by op and returns the version number it was saved as. The first
+// version of a file is always recorded as a create.
+func captureFileVersion(db *database.DatabaseManager, filePath, op string) (int, error) {
 	// Get file info
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to stat current file: %w", err)
+		return 0, fmt.Errorf("failed to stat current file: %w", err)
 	}
 
-	// Calculate hash
-	currentHash, err := database.CalculateFileHash(filePath)
+	layout, err := db.StorageLayout()
 	if err != nil {
-		return fmt.Errorf("failed to calculate file hash: %w", err)
+		return 0, err
+	}
+
+	// Calculate hash. Objects are named by their SHA256 hash, so the
+	// content-addressable layout always uses it.
+	algorithm := viper.GetString("hash_algorithm")
+	if layout == database.LayoutContentAddressable || !slices.Contains(database.HashAlgorithms, algorithm) {
+		algorithm = database.HashSHA256
+	}
+	currentHash, err := database.CalculateFileHashWith(filePath, algorithm)
+	if err != nil {
+		return 0, fmt.Errorf("failed to calculate file hash: %w", err)
 	}
 
 	// Get next version number
 	versionNumber, err := db.GetNextVersionNumber(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to get next version number: %w", err)
+		return 0, fmt.Errorf("failed to get next version number: %w", err)
+	}
+	if versionNumber == 1 {
+		op = database.EventOpCreate
 	}
 
 	// Create storage path
-	storagePath := db.CreateStoragePath(filePath, versionNumber)
-	fullStoragePath := filepath.Join(rewindRoot, ".rewind", "versions", storagePath)
+	var storagePath string
+	if layout == database.LayoutContentAddressable {
+		// Copy current file to its object, shared with versions of the same content
+		if currentHash, err = storeObject(db, filePath, currentHash); err != nil {
+			return 0, fmt.Errorf("failed to copy file to storage: %w", err)
+		}
+		storagePath = database.ObjectPath(currentHash)
+	} else {
+		storagePath = db.CreateStoragePath(filePath, versionNumber)
+		fullStoragePath := filepath.Join(db.VersionsDir(), storagePath)
 
-	// Create storage directory if it doesn't exist
-	storageDir := filepath.Dir(fullStoragePath)
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		return fmt.Errorf("failed to create storage directory: %w", err)
-	}
+		// Create storage directory if it doesn't exist
+		storageDir := filepath.Dir(fullStoragePath)
+		if err := os.MkdirAll(storageDir, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create storage directory: %w", err)
+		}
 
-	// Copy current file to storage location
-	if err := copyFile(filePath, fullStoragePath); err != nil {
-		return fmt.Errorf("failed to copy file to storage: %w", err)
+		// Copy current file to storage location
+		if err := copyFile(filePath, fullStoragePath); err != nil {
+			return 0, fmt.Errorf("failed to copy file to storage: %w", err)
+		}
 	}
 
 	// Get relative path for database
-	relPath, err := filepath.Rel(rewindRoot, filePath)
+	relPath, err := filepath.Rel(db.RootDir(), filePath)
 	if err != nil {
 		relPath = filePath
 	}
@@ -856,20 +1209,35 @@ func saveCurrentFileAsNewVersion(db *database.DatabaseManager, filePath, rewindR
 		VersionNumber: versionNumber,
 		Timestamp:     time.Now(),
 		FileHash:      currentHash,
+		HashAlgorithm: algorithm,
 		FileSize:      fileInfo.Size(),
 		StoragePath:   storagePath,
+		EventOp:       op,
 		Deleted:       false,
 	}
+	if viper.GetBool("capture_ownership") {
+		fileVersion.Owner = database.FileOwner(fileInfo)
+	}
+	if viper.GetBool("capture.xattrs") {
+		xattrs, err := database.FileXattrs(filePath)
+		if err != nil {
+			fmt.Printf("Warning: capturing %s without its extended attributes: %v\n", relPath, err)
+		}
+		fileVersion.Xattrs = xattrs
+	}
 
 	// Add to database
 	if err := db.AddFileVersion(fileVersion); err != nil {
-		// Clean up the file if database insertion fails
-		os.Remove(fullStoragePath)
-		return fmt.Errorf("failed to add file version to database: %w", err)
+		// Clean up the file if database insertion fails, unless it is an
+		// object other versions may share
+		if layout != database.LayoutContentAddressable {
+			os.Remove(filepath.Join(db.VersionsDir(), storagePath))
+		}
+		return 0, fmt.Errorf("failed to add file version to database: %w", err)
 	}
+	recordAudit(app.AuditCapture, filePath, fileVersion)
 
-	fmt.Printf("✓ Current state saved as version %d\n", versionNumber)
-	return nil
+	return versionNumber, nil
 }
 
 func copyFile(src, dst string) error {
@@ -879,6 +1247,12 @@ func copyFile(src, dst string) error {
 	}
 	defer sourceFile.Close()
 
+	// A destination with other hard links may share its inode with a stored
+	// version (storage_mode: hardlink); truncating it would rewrite that version
+	if err := unlinkSharedFile(dst); err != nil {
+		return err
+	}
+
 	destFile, err := os.Create(dst)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
@@ -890,13 +1264,24 @@ func copyFile(src, dst string) error {
 	}
 
 	// Sync to ensure data is written to disk
-	if err := destFile.Sync(); err != nil {
-		return fmt.Errorf("failed to sync destination file: %w", err)
+	if viper.GetBool("fsync") {
+		if err := destFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync destination file: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// writeRebuiltVersion writes the content of a delta or compressed version over dst
+func writeRebuiltVersion(dst string, content []byte) error {
+	// As in copyFile, never truncate an inode shared with a stored version
+	if err := unlinkSharedFile(dst); err != nil {
+		return err
+	}
+	return writeFileContent(dst, content)
+}
+
 func performRollbackByTag(db *database.DatabaseManager, filePath string, tagName string) error {
 	// Get the version with the specified tag
 	targetVersion, err := db.GetVersionByTag(filePath, tagName)
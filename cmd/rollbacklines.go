@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// parseLineRange parses a --lines value such as "40:80" into its first and
+// last line, both counted from 1
+func parseLineRange(value string) (int, int, error) {
+	startStr, endStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid line range %q: use start:end, e.g. 40:80", value)
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(startStr))
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid line range %q: start must be a line number from 1", value)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(endStr))
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid line range %q: end must be a line number no smaller than start", value)
+	}
+	return start, end, nil
+}
+
+// splitLines splits content into lines that keep their line endings, the
+// way the diff works on them
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// mapLines matches the lines of old to those of current with the diff used
+// by rewind diff. Entry i is the index in current of old line i, or -1 if
+// the line was changed or removed.
+func mapLines(old, current string) []int {
+	oldLines := len(splitLines(old))
+	mapping := make([]int, oldLines)
+
+	oi, ci := 0, 0
+	for _, edit := range myers.ComputeEdits(span.URIFromPath(""), old, current) {
+		from, to := edit.Span.Start().Line()-1, edit.Span.End().Line()-1
+		for ; oi < from; oi++ {
+			mapping[oi] = ci
+			ci++
+		}
+		for ; oi < to; oi++ {
+			mapping[oi] = -1
+		}
+		if edit.NewText != "" {
+			ci += len(splitLines(edit.NewText))
+		}
+	}
+	for ; oi < oldLines; oi++ {
+		mapping[oi] = ci
+		ci++
+	}
+	return mapping
+}
+
+// spliceLines replaces the part of current that corresponds to lines start to
+// end of old with those lines, leaving the rest of current alone. The
+// corresponding part runs between the nearest lines before and after the
+// range that are unchanged between old and current. It also returns the
+// first and last line of current that were replaced, counted from 1.
+func spliceLines(old, current string, start, end int) (string, int, int, error) {
+	oldLines := splitLines(old)
+	if end > len(oldLines) {
+		return "", 0, 0, fmt.Errorf("line range %d:%d is outside the version, which has %d lines", start, end, len(oldLines))
+	}
+	currentLines := splitLines(current)
+	mapping := mapLines(old, current)
+
+	from := 0
+	if start > 1 {
+		from = -1
+		for i := start - 2; i >= 0; i-- {
+			if mapping[i] >= 0 {
+				from = mapping[i] + 1
+				break
+			}
+		}
+	}
+	to := len(currentLines)
+	if end < len(oldLines) {
+		to = -1
+		for i := end; i < len(oldLines); i++ {
+			if mapping[i] >= 0 {
+				to = mapping[i]
+				break
+			}
+		}
+	}
+	if from < 0 || to < 0 {
+		return "", 0, 0, fmt.Errorf("could not find lines %d:%d in the current file: no unchanged lines around them to match against", start, end)
+	}
+
+	var result strings.Builder
+	for _, line := range currentLines[:from] {
+		result.WriteString(line)
+	}
+	for _, line := range oldLines[start-1 : end] {
+		result.WriteString(line)
+	}
+	for _, line := range currentLines[to:] {
+		result.WriteString(line)
+	}
+	return result.String(), from + 1, to, nil
+}
+
+// performLineRollback rolls lines start to end of a file back to their
+// content in targetVersion, keeping the rest of the working file as it is
+func performLineRollback(db *database.DatabaseManager, filePath string, targetVersion, start, end int) error {
+	targetVersionData, err := db.GetFileVersion(filePath, targetVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get target version: %w", err)
+	}
+	if targetVersionData == nil {
+		return withExitCode(exitNotFound, fmt.Errorf("version %d not found for file", targetVersion))
+	}
+	if targetVersionData.Deleted {
+		return fmt.Errorf("cannot rollback to deleted version %d", targetVersion)
+	}
+	if targetVersionData.IsBaseline() {
+		return fmt.Errorf("cannot rollback to version %d: it is a baseline with no stored content", targetVersion)
+	}
+
+	latestVersion, err := db.GetLatestFileVersion(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get latest version: %w", err)
+	}
+	if latestVersion == nil {
+		return withExitCode(exitNotFound, fmt.Errorf("no versions found for file"))
+	}
+
+	storedContent, err := readVerifiedVersion(db, targetVersionData)
+	if err != nil {
+		return err
+	}
+
+	currentContent, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("current file does not exist: %s", filePath)
+		}
+		return fmt.Errorf("failed to read current file: %w", err)
+	}
+
+	result, from, to, err := spliceLines(string(storedContent), string(currentContent), start, end)
+	if err != nil {
+		return err
+	}
+	if result == string(currentContent) {
+		fmt.Printf("Lines %d:%d already match version %d; nothing to roll back\n", start, end, targetVersion)
+		return nil
+	}
+
+	if !confirmLineRollback(filePath, string(currentContent), result, targetVersion, start, end, from, to) {
+		fmt.Println("Rollback cancelled.")
+		return nil
+	}
+
+	// Save the current state first if it differs from the latest version
+	currentHash, err := latestVersion.HashFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to calculate current file hash: %w", err)
+	}
+	if currentHash != latestVersion.FileHash {
+		fmt.Println("Current file differs from latest version, saving current state...")
+		if err := saveCurrentFileAsNewVersion(db, filePath); err != nil {
+			return fmt.Errorf("failed to save current file state: %w", err)
+		}
+	}
+
+	var lock *watcher.FileLock
+	if !db.IsGlobal() {
+		lock, err = watcher.AcquireFileLock(db.RootDir(), filePath)
+		if err != nil {
+			return fmt.Errorf("failed to lock file: %w", err)
+		}
+	}
+
+	writeErr := withWritableFile(filePath, func() error {
+		return writeRebuiltVersion(filePath, []byte(result))
+	})
+	if lock != nil {
+		if err := lock.Release(); err != nil {
+			app.Logger.WithError(err).Warn("Failed to release file lock")
+		}
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to restore lines: %w", writeErr)
+	}
+	recordAudit(app.AuditRollback, filePath, targetVersionData)
+
+	fmt.Printf("✓ Lines %d:%d restored from version %d\n", start, end, targetVersion)
+
+	// The spliced file matches no stored version, and the daemon ignores the
+	// rollback's own write, so record it here
+	versionNumber, err := captureFileVersion(db, filePath, database.EventOpRollback)
+	if err != nil {
+		return fmt.Errorf("failed to save the result: %w", err)
+	}
+	fmt.Printf("✓ Result saved as version %d\n", versionNumber)
+	return nil
+}
+
+// readVerifiedVersion reads the content of a stored version, checking it
+// against its recorded hash unless --force was given
+func readVerifiedVersion(db *database.DatabaseManager, fv *database.FileVersion) ([]byte, error) {
+	if fv.IsDelta() || fv.IsCompressed() {
+		return db.ReadVersionContent(fv)
+	}
+
+	storedVersionPath := filepath.Join(db.VersionsDir(), fv.StoragePath)
+	if _, err := os.Stat(storedVersionPath); os.IsNotExist(err) {
+		return nil, withExitCode(exitIntegrity, fmt.Errorf("stored version file not found: %s", storedVersionPath))
+	}
+	if !rollbackForceFlag {
+		if err := verifyStoredVersion(storedVersionPath, fv); err != nil {
+			return nil, err
+		}
+	}
+	content, err := os.ReadFile(storedVersionPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored version: %w", err)
+	}
+	return content, nil
+}
+
+// confirmLineRollback shows the change a line rollback would make and asks
+// whether to go ahead
+func confirmLineRollback(filePath, current, result string, targetVersion, start, end, from, to int) bool {
+	if from > to {
+		fmt.Printf("Inserting lines %d:%d of version %d into %s before line %d\n",
+			start, end, targetVersion, filepath.Base(filePath), from)
+	} else {
+		fmt.Printf("Replacing lines %d:%d of %s with lines %d:%d of version %d\n",
+			from, to, filepath.Base(filePath), start, end, targetVersion)
+	}
+	fmt.Println()
+	fmt.Print(unifiedDiff("current", "after rollback", current, result, false))
+	fmt.Printf("\nContinue? [y/N]: ")
+
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import "testing"
+
+func TestSpliceLines(t *testing.T) {
+	old := "package main\n\nfunc a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n"
+
+	tests := []struct {
+		name       string
+		current    string
+		start, end int
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:    "range edited, other edits kept",
+			current: "// header\npackage main\n\nfunc a() {\n\treturn 100\n}\n\nfunc b() {\n\treturn 200\n}\n",
+			start:   3, end: 5,
+			want: "// header\npackage main\n\nfunc a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 200\n}\n",
+		},
+		{
+			name:    "range deleted from current",
+			current: "package main\n\nfunc b() {\n\treturn 2\n}\n",
+			start:   3, end: 6,
+			want: old,
+		},
+		{
+			name:    "range at end of file",
+			current: "package main\n\nfunc a() {\n\treturn 1\n}\n\nfunc c() {}\n",
+			start:   7, end: 9,
+			want: "package main\n\nfunc a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n",
+		},
+		{
+			name:    "nothing in common",
+			current: "unrelated\ncontent\n",
+			start:   3, end: 5,
+			wantErr: true,
+		},
+		{
+			name:    "range past end of version",
+			current: old,
+			start:   8, end: 20,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _, err := spliceLines(old, tt.current, tt.start, tt.end)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("spliceLines() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("spliceLines() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// rollbackPatch is the --preview-only --json output: the change a rollback
+// would make to a file, as a unified patch
+type rollbackPatch struct {
+	FilePath    string `json:"file_path"`
+	Version     int    `json:"version"`
+	CurrentHash string `json:"current_hash"`
+	TargetHash  string `json:"target_hash"`
+	Identical   bool   `json:"identical"`
+	Patch       string `json:"patch"`
+}
+
+// previewRollbackPatch prints the change rolling a file back to the version
+// chosen with --version, --tag or --time-ago would make, without making it
+func previewRollbackPatch(db *database.DatabaseManager, filePath string) error {
+	var target *database.FileVersion
+	var err error
+	switch {
+	case versionFlag > 0:
+		target, err = db.GetFileVersion(filePath, versionFlag)
+		if err == nil && target == nil {
+			err = withExitCode(exitNotFound, fmt.Errorf("version %d not found for file", versionFlag))
+		}
+	case tagFlag != "":
+		target, err = db.GetVersionByTag(filePath, tagFlag)
+	default:
+		target, err = findVersionBefore(db, filePath, timeAgoFlag)
+	}
+	if err != nil {
+		return err
+	}
+	if target.Deleted {
+		return fmt.Errorf("cannot rollback to deleted version %d", target.VersionNumber)
+	}
+	if target.IsBaseline() {
+		return fmt.Errorf("cannot rollback to version %d: it is a baseline with no stored content", target.VersionNumber)
+	}
+
+	targetContent, err := readVerifiedVersion(db, target)
+	if err != nil {
+		return err
+	}
+	currentContent, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("current file does not exist: %s", filePath)
+		}
+		return fmt.Errorf("failed to read current file: %w", err)
+	}
+	if !database.IsText(currentContent) || !database.IsText(targetContent) {
+		return fmt.Errorf("%s is binary, so the rollback cannot be shown as a patch", filePath)
+	}
+
+	label := filePath
+	if !db.IsGlobal() {
+		if rel, err := filepath.Rel(db.RootDir(), filePath); err == nil {
+			label = filepath.ToSlash(rel)
+		}
+	}
+	patch := ""
+	if string(currentContent) != string(targetContent) {
+		patch = unifiedDiff("a/"+label, "b/"+label, string(currentContent), string(targetContent), false)
+	}
+
+	if jsonFlag {
+		return emitJSON("rollback preview", rollbackPatch{
+			FilePath:    label,
+			Version:     target.VersionNumber,
+			CurrentHash: target.ContentHash(currentContent),
+			TargetHash:  target.FileHash,
+			Identical:   patch == "",
+			Patch:       patch,
+		})
+	}
+
+	fmt.Print(patch)
+	return nil
+}
@@ -5,13 +5,20 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/davenicholson-xyz/rewind/network"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -19,6 +26,8 @@ import (
 var cfgFile string
 var showVersionFlag bool
 var appVersion string
+var instanceFlag string
+var rootDirFlag string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -37,14 +46,19 @@ Key features:
 	Run: func(cmd *cobra.Command, args []string) {
 		if showVersionFlag {
 			if appVersion == "" {
-				fmt.Println("rewind version unknown")
+				fmt.Fprintln(cmd.OutOrStdout(), "rewind version unknown")
 			} else {
-				fmt.Printf("%s\n", appVersion)
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\n", appVersion)
 			}
 			return
 		}
 		cmd.Help()
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if err := useRootDir(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -58,7 +72,35 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	// -v/--version is local to the root command rather than persistent, so
+	// rollback, diff and tag can use -v for a version number
 	rootCmd.Flags().BoolVarP(&showVersionFlag, "version", "v", false, "Show version")
+	rootCmd.PersistentFlags().StringVar(&rootDirFlag, "root", "", "Run against the rewind project in this directory instead of the current one")
+
+	defaults := watcher.DefaultConfig()
+	viper.SetDefault("fsync", defaults.Fsync)
+	viper.SetDefault("auto_ignore_git", defaults.AutoIgnoreGit)
+	viper.SetDefault("ignore_editor_artifacts", defaults.IgnoreEditorArtifacts)
+	viper.SetDefault("storage_mode", defaults.StorageMode)
+	viper.SetDefault("delta_keyframe_interval", defaults.DeltaKeyframeInterval)
+	viper.SetDefault("hash_algorithm", defaults.HashAlgorithm)
+	viper.SetDefault("include", defaults.Include)
+	viper.SetDefault("notify_on_delete", defaults.NotifyOnDelete)
+	viper.SetDefault("create_grace_period", defaults.CreateGracePeriod)
+	viper.SetDefault("capture.on", defaults.CaptureOn)
+	viper.SetDefault("capture.quiet_period", defaults.CaptureQuietPeriod)
+	viper.SetDefault("max_versions_per_file", defaults.MaxVersionsPerFile)
+	viper.SetDefault("track_directories", defaults.TrackDirectories)
+	viper.SetDefault("latest_links", defaults.LatestLinks)
+	viper.SetDefault("capture_ownership", defaults.CaptureOwnership)
+	viper.SetDefault("capture.xattrs", defaults.CaptureXattrs)
+	viper.SetDefault("audit_log", defaults.AuditLog)
+	viper.SetDefault("max_captures_per_minute", defaults.MaxCapturesPerMinute)
+	viper.SetDefault("capture_cooldown", defaults.CaptureCooldown)
+	viper.SetDefault("max_depth", defaults.MaxDepth)
+	viper.SetDefault("max_dirs", defaults.MaxDirs)
+	viper.SetDefault("max_files", defaults.MaxFiles)
+	viper.SetDefault("case_insensitive_paths", database.PathCaseAuto)
 }
 
 // SetVersion sets the application version
@@ -66,6 +108,35 @@ func SetVersion(version string) {
 	appVersion = version
 }
 
+// useRootDir switches to the directory given with --root, so relative paths
+// and the project are resolved from there rather than from where rewind was
+// run
+func useRootDir() error {
+	if rootDirFlag == "" {
+		return nil
+	}
+
+	root, err := filepath.Abs(rootDirFlag)
+	if err != nil {
+		return fmt.Errorf("failed to resolve --root: %w", err)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return fmt.Errorf("failed to access --root: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("--root %s is not a directory", root)
+	}
+	if err := os.Chdir(root); err != nil {
+		return fmt.Errorf("failed to change to --root: %w", err)
+	}
+
+	// Relative paths now resolve against the working directory, which has
+	// any symlinks in root resolved
+	rootDirFlag, err = os.Getwd()
+	return err
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -84,115 +155,238 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+	expandConfigEnv()
+
+	database.CaseInsensitivePaths = viper.GetString("case_insensitive_paths")
 }
 
-// sendIPCMessage sends a message to the rewind daemon via Unix socket with timeout
-func sendIPCMessage(action, path string) error {
-	// Set timeout for the entire operation (5 seconds)
-	timeout := 5 * time.Second
+// expandedConfigKeys are the settings expandConfigEnv has overridden with
+// their expanded values
+var expandedConfigKeys []string
+
+// expandConfigEnv expands $VAR and ${VAR} in the string settings of the
+// config file, so values such as audit_log: ${XDG_STATE_HOME}/rewind.jsonl
+// can be written portably. Lists of patterns are left as written.
+func expandConfigEnv() {
+	for _, key := range viper.AllKeys() {
+		value, ok := viper.Get(key).(string)
+		if !ok || !strings.Contains(value, "$") {
+			continue
+		}
+		viper.Set(key, os.ExpandEnv(value))
+		expandedConfigKeys = append(expandedConfigKeys, key)
+	}
+}
 
-	// Connect to the Unix socket with timeout
-	conn, err := net.DialTimeout("unix", "/tmp/rewind.sock", timeout)
-	if err != nil {
-		return fmt.Errorf("failed to connect to rewind daemon: %w", err)
+// reloadConfig reads the config file again, for a daemon reloading its
+// settings. Settings removed from the file go back to their defaults.
+func reloadConfig() error {
+	// Expanded values override the file, so clear them to see its new ones
+	for _, key := range expandedConfigKeys {
+		viper.Set(key, nil)
 	}
-	defer conn.Close()
+	expandedConfigKeys = nil
 
-	// Set deadline for the entire connection
-	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return fmt.Errorf("failed to set connection deadline: %w", err)
+	if err := viper.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			expandConfigEnv()
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
 	}
+	expandConfigEnv()
 
-	// Create the message
-	msg := Message{
-		Action: action,
-		Path:   path,
+	database.CaseInsensitivePaths = viper.GetString("case_insensitive_paths")
+	return nil
+}
+
+// loadWatcherConfig builds the watch manager configuration from the config file and environment
+func loadWatcherConfig() watcher.Config {
+	config := watcher.DefaultConfig()
+	config.Fsync = viper.GetBool("fsync")
+	config.AutoIgnoreGit = viper.GetBool("auto_ignore_git")
+	config.IgnoreEditorArtifacts = viper.GetBool("ignore_editor_artifacts")
+	config.Include = viper.GetStringSlice("include")
+	config.NotifyOnDelete = viper.GetBool("notify_on_delete")
+	config.CreateGracePeriod = max(viper.GetDuration("create_grace_period"), 0)
+	config.CaptureQuietPeriod = max(viper.GetDuration("capture.quiet_period"), 10*time.Millisecond)
+	config.MaxVersionsPerFile = max(viper.GetInt("max_versions_per_file"), 0)
+	config.TrackDirectories = viper.GetBool("track_directories")
+	config.LatestLinks = viper.GetBool("latest_links")
+	config.CaptureOwnership = viper.GetBool("capture_ownership")
+	config.CaptureXattrs = viper.GetBool("capture.xattrs")
+	config.AuditLog = auditLogPath()
+	config.MaxCapturesPerMinute = max(viper.GetInt("max_captures_per_minute"), 0)
+	config.CaptureCooldown = max(viper.GetDuration("capture_cooldown"), 0)
+	config.DeltaKeyframeInterval = max(viper.GetInt("delta_keyframe_interval"), 1)
+	config.MaxDepth = max(viper.GetInt("max_depth"), 0)
+	config.MaxDirs = max(viper.GetInt("max_dirs"), 0)
+	config.MaxFiles = max(viper.GetInt("max_files"), 0)
+	config.MinFileSize = configSize("min_file_size")
+	config.MaxFileSize = configSize("max_file_size")
+	config.MaxTotalSize = configSize("max_total_size")
+
+	if viper.IsSet("compress_after") {
+		age, err := parseDuration(viper.GetString("compress_after"))
+		if err != nil {
+			app.Logger.WithError(err).Warn("Invalid compress_after, not compressing versions")
+		} else {
+			config.CompressAfter = age
+		}
 	}
 
-	// Marshal the message to JSON
-	msgBytes, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %w", err)
+	if viper.IsSet("vacuum_interval") {
+		interval, err := parseDuration(viper.GetString("vacuum_interval"))
+		if err != nil {
+			app.Logger.WithError(err).Warn("Invalid vacuum_interval, not vacuuming databases")
+		} else {
+			config.VacuumInterval = interval
+		}
 	}
 
-	// Send the message
-	if _, err := conn.Write(msgBytes); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	if viper.IsSet("rescan_interval") {
+		interval, err := parseDuration(viper.GetString("rescan_interval"))
+		if err != nil {
+			app.Logger.WithError(err).Warn("Invalid rescan_interval, not rescanning")
+		} else {
+			config.RescanInterval = interval
+		}
 	}
 
-	// Read the response
-	buffer := make([]byte, 1024)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	if viper.IsSet("baseline_older_than") {
+		age, err := parseDuration(viper.GetString("baseline_older_than"))
+		if err != nil {
+			app.Logger.WithError(err).Warn("Invalid baseline_older_than, storing every file")
+		} else {
+			config.BaselineOlderThan = age
+		}
 	}
 
-	// Parse the response
-	var response Response
-	if err := json.Unmarshal(buffer[:n], &response); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+	switch on := viper.GetString("capture.on"); on {
+	case watcher.CaptureOnWrite, watcher.CaptureOnClose:
+		config.CaptureOn = on
+	default:
+		app.Logger.WithField("capture.on", on).Warn("Unknown capture.on, capturing on write")
 	}
 
-	// Check if the operation was successful
-	if !response.Success {
-		return fmt.Errorf("daemon returned error: %s", response.Message)
+	switch mode := viper.GetString("storage_mode"); mode {
+	case watcher.StorageModeCopy, watcher.StorageModeHardlink, watcher.StorageModeDelta:
+		config.StorageMode = mode
+	default:
+		app.Logger.WithField("storage_mode", mode).Warn("Unknown storage mode, using copy")
+	}
+
+	if algorithm := viper.GetString("hash_algorithm"); slices.Contains(database.HashAlgorithms, algorithm) {
+		config.HashAlgorithm = algorithm
+	} else {
+		app.Logger.WithField("hash_algorithm", algorithm).Warn("Unknown hash algorithm, using sha256")
+	}
+	return config
+}
+
+// configSize reads a size from the config file, either a number of bytes or a
+// size with a unit as accepted by purge --max-size. Invalid sizes are ignored.
+func configSize(key string) int64 {
+	if !viper.IsSet(key) {
+		return 0
 	}
 
-	app.Logger.WithField("response", response.Message).Info("IPC message sent successfully")
+	value := viper.GetString(key)
+	if bytes, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return max(bytes, 0)
+	}
+
+	size, err := parseSize(value)
+	if err != nil {
+		app.Logger.WithError(err).WithField(key, value).Warn("Invalid size in config, ignoring")
+		return 0
+	}
+	return size
+}
+
+// addInstanceFlag registers the --instance flag on commands that talk to the daemon
+func addInstanceFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&instanceFlag, "instance", "i", "", "Name of the rewind daemon instance to use")
+}
+
+// ipcSocketPath returns the daemon socket path for the selected instance
+func ipcSocketPath() string {
+	return network.SocketPath("rewind", instanceFlag)
+}
+
+// sendIPCMessage sends a message to the rewind daemon via Unix socket with timeout
+func sendIPCMessage(action protocol.Action, path string) error {
+	message, err := sendIPCMessageWithResponse(action, path)
+	if err != nil {
+		return err
+	}
+
+	app.Logger.WithField("response", message).Info("IPC message sent successfully")
 	return nil
 }
 
 // sendIPCMessageWithResponse sends a message to the rewind daemon and returns the response message
-func sendIPCMessageWithResponse(action, path string) (string, error) {
-	// Set timeout for the entire operation (5 seconds)
-	timeout := 5 * time.Second
+func sendIPCMessageWithResponse(action protocol.Action, path string) (string, error) {
+	response, err := sendIPCRequest(action, path)
+	if err != nil {
+		return "", err
+	}
+	return response.Message, nil
+}
+
+// ipcTimeout returns how long to wait for the daemon to answer an action.
+// Adding a watch scans the whole project, vacuuming rewrites its database and
+// migrating rewrites its version store, so they get far longer.
+func ipcTimeout(action protocol.Action) time.Duration {
+	if action == protocol.ActionAdd || action == protocol.ActionVacuum || action == protocol.ActionMigrateStore {
+		return 10 * time.Minute
+	}
+	return 5 * time.Second
+}
 
-	// Connect to the Unix socket with timeout
-	conn, err := net.DialTimeout("unix", "/tmp/rewind.sock", timeout)
+// sendIPCRequest sends a message to the rewind daemon and returns the full response
+func sendIPCRequest(action protocol.Action, path string) (*protocol.Response, error) {
+	return sendIPC(protocol.Message{Action: action, Path: path})
+}
+
+// sendIPC sends msg to the rewind daemon and returns the full response
+func sendIPC(msg protocol.Message) (*protocol.Response, error) {
+	timeout := ipcTimeout(msg.Action)
+
+	// Connect to the Unix socket, retrying while the daemon comes up
+	conn, err := network.DialIPC(ipcSocketPath(), 5*time.Second)
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to rewind daemon: %w", err)
+		return nil, withExitCode(exitDaemonUnreachable, fmt.Errorf("failed to connect to rewind daemon: %w", err))
 	}
 	defer conn.Close()
 
 	// Set deadline for the entire connection
 	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
-		return "", fmt.Errorf("failed to set connection deadline: %w", err)
-	}
-
-	// Create the message
-	msg := Message{
-		Action: action,
-		Path:   path,
+		return nil, fmt.Errorf("failed to set connection deadline: %w", err)
 	}
 
 	// Marshal the message to JSON
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal message: %w", err)
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
 	}
 
 	// Send the message
 	if _, err := conn.Write(msgBytes); err != nil {
-		return "", fmt.Errorf("failed to send message: %w", err)
+		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
-	// Read the response with larger buffer for status responses
-	buffer := make([]byte, 4096)
-	n, err := conn.Read(buffer)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Parse the response
-	var response Response
-	if err := json.Unmarshal(buffer[:n], &response); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+	// Decode the response straight from the connection, as status and
+	// metrics responses can span several reads
+	var response protocol.Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// Check if the operation was successful
 	if !response.Success {
-		return "", fmt.Errorf("daemon returned error: %s", response.Message)
+		return nil, fmt.Errorf("daemon returned error: %s", response.Message)
 	}
 
-	return response.Message, nil
+	return &response, nil
 }
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestVersionFlags(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// rewind -v prints the app version
+	appVersion = "1.2.3"
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"-v"})
+	defer func() {
+		appVersion = ""
+		showVersionFlag = false
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "1.2.3" {
+		t.Errorf("rewind -v printed %q, want 1.2.3", got)
+	}
+
+	// Subcommands' -v selects a version number
+	tests := []struct {
+		args    []string
+		version *int
+	}{
+		{[]string{"rollback", "main.go", "-v", "3"}, &versionFlag},
+		{[]string{"diff", "main.go", "-v", "3"}, &diffVersionFlag},
+		{[]string{"tag", "main.go", "stable", "-v", "3"}, &tagVersionFlag},
+	}
+	for _, tt := range tests {
+		cmd, args, err := rootCmd.Find(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Errorf("rewind %s: %v", strings.Join(tt.args, " "), err)
+			continue
+		}
+		if *tt.version != 3 {
+			t.Errorf("rewind %s selected version %d, want 3", strings.Join(tt.args, " "), *tt.version)
+		}
+		*tt.version = 0
+	}
+}
+
+func TestFindRewindRoot_RootFlag(t *testing.T) {
+	project, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(project, "nested")
+	for _, dir := range []string{filepath.Join(project, ".rewind"), filepath.Join(nested, ".rewind")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Chdir(t.TempDir())
+	rootDirFlag = project
+	defer func() { rootDirFlag = "" }()
+	if err := useRootDir(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Relative paths resolve against --root, and its project is used even
+	// for files in a nested one
+	abs, err := filepath.Abs(filepath.Join("nested", "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err := findRewindRoot(abs); err != nil || root != project {
+		t.Errorf("findRewindRoot(%q) = %q, %v, want %q", abs, root, err, project)
+	}
+
+	if _, err := findRewindRoot(filepath.Dir(project)); err == nil {
+		t.Errorf("findRewindRoot accepted a path outside --root")
+	}
+}
+
+func TestExpandConfigEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("REWIND_TEST_STATE", "/var/state")
+
+	for _, key := range []string{"audit_log", "http_addr", "include"} {
+		defer viper.Set(key, viper.Get(key))
+	}
+	viper.Set("audit_log", "${REWIND_TEST_STATE}/audit.jsonl")
+	viper.Set("http_addr", "127.0.0.1:$REWIND_TEST_PORT")
+	viper.Set("include", []string{"$literal"})
+
+	expandConfigEnv()
+
+	if got := viper.GetString("audit_log"); got != "/var/state/audit.jsonl" {
+		t.Errorf("audit_log = %q, want /var/state/audit.jsonl", got)
+	}
+	if got := viper.GetString("http_addr"); got != "127.0.0.1:" {
+		t.Errorf("http_addr = %q, want the unset variable expanded to nothing", got)
+	}
+	if got := viper.GetStringSlice("include"); len(got) != 1 || got[0] != "$literal" {
+		t.Errorf("include = %q, want patterns left as written", got)
+	}
+
+	viper.Set("audit_log", "~/audit.jsonl")
+	if got := auditLogPath(); got != filepath.Join(home, "audit.jsonl") {
+		t.Errorf("auditLogPath() = %q, want ~ expanded to %s", got, home)
+	}
+}
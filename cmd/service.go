@@ -34,37 +34,37 @@ This command will install the appropriate service file for your operating system
 		case "install":
 			if err := installService(); err != nil {
 				fmt.Printf("Error installing service: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			fmt.Println("Service installed successfully")
 		case "uninstall":
 			if err := uninstallService(); err != nil {
 				fmt.Printf("Error uninstalling service: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			fmt.Println("Service uninstalled successfully")
 		case "start":
 			if err := startService(); err != nil {
 				fmt.Printf("Error starting service: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			fmt.Println("Service started successfully")
 		case "stop":
 			if err := stopService(); err != nil {
 				fmt.Printf("Error stopping service: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			fmt.Println("Service stopped successfully")
 		case "restart":
 			if err := restartService(); err != nil {
 				fmt.Printf("Error restarting service: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			fmt.Println("Service restarted successfully")
 		case "status":
 			if err := statusService(); err != nil {
 				fmt.Printf("Error checking service status: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 		default:
 			fmt.Println("Usage: rewind service <install|uninstall|start|stop|restart|status>")
@@ -196,6 +196,7 @@ After=graphical-session.target
 [Service]
 Type=simple
 ExecStart=%s watch
+ExecReload=/bin/kill -HUP $MAINPID
 Restart=on-failure
 RestartSec=5
 
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var snapshotTagFlag string
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [--tag <tag_name>]",
+	Short: "Capture the current state of every tracked file",
+	Long: `Capture a new version of every tracked file whose content differs from its
+latest stored version, regardless of whether the daemon noticed the change.
+
+This is a manual, synchronous save point - useful before a risky operation.
+With --tag, the resulting latest version of every tracked file is tagged,
+so the whole project state can be found again later.
+
+Examples:
+  rewind snapshot                         # Capture any uncaptured changes
+  rewind snapshot --tag before-refactor   # Capture and tag every file`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSnapshot(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.Flags().StringVarP(&snapshotTagFlag, "tag", "t", "", "Tag to apply to the latest version of every tracked file")
+}
+
+func runSnapshot() error {
+	if snapshotTagFlag != "" {
+		if err := validateTagName(snapshotTagFlag); err != nil {
+			return err
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	allFiles, err := db.GetAllLatestFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get all files: %w", err)
+	}
+
+	if len(allFiles) == 0 {
+		fmt.Println("No tracked files found")
+		return nil
+	}
+
+	var captured, unchanged, missing, tagged int
+	var errors []string
+
+	for _, file := range allFiles {
+		if file.Deleted {
+			continue
+		}
+
+		absPath := filepath.Join(rewindRoot, file.FilePath)
+		if _, err := os.Stat(absPath); os.IsNotExist(err) {
+			missing++
+			continue
+		}
+
+		currentHash, err := file.HashFile(absPath)
+		if err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %v", file.FilePath, err))
+			continue
+		}
+
+		versionNumber := file.VersionNumber
+		if currentHash != file.FileHash {
+			versionNumber, err = captureFileVersion(db, absPath, database.EventOpWrite)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", file.FilePath, err))
+				continue
+			}
+			fmt.Printf("✓ %s saved as version %d\n", file.FilePath, versionNumber)
+			captured++
+		} else {
+			unchanged++
+		}
+
+		if snapshotTagFlag != "" {
+			if err := db.AddTag(absPath, versionNumber, snapshotTagFlag); err != nil {
+				errors = append(errors, fmt.Sprintf("%s: %v", file.FilePath, err))
+				continue
+			}
+			tagged++
+		}
+	}
+
+	// Print summary
+	fmt.Printf("\n✓ Snapshot completed\n")
+	fmt.Printf("✓ %d files captured, %d unchanged\n", captured, unchanged)
+	if snapshotTagFlag != "" {
+		fmt.Printf("✓ %d files tagged as '%s'\n", tagged, snapshotTagFlag)
+	}
+	if missing > 0 {
+		fmt.Printf("- %d tracked files missing from disk (see 'rewind restore')\n", missing)
+	}
+
+	if len(errors) > 0 {
+		fmt.Printf("✗ %d files failed:\n", len(errors))
+		for _, errMsg := range errors {
+			fmt.Printf("  - %s\n", errMsg)
+		}
+	}
+
+	return nil
+}
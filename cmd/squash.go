@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/spf13/cobra"
+)
+
+var (
+	squashFromFlag    int
+	squashToFlag      int
+	squashConfirmFlag bool
+)
+
+// squashCmd represents the squash command
+var squashCmd = &cobra.Command{
+	Use:   "squash <file_path> --from <version> --to <version> --confirm",
+	Short: "Collapse a range of versions of a file into one",
+	Long: `Collapse the versions of a file from --from to --to into a single version,
+tidying a run of small auto-captured changes between two meaningful points.
+
+The version given with --to keeps its content and takes the number given with
+--from. The versions before it in the range are removed, and any tags on them
+move to the kept version. Every later version is renumbered down so history
+has no gap. If the kept version would end up with the same tag twice, nothing
+is changed.
+
+Squashing deletes versions, so the change is shown for review and --confirm
+is required.
+
+Examples:
+  rewind squash src/main.go --from 5 --to 12 --confirm   # Versions 5-11 go, 12 becomes 5`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runSquash(args[0]); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(squashCmd)
+	squashCmd.Flags().IntVar(&squashFromFlag, "from", 0, "First version of the range to squash")
+	squashCmd.Flags().IntVar(&squashToFlag, "to", 0, "Last version of the range, whose content is kept")
+	squashCmd.Flags().BoolVar(&squashConfirmFlag, "confirm", false, "Review the squash and confirm it before anything is deleted")
+	squashCmd.MarkFlagRequired("from")
+	squashCmd.MarkFlagRequired("to")
+	addBytesFlag(squashCmd)
+}
+
+func runSquash(filePath string) error {
+	if !squashConfirmFlag {
+		return fmt.Errorf("squash deletes versions; review the change with --confirm")
+	}
+
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(absPath)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	plan, err := db.SquashVersions(absPath, squashFromFlag, squashToFlag, true)
+	if err != nil {
+		return err
+	}
+
+	displaySquash(plan)
+	fmt.Printf("\nContinue? [y/N]: ")
+	reader := bufio.NewReader(os.Stdin)
+	response, _ := reader.ReadString('\n')
+	response = strings.TrimSpace(strings.ToLower(response))
+	if response != "y" && response != "yes" {
+		fmt.Println("Squash cancelled")
+		return nil
+	}
+
+	result, err := db.SquashVersions(absPath, squashFromFlag, squashToFlag, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Squashed versions %d-%d of %s into version %d, reclaiming %s\n",
+		result.From, result.To, filepath.Base(filePath), result.From, formatSize(result.ReclaimedBytes))
+	return nil
+}
+
+// displaySquash describes what squashing a range of versions will do
+func displaySquash(plan *database.SquashResult) {
+	fmt.Printf("Squashing %s\n", plan.FilePath)
+	fmt.Printf("  Remove:   %s (%d versions, %s)\n", formatVersionRanges(plan.Removed), len(plan.Removed), formatSize(plan.ReclaimedBytes))
+	fmt.Printf("  Keep:     v%d, renumbered to v%d\n", plan.To, plan.From)
+	if later := plan.Renumbered - 1; later > 0 {
+		fmt.Printf("  Renumber: %d later versions, each down by %d\n", later, plan.To-plan.From)
+	}
+	if len(plan.MovedTags) > 0 {
+		fmt.Printf("  Tags:     %s move to the kept version\n", strings.Join(plan.MovedTags, ", "))
+	}
+}
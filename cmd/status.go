@@ -4,8 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -17,39 +24,141 @@ var statusCmd = &cobra.Command{
 - Running status and uptime
 - Number of active watches and directories
 - Event channel status
-- Individual watch details (only shown when in a watched directory)`,
+- Individual watch details (only shown when in a watched directory)
+
+With --dirty, lists the tracked files in the current project whose contents
+differ from their latest captured version instead: changes the daemon hasn't
+captured yet, or made while it wasn't running. This doesn't need the daemon.
+
+With --dirs, lists every directory the daemon watches for the current project,
+one per line relative to the project root.
+
+With --verbose, the settings the daemon is running with are listed too, after
+defaults and the config file were applied when it started or last reloaded.
+They show whether a change to the config file has been picked up: the daemon
+reads it at startup and when it receives SIGHUP. --json always includes them.
+
+With --since <start:seq>, only the versions captured and deletions recorded
+under the current directory after change number seq are listed, followed by
+the value to pass to the next --since. start identifies the run of the daemon
+the changes were numbered by. Tools polling the daemon use it to pick up new
+captures without fetching the full status each time. Start with --since 0. If
+changes after seq are no longer kept, or the daemon has restarted since, the
+output says so and the full status should be read again.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		jsonOutput, _ := cmd.Flags().GetBool("json")
-		if err := runStatus(jsonOutput); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		dirty, _ := cmd.Flags().GetBool("dirty")
+		dirs, _ := cmd.Flags().GetBool("dirs")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		since, _ := cmd.Flags().GetString("since")
+
+		var err error
+		if dirty && dirs {
+			err = fmt.Errorf("cannot combine --dirty and --dirs")
+		} else if cmd.Flags().Changed("since") && (dirty || dirs) {
+			err = fmt.Errorf("cannot combine --since with --dirty or --dirs")
+		} else if cmd.Flags().Changed("since") {
+			err = runChanges(since, jsonOutput)
+		} else if dirty {
+			err = runDirty(false, jsonOutput)
+		} else {
+			err = runStatus(jsonOutput, dirs, verbose)
+		}
+		if err != nil {
+			exitWithError(cmd, err)
 		}
 	},
 }
 
-func runStatus(jsonOutput bool) error {
+func runStatus(jsonOutput, dirsOnly, verbose bool) error {
 	cwd, err := os.Getwd()
 	if err != nil {
 		cwd = "."
 	}
+	// The daemon reports watch roots with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
 
 	// Try to get status from daemon via IPC
 	response, err := sendStatusIPC(cwd)
 	if err != nil {
+		if jsonOutput {
+			return err
+		}
 		fmt.Printf("Cannot connect to rewind daemon: %v\n", err)
 		fmt.Println("The rewind daemon may not be running. Try 'rewind watch' to start it.")
-		return nil
+		os.Exit(exitDaemonUnreachable)
 	}
 
 	// Parse and display the status
-	return displayStatus(response, cwd, jsonOutput)
+	return displayStatus(response, cwd, jsonOutput, dirsOnly, verbose)
+}
+
+// parseSince splits a --since value into the daemon run and the change
+// number. A bare change number has no run, as from a first query.
+func parseSince(since string) (string, uint64, error) {
+	start, seqText, found := strings.Cut(since, ":")
+	if !found {
+		start, seqText = "", since
+	}
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid --since %q: want a change number, or start:seq from the last query", since)
+	}
+	return start, seq, nil
+}
+
+// runChanges lists the changes the daemon recorded under the current
+// directory after the change since names
+func runChanges(since string, jsonOutput bool) error {
+	start, seq, err := parseSince(since)
+	if err != nil {
+		return err
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	// The daemon records watch roots with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		cwd = resolved
+	}
+
+	response, err := sendIPC(protocol.Message{Action: protocol.ActionChanges, Path: cwd, Since: seq, Start: start})
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return emitJSON("status", response.Data)
+	}
+
+	var changes watcher.Changes
+	if err := json.Unmarshal(response.Data, &changes); err != nil {
+		return fmt.Errorf("failed to parse changes: %w", err)
+	}
+
+	if changes.Reset {
+		fmt.Printf("Changes since %s are no longer all kept, or the daemon has restarted; run 'rewind status' for the full state\n", since)
+	}
+	for _, change := range changes.Changes {
+		path := filepath.Join(change.Project, change.Path)
+		if rel, err := filepath.Rel(cwd, path); err == nil {
+			path = rel
+		}
+		fmt.Printf("%d\t%s\t%s\tv%d\t%s\n", change.Seq, change.Time.Format("15:04:05"), change.Kind, change.Version, path)
+	}
+	fmt.Printf("since %s:%d\n", changes.Start, changes.Seq)
+	return nil
 }
 
 func sendStatusIPC(path string) (string, error) {
-	return sendIPCMessageWithResponse("status", path)
+	return sendIPCMessageWithResponse(protocol.ActionStatus, path)
 }
 
-func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error {
+func displayStatus(statusJSON string, currentDir string, jsonOutput, dirsOnly, verbose bool) error {
 	// Parse the status JSON
 	var status map[string]interface{}
 	if err := json.Unmarshal([]byte(statusJSON), &status); err != nil {
@@ -72,6 +181,13 @@ func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error
 		}
 	}
 
+	if dirsOnly {
+		if !inWatchedDir {
+			return withExitCode(exitNotInitialized, fmt.Errorf("%s is not in a watched project", currentDir))
+		}
+		return displayWatchDirs(status, currentWatchRoot, jsonOutput)
+	}
+
 	// Handle JSON output
 	if jsonOutput {
 		if inWatchedDir {
@@ -91,12 +207,7 @@ func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error
 			// Remove watch_details when not in a watched directory
 			delete(status, "watch_details")
 		}
-		output, err := json.Marshal(status)
-		if err != nil {
-			return fmt.Errorf("failed to marshal status to JSON: %w", err)
-		}
-		fmt.Print(string(output))
-		return nil
+		return emitJSON("status", status)
 	}
 
 	// Display overall status
@@ -117,6 +228,14 @@ func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error
 		fmt.Printf("Uptime: %s\n", uptime)
 	}
 
+	displaySkippedWatches(status)
+	displayWatchConflicts(status)
+	displayThrottledFiles(status)
+	displayRecentErrors(status)
+	if verbose {
+		displayConfig(status)
+	}
+
 
 	// Display watch details only if in a watched directory
 	if inWatchedDir {
@@ -135,9 +254,20 @@ func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error
 					ignoreCount := getFloat(watchMap, "ignore_count")
 
 					fmt.Printf("Path: %s\n", path)
+					if template := getString(watchMap, "template"); template != "" {
+						fmt.Printf("Watched As: %s\n", template)
+					}
 					fmt.Printf("Directories: %.0f\n", dirCount)
 					fmt.Printf("Ignore Patterns: %.0f\n", ignoreCount)
 
+					if warnings, ok := watchMap["warnings"].([]interface{}); ok {
+						for _, warning := range warnings {
+							if warningStr, ok := warning.(string); ok {
+								fmt.Printf("⚠️  Warning: %s\n", warningStr)
+							}
+						}
+					}
+
 					// Show all watch directories
 					if watchDirs, ok := watchMap["watch_dirs"].([]interface{}); ok && len(watchDirs) > 0 {
 						fmt.Printf("Watched Dirs: ")
@@ -173,6 +303,159 @@ func displayStatus(statusJSON string, currentDir string, jsonOutput bool) error
 	return nil
 }
 
+// displayWatchDirs prints every directory watched for the watch at root, one
+// per line relative to it
+func displayWatchDirs(status map[string]interface{}, root string, jsonOutput bool) error {
+	var dirs []string
+	watchDetails, _ := status["watch_details"].([]interface{})
+	for _, detail := range watchDetails {
+		watchMap, ok := detail.(map[string]interface{})
+		if !ok || getString(watchMap, "path") != root {
+			continue
+		}
+
+		watchDirs, _ := watchMap["watch_dirs"].([]interface{})
+		for _, dir := range watchDirs {
+			dirStr, ok := dir.(string)
+			if !ok {
+				continue
+			}
+			if rel, err := filepath.Rel(root, dirStr); err == nil {
+				dirStr = rel
+			}
+			dirs = append(dirs, dirStr)
+		}
+	}
+	sort.Strings(dirs)
+
+	if jsonOutput {
+		if dirs == nil {
+			dirs = []string{}
+		}
+		return emitJSON("status", map[string]interface{}{
+			"path":       root,
+			"watch_dirs": dirs,
+		})
+	}
+
+	for _, dir := range dirs {
+		fmt.Println(dir)
+	}
+	return nil
+}
+
+// displayConfig lists the settings the daemon is running with
+func displayConfig(status map[string]interface{}) {
+	config, ok := status["config"].(map[string]interface{})
+	if !ok {
+		// Daemons from before the config was reported
+		return
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println("\nConfiguration")
+	fmt.Println("=============")
+	for _, key := range keys {
+		fmt.Printf("%s: %s\n", key, formatConfigValue(config[key]))
+	}
+}
+
+// displaySkippedWatches lists the projects the daemon isn't watching because
+// their database failed its check when loaded, or their path uses an unset
+// environment variable
+func displaySkippedWatches(status map[string]interface{}) {
+	skipped, ok := status["skipped_watches"].([]interface{})
+	if !ok || len(skipped) == 0 {
+		return
+	}
+
+	fmt.Println("\nSkipped Projects")
+	fmt.Println("================")
+	for _, entry := range skipped {
+		watchMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fmt.Printf("✗ %s: %s\n", getString(watchMap, "path"), getString(watchMap, "error"))
+	}
+	fmt.Println("Run 'rewind doctor' in a skipped project, or set the variables its path uses, and restart the daemon once it is fixed.")
+}
+
+// displayWatchConflicts lists watched projects nested inside other watched
+// projects, whose files could be claimed by either
+func displayWatchConflicts(status map[string]interface{}) {
+	conflicts, ok := status["conflicts"].([]interface{})
+	if !ok || len(conflicts) == 0 {
+		return
+	}
+
+	fmt.Println("\nNested Projects")
+	fmt.Println("===============")
+	for _, entry := range conflicts {
+		conflictMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fmt.Printf("⚠ %s is inside %s\n", getString(conflictMap, "path"), getString(conflictMap, "within"))
+	}
+	fmt.Println("Files under a nested project are captured by that project only. Remove one of the watches with 'rewind remove' to resolve this.")
+}
+
+// displayThrottledFiles lists the files whose captures are being skipped
+// because they changed more often than max_captures_per_minute
+func displayThrottledFiles(status map[string]interface{}) {
+	throttled, ok := status["throttled_files"].([]interface{})
+	if !ok || len(throttled) == 0 {
+		return
+	}
+
+	fmt.Println("\nThrottled Files")
+	fmt.Println("===============")
+	for _, entry := range throttled {
+		fileMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		resumes := getString(fileMap, "until")
+		if t, err := time.Parse(time.RFC3339Nano, resumes); err == nil {
+			resumes = humanize.Time(t)
+		}
+		path := filepath.Join(getString(fileMap, "project"), getString(fileMap, "path"))
+		fmt.Printf("⏸ %s: %.0f changes skipped, capturing again %s\n", path, getFloat(fileMap, "skipped"), resumes)
+	}
+}
+
+// displayRecentErrors lists the files the daemon recently failed to capture,
+// newest first
+func displayRecentErrors(status map[string]interface{}) {
+	recentErrors, ok := status["recent_errors"].([]interface{})
+	if !ok || len(recentErrors) == 0 {
+		return
+	}
+
+	fmt.Println("\nRecent Errors")
+	fmt.Println("=============")
+	for _, entry := range recentErrors {
+		errMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		when := getString(errMap, "time")
+		if t, err := time.Parse(time.RFC3339Nano, when); err == nil {
+			when = humanize.Time(t)
+		}
+		path := filepath.Join(getString(errMap, "project"), getString(errMap, "path"))
+		fmt.Printf("✗ %s  %s: %s\n", when, path, getString(errMap, "message"))
+	}
+}
+
 // Helper functions for safe type assertions
 func getString(m map[string]interface{}, key string) string {
 	if val, ok := m[key].(string); ok {
@@ -190,7 +473,12 @@ func getFloat(m map[string]interface{}, key string) float64 {
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
+	addInstanceFlag(statusCmd)
 
 	// Add --json flag for JSON output
 	statusCmd.Flags().BoolP("json", "j", false, "Output status information as JSON")
+	statusCmd.Flags().Bool("dirty", false, "List tracked files that differ from their latest version")
+	statusCmd.Flags().Bool("dirs", false, "List every watched directory of the current project, one per line")
+	statusCmd.Flags().BoolP("verbose", "v", false, "Also show the configuration the daemon is running with")
+	statusCmd.Flags().String("since", "", "Only list the captures and deletions after this change (start:seq, or 0 to begin)")
 }
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -12,29 +11,60 @@ import (
 
 // tagCmd represents the tag command
 var tagCmd = &cobra.Command{
-	Use:   "tag <file_path> <tag_name> [--version <version_number>]",
+	Use:   "tag <file_path> <tag_name> [--version <version_number>] | tag <file_path> --rename <old>:<new>",
 	Short: "Add a tag to a file version",
 	Long: `Add a descriptive tag to a specific file version to make it easier to find later.
 
 By default, tags the latest version of the file. Use --version to tag a specific version.
+The tagged version's capture time, size and hash are shown, along with any
+tags it already had, so you can check you tagged the one you meant. Use
+--quiet to print nothing but errors.
 
 Examples:
   rewind tag src/main.go "stable-release"           # Tag latest version
-  rewind tag src/main.go "feature-complete" --version 5  # Tag version 5`,
-	Args: cobra.ExactArgs(2),
+  rewind tag src/main.go "feature-complete" --version 5  # Tag version 5
+  rewind tag 'src/*.go' "stable"                    # Tag every matching file
+  rewind tag src/main.go --rename stabel:stable     # Rename a tag
+
+A quoted glob pattern tags the latest version of each matching file.
+
+--rename renames a tag in place. It applies to the version carrying the old
+tag, or to the version given with --version.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if tagRenameFlag != "" {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		if err := runTag(args[0], args[1]); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		var err error
+		if tagRenameFlag != "" {
+			err = runTagRenames(args[0], tagRenameFlag)
+		} else if isFileGlob(args[0]) {
+			err = runForEachFile(args[0], "Tagged", false, func(filePath string) error {
+				return runTag(filePath, args[1])
+			})
+		} else {
+			err = runTag(args[0], args[1])
+		}
+		if err != nil {
+			exitWithError(cmd, err)
 		}
 	},
 }
 
-var tagVersionFlag int
+var (
+	tagVersionFlag int
+	tagRenameFlag  string
+	tagQuietFlag   bool
+)
 
 func init() {
 	rootCmd.AddCommand(tagCmd)
 	tagCmd.Flags().IntVarP(&tagVersionFlag, "version", "v", 0, "Version number to tag (defaults to latest)")
+	tagCmd.Flags().StringVar(&tagRenameFlag, "rename", "", "Rename a tag, given as old:new")
+	tagCmd.Flags().BoolVarP(&tagQuietFlag, "quiet", "q", false, "Print nothing but errors")
+	addBytesFlag(tagCmd)
 }
 
 func runTag(filePath, tagName string) error {
@@ -77,7 +107,7 @@ func runTag(filePath, tagName string) error {
 			return fmt.Errorf("failed to get latest version: %w", err)
 		}
 		if latestVersion == nil {
-			return fmt.Errorf("no versions found for file: %s", filePath)
+			return withExitCode(exitNotFound, fmt.Errorf("no versions found for file: %s", filePath))
 		}
 		targetVersion = latestVersion.VersionNumber
 	}
@@ -87,7 +117,102 @@ func runTag(filePath, tagName string) error {
 		return err
 	}
 
+	if tagQuietFlag {
+		return nil
+	}
+
 	fmt.Printf("✓ Tagged version %d of %s as '%s'\n", targetVersion, filepath.Base(filePath), tagName)
+	return displayTaggedVersion(db, absPath, targetVersion, tagName)
+}
+
+// displayTaggedVersion describes a version that has just been tagged
+// tagName, listing the other tags it carries
+func displayTaggedVersion(db *database.DatabaseManager, absPath string, versionNumber int, tagName string) error {
+	version, err := db.GetFileVersion(absPath, versionNumber)
+	if err != nil || version == nil {
+		return err
+	}
+
+	hashStr := version.FileHash
+	if len(hashStr) > 8 {
+		hashStr = hashStr[:8] + "..."
+	}
+	fmt.Printf("  Captured %s, %s, hash %s\n", version.Timestamp.Format("2006-01-02 15:04:05"), formatSize(version.FileSize), hashStr)
+
+	tags, err := db.GetTagsForVersion(absPath, versionNumber)
+	if err != nil {
+		return err
+	}
+	var others []string
+	for _, tag := range tags {
+		if tag.TagName != tagName {
+			others = append(others, tag.TagName)
+		}
+	}
+	if len(others) > 0 {
+		fmt.Printf("  Also tagged: %s\n", strings.Join(others, ", "))
+	}
+	return nil
+}
+
+// runTagRenames parses a --rename value and renames the tag on the file, or on
+// every file matching a glob pattern
+func runTagRenames(filePath, rename string) error {
+	oldName, newName, ok := strings.Cut(rename, ":")
+	if !ok || oldName == "" {
+		return fmt.Errorf("invalid --rename %q (use old:new)", rename)
+	}
+	if err := validateTagName(newName); err != nil {
+		return err
+	}
+	if oldName == newName {
+		return fmt.Errorf("tag is already named '%s'", newName)
+	}
+
+	if isFileGlob(filePath) {
+		return runForEachFile(filePath, "Renamed tag on", false, func(filePath string) error {
+			return runRenameTag(filePath, oldName, newName)
+		})
+	}
+	return runRenameTag(filePath, oldName, newName)
+}
+
+func runRenameTag(filePath, oldName, newName string) error {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(absPath)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Without --version, rename the tag on the version that carries it
+	targetVersion := tagVersionFlag
+	if targetVersion <= 0 {
+		version, err := db.GetVersionByTag(absPath, oldName)
+		if err != nil {
+			return err
+		}
+		targetVersion = version.VersionNumber
+	}
+
+	if err := db.RenameTag(absPath, targetVersion, oldName, newName); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Renamed tag '%s' to '%s' on version %d of %s\n", oldName, newName, targetVersion, filepath.Base(filePath))
 	return nil
 }
 
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var tagsVersionsFlag bool
+var tagsJSONFlag bool
+
+// tagsCmd represents the tags command
+var tagsCmd = &cobra.Command{
+	Use:   "tags [tag_name] [--versions] [--json]",
+	Short: "List the tags used across the project",
+	Long: `List every tag in the current project with the number of files carrying it.
+
+With --versions, each tag is followed by the versions it is on: the file,
+version number, capture time, size and hash. This makes a tag applied with
+'rewind snapshot --tag' a manifest of the project at that point. Give a tag
+name to show only that tag.
+
+--json always includes the versions.
+
+Examples:
+  rewind tags                           # List tags and how many files carry them
+  rewind tags --versions                # Also list the versions each tag is on
+  rewind tags release-2.0 --versions    # Show every file tagged release-2.0
+  rewind tags --json                    # Full manifest as JSON
+  rewind tags release-2.0 --format '{{.Version.FilePath}} {{.Version.FileHash}}'
+
+--format prints each tagged version with a Go text/template, one per line. The
+template is run against .Tag (TagName and CreatedAt) and .Version (FilePath,
+VersionNumber, Timestamp, FileHash, FileSize and the other version fields).
+The functions size, ago, date and short format a size, a time as "3 hours
+ago", a time as a date and a hash as its first 8 characters.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tagName := ""
+		if len(args) == 1 {
+			tagName = args[0]
+		}
+		if err := runTags(tagName); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+	tagsCmd.Flags().BoolVar(&tagsVersionsFlag, "versions", false, "List the versions carrying each tag")
+	tagsCmd.Flags().BoolVarP(&tagsJSONFlag, "json", "j", false, "Output tags and their versions as JSON")
+	addBytesFlag(tagsCmd)
+	addFormatFlag(tagsCmd, "tagged version")
+}
+
+// taggedVersionJSON is a version carrying a tag, as output by tags --json
+type taggedVersionJSON struct {
+	File          string `json:"file"`
+	Version       int    `json:"version"`
+	Timestamp     string `json:"timestamp"`
+	TimestampUnix int64  `json:"timestamp_unix"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Hash          string `json:"hash"`
+	TaggedAt      string `json:"tagged_at"`
+}
+
+// tagJSON is a tag and the versions it is on, as output by tags --json
+type tagJSON struct {
+	Tag      string              `json:"tag"`
+	Versions []taggedVersionJSON `json:"versions"`
+}
+
+// tagGroup is a tag and the versions carrying it
+type tagGroup struct {
+	name     string
+	versions []*database.TaggedVersion
+}
+
+func runTags(tagName string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	tagged, err := db.GetAllTagsWithVersions()
+	if err != nil {
+		return err
+	}
+
+	groups := groupByTag(tagged, tagName)
+	if tagName != "" && len(groups) == 0 {
+		return withExitCode(exitNotFound, fmt.Errorf("no versions are tagged '%s'", tagName))
+	}
+
+	if tagsJSONFlag {
+		if formatFlag != "" {
+			return fmt.Errorf("--format and --json cannot be used together")
+		}
+		return emitJSON("tags", tagsToJSON(groups))
+	}
+
+	if formatFlag != "" {
+		var rows []any
+		for _, group := range groups {
+			for _, tagged := range group.versions {
+				rows = append(rows, tagged)
+			}
+		}
+		return printFormatted(rows)
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No tags found")
+		return nil
+	}
+
+	if tagsVersionsFlag {
+		return displayTagVersions(groups)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tFILES\tLAST TAGGED")
+	fmt.Fprintln(w, "---\t-----\t-----------")
+	for _, group := range groups {
+		lastTagged := group.versions[0].Tag.CreatedAt
+		for _, version := range group.versions {
+			if version.Tag.CreatedAt.After(lastTagged) {
+				lastTagged = version.Tag.CreatedAt
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", group.name, len(group.versions), humanize.Time(lastTagged))
+	}
+	return w.Flush()
+}
+
+// groupByTag splits tagged versions, already ordered by tag name, into one
+// group per tag. With tagName, only that tag is kept.
+func groupByTag(tagged []*database.TaggedVersion, tagName string) []tagGroup {
+	var groups []tagGroup
+	for _, version := range tagged {
+		if tagName != "" && version.Tag.TagName != tagName {
+			continue
+		}
+		if len(groups) == 0 || groups[len(groups)-1].name != version.Tag.TagName {
+			groups = append(groups, tagGroup{name: version.Tag.TagName})
+		}
+		last := &groups[len(groups)-1]
+		last.versions = append(last.versions, version)
+	}
+	return groups
+}
+
+// displayTagVersions lists each tag followed by the versions carrying it
+func displayTagVersions(groups []tagGroup) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%d files)\n", group.name, len(group.versions))
+		fmt.Fprintln(w, "  FILE\tVERSION\tTIME\tSIZE\tHASH")
+		for _, tagged := range group.versions {
+			version := tagged.Version
+			hashStr := version.FileHash
+			if len(hashStr) > 8 {
+				hashStr = hashStr[:8] + "..."
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%s\t%s\t%s\n",
+				version.FilePath,
+				version.VersionNumber,
+				version.Timestamp.Format("2006-01-02 15:04:05"),
+				formatSize(version.FileSize),
+				hashStr)
+		}
+	}
+	return w.Flush()
+}
+
+func tagsToJSON(groups []tagGroup) []tagJSON {
+	result := make([]tagJSON, 0, len(groups))
+	for _, group := range groups {
+		entry := tagJSON{Tag: group.name, Versions: make([]taggedVersionJSON, 0, len(group.versions))}
+		for _, tagged := range group.versions {
+			version := tagged.Version
+			entry.Versions = append(entry.Versions, taggedVersionJSON{
+				File:          version.FilePath,
+				Version:       version.VersionNumber,
+				Timestamp:     version.Timestamp.Format("2006-01-02 15:04:05"),
+				TimestampUnix: version.Timestamp.Unix(),
+				SizeBytes:     version.FileSize,
+				Hash:          version.FileHash,
+				TaggedAt:      tagged.Tag.CreatedAt.Format("2006-01-02 15:04:05"),
+			})
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+	"github.com/spf13/cobra"
+)
+
+var traceDuration time.Duration
+
+// traceCmd represents the trace command
+var traceCmd = &cobra.Command{
+	Use:   "trace <path>",
+	Short: "Log why the daemon does or doesn't capture a path",
+	Long: `Ask the running daemon to log every event it receives for a path, and each
+decision it makes about capturing it, for a while: events dropped as
+duplicates, ignore pattern and capture filter matches, grace and quiet periods,
+throttling, and the hash comparison with the latest version.
+
+The lines go to the daemon's log at info level with trace=true, so a file that
+isn't being versioned can be followed without debug logging the whole daemon.
+Tracing a directory traces every file under it. The path need not exist yet.`,
+	Example: `  rewind trace src/main.go            # Trace a file for 10 minutes
+  rewind trace build/ --for 2m        # Trace everything under a directory`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonOutput, _ := cmd.Flags().GetBool("json")
+		if err := runTrace(args[0], traceDuration, jsonOutput); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(traceCmd)
+	addInstanceFlag(traceCmd)
+	traceCmd.Flags().DurationVar(&traceDuration, "for", watcher.DefaultTraceDuration, "How long to trace the path")
+	traceCmd.Flags().BoolP("json", "j", false, "Output the trace as JSON")
+}
+
+func runTrace(path string, duration time.Duration, jsonOutput bool) error {
+	if duration <= 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	// The daemon reports events with symlinks resolved. A file that doesn't
+	// exist yet is resolved through its directory.
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	} else if dir, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil {
+		absPath = filepath.Join(dir, filepath.Base(absPath))
+	}
+
+	response, err := sendIPC(protocol.Message{Action: protocol.ActionTrace, Path: absPath, Duration: duration.String()})
+	if err != nil {
+		return err
+	}
+
+	var trace protocol.Trace
+	if err := json.Unmarshal(response.Data, &trace); err != nil {
+		return fmt.Errorf("failed to decode trace: %w", err)
+	}
+
+	if jsonOutput {
+		return emitJSON("trace", trace)
+	}
+
+	fmt.Printf("✓ Tracing %s until %s\n", trace.Path, trace.Until.Format("15:04:05"))
+	fmt.Println("Trace lines are written to the daemon's log with trace=true")
+	return nil
+}
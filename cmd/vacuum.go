@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
+	"github.com/spf13/cobra"
+)
+
+// vacuumCmd represents the vacuum command
+var vacuumCmd = &cobra.Command{
+	Use:   "vacuum",
+	Short: "Compact the project database after large purges",
+	Long: `Compact the current project's database, returning the space left free by
+purged versions to the filesystem, and refresh its query statistics.
+
+Purging versions removes their rows, but SQLite keeps the freed pages in the
+database file for reuse. After a large purge, vacuuming shrinks the file.
+
+When the daemon is running, it does the work between captures so nothing is
+written to the database while it is rebuilt. Otherwise the database is
+compacted directly.
+
+Set vacuum_interval in the config to have the daemon do this periodically.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runVacuum(); err != nil {
+			exitWithError(cmd, err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vacuumCmd)
+	addInstanceFlag(vacuumCmd)
+}
+
+func runVacuum() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	rewindRoot, err := findRewindRoot(cwd)
+	if err != nil {
+		return fmt.Errorf("not in a rewind project: %w", err)
+	}
+
+	result, err := vacuumWithDaemon(rewindRoot)
+	if exitCode(err) == exitDaemonUnreachable {
+		fmt.Println("Daemon not running, compacting the database directly")
+		result, err = vacuumDirectly(rewindRoot)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Vacuumed database: %s -> %s, reclaimed %s\n",
+		formatSize(result.SizeBefore), formatSize(result.SizeAfter),
+		formatSize(max(result.SizeBefore-result.SizeAfter, 0)))
+	return nil
+}
+
+// vacuumWithDaemon asks the daemon to compact the project database between
+// captures
+func vacuumWithDaemon(rewindRoot string) (*database.VacuumResult, error) {
+	response, err := sendIPCRequest(protocol.ActionVacuum, rewindRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var result database.VacuumResult
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode vacuum result: %w", err)
+	}
+	return &result, nil
+}
+
+// vacuumDirectly compacts the project database without the daemon
+func vacuumDirectly(rewindRoot string) (*database.VacuumResult, error) {
+	db, err := database.NewDatabaseManager(rewindRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return db.Vacuum()
+}
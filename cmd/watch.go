@@ -1,16 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/api"
 	"github.com/davenicholson-xyz/rewind/internal/ipc"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
 	"github.com/davenicholson-xyz/rewind/internal/watcher"
-	"github.com/davenicholson-xyz/rewind/network"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var watchCmd = &cobra.Command{
@@ -23,22 +25,78 @@ watch list, creating versions when changes are detected.
 The daemon uses Unix sockets for IPC communication and supports graceful shutdown 
 via signal handling or the --stop flag.
 
+With --http (or http_addr in the config file) the daemon also serves a
+read-only JSON API for editor plugins and dashboards. A bare port binds to
+127.0.0.1; the API is off unless enabled.
+
+With --scan-only, every project in the watch list is scanned once and any new
+or changed files are captured, then rewind exits. No daemon, IPC socket or
+file watching is started, so it suits scheduled snapshots from cron.
+
+File events can be missed under heavy load or on some filesystems, such as
+network mounts. With --rescan-interval (or rescan_interval in the config), the
+daemon scans every project again this often and captures anything new or
+changed. A rescan hashes every file like the startup scan does, so pick an
+interval to suit the size of your projects.
+
+With --dry-run, the scan and file events are handled as usual but nothing is
+stored: each file that would be captured is logged to the terminal with the
+version it would become and its size, along with the deletions and renames
+that would be recorded. This shows what ignore patterns leave in, and how
+much, before trusting a large or sensitive tree to rewind. A dry run takes
+over neither the daemon socket nor the HTTP API, so it can run alongside the
+daemon; stop it with Ctrl+C. Combine it with --scan-only to check one scan.
+
 Examples:
-  rewind watch          # Start the watcher daemon
-  rewind watch --stop   # Stop the running daemon`,
+  rewind watch                  # Start the watcher daemon
+  rewind watch --stop           # Stop the running daemon
+  rewind watch --instance work  # Start a separate daemon on /tmp/rewind-work.sock
+  rewind watch --http 7373      # Also serve the HTTP API on 127.0.0.1:7373
+  rewind watch --scan-only      # Capture changes once and exit (e.g. from cron)
+  rewind watch --scan-only --dry-run # Show what a scan would capture
+  rewind watch --rescan-interval 30m # Also rescan every 30 minutes`,
 	Run: func(cmd *cobra.Command, args []string) {
 		stop, _ := cmd.Flags().GetBool("stop")
 		if stop {
 			if err := stopWatcher(); err != nil {
 				app.Logger.WithField("error", err).Error("Failed to stop watcher")
-				os.Exit(1)
+				os.Exit(exitCode(err))
 			}
 			return
 		}
 		
-		if err := runWatcher(); err != nil {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if dryRun {
+			logToConsole()
+		}
+
+		scanOnly, _ := cmd.Flags().GetBool("scan-only")
+		if scanOnly {
+			if err := runScanOnly(dryRun); err != nil {
+				exitWithError(cmd, err)
+			}
+			return
+		}
+
+		if rescan, _ := cmd.Flags().GetString("rescan-interval"); rescan != "" {
+			if _, err := parseDuration(rescan); err != nil {
+				exitWithError(cmd, fmt.Errorf("invalid --rescan-interval: %w", err))
+			}
+			viper.Set("rescan_interval", rescan)
+		}
+
+		httpAddr, _ := cmd.Flags().GetString("http")
+		if httpAddr == "" {
+			httpAddr = viper.GetString("http_addr")
+		}
+
+		if dryRun {
+			httpAddr = ""
+		}
+
+		if err := runWatcher(httpAddr, dryRun); err != nil {
 			app.Logger.WithField("error", err).Error("Watcher failed")
-			os.Exit(1)
+			os.Exit(exitCode(err))
 		}
 	},
 }
@@ -46,11 +104,16 @@ Examples:
 func init() {
 	rootCmd.AddCommand(watchCmd)
 	watchCmd.Flags().BoolP("stop", "s", false, "Stop the rewind watch process")
+	watchCmd.Flags().Bool("scan-only", false, "Scan every watched project once and exit without starting the daemon")
+	watchCmd.Flags().String("http", "", "Serve a read-only HTTP API on this address (e.g. 127.0.0.1:7373)")
+	watchCmd.Flags().Bool("dry-run", false, "Log what would be captured without storing anything")
+	watchCmd.Flags().String("rescan-interval", "", "Rescan watched projects this often for changes the watcher missed, overriding rescan_interval (e.g. 30m)")
+	addInstanceFlag(watchCmd)
 }
 
-func runWatcher() error {
+func runWatcher(httpAddr string, dryRun bool) error {
 
-	lm, err := watcher.NewWatchList()
+	lm, err := newWatchList(dryRun)
 	if err != nil {
 		return err
 	}
@@ -59,10 +122,24 @@ func runWatcher() error {
 	if err != nil {
 		return err
 	}
+	wm.DryRun = dryRun
 
-	ipc, err := ipc.NewHandler(wm)
-	if err != nil {
-		return err
+	// A dry run leaves the socket to the daemon
+	var ipcHandler *ipc.Handler
+	if !dryRun {
+		ipcHandler, err = ipc.NewHandler(wm, instanceFlag)
+		if err != nil {
+			return err
+		}
+	}
+
+	var apiServer *api.Server
+	if httpAddr != "" {
+		addr, err := api.ListenAddress(httpAddr)
+		if err != nil {
+			return err
+		}
+		apiServer = api.NewServer(wm, addr)
 	}
 
 	err = wm.Start()
@@ -71,61 +148,129 @@ func runWatcher() error {
 	}
 	defer wm.Stop()
 
-	go ipc.Start()
+	if ipcHandler != nil {
+		go ipcHandler.Start()
+	}
+
+	if apiServer != nil {
+		go func() {
+			if err := apiServer.Start(); err != nil {
+				app.Logger.WithError(err).Error("HTTP API stopped")
+			}
+		}()
+		defer func() {
+			if err := apiServer.Stop(); err != nil {
+				app.Logger.WithError(err).Warn("Failed to stop HTTP API")
+			}
+		}()
+	}
 
-	// Set up signal handling for graceful shutdown
+	// Set up signal handling for graceful shutdown, and SIGHUP to reload
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	app.Logger.Info("Watch manager started. Press Ctrl+C to stop.")
 	
 	// Block until we receive a signal OR the watch manager context is cancelled
-	select {
-	case <-sigChan:
-		app.Logger.Info("Received shutdown signal, stopping...")
-	case <-wm.Context().Done():
-		app.Logger.Info("Received stop command via IPC, stopping...")
+	for {
+		select {
+		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				reloadWatcher(wm)
+				continue
+			}
+			app.Logger.Info("Received shutdown signal, stopping...")
+		case <-wm.Context().Done():
+			app.Logger.Info("Received stop command via IPC, stopping...")
+		}
+		return nil
 	}
 
-	return nil
-
 }
 
-func stopWatcher() error {
-	app.Logger.Info("Stopping rewind watch process...")
-	
-	// Create stop message
-	message := ipc.Message{
-		Action: "stop",
-		Path:   "",
+// reloadWatcher applies the config file and watchlist to the running daemon.
+// A config file that can't be read leaves the running settings in place.
+func reloadWatcher(wm *watcher.WatchManager) {
+	app.Logger.Info("Received SIGHUP, reloading configuration and watchlist")
+
+	if err := reloadConfig(); err != nil {
+		app.Logger.WithError(err).Error("Failed to reload configuration, keeping current settings")
+		return
 	}
-	
-	messageJSON, err := json.Marshal(message)
+
+	result, err := wm.Reload(loadWatcherConfig())
+	if err != nil {
+		app.Logger.WithError(err).Error("Failed to reload watchlist")
+		return
+	}
+	if len(result.Restart) > 0 {
+		app.Logger.WithField("settings", result.Restart).Warn("Some changed settings only take effect after a restart")
+	}
+}
+
+// runScanOnly captures new and changed files in every watched project once,
+// without starting the daemon. A dry run only logs what it would capture.
+func runScanOnly(dryRun bool) error {
+	lm, err := newWatchList(dryRun)
 	if err != nil {
-		app.Logger.WithError(err).Error("Failed to marshal stop message")
 		return err
 	}
-	
-	// Send stop command via IPC
-	response, err := network.SendToIPC("/tmp/rewind.sock", string(messageJSON))
+
+	wm, err := watcher.NewWatchManager(lm)
 	if err != nil {
-		app.Logger.WithError(err).Error("Failed to send stop command")
 		return err
 	}
-	
-	// Parse response
-	var ipcResponse ipc.Response
-	if err := json.Unmarshal([]byte(response), &ipcResponse); err != nil {
-		app.Logger.WithError(err).Error("Failed to parse stop response")
+	defer wm.Stop()
+	wm.DryRun = dryRun
+
+	if len(lm.Watches) == 0 {
+		fmt.Println("No projects are being watched")
+		return nil
+	}
+
+	stats, err := wm.PerformInitialScan()
+	if err != nil {
 		return err
 	}
-	
-	if ipcResponse.Success {
-		app.Logger.Info("Stop command sent successfully")
-	} else {
-		app.Logger.WithField("message", ipcResponse.Message).Error("Stop command failed")
+
+	fmt.Printf("Scanned %d projects\n", len(lm.Watches))
+	printScanStats(stats)
+	if dryRun {
+		fmt.Println("Dry run: nothing was captured")
 	}
-	
 	return nil
 }
 
+// newWatchList loads the watchlist of the daemon instance. A dry run leaves
+// the file untouched.
+func newWatchList(dryRun bool) (*watcher.WatchList, error) {
+	if dryRun {
+		return watcher.NewDryRunWatchList(instanceFlag, loadWatcherConfig())
+	}
+	return watcher.NewWatchList(instanceFlag, loadWatcherConfig())
+}
+
+// logToConsole sends the log to the terminal as text, where a dry run
+// reports what it would do
+func logToConsole() {
+	config := app.LoggerConfigFromEnv()
+	config.Output = "console"
+	config.Format = "text"
+	if err := app.InitLogger(config); err != nil {
+		fmt.Printf("Warning: could not log to the terminal: %v\n", err)
+	}
+}
+
+func stopWatcher() error {
+	app.Logger.Info("Stopping rewind watch process...")
+
+	// Send stop command via IPC
+	message, err := sendIPCMessageWithResponse(protocol.ActionStop, "")
+	if err != nil {
+		app.Logger.WithError(err).Error("Stop command failed")
+		return err
+	}
+
+	app.Logger.WithField("message", message).Info("Stop command sent successfully")
+	return nil
+}
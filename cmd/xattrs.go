@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// restoreXattrs sets the extended attributes recorded for version on path,
+// when capture.xattrs recorded them. It runs after restoreOwnership, as
+// changing a file's owner can clear some of them. Attributes in namespaces
+// such as security usually need root, so a failure is a warning: the
+// contents have already been restored.
+func restoreXattrs(db *database.DatabaseManager, version *database.FileVersion, path string) {
+	xattrs, err := db.GetVersionXattrs(version.ID)
+	if err != nil {
+		fmt.Printf("Warning: could not read the extended attributes recorded for %s: %v\n", path, err)
+		return
+	}
+	if len(xattrs) == 0 {
+		return
+	}
+
+	if err := database.SetFileXattrs(path, xattrs); err != nil {
+		fmt.Printf("Warning: could not restore extended attributes of %s: %v\n", path, err)
+	}
+}
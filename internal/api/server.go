@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/watcher"
+)
+
+// DefaultHost is the interface the API binds to when only a port is given
+const DefaultHost = "127.0.0.1"
+
+// Server is a read-only HTTP API over the daemon's watches and version history
+type Server struct {
+	WatchManager *watcher.WatchManager
+	server       *http.Server
+}
+
+// fileJSON describes a tracked file and its latest version
+type fileJSON struct {
+	Path          string `json:"path"`
+	LatestVersion int    `json:"latest_version"`
+	Timestamp     string `json:"timestamp"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Deleted       bool   `json:"deleted"`
+}
+
+// versionJSON describes a single stored version of a file
+type versionJSON struct {
+	Version   int    `json:"version"`
+	Timestamp string `json:"timestamp"`
+	SizeBytes int64  `json:"size_bytes"`
+	Hash      string `json:"hash"`
+	EventOp   string `json:"event_op"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// ListenAddress normalises an address given on the command line. A bare port
+// or ":port" binds to the loopback interface rather than all interfaces.
+func ListenAddress(addr string) (string, error) {
+	if _, err := strconv.Atoi(addr); err == nil {
+		return net.JoinHostPort(DefaultHost, addr), nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid HTTP address %q: %w", addr, err)
+	}
+	if host == "" {
+		host = DefaultHost
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+func NewServer(wm *watcher.WatchManager, addr string) *Server {
+	s := &Server{WatchManager: wm}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /watches", s.handleWatches)
+	mux.HandleFunc("GET /files", s.handleFiles)
+	mux.HandleFunc("GET /versions", s.handleVersions)
+	mux.HandleFunc("GET /content", s.handleContent)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.checkHost(mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return s
+}
+
+// Start serves the API until Stop is called
+func (s *Server) Start() error {
+	app.Logger.WithField("address", s.server.Addr).Info("Starting HTTP API")
+
+	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP API failed: %w", err)
+	}
+	return nil
+}
+
+// Stop shuts the API down, waiting briefly for in-flight requests
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+// checkHost rejects requests whose Host header doesn't name the API by a
+// loopback name or address, or by the address it listens on. After a DNS
+// rebinding attack a web page reaches the API through a name it controls,
+// and the browser sends that name as the Host.
+func (s *Server) checkHost(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.allowedHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedHost reports whether host, as sent in a Host header, is a loopback
+// name or address or the host the API listens on
+func (s *Server) allowedHost(host string) bool {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	// An API bound to a specific address is also reached by that address
+	listenHost, _, err := net.SplitHostPort(s.server.Addr)
+	if err != nil {
+		return false
+	}
+	listenIP := net.ParseIP(listenHost)
+	return listenIP != nil && !listenIP.IsUnspecified() && host == listenHost
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.WatchManager.GetStatus())
+}
+
+func (s *Server) handleWatches(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.WatchManager.GetStatus().WatchDetails)
+}
+
+// handleFiles lists the tracked files of a project. The project parameter
+// may be omitted when the daemon watches a single project.
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	watch, err := s.findProject(r.URL.Query().Get("project"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := openDatabase(watch.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	latestFiles, err := db.GetAllLatestFiles()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	files := make([]fileJSON, 0, len(latestFiles))
+	for _, file := range latestFiles {
+		files = append(files, fileJSON{
+			Path:          filepath.Join(watch.Path, file.FilePath),
+			LatestVersion: file.VersionNumber,
+			Timestamp:     file.Timestamp.Format(time.RFC3339),
+			SizeBytes:     file.FileSize,
+			Deleted:       file.Deleted,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, files)
+}
+
+func (s *Server) handleVersions(w http.ResponseWriter, r *http.Request) {
+	filePath, watch, err := s.findFile(r.URL.Query().Get("file"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := openDatabase(watch.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	fileVersions, err := db.GetFileVersions(filePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(fileVersions) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no versions found for %s", filePath))
+		return
+	}
+
+	versions := make([]versionJSON, 0, len(fileVersions))
+	for _, version := range fileVersions {
+		versions = append(versions, versionJSON{
+			Version:   version.VersionNumber,
+			Timestamp: version.Timestamp.Format(time.RFC3339),
+			SizeBytes: version.FileSize,
+			Hash:      version.FileHash,
+			EventOp:   version.EventOp,
+			Deleted:   version.Deleted,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// handleContent returns the stored content of a version, or of the latest
+// version when none is given
+func (s *Server) handleContent(w http.ResponseWriter, r *http.Request) {
+	filePath, watch, err := s.findFile(r.URL.Query().Get("file"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := openDatabase(watch.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	var version *database.FileVersion
+	if versionParam := r.URL.Query().Get("version"); versionParam != "" {
+		versionNumber, err := strconv.Atoi(versionParam)
+		if err != nil || versionNumber < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid version: %s", versionParam))
+			return
+		}
+		version, err = db.GetFileVersion(filePath, versionNumber)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	} else {
+		version, err = db.GetLatestFileVersion(filePath)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if version == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("version not found for %s", filePath))
+		return
+	}
+	if version.IsBaseline() {
+		writeError(w, http.StatusNotFound, fmt.Errorf("version %d of %s is a baseline with no stored content", version.VersionNumber, filePath))
+		return
+	}
+
+	var content io.ReadSeeker
+	if version.IsDelta() || version.IsCompressed() {
+		rebuilt, err := db.ReadVersionContent(version)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		content = bytes.NewReader(rebuilt)
+	} else {
+		storagePath := filepath.Join(db.VersionsDir(), version.StoragePath)
+		file, err := os.Open(storagePath)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to open stored version: %w", err))
+			return
+		}
+		defer file.Close()
+		content = file
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Rewind-Version", strconv.Itoa(version.VersionNumber))
+	http.ServeContent(w, r, filepath.Base(filePath), version.Timestamp, content)
+}
+
+// findProject returns the watch for a project path, defaulting to the only
+// watch when path is empty
+func (s *Server) findProject(path string) (*watcher.Watch, error) {
+	watches := s.WatchManager.WatchList.Snapshot()
+
+	if path == "" {
+		if len(watches) == 1 {
+			return watches[0], nil
+		}
+		return nil, fmt.Errorf("project parameter is required when watching %d projects", len(watches))
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project path: %w", err)
+	}
+
+	for _, watch := range watches {
+		if watch.Path == absPath {
+			return watch, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a watched project", path)
+}
+
+// findFile resolves the file parameter to an absolute path inside a watched
+// project
+func (s *Server) findFile(path string) (string, *watcher.Watch, error) {
+	if path == "" {
+		return "", nil, fmt.Errorf("file parameter is required")
+	}
+	if !filepath.IsAbs(path) {
+		return "", nil, fmt.Errorf("file must be an absolute path")
+	}
+
+	filePath := filepath.Clean(path)
+	watch, found := s.WatchManager.WatchList.FindByPath(filePath)
+	if !found {
+		return "", nil, fmt.Errorf("%s is not in a watched project", path)
+	}
+	return filePath, watch, nil
+}
+
+func openDatabase(rootPath string) (*database.DatabaseManager, error) {
+	db, err := database.NewDatabaseManager(rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		app.Logger.WithError(err).Warn("Failed to write HTTP API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
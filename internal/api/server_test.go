@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerRejectsForeignHosts(t *testing.T) {
+	s := &Server{server: &http.Server{Addr: "192.168.1.20:8080"}}
+	handler := s.checkHost(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"127.0.0.1:8080", http.StatusOK},
+		{"localhost:8080", http.StatusOK},
+		{"LOCALHOST", http.StatusOK},
+		{"[::1]:8080", http.StatusOK},
+		{"192.168.1.20:8080", http.StatusOK},
+		{"attacker.example:8080", http.StatusForbidden},
+		{"localhost.attacker.example", http.StatusForbidden},
+		{"192.168.1.21:8080", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/content", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("Host %q: status %d, want %d", tt.host, rec.Code, tt.want)
+		}
+	}
+}
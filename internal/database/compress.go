@@ -0,0 +1,200 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CompressResult describes the outcome of compressing one version
+type CompressResult struct {
+	OriginalSize   int64
+	CompressedSize int64
+	// Skipped is set when compression would not save space, in which case
+	// the version is left as it is
+	Skipped bool
+}
+
+// readStoredFile returns the content of a version stored in full,
+// decompressing it if needed
+func (dm *DatabaseManager) readStoredFile(fv *FileVersion) ([]byte, error) {
+	data, err := os.ReadFile(dm.versionStoragePath(fv))
+	if err != nil || !fv.IsCompressed() {
+		return data, err
+	}
+
+	content, err := gunzip(data)
+	if err != nil {
+		return nil, mark(ErrCorrupt, fmt.Errorf("failed to decompress version %d of %s: %w", fv.VersionNumber, fv.FilePath, err))
+	}
+
+	if hash := fv.ContentHash(content); hash != fv.FileHash {
+		return nil, mark(ErrCorrupt, fmt.Errorf("decompressed version %d of %s does not match its recorded hash (expected %s, got %s)",
+			fv.VersionNumber, fv.FilePath, fv.FileHash, hash))
+	}
+
+	return content, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// GetCompressibleVersions returns the versions stored in full and
+// uncompressed that were captured before cutoff, oldest first.
+// Content-addressable objects may be shared by several versions, so they are
+// left uncompressed.
+func (dm *DatabaseManager) GetCompressibleVersions(cutoff time.Time) ([]*FileVersion, error) {
+	query := `
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm
+	FROM versions
+	WHERE storage_type = ? AND storage_path != '' AND storage_path NOT LIKE 'objects/%' AND timestamp < ?
+	ORDER BY timestamp ASC, id ASC
+	`
+
+	rows, err := dm.db.Query(query, StorageTypeFull, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query compressible versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*FileVersion
+	for rows.Next() {
+		fv := &FileVersion{}
+		var timestampStr string
+
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		fv.fromStored()
+
+		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		fv.Timestamp = fv.Timestamp.Local()
+
+		versions = append(versions, fv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating compressible versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// CompressVersion replaces a version stored in full with a gzip-compressed
+// copy. The stored file is checked against its recorded hash first, and the
+// compressed copy is decompressed and checked again before the database is
+// pointed at it and the original removed. With dryRun nothing is written.
+func (dm *DatabaseManager) CompressVersion(fv *FileVersion, dryRun bool) (CompressResult, error) {
+	var result CompressResult
+	if fv.StorageType != StorageTypeFull || fv.IsBaseline() {
+		return result, fmt.Errorf("version %d of %s is not stored in full", fv.VersionNumber, fv.FilePath)
+	}
+
+	storagePath := dm.versionStoragePath(fv)
+	content, err := os.ReadFile(storagePath)
+	if err != nil {
+		return result, fmt.Errorf("failed to read stored version: %w", err)
+	}
+	if hash := fv.ContentHash(content); hash != fv.FileHash {
+		return result, mark(ErrCorrupt, fmt.Errorf("stored version %d of %s does not match its recorded hash, leaving it uncompressed", fv.VersionNumber, fv.FilePath))
+	}
+
+	var compressed bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return result, err
+	}
+	if _, err := writer.Write(content); err != nil {
+		return result, fmt.Errorf("failed to compress version: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return result, fmt.Errorf("failed to compress version: %w", err)
+	}
+
+	result.OriginalSize = int64(len(content))
+	result.CompressedSize = int64(compressed.Len())
+	if result.CompressedSize >= result.OriginalSize {
+		result.Skipped = true
+		return result, nil
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	newStoragePath := fv.StoragePath + ".gz"
+	compressedPath := storagePath + ".gz"
+	if err := writeFileSynced(compressedPath, compressed.Bytes()); err != nil {
+		os.Remove(compressedPath)
+		return result, fmt.Errorf("failed to write compressed version: %w", err)
+	}
+
+	// Read back what reached the disk before trusting it over the original
+	written, err := os.ReadFile(compressedPath)
+	if err == nil {
+		written, err = gunzip(written)
+	}
+	if err != nil || fv.ContentHash(written) != fv.FileHash {
+		os.Remove(compressedPath)
+		return result, mark(ErrCorrupt, fmt.Errorf("compressed copy of version %d of %s failed verification", fv.VersionNumber, fv.FilePath))
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		os.Remove(compressedPath)
+		return result, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	res, err := tx.Exec(`UPDATE versions SET storage_type = ?, storage_path = ? WHERE id = ? AND storage_type = ?`,
+		StorageTypeGzip, filepath.ToSlash(newStoragePath), fv.ID, StorageTypeFull)
+	if err == nil {
+		var updated int64
+		if updated, err = res.RowsAffected(); err == nil && updated == 0 {
+			err = fmt.Errorf("version was changed or removed while it was being compressed")
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		os.Remove(compressedPath)
+		return result, fmt.Errorf("failed to record compressed version: %w", err)
+	}
+
+	if err := os.Remove(storagePath); err != nil {
+		fmt.Printf("Warning: failed to delete uncompressed copy %s: %v\n", storagePath, err)
+	}
+
+	fv.StorageType = StorageTypeGzip
+	fv.StoragePath = newStoragePath
+	dm.keepLatestLink(fv.FilePath)
+	return result, nil
+}
+
+// writeFileSynced writes data to path and flushes it to disk
+func writeFileSynced(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressVersion(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	content := []byte(strings.Repeat("compressible line\n", 200))
+	storagePath := filepath.Join("file.txt", "v1")
+	if err := os.MkdirAll(filepath.Join(root, ".rewind", "versions", "file.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".rewind", "versions", storagePath), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.AddFileVersion(&FileVersion{
+		FilePath:      "file.txt",
+		VersionNumber: 1,
+		Timestamp:     time.Now(),
+		FileHash:      fmt.Sprintf("%x", sha256.Sum256(content)),
+		FileSize:      int64(len(content)),
+		StoragePath:   storagePath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := dm.GetCompressibleVersions(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("GetCompressibleVersions() returned %d versions, want 1", len(versions))
+	}
+
+	result, err := dm.CompressVersion(versions[0], false)
+	if err != nil {
+		t.Fatalf("CompressVersion() error = %v", err)
+	}
+	if result.Skipped || result.CompressedSize >= result.OriginalSize {
+		t.Errorf("CompressVersion() = %+v, want a smaller copy", result)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".rewind", "versions", storagePath)); !os.IsNotExist(err) {
+		t.Errorf("uncompressed copy still exists")
+	}
+
+	latest, err := dm.GetLatestFileVersion(filepath.Join(root, "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.IsCompressed() {
+		t.Errorf("version not recorded as compressed: %+v", latest)
+	}
+
+	got, err := dm.ReadVersionContent(latest)
+	if err != nil {
+		t.Fatalf("ReadVersionContent() error = %v", err)
+	}
+	if string(got) != string(content) {
+		t.Errorf("ReadVersionContent() returned different content")
+	}
+
+	if versions, err := dm.GetCompressibleVersions(time.Now().Add(time.Minute)); err != nil || len(versions) != 0 {
+		t.Errorf("GetCompressibleVersions() = %d versions, %v; want none after compressing", len(versions), err)
+	}
+}
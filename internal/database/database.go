@@ -3,8 +3,10 @@ package database
 import (
 	"crypto/sha256"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,7 +24,82 @@ type FileVersion struct {
 	FileHash      string
 	FileSize      int64
 	StoragePath   string
+	StorageType   string
+	EventOp       string
 	Deleted       bool
+
+	// MatchesVersion is the latest earlier version of the file with the same
+	// content, such as the version a manual revert went back to, or zero.
+	// Only GetRecentVersions fills it in.
+	MatchesVersion int
+
+	// Owner is the file's owner when it was captured, recorded only when
+	// capture_ownership is enabled. It is written by AddFileVersion; read it
+	// back with GetVersionOwner.
+	Owner *Owner
+
+	// Xattrs are the file's extended attributes when it was captured,
+	// recorded only when capture.xattrs is enabled. Like Owner, they are
+	// written by AddFileVersion; read them back with GetVersionXattrs.
+	Xattrs map[string][]byte
+
+	// HashAlgorithm is the algorithm FileHash was calculated with. Empty
+	// means SHA256, which every version captured before the algorithm was
+	// configurable used.
+	HashAlgorithm string
+}
+
+// Owner is the numeric user and group owning a file
+type Owner struct {
+	UID int
+	GID int
+}
+
+// Operations that cause a version to be captured
+const (
+	EventOpCreate   = "CREATE"
+	EventOpWrite    = "WRITE"
+	EventOpRename   = "RENAME"
+	EventOpRollback = "ROLLBACK"
+)
+
+// IsBaseline reports whether the version only records the file's hash.
+// Baseline versions have no stored content to restore or diff against.
+func (fv *FileVersion) IsBaseline() bool {
+	return fv.StoragePath == ""
+}
+
+// IsDelta reports whether the version is stored as a patch against the
+// version before it
+func (fv *FileVersion) IsDelta() bool {
+	return fv.StorageType == StorageTypeDelta
+}
+
+// IsCompressed reports whether the version's full content is stored
+// compressed
+func (fv *FileVersion) IsCompressed() bool {
+	return fv.StorageType == StorageTypeGzip
+}
+
+func (fv *FileVersion) storageType() string {
+	if fv.StorageType == "" {
+		return StorageTypeFull
+	}
+	return fv.StorageType
+}
+
+func (fv *FileVersion) eventOp() string {
+	if fv.EventOp == "" {
+		return EventOpWrite
+	}
+	return fv.EventOp
+}
+
+// fromStored converts the paths of a version read from the database, which
+// are stored with forward slashes, to the platform's separator
+func (fv *FileVersion) fromStored() {
+	fv.FilePath = filepath.FromSlash(fv.FilePath)
+	fv.StoragePath = filepath.FromSlash(fv.StoragePath)
 }
 
 // Tag represents a version tag in the database
@@ -35,9 +112,12 @@ type Tag struct {
 
 // DatabaseManager handles all database operations
 type DatabaseManager struct {
-	db      *sql.DB
-	rootDir string
-	dbPath  string
+	db       *sql.DB
+	rootDir  string
+	storeDir string
+	dbPath   string
+	global   bool
+	foldCase bool // Match stored paths regardless of case
 }
 
 // NewDatabaseManager creates a new database manager instance
@@ -46,13 +126,66 @@ func NewDatabaseManager(rootDir string) (*DatabaseManager, error) {
 	dbPath := filepath.Join(rewindDir, "versions.db")
 
 	dm := &DatabaseManager{
-		rootDir: rootDir,
-		dbPath:  dbPath,
+		rootDir:  rootDir,
+		storeDir: rewindDir,
+		dbPath:   dbPath,
+	}
+
+	return dm, nil
+}
+
+// NewGlobalDatabaseManager creates a database manager for the per-user global
+// store in ~/.local/share/rewind, which versions files outside any project.
+// Files are recorded by their path from the filesystem root.
+func NewGlobalDatabaseManager() (*DatabaseManager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	storeDir := filepath.Join(home, ".local", "share", "rewind")
+	dm := &DatabaseManager{
+		rootDir:  filepath.VolumeName(home) + string(filepath.Separator),
+		storeDir: storeDir,
+		dbPath:   filepath.Join(storeDir, "global.db"),
+		global:   true,
 	}
 
 	return dm, nil
 }
 
+// RootDir returns the directory stored file paths are relative to
+func (dm *DatabaseManager) RootDir() string {
+	return dm.rootDir
+}
+
+// VersionsDir returns the directory holding the stored versions
+func (dm *DatabaseManager) VersionsDir() string {
+	return filepath.Join(dm.storeDir, "versions")
+}
+
+// IsGlobal reports whether this is the global store rather than a project's
+// database
+func (dm *DatabaseManager) IsGlobal() bool {
+	return dm.global
+}
+
+// relPath returns a path relative to the project root in the form stored in
+// the database. Stored paths use forward slashes on every platform, so a
+// project's history reads the same wherever it is opened. Paths that can't be
+// made relative are stored as given.
+func (dm *DatabaseManager) relPath(path string) string {
+	relPath, err := filepath.Rel(dm.rootDir, path)
+	if err != nil {
+		relPath = path
+	}
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	if dm.foldCase {
+		return dm.matchStoredCase(relPath)
+	}
+	return relPath
+}
+
 // InitDatabase creates the .rewind directory and initializes the database schema
 func (dm *DatabaseManager) InitDatabase() error {
 	rewindDir := filepath.Dir(dm.dbPath)
@@ -63,7 +196,8 @@ func (dm *DatabaseManager) InitDatabase() error {
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite", dm.dbPath)
+	dm.detectPathCase()
+	db, err := sql.Open("sqlite", dm.dataSourceName())
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -74,24 +208,46 @@ func (dm *DatabaseManager) InitDatabase() error {
 		return fmt.Errorf("failed to create database schema: %w", err)
 	}
 
-	return nil
+	if err := dm.migrateSchema(); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
+	return dm.addPathCaseIndex()
+}
+
+// dataSourceName returns the connection string for the database. The daemon
+// and the CLI write to the same database, so wait for a lock rather than
+// failing immediately with SQLITE_BUSY. LIKE ignores ASCII case by default,
+// so path prefixes only match regardless of case when the database folds
+// path case.
+func (dm *DatabaseManager) dataSourceName() string {
+	dsn := dm.dbPath + "?_pragma=busy_timeout(5000)"
+	if !dm.foldCase {
+		dsn += "&_pragma=case_sensitive_like(1)"
+	}
+	return dsn
 }
 
 // Connect opens a connection to an existing database
 func (dm *DatabaseManager) Connect() error {
 	// Check if database exists
 	if _, err := os.Stat(dm.dbPath); os.IsNotExist(err) {
-		return fmt.Errorf("database does not exist at %s. Run 'rewind init' first", dm.dbPath)
+		return mark(ErrNotInitialized, fmt.Errorf("database does not exist at %s. Run 'rewind init' first", dm.dbPath))
 	}
 
 	// Open database connection
-	db, err := sql.Open("sqlite", dm.dbPath)
+	dm.detectPathCase()
+	db, err := sql.Open("sqlite", dm.dataSourceName())
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 	dm.db = db
 
-	return nil
+	if err := dm.migrateSchema(); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+
+	return dm.addPathCaseIndex()
 }
 
 // createSchema creates the database tables and indexes
@@ -105,10 +261,30 @@ func (dm *DatabaseManager) createSchema() error {
 		file_hash TEXT NOT NULL,
 		file_size INTEGER NOT NULL,
 		storage_path TEXT NOT NULL,
+		storage_type TEXT NOT NULL DEFAULT 'full',
+		event_op TEXT NOT NULL DEFAULT 'WRITE',
 		deleted BOOLEAN NOT NULL DEFAULT 0,
+		uid INTEGER,
+		gid INTEGER,
+		xattrs TEXT,
+		hash_algorithm TEXT NOT NULL DEFAULT 'sha256',
 		UNIQUE(file_path, version_number)
 	);
 
+	CREATE TABLE IF NOT EXISTS directories (
+		path TEXT PRIMARY KEY,
+		deleted BOOLEAN NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS renames (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_path TEXT NOT NULL,
+		rename_from TEXT NOT NULL,
+		version_id INTEGER NOT NULL,
+		timestamp TEXT NOT NULL
+	);
+
 	CREATE TABLE IF NOT EXISTS tags (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		version_id INTEGER NOT NULL,
@@ -129,6 +305,107 @@ func (dm *DatabaseManager) createSchema() error {
 	return err
 }
 
+// schemaColumns lists the columns added to the versions table after it was
+// first released, with the definition used to add them to older databases.
+// Existing rows take the column's default.
+var schemaColumns = []struct {
+	name       string
+	definition string
+}{
+	{"storage_type", "TEXT NOT NULL DEFAULT 'full'"},
+	{"event_op", "TEXT NOT NULL DEFAULT 'WRITE'"},
+	{"uid", "INTEGER"},
+	{"gid", "INTEGER"},
+	{"xattrs", "TEXT"},
+	{"hash_algorithm", "TEXT NOT NULL DEFAULT 'sha256'"},
+}
+
+// migrateSchema adds columns introduced after a database was created
+func (dm *DatabaseManager) migrateSchema() error {
+	for _, column := range schemaColumns {
+		exists, err := dm.hasColumn("versions", column.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		_, err = dm.db.Exec(fmt.Sprintf("ALTER TABLE versions ADD COLUMN %s %s", column.name, column.definition))
+		if err != nil {
+			// Another process may have migrated the database first
+			if exists, checkErr := dm.hasColumn("versions", column.name); checkErr == nil && exists {
+				continue
+			}
+			return fmt.Errorf("failed to add %s column: %w", column.name, err)
+		}
+	}
+
+	return dm.migratePathSeparators()
+}
+
+// storedPathColumns lists every column holding a path relative to the project
+// root
+var storedPathColumns = []struct {
+	table  string
+	column string
+}{
+	{"versions", "file_path"},
+	{"versions", "storage_path"},
+	{"directories", "path"},
+	{"renames", "file_path"},
+	{"renames", "rename_from"},
+}
+
+// migratePathSeparators converts paths stored with backslashes by earlier
+// Windows builds to forward slashes. Elsewhere a backslash is a valid file
+// name character, so paths are only converted on Windows.
+func (dm *DatabaseManager) migratePathSeparators() error {
+	if filepath.Separator != '\\' {
+		return nil
+	}
+
+	for _, stored := range storedPathColumns {
+		exists, err := dm.hasColumn(stored.table, stored.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+
+		query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = REPLACE(%[2]s, '\', '/') WHERE INSTR(%[2]s, '\') > 0`, stored.table, stored.column)
+		if _, err := dm.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to convert %s.%s to forward slashes: %w", stored.table, stored.column, err)
+		}
+	}
+
+	return nil
+}
+
+// hasColumn reports whether a table has the named column
+func (dm *DatabaseManager) hasColumn(table, column string) (bool, error) {
+	rows, err := dm.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to read %s columns: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
 // Close closes the database connection
 func (dm *DatabaseManager) Close() error {
 	if dm.db != nil {
@@ -137,6 +414,49 @@ func (dm *DatabaseManager) Close() error {
 	return nil
 }
 
+// IntegrityCheck runs SQLite's integrity check and returns an error describing
+// any problems it finds
+func (dm *DatabaseManager) IntegrityCheck() error {
+	return dm.runCheck("integrity_check")
+}
+
+// QuickCheck runs SQLite's quick check, which skips matching indexes against
+// their tables and so is fast enough to run whenever a project is loaded
+func (dm *DatabaseManager) QuickCheck() error {
+	return dm.runCheck("quick_check")
+}
+
+// runCheck runs a checking pragma and returns an error describing any
+// problems it reports
+func (dm *DatabaseManager) runCheck(pragma string) error {
+	name := strings.ReplaceAll(pragma, "_", " ")
+
+	rows, err := dm.db.Query("PRAGMA " + pragma)
+	if err != nil {
+		return fmt.Errorf("failed to run %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to read %s result: %w", name, err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s result: %w", name, err)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s failed: %s", name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 // GetDatabasePath returns the path to the database file
 func (dm *DatabaseManager) GetDatabasePath() string {
 	return dm.dbPath
@@ -151,30 +471,90 @@ func (dm *DatabaseManager) DatabaseExists() bool {
 // AddFileVersion adds a new file version to the database
 func (dm *DatabaseManager) AddFileVersion(fv *FileVersion) error {
 	query := `
-	INSERT INTO versions (file_path, version_number, timestamp, file_hash, file_size, storage_path, deleted)
-	VALUES (?, ?, ?, ?, ?, ?, ?)
+	INSERT INTO versions (file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, uid, gid, xattrs, hash_algorithm)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := dm.db.Exec(query, fv.FilePath, fv.VersionNumber, fv.Timestamp.UTC().Format("2006-01-02 15:04:05"),
-		fv.FileHash, fv.FileSize, fv.StoragePath, fv.Deleted)
+	var uid, gid sql.NullInt64
+	if fv.Owner != nil {
+		uid = sql.NullInt64{Int64: int64(fv.Owner.UID), Valid: true}
+		gid = sql.NullInt64{Int64: int64(fv.Owner.GID), Valid: true}
+	}
+
+	// Values are arbitrary bytes, which JSON keeps as base64
+	var xattrs sql.NullString
+	if fv.Xattrs != nil {
+		encoded, err := json.Marshal(fv.Xattrs)
+		if err != nil {
+			return fmt.Errorf("failed to encode extended attributes: %w", err)
+		}
+		xattrs = sql.NullString{String: string(encoded), Valid: true}
+	}
+
+	if dm.foldCase {
+		if err := dm.adoptPathCase(filepath.ToSlash(fv.FilePath)); err != nil {
+			return err
+		}
+	}
+
+	_, err := dm.db.Exec(query, filepath.ToSlash(fv.FilePath), fv.VersionNumber, fv.Timestamp.UTC().Format("2006-01-02 15:04:05"),
+		fv.FileHash, fv.FileSize, filepath.ToSlash(fv.StoragePath), fv.storageType(), fv.eventOp(), fv.Deleted, uid, gid, xattrs, fv.hashAlgorithm())
 
 	if err != nil {
 		return fmt.Errorf("failed to add file version: %w", err)
 	}
 
+	dm.keepLatestLink(filepath.ToSlash(fv.FilePath))
 	return nil
 }
 
+// GetVersionOwner returns the owner recorded for a version, or nil if its
+// ownership was not captured
+func (dm *DatabaseManager) GetVersionOwner(versionID int64) (*Owner, error) {
+	var uid, gid sql.NullInt64
+	err := dm.db.QueryRow("SELECT uid, gid FROM versions WHERE id = ?", versionID).Scan(&uid, &gid)
+	if err == sql.ErrNoRows {
+		return nil, mark(ErrNotFound, fmt.Errorf("version %d not found", versionID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version owner: %w", err)
+	}
+
+	if !uid.Valid || !gid.Valid {
+		return nil, nil
+	}
+	return &Owner{UID: int(uid.Int64), GID: int(gid.Int64)}, nil
+}
+
+// GetVersionXattrs returns the extended attributes recorded for a version,
+// or nil if they were not captured
+func (dm *DatabaseManager) GetVersionXattrs(versionID int64) (map[string][]byte, error) {
+	var encoded sql.NullString
+	err := dm.db.QueryRow("SELECT xattrs FROM versions WHERE id = ?", versionID).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, mark(ErrNotFound, fmt.Errorf("version %d not found", versionID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get version extended attributes: %w", err)
+	}
+
+	if !encoded.Valid {
+		return nil, nil
+	}
+	var xattrs map[string][]byte
+	if err := json.Unmarshal([]byte(encoded.String), &xattrs); err != nil {
+		return nil, fmt.Errorf("failed to decode extended attributes of version %d: %w", versionID, err)
+	}
+	return xattrs, nil
+}
+
 // GetLatestFileVersion retrieves the latest version of a file from the database
 func (dm *DatabaseManager) GetLatestFileVersion(filePath string) (*FileVersion, error) {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	query := `
-	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, deleted
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm
 	FROM versions 
 	WHERE file_path = ?
 	ORDER BY version_number DESC
@@ -186,8 +566,8 @@ func (dm *DatabaseManager) GetLatestFileVersion(filePath string) (*FileVersion,
 	fv := &FileVersion{}
 	var timestampStr string
 
-	err = row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr,
-		&fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.Deleted)
+	err := row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr,
+		&fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -195,6 +575,7 @@ func (dm *DatabaseManager) GetLatestFileVersion(filePath string) (*FileVersion,
 		}
 		return nil, fmt.Errorf("failed to get latest file version: %w", err)
 	}
+	fv.fromStored()
 
 	// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
 	fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -209,13 +590,35 @@ func (dm *DatabaseManager) GetLatestFileVersion(filePath string) (*FileVersion,
 	return fv, nil
 }
 
-// GetNextVersionNumber returns the next version number for a file
-func (dm *DatabaseManager) GetNextVersionNumber(filePath string) (int, error) {
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
+// FindVersionByHash returns the latest version of a file whose content has
+// the given hash, or nil if it never had that content
+func (dm *DatabaseManager) FindVersionByHash(filePath, fileHash string) (*FileVersion, error) {
+	relPath := dm.relPath(filePath)
+
+	query := `
+	SELECT version_number
+	FROM versions
+	WHERE file_path = ? AND file_hash = ?
+	ORDER BY version_number DESC
+	LIMIT 1
+	`
+
+	var versionNumber int
+	err := dm.db.QueryRow(query, relPath, fileHash).Scan(&versionNumber)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		relPath = filePath
+		return nil, fmt.Errorf("failed to find version by hash: %w", err)
 	}
 
+	return dm.GetFileVersion(filePath, versionNumber)
+}
+
+// GetNextVersionNumber returns the next version number for a file
+func (dm *DatabaseManager) GetNextVersionNumber(filePath string) (int, error) {
+	relPath := dm.relPath(filePath)
+
 	query := `
 	SELECT COALESCE(MAX(version_number), 0) + 1
 	FROM versions 
@@ -223,7 +626,7 @@ func (dm *DatabaseManager) GetNextVersionNumber(filePath string) (int, error) {
 	`
 
 	var nextVersion int
-	err = dm.db.QueryRow(query, relPath).Scan(&nextVersion)
+	err := dm.db.QueryRow(query, relPath).Scan(&nextVersion)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get next version number: %w", err)
 	}
@@ -233,42 +636,65 @@ func (dm *DatabaseManager) GetNextVersionNumber(filePath string) (int, error) {
 
 // CalculateFileHash calculates SHA256 hash of a file
 func CalculateFileHash(filePath string) (string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return "", fmt.Errorf("failed to calculate hash: %w", err)
-	}
+	return CalculateFileHashWith(filePath, HashSHA256)
+}
 
-	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+// CopyAndHash copies src to dst and returns the SHA256 hash of the content
+// copied, so a file can be stored and hashed with a single read
+func CopyAndHash(dst io.Writer, src io.Reader) (string, error) {
+	return CopyAndHashWith(dst, src, HashSHA256)
 }
 
 // CreateStoragePath creates a storage path for a file version
 func (dm *DatabaseManager) CreateStoragePath(filePath string, versionNumber int) string {
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	return dm.storagePathAt(dm.relPath(filePath), versionNumber, time.Now())
+}
 
-	// Remove any leading "./" or "\" from the relative path
-	relPath = filepath.Clean(relPath)
-	if strings.HasPrefix(relPath, "./") {
-		relPath = relPath[2:]
+// storagePathAt returns the storage path of a version captured at the given
+// time, mirroring the project tree where it fits
+func (dm *DatabaseManager) storagePathAt(relPath string, versionNumber int, captured time.Time) string {
+	relPath = filepath.FromSlash(relPath)
+	timestamp := captured.Format("20060102_150405")
+	versionName := fmt.Sprintf("v%d_%s", versionNumber, timestamp)
+
+	storagePath := filepath.Join(relPath, versionName)
+	if dm.storagePathFits(storagePath) {
+		return storagePath
 	}
 
-	now := time.Now()
-	timestamp := now.Format("20060102_150405")
+	// Mirroring the project tree would exceed the filesystem's limits, so
+	// store the version under a hash of its path instead. The database
+	// records where each version is, so nothing relies on the layout.
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(relPath)))
+	return filepath.Join(hash[:2], hash+"_"+versionName)
+}
+
+// Filesystems commonly limit file names to 255 bytes and paths to 4096.
+// Storage paths are kept below these with room for a temporary suffix.
+const (
+	maxStorageNameLen = 255 - 16
+	maxStoragePathLen = 4096 - 16
+)
+
+// storagePathFits reports whether a storage path is within the name and path
+// length limits once placed in the version store
+func (dm *DatabaseManager) storagePathFits(storagePath string) bool {
+	fullPath := filepath.Join(dm.VersionsDir(), storagePath)
+	if len(fullPath) > maxStoragePathLen {
+		return false
+	}
 
-	return filepath.Join(relPath, fmt.Sprintf("v%d_%s", versionNumber, timestamp))
+	for _, name := range strings.Split(storagePath, string(filepath.Separator)) {
+		if len(name) > maxStorageNameLen {
+			return false
+		}
+	}
+	return true
 }
 
 func (dm *DatabaseManager) GetAllLatestFiles() ([]*FileVersion, error) {
 	query := `
-	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.deleted
+	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.storage_type, v.event_op, v.deleted, v.hash_algorithm
 		FROM versions v
 		INNER JOIN (
 			SELECT file_path, MAX(version_number) as max_version
@@ -289,10 +715,11 @@ func (dm *DatabaseManager) GetAllLatestFiles() ([]*FileVersion, error) {
 		fv := &FileVersion{}
 		var timestampStr string
 
-		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.Deleted)
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file version: %w", err)
 		}
+		fv.fromStored()
 
 		// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
 		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -316,18 +743,10 @@ func (dm *DatabaseManager) GetAllLatestFiles() ([]*FileVersion, error) {
 
 func (dm *DatabaseManager) GetFileVersions(absPath string) ([]*FileVersion, error) {
 
-	relPath, err := filepath.Rel(dm.rootDir, absPath)
-	if err != nil {
-		relPath = absPath
-	}
-
-	relPath = filepath.Clean(relPath)
-	if strings.HasPrefix(relPath, "./") {
-		relPath = relPath[2:]
-	}
+	relPath := dm.relPath(absPath)
 
 	query := `
-	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.deleted
+	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.storage_type, v.event_op, v.deleted, v.hash_algorithm
 	FROM versions v
 	WHERE v.file_path = ?
 	ORDER BY v.version_number DESC
@@ -344,10 +763,11 @@ func (dm *DatabaseManager) GetFileVersions(absPath string) ([]*FileVersion, erro
 		fv := &FileVersion{}
 		var timestampStr string
 
-		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.Deleted)
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan file version: %w", err)
 		}
+		fv.fromStored()
 
 		// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
 		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -370,13 +790,10 @@ func (dm *DatabaseManager) GetFileVersions(absPath string) ([]*FileVersion, erro
 }
 
 func (dm *DatabaseManager) GetFileVersion(absPath string, version int) (*FileVersion, error) {
-	relPath, err := filepath.Rel(dm.rootDir, absPath)
-	if err != nil {
-		relPath = absPath
-	}
+	relPath := dm.relPath(absPath)
 
 	query := `
-	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, deleted
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm
 	FROM versions 
 	WHERE file_path = ? AND version_number = ?
 	`
@@ -386,8 +803,8 @@ func (dm *DatabaseManager) GetFileVersion(absPath string, version int) (*FileVer
 	fv := &FileVersion{}
 	var timestampStr string
 
-	err = row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr,
-		&fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.Deleted)
+	err := row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr,
+		&fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -395,6 +812,7 @@ func (dm *DatabaseManager) GetFileVersion(absPath string, version int) (*FileVer
 		}
 		return nil, fmt.Errorf("failed to get file version: %w", err)
 	}
+	fv.fromStored()
 
 	// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
 	fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -413,10 +831,7 @@ func (dm *DatabaseManager) GetFileVersion(absPath string, version int) (*FileVer
 // MarkFileDeleted marks the latest version of a file as deleted
 func (dm *DatabaseManager) MarkFileDeleted(filePath string) error {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	// Get the latest version to mark as deleted
 	latestVersion, err := dm.GetLatestFileVersion(filePath)
@@ -425,7 +840,7 @@ func (dm *DatabaseManager) MarkFileDeleted(filePath string) error {
 	}
 
 	if latestVersion == nil {
-		return fmt.Errorf("no versions found for file: %s", relPath)
+		return mark(ErrNotFound, fmt.Errorf("no versions found for file: %s", relPath))
 	}
 
 	// Update the latest version to mark it as deleted
@@ -440,20 +855,27 @@ func (dm *DatabaseManager) MarkFileDeleted(filePath string) error {
 		return fmt.Errorf("failed to mark file as deleted: %w", err)
 	}
 
+	dm.keepLatestLink(relPath)
 	return nil
 }
 
 // GetAllDeletedFiles returns all files that are currently marked as deleted
 func (dm *DatabaseManager) GetAllDeletedFiles() ([]*FileVersion, error) {
+	return dm.GetDeletedFilesUnder("")
+}
+
+// GetDeletedFilesUnder returns the files currently marked as deleted whose
+// relative path starts with prefix
+func (dm *DatabaseManager) GetDeletedFilesUnder(prefix string) ([]*FileVersion, error) {
 	query := `
-	SELECT DISTINCT file_path, MAX(version_number) as version_number, timestamp, file_hash, file_size, storage_path 
+	SELECT DISTINCT file_path, MAX(version_number) as version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, hash_algorithm 
 	FROM versions 
-	WHERE deleted = 1 
+	WHERE deleted = 1 AND file_path LIKE ? ESCAPE '\'
 	GROUP BY file_path
 	ORDER BY timestamp DESC
 	`
 
-	rows, err := dm.db.Query(query)
+	rows, err := dm.db.Query(query, likePrefix(prefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query deleted files: %w", err)
 	}
@@ -464,10 +886,11 @@ func (dm *DatabaseManager) GetAllDeletedFiles() ([]*FileVersion, error) {
 		fv := &FileVersion{Deleted: true}
 		var timestampStr string
 
-		err := rows.Scan(&fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath)
+		err := rows.Scan(&fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.HashAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan deleted file row: %w", err)
 		}
+		fv.fromStored()
 
 		// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
 		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -488,10 +911,7 @@ func (dm *DatabaseManager) GetAllDeletedFiles() ([]*FileVersion, error) {
 // RestoreFile marks a deleted file as not deleted and returns the file version to restore
 func (dm *DatabaseManager) RestoreFile(filePath string) (*FileVersion, error) {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	// Get the latest version (should be deleted)
 	latestVersion, err := dm.GetLatestFileVersion(filePath)
@@ -500,7 +920,7 @@ func (dm *DatabaseManager) RestoreFile(filePath string) (*FileVersion, error) {
 	}
 
 	if latestVersion == nil {
-		return nil, fmt.Errorf("no versions found for file: %s", relPath)
+		return nil, mark(ErrNotFound, fmt.Errorf("no versions found for file: %s", relPath))
 	}
 
 	if !latestVersion.Deleted {
@@ -521,6 +941,7 @@ func (dm *DatabaseManager) RestoreFile(filePath string) (*FileVersion, error) {
 
 	latestVersion.Deleted = false
 	latestVersion.Timestamp = time.Now()
+	dm.keepLatestLink(relPath)
 
 	return latestVersion, nil
 }
@@ -528,18 +949,15 @@ func (dm *DatabaseManager) RestoreFile(filePath string) (*FileVersion, error) {
 // AddTag adds a tag to a specific version
 func (dm *DatabaseManager) AddTag(filePath string, versionNumber int, tagName string) error {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	// Get the version ID for the specified file and version number
 	var versionID int64
 	query := `SELECT id FROM versions WHERE file_path = ? AND version_number = ? AND deleted = 0`
-	err = dm.db.QueryRow(query, relPath, versionNumber).Scan(&versionID)
+	err := dm.db.QueryRow(query, relPath, versionNumber).Scan(&versionID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("version %d not found for file %s", versionNumber, relPath)
+			return mark(ErrNotFound, fmt.Errorf("version %d not found for file %s", versionNumber, relPath))
 		}
 		return fmt.Errorf("failed to get version ID: %w", err)
 	}
@@ -557,13 +975,41 @@ func (dm *DatabaseManager) AddTag(filePath string, versionNumber int, tagName st
 	return nil
 }
 
+// RenameTag renames a tag on a specific version of a file. The new name must
+// not already be used on that version.
+func (dm *DatabaseManager) RenameTag(filePath string, versionNumber int, oldName, newName string) error {
+	relPath := dm.relPath(filePath)
+
+	query := `
+	UPDATE tags
+	SET tag_name = ?
+	WHERE tag_name = ?
+	  AND version_id = (SELECT id FROM versions WHERE file_path = ? AND version_number = ?)
+	`
+
+	result, err := dm.db.Exec(query, newName, oldName, relPath, versionNumber)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("tag '%s' already exists for version %d", newName, versionNumber)
+		}
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+
+	renamed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+	if renamed == 0 {
+		return mark(ErrNotFound, fmt.Errorf("no tag '%s' on version %d of %s", oldName, versionNumber, relPath))
+	}
+
+	return nil
+}
+
 // GetTagsForVersion returns all tags for a specific version
 func (dm *DatabaseManager) GetTagsForVersion(filePath string, versionNumber int) ([]*Tag, error) {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	query := `
 	SELECT t.id, t.version_id, t.tag_name, t.created_at
@@ -605,13 +1051,10 @@ func (dm *DatabaseManager) GetTagsForVersion(filePath string, versionNumber int)
 // GetVersionByTag returns a file version by tag name
 func (dm *DatabaseManager) GetVersionByTag(filePath string, tagName string) (*FileVersion, error) {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	query := `
-	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.deleted
+	SELECT v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.storage_type, v.event_op, v.deleted, v.hash_algorithm
 	FROM versions v
 	JOIN tags t ON v.id = t.version_id
 	WHERE v.file_path = ? AND t.tag_name = ? AND v.deleted = 0
@@ -621,13 +1064,14 @@ func (dm *DatabaseManager) GetVersionByTag(filePath string, tagName string) (*Fi
 
 	fv := &FileVersion{}
 	var timestampStr string
-	err = row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.Deleted)
+	err := row.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no version found with tag '%s' for file %s", tagName, relPath)
+			return nil, mark(ErrNotFound, fmt.Errorf("no version found with tag '%s' for file %s", tagName, relPath))
 		}
 		return nil, fmt.Errorf("failed to get version by tag: %w", err)
 	}
+	fv.fromStored()
 
 	// Parse timestamp
 	fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
@@ -642,10 +1086,7 @@ func (dm *DatabaseManager) GetVersionByTag(filePath string, tagName string) (*Fi
 // GetAllTagsForFile returns all tags for all versions of a file
 func (dm *DatabaseManager) GetAllTagsForFile(filePath string) (map[int][]*Tag, error) {
 	// Convert to relative path for consistent storage
-	relPath, err := filepath.Rel(dm.rootDir, filePath)
-	if err != nil {
-		relPath = filePath
-	}
+	relPath := dm.relPath(filePath)
 
 	query := `
 	SELECT v.version_number, t.id, t.version_id, t.tag_name, t.created_at
@@ -685,9 +1126,77 @@ func (dm *DatabaseManager) GetAllTagsForFile(filePath string) (map[int][]*Tag, e
 	return tagsByVersion, nil
 }
 
+// TaggedVersion is a tag together with the version it is on
+type TaggedVersion struct {
+	Tag     *Tag
+	Version *FileVersion
+}
+
+// GetAllTagsWithVersions returns every tag in the project with the version it
+// is on, ordered by tag name, then file path and version number
+func (dm *DatabaseManager) GetAllTagsWithVersions() ([]*TaggedVersion, error) {
+	query := `
+	SELECT t.id, t.version_id, t.tag_name, t.created_at,
+		v.id, v.file_path, v.version_number, v.timestamp, v.file_hash, v.file_size, v.storage_path, v.storage_type, v.event_op, v.deleted, v.hash_algorithm
+	FROM tags t
+	JOIN versions v ON t.version_id = v.id
+	WHERE v.deleted = 0
+	ORDER BY t.tag_name, v.file_path, v.version_number
+	`
+
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tagged []*TaggedVersion
+	for rows.Next() {
+		tag := &Tag{}
+		fv := &FileVersion{}
+		var createdAtStr, timestampStr string
+
+		err := rows.Scan(&tag.ID, &tag.VersionID, &tag.TagName, &createdAtStr,
+			&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag row: %w", err)
+		}
+		fv.fromStored()
+
+		tag.CreatedAt, err = time.Parse("2006-01-02 15:04:05", createdAtStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tag timestamp: %w", err)
+		}
+		tag.CreatedAt = tag.CreatedAt.Local()
+
+		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		fv.Timestamp = fv.Timestamp.Local()
+
+		tagged = append(tagged, &TaggedVersion{Tag: tag, Version: fv})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	return tagged, nil
+}
+
+// likePrefix returns a LIKE pattern matching stored paths that start with
+// prefix, for use with ESCAPE '\'. The match ignores case only when the
+// database folds path case.
+func likePrefix(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(filepath.ToSlash(prefix)) + "%"
+}
+
 // GetVersionsForPurge returns version IDs to be purged based on keep-last strategy
-// Excludes tagged versions and ensures at least one version remains per file
-func (dm *DatabaseManager) GetVersionsForPurge(keepLast int) ([]int64, error) {
+// Excludes tagged versions and ensures at least one version remains per file.
+// Only files whose relative path starts with relPrefix are considered.
+func (dm *DatabaseManager) GetVersionsForPurge(keepLast int, relPrefix string) ([]int64, error) {
 	if keepLast < 1 {
 		return nil, fmt.Errorf("keepLast must be at least 1")
 	}
@@ -698,10 +1207,11 @@ func (dm *DatabaseManager) GetVersionsForPurge(keepLast int) ([]int64, error) {
 	LEFT JOIN tags t ON v.id = t.version_id
 	WHERE v.deleted = 0
 	  AND t.version_id IS NULL
+	  AND v.file_path LIKE ? ESCAPE '\'
 	ORDER BY v.file_path, v.version_number DESC
 	`
 
-	rows, err := dm.db.Query(query)
+	rows, err := dm.db.Query(query, likePrefix(relPrefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query versions: %w", err)
 	}
@@ -745,20 +1255,64 @@ func (dm *DatabaseManager) GetVersionsForPurge(keepLast int) ([]int64, error) {
 	return versionsToPurge, nil
 }
 
-// GetVersionsForPurgeByAge returns version IDs to be purged based on age
-// Excludes tagged versions and ensures at least one version remains per file
-func (dm *DatabaseManager) GetVersionsForPurgeByAge(olderThan time.Time) ([]int64, error) {
-	query := `
-	SELECT v.id, v.file_path, v.timestamp
+// GetVersionsForPurgeForFile returns the IDs of a file's untagged versions
+// beyond the newest keepLast, oldest last
+func (dm *DatabaseManager) GetVersionsForPurgeForFile(filePath string, keepLast int) ([]int64, error) {
+	if keepLast < 1 {
+		return nil, fmt.Errorf("keepLast must be at least 1")
+	}
+
+	relPath := dm.relPath(filePath)
+
+	query := `
+	SELECT v.id
+	FROM versions v
+	LEFT JOIN tags t ON v.id = t.version_id
+	WHERE v.file_path = ?
+	  AND v.deleted = 0
+	  AND t.version_id IS NULL
+	ORDER BY v.version_number DESC
+	LIMIT -1 OFFSET ?
+	`
+
+	rows, err := dm.db.Query(query, relPath, keepLast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versionsToPurge []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		versionsToPurge = append(versionsToPurge, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return versionsToPurge, nil
+}
+
+// GetVersionsForPurgeByAge returns version IDs to be purged based on age
+// Excludes tagged versions and ensures at least one version remains per file.
+// Only files whose relative path starts with relPrefix are considered.
+func (dm *DatabaseManager) GetVersionsForPurgeByAge(olderThan time.Time, relPrefix string) ([]int64, error) {
+	query := `
+	SELECT v.id, v.file_path, v.timestamp
 	FROM versions v
 	LEFT JOIN tags t ON v.id = t.version_id
 	WHERE v.deleted = 0
 	  AND t.version_id IS NULL
 	  AND v.timestamp < ?
+	  AND v.file_path LIKE ? ESCAPE '\'
 	ORDER BY v.file_path, v.timestamp DESC
 	`
 
-	rows, err := dm.db.Query(query, olderThan.UTC().Format("2006-01-02 15:04:05"))
+	rows, err := dm.db.Query(query, olderThan.UTC().Format("2006-01-02 15:04:05"), likePrefix(relPrefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query versions by age: %w", err)
 	}
@@ -787,10 +1341,11 @@ func (dm *DatabaseManager) GetVersionsForPurgeByAge(olderThan time.Time) ([]int6
 	SELECT file_path, COUNT(*) as total_count
 	FROM versions
 	WHERE deleted = 0
+	  AND file_path LIKE ? ESCAPE '\'
 	GROUP BY file_path
 	`
 	
-	countRows, err := dm.db.Query(countQuery)
+	countRows, err := dm.db.Query(countQuery, likePrefix(relPrefix))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query version counts: %w", err)
 	}
@@ -828,114 +1383,333 @@ func (dm *DatabaseManager) GetVersionsForPurgeByAge(olderThan time.Time) ([]int6
 }
 
 // GetVersionsForPurgeBySize returns version IDs to be purged to keep total size under maxSize
-// Excludes tagged versions and ensures at least one version remains per file
-func (dm *DatabaseManager) GetVersionsForPurgeBySize(maxSize int64) ([]int64, error) {
-	// First get current total size
-	totalSizeQuery := `
-	SELECT COALESCE(SUM(file_size), 0) as total_size
-	FROM versions
-	WHERE deleted = 0
-	`
-	
-	var currentSize int64
-	err := dm.db.QueryRow(totalSizeQuery).Scan(&currentSize)
+// Excludes tagged versions and the latest version of each file, so at least one version remains per file.
+// Only files whose relative path starts with relPrefix are considered, and
+// maxSize applies to their combined stored size, as measured by GetStoredSize.
+// A version's file only counts as reclaimed once every version sharing it is
+// selected.
+func (dm *DatabaseManager) GetVersionsForPurgeBySize(maxSize int64, relPrefix string) ([]int64, error) {
+	copies, sizes, err := dm.storedCopies(relPrefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get current total size: %w", err)
+		return nil, err
 	}
-	
+
 	// If we're already under the limit, nothing to purge
+	currentSize := storedSize(copies, sizes)
 	if currentSize <= maxSize {
 		return []int64{}, nil
 	}
-	
-	// Get all versions ordered by timestamp (oldest first), excluding tagged versions
+
+	references := make(map[string]int)
+	for _, c := range copies {
+		references[c.key]++
+	}
+
+	// Select versions to purge, starting with oldest
+	var versionsToPurge []int64
+	sizeToRemove := currentSize - maxSize
+	removedSize := int64(0)
+	selected := make(map[string]int) // Track how many of each file's references we're removing
+
+	for _, c := range copies {
+		if !c.inScope || !c.purgeable {
+			continue
+		}
+
+		versionsToPurge = append(versionsToPurge, c.id)
+		selected[c.key]++
+		if selected[c.key] == references[c.key] {
+			removedSize += sizes[c.key]
+		}
+
+		// Stop if we've removed enough to get under the limit
+		if removedSize >= sizeToRemove {
+			break
+		}
+	}
+
+	return versionsToPurge, nil
+}
+
+// ThinningPolicy describes how version history decays with age. Every version
+// younger than KeepAll is kept; older versions are thinned to one per hour up
+// to HourlyFor, one per day up to DailyFor, and one per week beyond that.
+type ThinningPolicy struct {
+	KeepAll   time.Duration
+	HourlyFor time.Duration
+	DailyFor  time.Duration
+}
+
+// DefaultThinningPolicy keeps everything from the last hour, hourly versions
+// for the last day, daily versions for the last month and weekly beyond
+func DefaultThinningPolicy() ThinningPolicy {
+	return ThinningPolicy{
+		KeepAll:   time.Hour,
+		HourlyFor: 24 * time.Hour,
+		DailyFor:  30 * 24 * time.Hour,
+	}
+}
+
+// bucket returns the retention bucket a version of the given age belongs to,
+// or "" if the version is young enough to always be kept
+func (p ThinningPolicy) bucket(timestamp time.Time, age time.Duration) string {
+	switch {
+	case age < p.KeepAll:
+		return ""
+	case age < p.HourlyFor:
+		return "hour:" + timestamp.Format("2006-01-02 15")
+	case age < p.DailyFor:
+		return "day:" + timestamp.Format("2006-01-02")
+	default:
+		year, week := timestamp.ISOWeek()
+		return fmt.Sprintf("week:%d-%02d", year, week)
+	}
+}
+
+// GetVersionsForPurgeByThinning returns version IDs to be purged so that each
+// file keeps one version per retention bucket of the policy. The newest version
+// in a bucket is kept. Tagged versions and the latest version of each file are
+// always kept. Only files whose relative path starts with relPrefix are
+// considered.
+func (dm *DatabaseManager) GetVersionsForPurgeByThinning(policy ThinningPolicy, relPrefix string) ([]int64, error) {
 	query := `
-	SELECT v.id, v.file_path, v.file_size, v.timestamp
+	SELECT v.id, v.file_path, v.timestamp, COUNT(t.id) > 0 AS tagged
 	FROM versions v
 	LEFT JOIN tags t ON v.id = t.version_id
 	WHERE v.deleted = 0
-	  AND t.version_id IS NULL
-	ORDER BY v.timestamp ASC
+	  AND v.file_path LIKE ? ESCAPE '\'
+	GROUP BY v.id
+	ORDER BY v.file_path, v.version_number DESC
 	`
-	
-	rows, err := dm.db.Query(query)
+
+	rows, err := dm.db.Query(query, likePrefix(relPrefix))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query versions by size: %w", err)
+		return nil, fmt.Errorf("failed to query versions for thinning: %w", err)
 	}
 	defer rows.Close()
-	
-	type versionInfo struct {
-		id       int64
-		filePath string
-		size     int64
-		timestamp string
-	}
-	
-	var versions []versionInfo
+
+	now := time.Now()
+	var versionsToPurge []int64
+	var currentFile string
+	keptBuckets := make(map[string]bool)
+
 	for rows.Next() {
-		var v versionInfo
-		if err := rows.Scan(&v.id, &v.filePath, &v.size, &v.timestamp); err != nil {
+		var id int64
+		var filePath, timestampStr string
+		var tagged bool
+
+		if err := rows.Scan(&id, &filePath, &timestampStr, &tagged); err != nil {
 			return nil, fmt.Errorf("failed to scan version row: %w", err)
 		}
-		versions = append(versions, v)
+
+		timestamp, err := time.ParseInLocation("2006-01-02 15:04:05", timestampStr, time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		timestamp = timestamp.Local()
+
+		// Rows are grouped by file, newest first, so the first row of each
+		// file is its latest version
+		if filePath != currentFile {
+			currentFile = filePath
+			clear(keptBuckets)
+			keptBuckets[policy.bucket(timestamp, now.Sub(timestamp))] = true
+			continue
+		}
+
+		bucket := policy.bucket(timestamp, now.Sub(timestamp))
+		if bucket == "" || tagged {
+			keptBuckets[bucket] = true
+			continue
+		}
+
+		if keptBuckets[bucket] {
+			versionsToPurge = append(versionsToPurge, id)
+			continue
+		}
+		keptBuckets[bucket] = true
 	}
-	
+
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
-	
-	// Get file counts to ensure we don't remove all versions of a file
-	fileCounts := make(map[string]int)
-	countQuery := `
-	SELECT file_path, COUNT(*) as total_count
+
+	return versionsToPurge, nil
+}
+
+// FileSizeSummary is the number and total size of a file's versions
+type FileSizeSummary struct {
+	FilePath string
+	Versions int
+	Bytes    int64
+}
+
+// versionIDArgs builds the IN clause placeholders and arguments for versionIDs
+func versionIDArgs(versionIDs []int64) (string, []interface{}) {
+	placeholders := strings.Repeat("?,", len(versionIDs)-1) + "?"
+	args := make([]interface{}, len(versionIDs))
+	for i, id := range versionIDs {
+		args[i] = id
+	}
+	return placeholders, args
+}
+
+// GetVersionsSizeSum returns the total stored size in bytes of the given
+// versions. Baseline versions take no space.
+func (dm *DatabaseManager) GetVersionsSizeSum(versionIDs []int64) (int64, error) {
+	if len(versionIDs) == 0 {
+		return 0, nil
+	}
+
+	placeholders, args := versionIDArgs(versionIDs)
+	query := fmt.Sprintf(`
+	SELECT COALESCE(SUM(CASE WHEN storage_path != '' THEN file_size ELSE 0 END), 0)
+	FROM versions
+	WHERE id IN (%s)
+	`, placeholders)
+
+	var total int64
+	if err := dm.db.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to sum version sizes: %w", err)
+	}
+
+	return total, nil
+}
+
+// GetVersionsSizeByFile returns the number and total size of the given
+// versions for each file, largest first
+func (dm *DatabaseManager) GetVersionsSizeByFile(versionIDs []int64) ([]*FileSizeSummary, error) {
+	if len(versionIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders, args := versionIDArgs(versionIDs)
+	query := fmt.Sprintf(`
+	SELECT file_path, COUNT(*), COALESCE(SUM(CASE WHEN storage_path != '' THEN file_size ELSE 0 END), 0) AS bytes
+	FROM versions
+	WHERE id IN (%s)
+	GROUP BY file_path
+	ORDER BY bytes DESC, file_path
+	`, placeholders)
+
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query version sizes: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*FileSizeSummary
+	for rows.Next() {
+		summary := &FileSizeSummary{}
+		if err := rows.Scan(&summary.FilePath, &summary.Versions, &summary.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan version sizes: %w", err)
+		}
+		summary.FilePath = filepath.FromSlash(summary.FilePath)
+		summaries = append(summaries, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating version sizes: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// GetStoredSize returns the total stored size in bytes of the versions of
+// files whose relative path starts with relPrefix, the size --max-size keeps
+// under. It is measured from the files in the store, so deltas and
+// compressed versions count at their stored size and a file shared by
+// several versions counts once. Baseline versions take no space.
+func (dm *DatabaseManager) GetStoredSize(relPrefix string) (int64, error) {
+	copies, sizes, err := dm.storedCopies(relPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current total size: %w", err)
+	}
+
+	return storedSize(copies, sizes), nil
+}
+
+// GetStoredSizeByFile returns the number and total stored size of the
+// versions of each file whose relative path starts with relPrefix
+func (dm *DatabaseManager) GetStoredSizeByFile(relPrefix string) ([]*FileSizeSummary, error) {
+	query := `
+	SELECT file_path, COUNT(*), COALESCE(SUM(CASE WHEN storage_path != '' THEN file_size ELSE 0 END), 0)
 	FROM versions
 	WHERE deleted = 0
+	  AND file_path LIKE ? ESCAPE '\'
 	GROUP BY file_path
+	ORDER BY file_path
 	`
-	
-	countRows, err := dm.db.Query(countQuery)
+
+	rows, err := dm.db.Query(query, likePrefix(relPrefix))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query version counts: %w", err)
+		return nil, fmt.Errorf("failed to query file sizes: %w", err)
 	}
-	defer countRows.Close()
-	
-	for countRows.Next() {
-		var filePath string
-		var count int
-		if err := countRows.Scan(&filePath, &count); err != nil {
-			return nil, fmt.Errorf("failed to scan count row: %w", err)
+	defer rows.Close()
+
+	var summaries []*FileSizeSummary
+	for rows.Next() {
+		summary := &FileSizeSummary{}
+		if err := rows.Scan(&summary.FilePath, &summary.Versions, &summary.Bytes); err != nil {
+			return nil, fmt.Errorf("failed to scan file size: %w", err)
 		}
-		fileCounts[filePath] = count
+		summary.FilePath = filepath.FromSlash(summary.FilePath)
+		summaries = append(summaries, summary)
 	}
-	
-	if err := countRows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating count rows: %w", err)
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating file sizes: %w", err)
 	}
-	
-	// Select versions to purge, starting with oldest
-	var versionsToPurge []int64
-	sizeToRemove := currentSize - maxSize
-	removedSize := int64(0)
-	filesToRemove := make(map[string]int) // Track how many versions we're removing per file
-	
-	for _, v := range versions {
-		// Check if we can remove this version without leaving the file with 0 versions
-		if filesToRemove[v.filePath]+1 >= fileCounts[v.filePath] {
-			// Skip this version as it would leave the file with no versions
-			continue
+
+	return summaries, nil
+}
+
+// GetVersionsByIDs returns the given versions ordered by file and version
+// number
+func (dm *DatabaseManager) GetVersionsByIDs(versionIDs []int64) ([]*FileVersion, error) {
+	if len(versionIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders, args := versionIDArgs(versionIDs)
+	query := fmt.Sprintf(`
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm
+	FROM versions
+	WHERE id IN (%s)
+	ORDER BY file_path, version_number
+	`, placeholders)
+
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	defer rows.Close()
+
+	var fileVersions []*FileVersion
+	for rows.Next() {
+		fv := &FileVersion{}
+		var timestampStr string
+
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan file version: %w", err)
 		}
-		
-		versionsToPurge = append(versionsToPurge, v.id)
-		removedSize += v.size
-		filesToRemove[v.filePath]++
-		
-		// Stop if we've removed enough to get under the limit
-		if removedSize >= sizeToRemove {
-			break
+		fv.fromStored()
+
+		timestamp, err := time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 		}
+		fv.Timestamp = timestamp.Local()
+
+		fileVersions = append(fileVersions, fv)
 	}
-	
-	return versionsToPurge, nil
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return fileVersions, nil
 }
 
 // RemoveVersions removes specified versions from both database and filesystem
@@ -944,6 +1718,11 @@ func (dm *DatabaseManager) RemoveVersions(versionIDs []int64) error {
 		return nil
 	}
 
+	// Deltas that build on a removed version must be stored in full first
+	if err := dm.keyframeDependentDeltas(versionIDs); err != nil {
+		return err
+	}
+
 	// First, get storage paths for file deletion
 	storagePaths := make(map[int64]string)
 	
@@ -972,22 +1751,13 @@ func (dm *DatabaseManager) RemoveVersions(versionIDs []int64) error {
 		if err := rows.Scan(&id, &storagePath); err != nil {
 			return fmt.Errorf("failed to scan storage path: %w", err)
 		}
-		storagePaths[id] = storagePath
+		storagePaths[id] = filepath.FromSlash(storagePath)
 	}
 
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("error iterating storage paths: %w", err)
 	}
 
-	// Delete physical files
-	for _, storagePath := range storagePaths {
-		fullPath := filepath.Join(dm.rootDir, ".rewind", "versions", storagePath)
-		if err := os.Remove(fullPath); err != nil {
-			// Log error but continue - the file might already be deleted
-			fmt.Printf("Warning: failed to delete file %s: %v\n", fullPath, err)
-		}
-	}
-
 	// Delete from database
 	deleteQuery := fmt.Sprintf(`
 	DELETE FROM versions
@@ -999,5 +1769,284 @@ func (dm *DatabaseManager) RemoveVersions(versionIDs []int64) error {
 		return fmt.Errorf("failed to delete versions from database: %w", err)
 	}
 
+	// Delete physical files no remaining version shares
+	paths := make([]string, 0, len(storagePaths))
+	for _, storagePath := range storagePaths {
+		paths = append(paths, storagePath)
+	}
+	dm.removeUnreferenced(paths)
+
+	return nil
+}
+
+// GetRecentVersions returns the most recently captured versions across all
+// files, newest first. A non-empty eventOp only returns versions captured by
+// that operation; a limit of 0 returns every version.
+func (dm *DatabaseManager) GetRecentVersions(limit int, eventOp string) ([]*FileVersion, error) {
+	var versions []*FileVersion
+	err := dm.EachRecentVersion(limit, eventOp, func(fv *FileVersion) error {
+		versions = append(versions, fv)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// EachRecentVersion calls fn with each version GetRecentVersions would
+// return, as it is read, so a long history can be listed without holding it
+// all in memory. It stops at the first error fn returns.
+func (dm *DatabaseManager) EachRecentVersion(limit int, eventOp string, fn func(*FileVersion) error) error {
+	query := `
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm,
+		(SELECT MAX(earlier.version_number) FROM versions earlier
+		 WHERE earlier.file_path = versions.file_path AND earlier.file_hash = versions.file_hash
+		 AND earlier.version_number < versions.version_number)
+	FROM versions
+	WHERE ? = '' OR event_op = ?
+	ORDER BY timestamp DESC, id DESC
+	LIMIT ?
+	`
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := dm.db.Query(query, eventOp, eventOp, limit)
+	if err != nil {
+		return fmt.Errorf("failed to query recent versions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		fv := &FileVersion{}
+		var timestampStr string
+		var matches sql.NullInt64
+
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm, &matches)
+		if err != nil {
+			return fmt.Errorf("failed to scan version row: %w", err)
+		}
+		fv.fromStored()
+		fv.MatchesVersion = int(matches.Int64)
+
+		// Parse timestamp as UTC (since we stored it as UTC), then convert to local time
+		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		fv.Timestamp = fv.Timestamp.Local()
+
+		if err := fn(fv); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating recent versions: %w", err)
+	}
+
+	return nil
+}
+
+// ensureDirectoriesTable creates the directories table in databases created
+// before directory tracking existed
+func (dm *DatabaseManager) ensureDirectoriesTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS directories (
+		path TEXT PRIMARY KEY,
+		deleted BOOLEAN NOT NULL DEFAULT 0,
+		timestamp TEXT NOT NULL
+	);
+	`
+
+	if _, err := dm.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create directories table: %w", err)
+	}
+	return nil
+}
+
+// RecordDirectory records that a directory exists, clearing any deleted mark
+func (dm *DatabaseManager) RecordDirectory(dirPath string) error {
+	if err := dm.ensureDirectoriesTable(); err != nil {
+		return err
+	}
+
+	relPath := dm.relPath(dirPath)
+
+	query := `
+	INSERT INTO directories (path, deleted, timestamp)
+	VALUES (?, 0, ?)
+	ON CONFLICT(path) DO UPDATE SET deleted = 0, timestamp = excluded.timestamp
+	WHERE directories.deleted = 1
+	`
+
+	_, err := dm.db.Exec(query, relPath, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("failed to record directory: %w", err)
+	}
+
+	return nil
+}
+
+// MarkDirectoryDeleted marks a tracked directory and every tracked directory
+// below it as deleted. It reports whether any directory was marked.
+func (dm *DatabaseManager) MarkDirectoryDeleted(dirPath string) (bool, error) {
+	if err := dm.ensureDirectoriesTable(); err != nil {
+		return false, err
+	}
+
+	relPath := dm.relPath(dirPath)
+
+	query := `
+	UPDATE directories
+	SET deleted = 1, timestamp = ?
+	WHERE deleted = 0
+	  AND (path = ? OR path LIKE ? ESCAPE '\')
+	`
+
+	result, err := dm.db.Exec(query, time.Now().UTC().Format("2006-01-02 15:04:05"),
+		relPath, likePrefix(relPath+"/"))
+	if err != nil {
+		return false, fmt.Errorf("failed to mark directory as deleted: %w", err)
+	}
+
+	marked, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to mark directory as deleted: %w", err)
+	}
+
+	return marked > 0, nil
+}
+
+// GetDeletedDirectoriesUnder returns the relative paths of tracked directories
+// marked as deleted that are below the directory prefix or are that directory
+// itself, parents first
+func (dm *DatabaseManager) GetDeletedDirectoriesUnder(prefix string) ([]string, error) {
+	if err := dm.ensureDirectoriesTable(); err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT path
+	FROM directories
+	WHERE deleted = 1
+	  AND (path LIKE ? ESCAPE '\' OR path = ?)
+	ORDER BY path
+	`
+
+	rows, err := dm.db.Query(query, likePrefix(prefix), strings.TrimSuffix(filepath.ToSlash(prefix), "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deleted directories: %w", err)
+	}
+	defer rows.Close()
+
+	var directories []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted directory row: %w", err)
+		}
+		directories = append(directories, filepath.FromSlash(path))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deleted directories: %w", err)
+	}
+
+	return directories, nil
+}
+
+// ensureRenamesTable creates the renames table in databases created before
+// rename tracking existed
+func (dm *DatabaseManager) ensureRenamesTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS renames (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_path TEXT NOT NULL,
+		rename_from TEXT NOT NULL,
+		version_id INTEGER NOT NULL,
+		timestamp TEXT NOT NULL
+	);
+	`
+
+	if _, err := dm.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create renames table: %w", err)
+	}
+	return nil
+}
+
+// RecordRename links the history of filePath to the file it was renamed
+// from. versionID is the first version captured under the new name; earlier
+// versions of filePath belong to a file that used the name before.
+func (dm *DatabaseManager) RecordRename(filePath, renamedFrom string, versionID int64) error {
+	if err := dm.ensureRenamesTable(); err != nil {
+		return err
+	}
+
+	relPath := dm.relPath(filePath)
+	fromRelPath := dm.relPath(renamedFrom)
+
+	query := `
+	INSERT INTO renames (file_path, rename_from, version_id, timestamp)
+	VALUES (?, ?, ?, ?)
+	`
+
+	_, err := dm.db.Exec(query, relPath, fromRelPath, versionID, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("failed to record rename: %w", err)
+	}
+
 	return nil
 }
+
+// GetFileVersionsFollowingRenames returns the versions of a file followed by
+// the versions of each file it was renamed from, newest first. Each version
+// keeps the path it was captured under.
+func (dm *DatabaseManager) GetFileVersionsFollowingRenames(absPath string) ([]*FileVersion, error) {
+	if err := dm.ensureRenamesTable(); err != nil {
+		return nil, err
+	}
+
+	relPath := dm.relPath(absPath)
+
+	query := `
+	SELECT rename_from, version_id
+	FROM renames
+	WHERE file_path = ? AND version_id <= ?
+	ORDER BY version_id DESC
+	LIMIT 1
+	`
+
+	var history []*FileVersion
+	var maxID int64 = math.MaxInt64
+	for {
+		// The rename that started this name's current lineage, if any
+		var renamedFrom string
+		var firstID int64
+		err := dm.db.QueryRow(query, relPath, maxID).Scan(&renamedFrom, &firstID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to query renames: %w", err)
+		}
+		renamed := err == nil
+
+		versions, err := dm.GetFileVersions(filepath.Join(dm.rootDir, relPath))
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			if version.ID <= maxID && (!renamed || version.ID >= firstID) {
+				history = append(history, version)
+			}
+		}
+
+		if !renamed {
+			return history, nil
+		}
+
+		// Each step only looks at older versions, so a chain of renames that
+		// returns to an earlier name still ends
+		relPath, maxID = renamedFrom, firstID-1
+	}
+}
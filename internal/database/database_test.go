@@ -0,0 +1,704 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDB returns an initialised database for a project in a temporary
+// directory, and the project's root. The database is closed when the test ends.
+func newTestDB(t *testing.T) (*DatabaseManager, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	dm, err := NewDatabaseManager(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	return dm, root
+}
+
+func TestGetFileVersionsFollowingRenames(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	add := func(relPath string, version int) *FileVersion {
+		t.Helper()
+		fv := &FileVersion{
+			FilePath:      relPath,
+			VersionNumber: version,
+			Timestamp:     time.Now(),
+			FileHash:      relPath,
+			StoragePath:   filepath.Join(relPath, "v"),
+		}
+		if err := dm.AddFileVersion(fv); err != nil {
+			t.Fatal(err)
+		}
+		latest, err := dm.GetLatestFileVersion(filepath.Join(root, relPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return latest
+	}
+	rename := func(to, from string, first *FileVersion) {
+		t.Helper()
+		if err := dm.RecordRename(filepath.Join(root, to), filepath.Join(root, from), first.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a.txt is renamed to b.txt, which is renamed to c.txt. A new a.txt is
+	// then created and is not part of c.txt's history.
+	add("a.txt", 1)
+	add("a.txt", 2)
+	rename("b.txt", "a.txt", add("b.txt", 1))
+	rename("c.txt", "b.txt", add("c.txt", 1))
+	add("c.txt", 2)
+	add("a.txt", 3)
+
+	versions, err := dm.GetFileVersionsFollowingRenames(filepath.Join(root, "c.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"c.txt@2", "c.txt@1", "b.txt@1", "a.txt@2", "a.txt@1"}
+	if len(versions) != len(want) {
+		t.Fatalf("got %d versions, want %d", len(versions), len(want))
+	}
+	for i, version := range versions {
+		got := fmt.Sprintf("%s@%d", version.FilePath, version.VersionNumber)
+		if got != want[i] {
+			t.Errorf("version %d = %s, want %s", i, got, want[i])
+		}
+	}
+}
+
+func TestCreateStoragePath_LongPaths(t *testing.T) {
+	root := t.TempDir()
+	dm, err := NewDatabaseManager(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	short := dm.CreateStoragePath(filepath.Join(root, "src", "main.go"), 1)
+	if !strings.HasPrefix(short, filepath.Join("src", "main.go", "v1_")) {
+		t.Errorf("CreateStoragePath() = %s, want it under src/main.go", short)
+	}
+
+	longName := strings.Repeat("n", 250)
+	deep := strings.Repeat(strings.Repeat("d", 200)+string(filepath.Separator), 20)
+	for _, filePath := range []string{longName, filepath.Join(deep, "file.txt")} {
+		storagePath := dm.CreateStoragePath(filepath.Join(root, filePath), 3)
+		if !dm.storagePathFits(storagePath) {
+			t.Errorf("CreateStoragePath() = %s, exceeds the path limits", storagePath)
+		}
+		if dir, name := filepath.Split(storagePath); len(dir) != 3 || !strings.HasPrefix(name, dir[:2]) || !strings.Contains(name, "_v3_") {
+			t.Errorf("CreateStoragePath() = %s, want a hashed path", storagePath)
+		}
+	}
+}
+
+func TestStoredPathsUseForwardSlashes(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	// Callers pass paths with the platform's separator
+	filePath := filepath.Join(root, "src", "pkg", "main.go")
+	storagePath := dm.CreateStoragePath(filePath, 1)
+	fv := &FileVersion{
+		FilePath:      filepath.Join("src", "pkg", "main.go"),
+		VersionNumber: 1,
+		Timestamp:     time.Now(),
+		FileHash:      "hash",
+		StoragePath:   storagePath,
+	}
+	if err := dm.AddFileVersion(fv); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.RecordDirectory(filepath.Join(root, "src", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+
+	var storedFile, storedStorage, storedDir string
+	if err := dm.db.QueryRow(`SELECT file_path, storage_path FROM versions`).Scan(&storedFile, &storedStorage); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.db.QueryRow(`SELECT path FROM directories`).Scan(&storedDir); err != nil {
+		t.Fatal(err)
+	}
+	if storedFile != "src/pkg/main.go" {
+		t.Errorf("stored file_path = %q, want src/pkg/main.go", storedFile)
+	}
+	if !strings.HasPrefix(storedStorage, "src/pkg/main.go/v1_") {
+		t.Errorf("stored storage_path = %q, want it under src/pkg/main.go/", storedStorage)
+	}
+	if storedDir != "src/pkg" {
+		t.Errorf("stored directory path = %q, want src/pkg", storedDir)
+	}
+
+	// Reads convert back to the platform's separator
+	latest, err := dm.GetLatestFileVersion(filePath)
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestFileVersion() = %v, %v", latest, err)
+	}
+	if latest.FilePath != fv.FilePath || latest.StoragePath != storagePath {
+		t.Errorf("read back %s, %s, want %s, %s", latest.FilePath, latest.StoragePath, fv.FilePath, storagePath)
+	}
+
+	// Prefixes built with the platform's separator match stored paths
+	if err := dm.MarkFileDeleted(filePath); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := dm.GetDeletedFilesUnder(filepath.Join("src", "pkg") + string(filepath.Separator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("GetDeletedFilesUnder() found %d files, want 1", len(deleted))
+	}
+}
+
+func TestVersionOwner(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	owner := &Owner{UID: 1000, GID: 100}
+	for i, recorded := range []*Owner{nil, owner} {
+		fv := &FileVersion{
+			FilePath:      "app.conf",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      "hash",
+			StoragePath:   dm.CreateStoragePath(filepath.Join(root, "app.conf"), i+1),
+			Owner:         recorded,
+		}
+		if err := dm.AddFileVersion(fv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := dm.GetFileVersions(filepath.Join(root, "app.conf"))
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("GetFileVersions() = %d versions, %v", len(versions), err)
+	}
+	for _, version := range versions {
+		got, err := dm.GetVersionOwner(version.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.VersionNumber == 1 && got != nil {
+			t.Errorf("version 1 owner = %v, want none recorded", *got)
+		}
+		if version.VersionNumber == 2 && (got == nil || *got != *owner) {
+			t.Errorf("version 2 owner = %v, want %v", got, *owner)
+		}
+	}
+}
+
+func TestVersionXattrs(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	// Binary values such as SELinux contexts end in a NUL byte
+	xattrs := map[string][]byte{"user.rewind.context": []byte("system_u:object_r:etc_t:s0\x00")}
+	for i, recorded := range []map[string][]byte{nil, xattrs} {
+		fv := &FileVersion{
+			FilePath:      "app.conf",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      "hash",
+			StoragePath:   dm.CreateStoragePath(filepath.Join(root, "app.conf"), i+1),
+			Xattrs:        recorded,
+		}
+		if err := dm.AddFileVersion(fv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := dm.GetFileVersions(filepath.Join(root, "app.conf"))
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("GetFileVersions() = %d versions, %v", len(versions), err)
+	}
+	for _, version := range versions {
+		got, err := dm.GetVersionXattrs(version.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version.VersionNumber == 1 && got != nil {
+			t.Errorf("version 1 xattrs = %q, want none recorded", got)
+		}
+		if version.VersionNumber == 2 && !reflect.DeepEqual(got, xattrs) {
+			t.Errorf("version 2 xattrs = %q, want %q", got, xattrs)
+		}
+	}
+
+	// Setting them on a file reads back the same, where the file system
+	// supports user attributes
+	path := filepath.Join(root, "app.conf")
+	if err := os.WriteFile(path, []byte("conf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFileXattrs(path, xattrs); err != nil {
+		t.Skipf("extended attributes not supported here: %v", err)
+	}
+	got, err := FileXattrs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != nil && !reflect.DeepEqual(got, xattrs) {
+		t.Errorf("FileXattrs() = %q, want %q", got, xattrs)
+	}
+}
+
+func TestGetAllTagsWithVersions(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	for _, file := range []string{"b.txt", "a.txt"} {
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      file,
+			VersionNumber: 1,
+			Timestamp:     time.Now(),
+			FileHash:      "hash-" + file,
+			StoragePath:   dm.CreateStoragePath(filepath.Join(root, file), 1),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags := []struct{ file, tag string }{{"b.txt", "v2"}, {"b.txt", "v1"}, {"a.txt", "v1"}}
+	for _, tag := range tags {
+		if err := dm.AddTag(filepath.Join(root, tag.file), 1, tag.tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tagged, err := dm.GetAllTagsWithVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range tagged {
+		got = append(got, entry.Tag.TagName+":"+entry.Version.FilePath+":"+entry.Version.FileHash)
+	}
+	want := []string{"v1:a.txt:hash-a.txt", "v1:b.txt:hash-b.txt", "v2:b.txt:hash-b.txt"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GetAllTagsWithVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestVersionsMatchingEarlierContent(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	filePath := filepath.Join(root, "main.go")
+	for i, hash := range []string{"one", "two", "one"} {
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "main.go",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      hash,
+			StoragePath:   dm.CreateStoragePath(filePath, i+1),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	match, err := dm.FindVersionByHash(filePath, "two")
+	if err != nil || match == nil || match.VersionNumber != 2 {
+		t.Errorf("FindVersionByHash(two) = %v, %v, want version 2", match, err)
+	}
+	if match, err := dm.FindVersionByHash(filePath, "three"); err != nil || match != nil {
+		t.Errorf("FindVersionByHash(three) = %v, %v, want none", match, err)
+	}
+
+	versions, err := dm.GetRecentVersions(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := map[int]int{}
+	for _, version := range versions {
+		matches[version.VersionNumber] = version.MatchesVersion
+	}
+	if matches[1] != 0 || matches[2] != 0 || matches[3] != 1 {
+		t.Errorf("MatchesVersion by version = %v, want only version 3 matching version 1", matches)
+	}
+}
+
+func TestVacuumReclaimsFreePages(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	for i := 1; i <= 500; i++ {
+		filePath := filepath.Join(root, fmt.Sprintf("file%d.txt", i))
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      fmt.Sprintf("file%d.txt", i),
+			VersionNumber: 1,
+			Timestamp:     time.Now(),
+			FileHash:      strings.Repeat("a", 64),
+			StoragePath:   dm.CreateStoragePath(filePath, 1),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dm.db.Exec("DELETE FROM versions"); err != nil {
+		t.Fatal(err)
+	}
+
+	if free, _, err := dm.FreePages(); err != nil || free == 0 {
+		t.Fatalf("FreePages() after delete = %d, %v, want free pages", free, err)
+	}
+
+	result, err := dm.Vacuum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SizeAfter >= result.SizeBefore {
+		t.Errorf("Vacuum() size %d -> %d, want it to shrink", result.SizeBefore, result.SizeAfter)
+	}
+	if free, _, err := dm.FreePages(); err != nil || free != 0 {
+		t.Errorf("FreePages() after vacuum = %d, %v, want 0", free, err)
+	}
+}
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	for _, mode := range []string{PathCaseInsensitive, PathCaseSensitive} {
+		t.Run("case_insensitive_paths="+mode, func(t *testing.T) {
+			defer func(previous string) { CaseInsensitivePaths = previous }(CaseInsensitivePaths)
+			CaseInsensitivePaths = mode
+
+			dm, root := newTestDB(t)
+
+			add := func(relPath string) {
+				t.Helper()
+				version, err := dm.GetNextVersionNumber(filepath.Join(root, relPath))
+				if err != nil {
+					t.Fatal(err)
+				}
+				fv := &FileVersion{
+					FilePath:      relPath,
+					VersionNumber: version,
+					Timestamp:     time.Now(),
+					FileHash:      fmt.Sprintf("hash%d", version),
+					StoragePath:   filepath.Join(relPath, fmt.Sprint(version)),
+				}
+				if err := dm.AddFileVersion(fv); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Foo.go is renamed to foo.go, changing only its case
+			add("src/Foo.go")
+			add("src/Foo.go")
+			add("src/foo.go")
+
+			versions, err := dm.GetFileVersions(filepath.Join(root, "SRC/FOO.GO"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			latest, err := dm.GetLatestFileVersion(filepath.Join(root, "src/foo.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if mode == PathCaseSensitive {
+				if len(versions) != 0 {
+					t.Errorf("SRC/FOO.GO has %d versions, want none", len(versions))
+				}
+				if latest == nil || latest.VersionNumber != 1 {
+					t.Errorf("src/foo.go latest version = %+v, want its own version 1", latest)
+				}
+				return
+			}
+
+			if len(versions) != 3 {
+				t.Fatalf("SRC/FOO.GO has %d versions, want 3", len(versions))
+			}
+			for _, version := range versions {
+				if version.FilePath != "src/foo.go" {
+					t.Errorf("version %d is stored as %s, want the current name src/foo.go", version.VersionNumber, version.FilePath)
+				}
+			}
+			if latest == nil || latest.VersionNumber != 3 {
+				t.Errorf("src/foo.go latest version = %+v, want version 3", latest)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitivePaths_KeepsHistoriesToldApartByCase(t *testing.T) {
+	defer func(previous string) { CaseInsensitivePaths = previous }(CaseInsensitivePaths)
+	CaseInsensitivePaths = PathCaseSensitive
+
+	dm, root := newTestDB(t)
+	for _, relPath := range []string{"README", "readme"} {
+		fv := &FileVersion{FilePath: relPath, VersionNumber: 1, Timestamp: time.Now(), FileHash: relPath, StoragePath: relPath + "/1"}
+		if err := dm.AddFileVersion(fv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dm.Close()
+
+	// Turning the option on afterwards finds each by its exact name
+	CaseInsensitivePaths = PathCaseInsensitive
+	if err := dm.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer dm.Close()
+
+	for _, relPath := range []string{"README", "readme"} {
+		latest, err := dm.GetLatestFileVersion(filepath.Join(root, relPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest == nil || latest.FileHash != relPath {
+			t.Errorf("latest version of %s = %+v, want its own history", relPath, latest)
+		}
+	}
+	fv := &FileVersion{FilePath: "readme", VersionNumber: 2, Timestamp: time.Now(), FileHash: "readme2", StoragePath: "readme/2"}
+	if err := dm.AddFileVersion(fv); err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := dm.GetLatestFileVersion(filepath.Join(root, "README")); err != nil || latest.VersionNumber != 1 {
+		t.Errorf("README latest version = %+v (%v), want its own version 1", latest, err)
+	}
+}
+
+func TestCaseInsensitivePaths_Prefix(t *testing.T) {
+	for _, mode := range []string{PathCaseInsensitive, PathCaseSensitive} {
+		t.Run("case_insensitive_paths="+mode, func(t *testing.T) {
+			defer func(previous string) { CaseInsensitivePaths = previous }(CaseInsensitivePaths)
+			CaseInsensitivePaths = mode
+
+			dm, _ := newTestDB(t)
+			for _, relPath := range []string{"build/a", "Build/b"} {
+				for version := 1; version <= 2; version++ {
+					fv := &FileVersion{FilePath: relPath, VersionNumber: version, Timestamp: time.Now(), FileHash: fmt.Sprint(relPath, version), StoragePath: fmt.Sprint(relPath, "/", version)}
+					if err := dm.AddFileVersion(fv); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			// Only the older version of each matching file is purged
+			ids, err := dm.GetVersionsForPurge(1, "build/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 1
+			if mode == PathCaseInsensitive {
+				want = 2
+			}
+			if len(ids) != want {
+				t.Errorf("GetVersionsForPurge() under build/ = %d versions, want %d", len(ids), want)
+			}
+		})
+	}
+}
+
+func TestSquashVersions(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	filePath := filepath.Join(root, "notes.txt")
+	for version := 1; version <= 8; version++ {
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "notes.txt",
+			VersionNumber: version,
+			Timestamp:     time.Now(),
+			FileHash:      fmt.Sprintf("hash-%d", version),
+			StoragePath:   "", // a baseline, so nothing is stored
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tag := range []struct {
+		version int
+		name    string
+	}{{3, "draft"}, {6, "review"}, {7, "final"}} {
+		if err := dm.AddTag(filePath, tag.version, tag.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := dm.SquashVersions(filePath, 2, 6, false)
+	if err != nil {
+		t.Fatalf("SquashVersions() error = %v", err)
+	}
+	if fmt.Sprint(result.Removed) != "[2 3 4 5]" || fmt.Sprint(result.MovedTags) != "[draft]" {
+		t.Errorf("SquashVersions() = %+v, want versions 2-5 removed and draft moved", result)
+	}
+
+	versions, err := dm.GetFileVersions(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, version := range versions {
+		got = append(got, fmt.Sprintf("%d:%s", version.VersionNumber, version.FileHash))
+	}
+	want := "4:hash-8,3:hash-7,2:hash-6,1:hash-1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("versions after squash = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	for name, versionNumber := range map[string]int{"draft": 2, "review": 2, "final": 3} {
+		version, err := dm.GetVersionByTag(filePath, name)
+		if err != nil || version == nil || version.VersionNumber != versionNumber {
+			t.Errorf("tag %s on %+v (%v), want version %d", name, version, err, versionNumber)
+		}
+	}
+
+	// Moving a tag onto a version that already has it changes nothing
+	if err := dm.AddTag(filePath, 3, "draft"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dm.SquashVersions(filePath, 2, 3, false); err == nil {
+		t.Error("SquashVersions() succeeded moving a tag onto a version that already has it")
+	}
+	if versions, _ := dm.GetFileVersions(filePath); len(versions) != 4 {
+		t.Errorf("a refused squash left %d versions, want 4", len(versions))
+	}
+}
+
+func TestRenumberVersions(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	filePath := filepath.Join(root, "notes.txt")
+	for _, version := range []int{3, 4, 7, 9} {
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "notes.txt",
+			VersionNumber: version,
+			Timestamp:     time.Now(),
+			FileHash:      fmt.Sprintf("hash-%d", version),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dm.AddTag(filePath, 7, "release"); err != nil {
+		t.Fatal(err)
+	}
+
+	gaps, err := dm.FindVersionGaps("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 || gaps[0].FilePath != "notes.txt" || gaps[0].Missing != 3 {
+		t.Fatalf("FindVersionGaps() = %+v, want notes.txt missing 3 numbers", gaps)
+	}
+
+	result, err := dm.RenumberVersions(filePath, false)
+	if err != nil {
+		t.Fatalf("RenumberVersions() error = %v", err)
+	}
+	if fmt.Sprint(result.Changes) != "[{7 5} {9 6}]" {
+		t.Errorf("RenumberVersions() changes = %v, want 7 to 5 and 9 to 6", result.Changes)
+	}
+
+	versions, err := dm.GetFileVersions(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, version := range versions {
+		got = append(got, fmt.Sprintf("%d:%s", version.VersionNumber, version.FileHash))
+	}
+	if want := "6:hash-9,5:hash-7,4:hash-4,3:hash-3"; strings.Join(got, ",") != want {
+		t.Errorf("versions after renumber = %s, want %s", strings.Join(got, ","), want)
+	}
+	if version, err := dm.GetVersionByTag(filePath, "release"); err != nil || version == nil || version.VersionNumber != 5 {
+		t.Errorf("tag release on %+v (%v), want version 5", version, err)
+	}
+
+	if gaps, err := dm.FindVersionGaps(filePath); err != nil || len(gaps) != 0 {
+		t.Errorf("FindVersionGaps() after renumber = %+v (%v), want none", gaps, err)
+	}
+}
+
+// addStoredVersion records a version of file whose stored copy, size bytes
+// long, is at storagePath in the store. Versions given the same storagePath
+// share one copy.
+func addStoredVersion(t *testing.T, dm *DatabaseManager, file string, version int, storagePath string, size int) {
+	t.Helper()
+
+	fullPath := filepath.Join(dm.VersionsDir(), storagePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.AddFileVersion(&FileVersion{
+		FilePath:      file,
+		VersionNumber: version,
+		Timestamp:     time.Now().Add(time.Duration(version) * time.Minute),
+		FileHash:      fmt.Sprintf("%s-%d", file, version),
+		FileSize:      1000,
+		StoragePath:   storagePath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetVersionsForPurgeBySize_KeepsTaggedAndLatest(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	for _, file := range []string{"tagged.txt", "plain.txt"} {
+		for version := 1; version <= 2; version++ {
+			addStoredVersion(t, dm, file, version, fmt.Sprintf("%s.v%d", file, version), 100)
+		}
+	}
+	if err := dm.AddTag(filepath.Join(root, "tagged.txt"), 1, "keep"); err != nil {
+		t.Fatal(err)
+	}
+
+	versionIDs, err := dm.GetVersionsForPurgeBySize(0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := dm.GetVersionsByIDs(versionIDs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, version := range versions {
+		got = append(got, fmt.Sprintf("%s:%d", version.FilePath, version.VersionNumber))
+	}
+	if want := "plain.txt:1"; strings.Join(got, ",") != want {
+		t.Errorf("GetVersionsForPurgeBySize() = %v, want only %s", got, want)
+	}
+}
+
+func TestGetStoredSize_CountsStoredBytes(t *testing.T) {
+	dm, _ := newTestDB(t)
+
+	// Two versions sharing one object, and a third stored on its own. Each
+	// version's file_size is 1000, but the store holds 300 bytes.
+	addStoredVersion(t, dm, "notes.txt", 1, "objects/ab/shared", 100)
+	addStoredVersion(t, dm, "notes.txt", 2, "objects/ab/shared", 100)
+	addStoredVersion(t, dm, "notes.txt", 3, "objects/cd/other", 200)
+
+	size, err := dm.GetStoredSize("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 300 {
+		t.Errorf("GetStoredSize() = %d, want 300", size)
+	}
+
+	// Removing one version of the shared object frees nothing, so getting
+	// under 250 bytes takes both
+	versionIDs, err := dm.GetVersionsForPurgeBySize(250, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versionIDs) != 2 {
+		t.Errorf("GetVersionsForPurgeBySize() selected %d versions, want both sharing the object", len(versionIDs))
+	}
+	if after, err := dm.GetStoredSizeWithout(versionIDs[:1], ""); err != nil || after != 300 {
+		t.Errorf("GetStoredSizeWithout(one sharer) = %d, %v, want 300", after, err)
+	}
+	if after, err := dm.GetStoredSizeWithout(versionIDs, ""); err != nil || after != 200 {
+		t.Errorf("GetStoredSizeWithout(both sharers) = %d, %v, want 200", after, err)
+	}
+}
@@ -0,0 +1,283 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hexops/gotextdiff/myers"
+	"github.com/hexops/gotextdiff/span"
+)
+
+// Storage types recorded for each version
+const (
+	// StorageTypeFull versions hold a complete copy of the file
+	StorageTypeFull = "full"
+
+	// StorageTypeDelta versions hold a patch against the version before them.
+	// Their content is rebuilt by applying patches forward from the nearest
+	// full version, the keyframe.
+	StorageTypeDelta = "delta"
+
+	// StorageTypeGzip versions hold a complete copy of the file compressed
+	// with gzip. Versions are only compressed after they are captured.
+	StorageTypeGzip = "gzip"
+)
+
+// lineEdit replaces lines [Start, End) of the base content with Text
+type lineEdit struct {
+	Start int    `json:"s"`
+	End   int    `json:"e"`
+	Text  string `json:"t,omitempty"`
+}
+
+// IsText reports whether content can be stored as a delta
+func IsText(content []byte) bool {
+	return bytes.IndexByte(content, 0) == -1 && utf8.Valid(content)
+}
+
+// CreatePatch returns a patch that turns base into target
+func CreatePatch(base, target string) ([]byte, error) {
+	edits := myers.ComputeEdits(span.URIFromPath(""), base, target)
+
+	patch := make([]lineEdit, 0, len(edits))
+	for _, edit := range edits {
+		patch = append(patch, lineEdit{
+			Start: edit.Span.Start().Line() - 1,
+			End:   edit.Span.End().Line() - 1,
+			Text:  edit.NewText,
+		})
+	}
+
+	return json.Marshal(patch)
+}
+
+// ApplyPatch applies a patch created by CreatePatch to base
+func ApplyPatch(base string, patch []byte) (string, error) {
+	var edits []lineEdit
+	if err := json.Unmarshal(patch, &edits); err != nil {
+		return "", fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	lines := splitLines(base)
+	var result strings.Builder
+	next := 0
+	for _, edit := range edits {
+		if edit.Start < next || edit.End < edit.Start || edit.End > len(lines) {
+			return "", fmt.Errorf("patch does not apply: edit of lines %d-%d", edit.Start, edit.End)
+		}
+		for _, line := range lines[next:edit.Start] {
+			result.WriteString(line)
+		}
+		result.WriteString(edit.Text)
+		next = edit.End
+	}
+	for _, line := range lines[next:] {
+		result.WriteString(line)
+	}
+
+	return result.String(), nil
+}
+
+// splitLines splits text into lines the same way the myers package does, so
+// that patch line numbers refer to the same lines
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// ReadVersionContent returns the content of a stored version, rebuilding delta
+// versions from their keyframe and decompressing compressed ones. Rebuilt and
+// decompressed versions are checked against the hash recorded when they were
+// captured.
+func (dm *DatabaseManager) ReadVersionContent(fv *FileVersion) ([]byte, error) {
+	if fv.IsBaseline() {
+		return nil, fmt.Errorf("version %d of %s is a baseline with no stored content", fv.VersionNumber, fv.FilePath)
+	}
+	if !fv.IsDelta() {
+		return dm.readStoredFile(fv)
+	}
+
+	chain, err := dm.GetDeltaChain(fv)
+	if err != nil {
+		return nil, err
+	}
+
+	keyframe, err := dm.readStoredFile(chain[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyframe version %d: %w", chain[0].VersionNumber, err)
+	}
+
+	content := string(keyframe)
+	for _, delta := range chain[1:] {
+		patch, err := os.ReadFile(dm.versionStoragePath(delta))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delta version %d: %w", delta.VersionNumber, err)
+		}
+		content, err = ApplyPatch(content, patch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild version %d: %w", delta.VersionNumber, err)
+		}
+	}
+
+	hash := fv.ContentHash([]byte(content))
+	if hash != fv.FileHash {
+		return nil, mark(ErrCorrupt, fmt.Errorf("rebuilt version %d of %s does not match its recorded hash (expected %s, got %s)",
+			fv.VersionNumber, fv.FilePath, fv.FileHash, hash))
+	}
+
+	return []byte(content), nil
+}
+
+// GetDeltaChain returns the versions needed to rebuild fv, starting with its
+// keyframe and ending with fv itself
+func (dm *DatabaseManager) GetDeltaChain(fv *FileVersion) ([]*FileVersion, error) {
+	versions, err := dm.storedVersions(fv.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*FileVersion
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		if version.VersionNumber > fv.VersionNumber {
+			continue
+		}
+		if len(chain) == 0 && version.VersionNumber != fv.VersionNumber {
+			break
+		}
+		if version.IsBaseline() {
+			break
+		}
+
+		chain = append([]*FileVersion{version}, chain...)
+		if !version.IsDelta() {
+			return chain, nil
+		}
+	}
+
+	return nil, mark(ErrCorrupt, fmt.Errorf("no keyframe found for delta version %d of %s", fv.VersionNumber, fv.FilePath))
+}
+
+// storedVersions returns the versions of a file in version order with the
+// fields needed to rebuild deltas
+func (dm *DatabaseManager) storedVersions(relPath string) ([]*FileVersion, error) {
+	query := `
+	SELECT id, file_path, version_number, file_hash, storage_path, storage_type, hash_algorithm
+	FROM versions
+	WHERE file_path = ?
+	ORDER BY version_number ASC
+	`
+
+	rows, err := dm.db.Query(query, filepath.ToSlash(relPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*FileVersion
+	for rows.Next() {
+		fv := &FileVersion{}
+		if err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &fv.FileHash, &fv.StoragePath, &fv.StorageType, &fv.HashAlgorithm); err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		fv.fromStored()
+		versions = append(versions, fv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// keyframeDependentDeltas stores in full every delta whose base version is
+// about to be removed, so that removing versions never breaks later ones. If
+// a delta cannot be rebuilt to be stored in full, it returns an error and the
+// versions must not be removed.
+func (dm *DatabaseManager) keyframeDependentDeltas(versionIDs []int64) error {
+	placeholders, args := versionIDArgs(versionIDs)
+	query := fmt.Sprintf(`
+	SELECT DISTINCT file_path
+	FROM versions
+	WHERE id IN (%s)
+	`, placeholders)
+
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query files of removed versions: %w", err)
+	}
+
+	var filePaths []string
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan file path: %w", err)
+		}
+		filePaths = append(filePaths, filePath)
+	}
+	rows.Close()
+
+	removing := make(map[int64]bool, len(versionIDs))
+	for _, id := range versionIDs {
+		removing[id] = true
+	}
+
+	for _, filePath := range filePaths {
+		versions, err := dm.storedVersions(filePath)
+		if err != nil {
+			return err
+		}
+
+		for i := 1; i < len(versions); i++ {
+			version := versions[i]
+			if !version.IsDelta() || removing[version.ID] || !removing[versions[i-1].ID] {
+				continue
+			}
+			// Removing the base would lose the delta, so nothing is removed
+			if err := dm.storeAsKeyframe(version); err != nil {
+				return fmt.Errorf("failed to keep version %d of %s, which is stored as a change to a removed version: %w", version.VersionNumber, filePath, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// storeAsKeyframe replaces a delta version's patch with its full content
+func (dm *DatabaseManager) storeAsKeyframe(fv *FileVersion) error {
+	content, err := dm.ReadVersionContent(fv)
+	if err != nil {
+		return err
+	}
+
+	storagePath := dm.versionStoragePath(fv)
+	tempPath := storagePath + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write keyframe: %w", err)
+	}
+	if err := os.Rename(tempPath, storagePath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to replace delta with keyframe: %w", err)
+	}
+
+	if _, err := dm.db.Exec(`UPDATE versions SET storage_type = ? WHERE id = ?`, StorageTypeFull, fv.ID); err != nil {
+		return fmt.Errorf("failed to update storage type: %w", err)
+	}
+
+	fv.StorageType = StorageTypeFull
+	return nil
+}
+
+func (dm *DatabaseManager) versionStoragePath(fv *FileVersion) string {
+	return filepath.Join(dm.VersionsDir(), fv.StoragePath)
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		target string
+	}{
+		{"identical", "a\nb\nc\n", "a\nb\nc\n"},
+		{"empty base", "", "a\nb\n"},
+		{"empty target", "a\nb\n", ""},
+		{"change middle line", "a\nb\nc\n", "a\nB\nc\n"},
+		{"insert at start", "b\nc\n", "a\nb\nc\n"},
+		{"append", "a\nb\n", "a\nb\nc\nd\n"},
+		{"delete lines", "a\nb\nc\nd\n", "a\nd\n"},
+		{"no trailing newline", "a\nb", "a\nb\nc"},
+		{"add trailing newline", "a\nb", "a\nb\n"},
+		{"scattered edits", "1\n2\n3\n4\n5\n6\n7\n8\n", "0\n1\n3\n4\nfour\n5\n7\n8\n9\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := CreatePatch(tt.base, tt.target)
+			if err != nil {
+				t.Fatalf("CreatePatch: %v", err)
+			}
+
+			got, err := ApplyPatch(tt.base, patch)
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if got != tt.target {
+				t.Errorf("ApplyPatch = %q, want %q", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestApplyPatchRejectsMismatchedBase(t *testing.T) {
+	patch, err := CreatePatch("a\nb\nc\n", "a\nc\n")
+	if err != nil {
+		t.Fatalf("CreatePatch: %v", err)
+	}
+
+	if _, err := ApplyPatch("", patch); err == nil {
+		t.Error("ApplyPatch on a shorter base should fail")
+	}
+}
+
+func TestIsText(t *testing.T) {
+	if !IsText([]byte("package main\n")) {
+		t.Error("source code should be text")
+	}
+	if IsText([]byte{'a', 0, 'b'}) {
+		t.Error("content with NUL bytes should not be text")
+	}
+	if IsText([]byte{0xff, 0xfe}) {
+		t.Error("invalid UTF-8 should not be text")
+	}
+}
+
+func TestRemoveVersionsKeepsBaseOfUnrebuildableDelta(t *testing.T) {
+	dm, root := newTestDB(t)
+	addDeltaChain(t, dm, root, HashSHA256)
+
+	file := filepath.Join(root, "notes.txt")
+	first, err := dm.GetFileVersion(file, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dm.VersionsDir(), first.StoragePath), []byte("corrupted\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dm.RemoveVersions([]int64{first.ID}); err == nil {
+		t.Fatal("RemoveVersions() of a base whose delta cannot be rebuilt succeeded")
+	}
+	if version, err := dm.GetFileVersion(file, 1); err != nil || version == nil {
+		t.Errorf("version 1 was removed although version 2 builds on it: %v", err)
+	}
+	if version, err := dm.GetFileVersion(file, 2); err != nil || !version.IsDelta() {
+		t.Errorf("version 2 = %+v, %v; want it left as a delta", version, err)
+	}
+}
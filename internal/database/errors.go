@@ -0,0 +1,24 @@
+package database
+
+import "errors"
+
+// Errors returned by DatabaseManager are marked with one of these where the
+// cause is known, so callers can tell failures apart with errors.Is
+var (
+	ErrNotInitialized = errors.New("rewind project not initialized")
+	ErrNotFound       = errors.New("not found")
+	ErrCorrupt        = errors.New("stored version is corrupt")
+)
+
+// markedError is an error marked with a sentinel. Its message is unchanged.
+type markedError struct {
+	err    error
+	marker error
+}
+
+func (e *markedError) Error() string   { return e.err.Error() }
+func (e *markedError) Unwrap() []error { return []error{e.err, e.marker} }
+
+func mark(marker, err error) error {
+	return &markedError{err: err, marker: marker}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CopyTo writes a consistent copy of the database to path, which must not
+// exist, and returns a manager connected to the copy. The copy's versions are
+// read from dm's version store, so a capture made while it is being read
+// can't leave it referring to content that hasn't been written yet.
+func (dm *DatabaseManager) CopyTo(path string) (*DatabaseManager, error) {
+	if _, err := dm.db.Exec("VACUUM INTO ?", path); err != nil {
+		return nil, fmt.Errorf("failed to copy database: %w", err)
+	}
+
+	copied := &DatabaseManager{
+		rootDir:  dm.rootDir,
+		storeDir: dm.storeDir,
+		dbPath:   path,
+		global:   dm.global,
+	}
+	if err := copied.Connect(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return copied, nil
+}
+
+// GetStoredVersions returns every version with stored content, including
+// those of deleted files, oldest first
+func (dm *DatabaseManager) GetStoredVersions() ([]*FileVersion, error) {
+	query := `
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, event_op, deleted, hash_algorithm
+	FROM versions
+	WHERE storage_path != ''
+	ORDER BY timestamp ASC, id ASC
+	`
+
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*FileVersion
+	for rows.Next() {
+		fv := &FileVersion{}
+		var timestampStr string
+
+		err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.EventOp, &fv.Deleted, &fv.HashAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		fv.fromStored()
+
+		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		fv.Timestamp = fv.Timestamp.Local()
+
+		versions = append(versions, fv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// VerifyVersion checks that a version's stored content matches its recorded
+// hash. Deltas are rebuilt from their keyframe to be checked.
+func (dm *DatabaseManager) VerifyVersion(fv *FileVersion) error {
+	content, err := dm.ReadVersionContent(fv)
+	if err != nil {
+		return err
+	}
+
+	// Compressed versions and deltas were checked as they were read
+	if fv.IsDelta() || fv.IsCompressed() {
+		return nil
+	}
+
+	if hash := fv.ContentHash(content); hash != fv.FileHash {
+		return mark(ErrCorrupt, fmt.Errorf("stored version %d of %s does not match its recorded hash (expected %s, got %s)",
+			fv.VersionNumber, fv.FilePath, fv.FileHash, hash))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package database
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// storedFileKey identifies the file on disk described by info, so hardlinks
+// to the same file are told apart from copies
+func storedFileKey(info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+// Algorithms a version's hash can be calculated with. SHA256 is the default.
+// BLAKE3 and xxHash are much faster ways of telling whether a file changed;
+// xxHash is not cryptographic, but still catches a corrupted stored copy.
+const (
+	HashSHA256 = "sha256"
+	HashBLAKE3 = "blake3"
+	HashXXHash = "xxhash"
+)
+
+// HashAlgorithms lists the supported hash algorithms
+var HashAlgorithms = []string{HashSHA256, HashBLAKE3, HashXXHash}
+
+// NewHasher returns a new hash for algorithm
+func NewHasher(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashBLAKE3:
+		return blake3.New(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %q (use %s)", algorithm, strings.Join(HashAlgorithms, ", "))
+	}
+}
+
+// HashBytes returns the hash of data calculated with algorithm
+func HashBytes(algorithm string, data []byte) (string, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	hasher.Write(data)
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// CalculateFileHashWith calculates the hash of a file with algorithm
+func CalculateFileHashWith(filePath, algorithm string) (string, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to calculate hash: %w", err)
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+// CopyAndHashWith copies src to dst and returns the hash of the content
+// copied, calculated with algorithm
+func CopyAndHashWith(dst io.Writer, src io.Reader, algorithm string) (string, error) {
+	hasher, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, io.TeeReader(src, hasher)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+func (fv *FileVersion) hashAlgorithm() string {
+	if fv.HashAlgorithm == "" {
+		return HashSHA256
+	}
+	return fv.HashAlgorithm
+}
+
+// HashFile calculates the hash of the file at path with the algorithm of the
+// version's hash, so the two can be compared
+func (fv *FileVersion) HashFile(path string) (string, error) {
+	return CalculateFileHashWith(path, fv.hashAlgorithm())
+}
+
+// ContentHash returns the hash of content calculated with the algorithm of
+// the version's hash. An algorithm this build does not know gives "", which
+// matches no hash.
+func (fv *FileVersion) ContentHash(content []byte) string {
+	hash, err := HashBytes(fv.hashAlgorithm(), content)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
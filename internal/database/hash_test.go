@@ -0,0 +1,197 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionsKeepTheirHashAlgorithm(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	// The hash algorithm changed after each version was captured
+	file := filepath.Join(root, "notes.txt")
+	for i, algorithm := range HashAlgorithms {
+		content := []byte("version " + algorithm + "\n")
+		if err := os.WriteFile(file, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := CalculateFileHashWith(file, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		storagePath := dm.CreateStoragePath(file, i+1)
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dm.VersionsDir(), storagePath)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dm.VersionsDir(), storagePath), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "notes.txt",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      hash,
+			HashAlgorithm: algorithm,
+			FileSize:      int64(len(content)),
+			StoragePath:   storagePath,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := dm.GetFileVersions(file)
+	if err != nil || len(versions) != len(HashAlgorithms) {
+		t.Fatalf("GetFileVersions() = %d versions, %v", len(versions), err)
+	}
+	for _, version := range versions {
+		want := HashAlgorithms[version.VersionNumber-1]
+		if version.HashAlgorithm != want {
+			t.Errorf("version %d hash algorithm = %q, want %q", version.VersionNumber, version.HashAlgorithm, want)
+		}
+		if err := dm.VerifyVersion(version); err != nil {
+			t.Errorf("VerifyVersion(%d) = %v", version.VersionNumber, err)
+		}
+	}
+
+	// The latest version still tells whether the file changed
+	latest, err := dm.GetLatestFileVersion(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash, err := latest.HashFile(file); err != nil || hash != latest.FileHash {
+		t.Errorf("HashFile() = %q, %v, want the latest version's hash %q", hash, err, latest.FileHash)
+	}
+
+	// A stored copy that no longer matches is caught whatever the algorithm
+	for _, version := range versions {
+		if err := os.WriteFile(filepath.Join(dm.VersionsDir(), version.StoragePath), []byte("corrupted"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := dm.VerifyVersion(version); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("VerifyVersion(%d) of a corrupted copy = %v, want ErrCorrupt", version.VersionNumber, err)
+		}
+	}
+}
+
+func TestNewHasherRejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := NewHasher("md5"); err == nil {
+		t.Error("NewHasher(\"md5\") succeeded, want an error")
+	}
+}
+
+func BenchmarkCalculateFileHash(b *testing.B) {
+	file := filepath.Join(b.TempDir(), "large.bin")
+	content := make([]byte, 64<<20)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	if err := os.WriteFile(file, content, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, algorithm := range HashAlgorithms {
+		b.Run(algorithm, func(b *testing.B) {
+			b.SetBytes(int64(len(content)))
+			for i := 0; i < b.N; i++ {
+				if _, err := CalculateFileHashWith(file, algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// addDeltaChain stores a keyframe and a delta on top of it for notes.txt,
+// both hashed with algorithm
+func addDeltaChain(t *testing.T, dm *DatabaseManager, root, algorithm string) []string {
+	t.Helper()
+
+	contents := []string{strings.Repeat("first line\n", 100), strings.Repeat("first line\n", 100) + "second line\n"}
+	file := filepath.Join(root, "notes.txt")
+	for i, content := range contents {
+		stored := []byte(content)
+		storageType := StorageTypeFull
+		if i > 0 {
+			patch, err := CreatePatch(contents[i-1], content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, storageType = patch, StorageTypeDelta
+		}
+
+		hash, err := HashBytes(algorithm, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		storagePath := dm.CreateStoragePath(file, i+1)
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dm.VersionsDir(), storagePath)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dm.VersionsDir(), storagePath), stored, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "notes.txt",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      hash,
+			HashAlgorithm: algorithm,
+			FileSize:      int64(len(content)),
+			StoragePath:   storagePath,
+			StorageType:   storageType,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return contents
+}
+
+func TestPurgeKeyframeOfNonSHA256Delta(t *testing.T) {
+	dm, root := newTestDB(t)
+	contents := addDeltaChain(t, dm, root, HashBLAKE3)
+
+	first, err := dm.GetFileVersion(filepath.Join(root, "notes.txt"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.RemoveVersions([]int64{first.ID}); err != nil {
+		t.Fatalf("RemoveVersions() = %v", err)
+	}
+
+	second, err := dm.GetFileVersion(filepath.Join(root, "notes.txt"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.IsDelta() {
+		t.Error("version 2 is still a delta after its keyframe was purged")
+	}
+	if content, err := dm.ReadVersionContent(second); err != nil || string(content) != contents[1] {
+		t.Errorf("ReadVersionContent(2) = %d bytes, %v; want the second content", len(content), err)
+	}
+}
+
+func TestReadNonSHA256DeltaOverCompressedKeyframe(t *testing.T) {
+	dm, root := newTestDB(t)
+	contents := addDeltaChain(t, dm, root, HashXXHash)
+
+	first, err := dm.GetFileVersion(filepath.Join(root, "notes.txt"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := dm.CompressVersion(first, false); err != nil || result.Skipped {
+		t.Fatalf("CompressVersion(1) = %+v, %v", result, err)
+	}
+
+	second, err := dm.GetFileVersion(filepath.Join(root, "notes.txt"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := dm.ReadVersionContent(second); err != nil || string(content) != contents[1] {
+		t.Errorf("ReadVersionContent(2) = %d bytes, %v; want the second content", len(content), err)
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// latestDir is the directory of the store holding a link to the latest
+// stored copy of each file, when latest links are enabled
+const latestDir = "latest"
+
+// LatestDir returns the directory holding the latest links, which mirrors
+// the project tree
+func (dm *DatabaseManager) LatestDir() string {
+	return filepath.Join(dm.storeDir, latestDir)
+}
+
+// HasLatestLinks reports whether the store keeps latest links, so that
+// commands changing a file's latest version outside the daemon keep them
+// current
+func (dm *DatabaseManager) HasLatestLinks() bool {
+	info, err := os.Stat(dm.LatestDir())
+	return err == nil && info.IsDir()
+}
+
+// latestLinkPath returns the path of the latest link for a stored path
+func (dm *DatabaseManager) latestLinkPath(relPath string) string {
+	return filepath.Join(dm.LatestDir(), filepath.FromSlash(relPath))
+}
+
+// UpdateLatestLink points the latest link of a file at the stored copy of its
+// newest version. Only a full copy holds the file's content as it is, so a
+// file whose newest version is a delta, compressed, a baseline or a deletion
+// has its link removed rather than left pointing at older content.
+func (dm *DatabaseManager) UpdateLatestLink(filePath string) error {
+	latest, err := dm.GetLatestFileVersion(filePath)
+	if err != nil {
+		return err
+	}
+	if latest == nil || latest.Deleted || latest.IsBaseline() || latest.StorageType != StorageTypeFull {
+		return dm.RemoveLatestLink(filePath)
+	}
+
+	linkPath := dm.latestLinkPath(dm.relPath(filePath))
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("failed to create latest link directory: %w", err)
+	}
+
+	// Relative, so the links survive the project being moved
+	target, err := filepath.Rel(filepath.Dir(linkPath), dm.versionStoragePath(latest))
+	if err != nil {
+		return fmt.Errorf("failed to resolve latest link target: %w", err)
+	}
+
+	// Replace the link in one step, so readers never find it missing
+	tempPath := linkPath + ".tmp"
+	os.Remove(tempPath)
+	if err := os.Symlink(target, tempPath); err != nil {
+		return fmt.Errorf("failed to create latest link: %w", err)
+	}
+	if err := os.Rename(tempPath, linkPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to update latest link: %w", err)
+	}
+	return nil
+}
+
+// RemoveLatestLink removes the latest link of a file, along with the
+// directories under the latest directory it leaves empty
+func (dm *DatabaseManager) RemoveLatestLink(filePath string) error {
+	linkPath := dm.latestLinkPath(dm.relPath(filePath))
+	if err := os.Remove(linkPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove latest link: %w", err)
+	}
+
+	for dir := filepath.Dir(linkPath); dir != dm.LatestDir() && len(dir) > len(dm.LatestDir()); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// EnableLatestLinks creates the latest directory and a link for every file,
+// after which the store keeps the links current
+func (dm *DatabaseManager) EnableLatestLinks() error {
+	if err := os.MkdirAll(dm.LatestDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create latest directory: %w", err)
+	}
+	return dm.RefreshLatestLinks()
+}
+
+// DisableLatestLinks removes the latest directory and every link in it
+func (dm *DatabaseManager) DisableLatestLinks() error {
+	if err := os.RemoveAll(dm.LatestDir()); err != nil {
+		return fmt.Errorf("failed to remove latest directory: %w", err)
+	}
+	return nil
+}
+
+// keepLatestLink updates the latest link of a file after its latest version
+// has changed, if the store keeps latest links. Failures are reported but
+// leave the change that caused them in place.
+func (dm *DatabaseManager) keepLatestLink(relPath string) {
+	if !dm.HasLatestLinks() {
+		return
+	}
+	if err := dm.UpdateLatestLink(filepath.Join(dm.rootDir, filepath.FromSlash(relPath))); err != nil {
+		fmt.Printf("Warning: failed to update latest link of %s: %v\n", relPath, err)
+	}
+}
+
+// RefreshLatestLinks brings every latest link up to date after stored copies
+// have moved, such as by compression or a storage migration. Stores without
+// latest links are left alone.
+func (dm *DatabaseManager) RefreshLatestLinks() error {
+	if !dm.HasLatestLinks() {
+		return nil
+	}
+
+	files, err := dm.GetAllLatestFiles()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := dm.UpdateLatestLink(filepath.Join(dm.rootDir, filepath.FromSlash(file.FilePath))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
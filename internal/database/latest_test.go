@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLatestLinks(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	filePath := filepath.Join(root, "docs", "notes.txt")
+	linkPath := filepath.Join(dm.LatestDir(), "docs", "notes.txt")
+	addVersion := func(version int, content string, storageType string) {
+		t.Helper()
+		storagePath := filepath.Join("docs", "notes.txt", fmt.Sprintf("v%d", version))
+		if err := os.MkdirAll(filepath.Join(dm.VersionsDir(), "docs", "notes.txt"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dm.VersionsDir(), storagePath), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "docs/notes.txt",
+			VersionNumber: version,
+			Timestamp:     time.Now(),
+			FileHash:      fmt.Sprintf("hash-%d", version),
+			FileSize:      int64(len(content)),
+			StoragePath:   storagePath,
+			StorageType:   storageType,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without the latest directory nothing is linked
+	addVersion(1, "first", StorageTypeFull)
+	if _, err := os.Lstat(linkPath); !os.IsNotExist(err) {
+		t.Fatalf("link exists before latest links were enabled (%v)", err)
+	}
+
+	if err := dm.EnableLatestLinks(); err != nil {
+		t.Fatalf("EnableLatestLinks() error = %v", err)
+	}
+	if content, err := os.ReadFile(linkPath); err != nil || string(content) != "first" {
+		t.Errorf("link after enabling reads %q (%v), want first", content, err)
+	}
+
+	addVersion(2, "second", StorageTypeFull)
+	if content, err := os.ReadFile(linkPath); err != nil || string(content) != "second" {
+		t.Errorf("link after a capture reads %q (%v), want second", content, err)
+	}
+
+	// A delta is not the file's content, so the link goes
+	addVersion(3, "delta", StorageTypeDelta)
+	if _, err := os.Lstat(linkPath); !os.IsNotExist(err) {
+		t.Errorf("link exists for a delta (%v), want none", err)
+	}
+
+	addVersion(4, "fourth", StorageTypeFull)
+	if err := dm.MarkFileDeleted(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(linkPath); !os.IsNotExist(err) {
+		t.Errorf("link exists for a deleted file (%v), want none", err)
+	}
+	if _, err := os.Stat(filepath.Dir(linkPath)); !os.IsNotExist(err) {
+		t.Errorf("empty directory left under latest (%v)", err)
+	}
+
+	if _, err := dm.RestoreFile(filePath); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := os.ReadFile(linkPath); err != nil || string(content) != "fourth" {
+		t.Errorf("link after restore reads %q (%v), want fourth", content, err)
+	}
+
+	if err := dm.DisableLatestLinks(); err != nil {
+		t.Fatalf("DisableLatestLinks() error = %v", err)
+	}
+	if dm.HasLatestLinks() {
+		t.Error("HasLatestLinks() = true after disabling")
+	}
+}
@@ -0,0 +1,328 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage layouts of the version store. A project starts in LayoutPaths and
+// is moved between layouts with rewind migrate-store.
+const (
+	// LayoutPaths stores each version under a path mirroring the project
+	// tree, named by its version number and capture time
+	LayoutPaths = "paths"
+	// LayoutContentAddressable stores full copies once per content under
+	// objects/, named by their hash, so versions with the same content share
+	// one file
+	LayoutContentAddressable = "content-addressable"
+)
+
+// StorageLayouts lists the layouts a store can be migrated to
+var StorageLayouts = []string{LayoutPaths, LayoutContentAddressable}
+
+// objectsDir is the directory of the version store holding
+// content-addressable objects
+const objectsDir = "objects"
+
+// storageLayoutKey is the meta key recording the layout of the version store
+const storageLayoutKey = "storage_layout"
+
+// ensureMetaTable creates the table of store-wide settings
+func (dm *DatabaseManager) ensureMetaTable() error {
+	_, err := dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS meta (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create meta table: %w", err)
+	}
+	return nil
+}
+
+// StorageLayout returns the layout new versions are stored in. Stores
+// created before layouts were recorded use LayoutPaths.
+func (dm *DatabaseManager) StorageLayout() (string, error) {
+	if err := dm.ensureMetaTable(); err != nil {
+		return "", err
+	}
+
+	var layout string
+	err := dm.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, storageLayoutKey).Scan(&layout)
+	if err == sql.ErrNoRows {
+		return LayoutPaths, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read storage layout: %w", err)
+	}
+	return layout, nil
+}
+
+// ObjectPath returns the storage path of the content-addressable object
+// holding content with the given hash
+func ObjectPath(hash string) string {
+	return filepath.Join(objectsDir, hash[:2], hash)
+}
+
+// IsObjectPath reports whether storagePath is a content-addressable object,
+// which several versions may share
+func IsObjectPath(storagePath string) bool {
+	return strings.HasPrefix(filepath.ToSlash(storagePath), objectsDir+"/")
+}
+
+// ObjectsDir returns the directory holding content-addressable objects
+func (dm *DatabaseManager) ObjectsDir() string {
+	return filepath.Join(dm.VersionsDir(), objectsDir)
+}
+
+// StoreMigration describes moving the version store to another layout
+type StoreMigration struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	DryRun bool   `json:"dry_run"`
+	// Versions is how many versions have their stored copy moved
+	Versions int `json:"versions"`
+	// Files is how many files the moved versions occupy after the migration
+	Files int `json:"files"`
+	// Skipped is how many deltas and compressed versions are left where
+	// they are, as they are not full copies of their content. Objects are
+	// named by their SHA256 hash, so versions hashed with another algorithm
+	// are left where they are too.
+	Skipped    int   `json:"skipped"`
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+// storeMove is one version whose stored copy moves to a new storage path
+type storeMove struct {
+	version *FileVersion
+	to      string
+}
+
+// MigrateStore moves the full copies of every version into the layout to.
+// Each copy is checked against its recorded hash as it is written to its new
+// place, and the database is only pointed at the new copies, in a single
+// transaction, once all of them are written. The old copies are removed
+// afterwards. With dryRun nothing is written and the result describes the
+// work a migration would do.
+func (dm *DatabaseManager) MigrateStore(to string, dryRun bool) (*StoreMigration, error) {
+	if to != LayoutPaths && to != LayoutContentAddressable {
+		return nil, fmt.Errorf("unknown storage layout %q (expected %s)", to, strings.Join(StorageLayouts, " or "))
+	}
+
+	from, err := dm.StorageLayout()
+	if err != nil {
+		return nil, err
+	}
+	migration := &StoreMigration{From: from, To: to, DryRun: dryRun}
+
+	moves, err := dm.planStoreMigration(migration)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return migration, nil
+	}
+
+	// Copies written so far, removed along with the directories made for
+	// them if the migration fails
+	var created []string
+	cleanUp := func() {
+		for _, path := range created {
+			os.Remove(path)
+			os.Remove(filepath.Dir(path))
+		}
+	}
+
+	for _, move := range moves {
+		dst := filepath.Join(dm.VersionsDir(), move.to)
+		if _, err := os.Stat(dst); err == nil {
+			// Already in place, such as an object stored for an earlier
+			// version with the same content, checked when it was written
+			continue
+		}
+
+		created = append(created, dst)
+		if err := dm.copyStoredVersion(move.version, dst); err != nil {
+			cleanUp()
+			return nil, err
+		}
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		cleanUp()
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, move := range moves {
+		_, err = tx.Exec(`UPDATE versions SET storage_path = ? WHERE id = ?`, filepath.ToSlash(move.to), move.version.ID)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO meta (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, storageLayoutKey, to)
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		cleanUp()
+		return nil, fmt.Errorf("failed to record migrated versions: %w", err)
+	}
+
+	oldPaths := make([]string, len(moves))
+	for i, move := range moves {
+		oldPaths[i] = move.version.StoragePath
+	}
+	dm.removeUnreferenced(oldPaths)
+	if err := dm.RefreshLatestLinks(); err != nil {
+		fmt.Printf("Warning: failed to update latest links: %v\n", err)
+	}
+
+	return migration, nil
+}
+
+// planStoreMigration returns the versions whose stored copies move to reach
+// migration.To, filling in the work and space figures of the migration
+func (dm *DatabaseManager) planStoreMigration(migration *StoreMigration) ([]storeMove, error) {
+	query := `
+	SELECT id, file_path, version_number, timestamp, file_hash, file_size, storage_path, storage_type, hash_algorithm
+	FROM versions
+	WHERE storage_path != ''
+	ORDER BY file_path, version_number
+	`
+
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored versions: %w", err)
+	}
+	defer rows.Close()
+
+	var moves []storeMove
+	sources := make(map[string]bool)
+	targets := make(map[string]bool)
+	for rows.Next() {
+		fv := &FileVersion{}
+		var timestampStr string
+		if err := rows.Scan(&fv.ID, &fv.FilePath, &fv.VersionNumber, &timestampStr, &fv.FileHash, &fv.FileSize, &fv.StoragePath, &fv.StorageType, &fv.HashAlgorithm); err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		fv.fromStored()
+		fv.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestampStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		fv.Timestamp = fv.Timestamp.Local()
+
+		if fv.StorageType != StorageTypeFull || (migration.To == LayoutContentAddressable && fv.hashAlgorithm() != HashSHA256) {
+			migration.Skipped++
+			continue
+		}
+
+		var to string
+		if migration.To == LayoutContentAddressable {
+			if IsObjectPath(fv.StoragePath) {
+				continue
+			}
+			to = ObjectPath(fv.FileHash)
+		} else {
+			if !IsObjectPath(fv.StoragePath) {
+				continue
+			}
+			to = dm.storagePathAt(fv.FilePath, fv.VersionNumber, fv.Timestamp)
+		}
+
+		migration.Versions++
+		if !sources[fv.StoragePath] {
+			sources[fv.StoragePath] = true
+			migration.SizeBefore += fv.FileSize
+		}
+		if !targets[to] {
+			targets[to] = true
+			migration.Files++
+			if _, err := os.Stat(filepath.Join(dm.VersionsDir(), to)); err != nil {
+				migration.SizeAfter += fv.FileSize
+			}
+		}
+		moves = append(moves, storeMove{version: fv, to: to})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stored versions: %w", err)
+	}
+
+	return moves, nil
+}
+
+// copyStoredVersion copies the stored copy of a full version to dst,
+// checking it against the version's recorded hash on the way
+func (dm *DatabaseManager) copyStoredVersion(fv *FileVersion, dst string) error {
+	src, err := os.Open(dm.versionStoragePath(fv))
+	if err != nil {
+		return fmt.Errorf("failed to read version %d of %s: %w", fv.VersionNumber, fv.FilePath, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tempPath := dst + ".tmp"
+	temp, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tempPath, err)
+	}
+	hash, err := CopyAndHashWith(temp, src, fv.hashAlgorithm())
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to copy version %d of %s: %w", fv.VersionNumber, fv.FilePath, err)
+	}
+
+	if hash != fv.FileHash {
+		os.Remove(tempPath)
+		return mark(ErrCorrupt, fmt.Errorf("stored version %d of %s does not match its recorded hash (expected %s, got %s)",
+			fv.VersionNumber, fv.FilePath, fv.FileHash, hash))
+	}
+
+	if err := os.Rename(tempPath, dst); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to move version %d of %s into place: %w", fv.VersionNumber, fv.FilePath, err)
+	}
+	return nil
+}
+
+// removeUnreferenced deletes the stored files at storagePaths that no
+// version refers to any more. Content-addressable objects may be shared, so
+// a stored file is only removed once its last version has gone.
+func (dm *DatabaseManager) removeUnreferenced(storagePaths []string) {
+	for _, storagePath := range storagePaths {
+		// Baseline versions have nothing stored
+		if storagePath == "" {
+			continue
+		}
+
+		var references int
+		err := dm.db.QueryRow(`SELECT COUNT(*) FROM versions WHERE storage_path = ?`, filepath.ToSlash(storagePath)).Scan(&references)
+		if err != nil || references > 0 {
+			continue
+		}
+
+		fullPath := filepath.Join(dm.VersionsDir(), storagePath)
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: failed to delete file %s: %v\n", fullPath, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateStore(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	// Two versions share their content, so they share one object
+	contents := []string{"first\n", "second\n", "first\n"}
+	for i, content := range contents {
+		storagePath := filepath.Join("file.txt", fmt.Sprintf("v%d", i+1))
+		if err := os.MkdirAll(filepath.Join(dm.VersionsDir(), "file.txt"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dm.VersionsDir(), storagePath), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := dm.AddFileVersion(&FileVersion{
+			FilePath:      "file.txt",
+			VersionNumber: i + 1,
+			Timestamp:     time.Now(),
+			FileHash:      fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+			FileSize:      int64(len(content)),
+			StoragePath:   storagePath,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plan, err := dm.MigrateStore(LayoutContentAddressable, true)
+	if err != nil {
+		t.Fatalf("MigrateStore(dry run) error = %v", err)
+	}
+	if plan.Versions != 3 || plan.Files != 2 || plan.SizeBefore != 19 || plan.SizeAfter != 13 {
+		t.Errorf("MigrateStore(dry run) = %+v, want 3 versions into 2 files, 19 B -> 13 B", plan)
+	}
+	if layout, _ := dm.StorageLayout(); layout != LayoutPaths {
+		t.Errorf("StorageLayout() after dry run = %q, want %q", layout, LayoutPaths)
+	}
+
+	if _, err := dm.MigrateStore(LayoutContentAddressable, false); err != nil {
+		t.Fatalf("MigrateStore() error = %v", err)
+	}
+	if layout, _ := dm.StorageLayout(); layout != LayoutContentAddressable {
+		t.Errorf("StorageLayout() = %q, want %q", layout, LayoutContentAddressable)
+	}
+
+	versions, err := dm.GetFileVersions(filepath.Join(root, "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions {
+		if !IsObjectPath(version.StoragePath) {
+			t.Errorf("version %d stored at %s, want an object", version.VersionNumber, version.StoragePath)
+		}
+		content, err := dm.ReadVersionContent(version)
+		if err != nil || string(content) != contents[version.VersionNumber-1] {
+			t.Errorf("version %d content = %q, %v", version.VersionNumber, content, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dm.VersionsDir(), "file.txt", "v1")); !os.IsNotExist(err) {
+		t.Errorf("old copy of version 1 still exists")
+	}
+
+	// Removing one of the versions sharing an object keeps it for the other
+	if err := dm.RemoveVersions([]int64{versions[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions[1:] {
+		if _, err := dm.ReadVersionContent(version); err != nil {
+			t.Errorf("version %d unreadable after removing version %d: %v", version.VersionNumber, versions[0].VersionNumber, err)
+		}
+	}
+}
+
+func TestMigrateStore_CorruptVersion(t *testing.T) {
+	dm, root := newTestDB(t)
+
+	storagePath := filepath.Join("file.txt", "v1")
+	if err := os.MkdirAll(filepath.Join(dm.VersionsDir(), "file.txt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dm.VersionsDir(), storagePath), []byte("damaged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.AddFileVersion(&FileVersion{
+		FilePath:      "file.txt",
+		VersionNumber: 1,
+		Timestamp:     time.Now(),
+		FileHash:      fmt.Sprintf("%x", sha256.Sum256([]byte("original\n"))),
+		FileSize:      9,
+		StoragePath:   storagePath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dm.MigrateStore(LayoutContentAddressable, false); err == nil {
+		t.Fatal("MigrateStore() succeeded with a corrupt version")
+	}
+
+	version, err := dm.GetFileVersion(filepath.Join(root, "file.txt"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.FromSlash(version.StoragePath) != storagePath {
+		t.Errorf("storage path = %s after a failed migration, want %s", version.StoragePath, storagePath)
+	}
+	if layout, _ := dm.StorageLayout(); layout != LayoutPaths {
+		t.Errorf("StorageLayout() = %q after a failed migration, want %q", layout, LayoutPaths)
+	}
+	if entries, _ := os.ReadDir(dm.ObjectsDir()); len(entries) != 0 {
+		t.Errorf("failed migration left %d entries in the objects directory", len(entries))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package database
+
+import "os"
+
+// FileOwner returns nil: file ownership is only recorded on Unix
+func FileOwner(info os.FileInfo) *Owner {
+	return nil
+}
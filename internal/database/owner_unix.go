@@ -0,0 +1,17 @@
+//go:build unix
+
+package database
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileOwner returns the owner of the file described by info
+func FileOwner(info os.FileInfo) *Owner {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &Owner{UID: int(stat.Uid), GID: int(stat.Gid)}
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Settings for case_insensitive_paths
+const (
+	PathCaseAuto        = "auto"
+	PathCaseInsensitive = "true"
+	PathCaseSensitive   = "false"
+)
+
+// CaseInsensitivePaths chooses whether stored file paths are matched
+// regardless of case. With PathCaseAuto it is detected from the filesystem
+// holding each database, so a project on a case-insensitive volume (the
+// default on macOS and Windows) keeps one history per file whatever case it
+// is referred to by.
+var CaseInsensitivePaths = PathCaseAuto
+
+// detectPathCase decides whether the database matches paths regardless of
+// case. It runs before the database is opened, as whether LIKE ignores case
+// is set on each connection.
+func (dm *DatabaseManager) detectPathCase() {
+	switch CaseInsensitivePaths {
+	case PathCaseInsensitive:
+		dm.foldCase = true
+	case PathCaseSensitive:
+		dm.foldCase = false
+	default:
+		dm.foldCase = caseInsensitiveFilesystem(filepath.Dir(dm.dbPath))
+	}
+}
+
+// addPathCaseIndex adds the index lookups regardless of case need
+func (dm *DatabaseManager) addPathCaseIndex() error {
+	if !dm.foldCase {
+		return nil
+	}
+	_, err := dm.db.Exec("CREATE INDEX IF NOT EXISTS idx_file_path_nocase ON versions(file_path COLLATE NOCASE)")
+	if err != nil {
+		return fmt.Errorf("failed to create case-insensitive path index: %w", err)
+	}
+	return nil
+}
+
+// caseInsensitiveFilesystem reports whether the filesystem holding the
+// existing file or directory at path ignores case, by looking it up with the
+// case of its name swapped
+func caseInsensitiveFilesystem(path string) bool {
+	name := filepath.Base(path)
+	swapped := strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, name)
+	if swapped == name {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	swappedInfo, err := os.Stat(filepath.Join(filepath.Dir(path), swapped))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(info, swappedInfo)
+}
+
+// matchStoredCase returns the spelling a file's history is stored under when
+// it differs from relPath only in case, or relPath if it has no history. Only
+// ASCII letters are compared regardless of case.
+func (dm *DatabaseManager) matchStoredCase(relPath string) string {
+	var stored string
+	err := dm.db.QueryRow(`
+	SELECT file_path FROM versions
+	WHERE file_path = ? COLLATE NOCASE
+	ORDER BY file_path = ? DESC, id DESC
+	LIMIT 1
+	`, relPath, relPath).Scan(&stored)
+	if err != nil {
+		return relPath
+	}
+	return stored
+}
+
+// adoptPathCase renames a file's history to relPath when it was stored under
+// a spelling differing only in case, such as before a case-only rename, so
+// listings show the file's current name. Histories of files that were told
+// apart by case before case-insensitive matching was enabled are left alone.
+func (dm *DatabaseManager) adoptPathCase(relPath string) error {
+	var spellings int
+	err := dm.db.QueryRow("SELECT COUNT(DISTINCT file_path) FROM versions WHERE file_path = ? COLLATE NOCASE", relPath).Scan(&spellings)
+	if err != nil {
+		return fmt.Errorf("failed to look up path case: %w", err)
+	}
+	if spellings != 1 {
+		return nil
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, update := range []string{
+		"UPDATE versions SET file_path = ? WHERE file_path = ? COLLATE NOCASE AND file_path != ?",
+		"UPDATE renames SET file_path = ? WHERE file_path = ? COLLATE NOCASE AND file_path != ?",
+		"UPDATE renames SET rename_from = ? WHERE rename_from = ? COLLATE NOCASE AND rename_from != ?",
+	} {
+		if _, err := tx.Exec(update, relPath, relPath, relPath); err != nil {
+			return fmt.Errorf("failed to update path case: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
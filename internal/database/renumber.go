@@ -0,0 +1,147 @@
+package database
+
+import (
+	"fmt"
+)
+
+// VersionGaps describes a file whose version numbers are not contiguous,
+// such as after purging versions from the middle of its history
+type VersionGaps struct {
+	FilePath string
+	// Versions lists the file's version numbers, oldest first
+	Versions []int
+	// Missing is how many numbers are skipped between the first and last
+	Missing int
+}
+
+// FindVersionGaps returns the files whose version numbers have gaps, in path
+// order. With filePath set only that file is checked. Numbering may start
+// above 1, as purging the oldest versions leaves no gap.
+func (dm *DatabaseManager) FindVersionGaps(filePath string) ([]*VersionGaps, error) {
+	query := `SELECT file_path, version_number FROM versions`
+	var args []interface{}
+	if filePath != "" {
+		query += ` WHERE file_path = ?`
+		args = append(args, dm.relPath(filePath))
+	}
+	query += ` ORDER BY file_path, version_number`
+
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query version numbers: %w", err)
+	}
+	defer rows.Close()
+
+	var files []*VersionGaps
+	for rows.Next() {
+		var path string
+		var versionNumber int
+		if err := rows.Scan(&path, &versionNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan version number: %w", err)
+		}
+		if len(files) == 0 || files[len(files)-1].FilePath != path {
+			files = append(files, &VersionGaps{FilePath: path})
+		}
+		file := files[len(files)-1]
+		if n := len(file.Versions); n > 0 {
+			file.Missing += versionNumber - file.Versions[n-1] - 1
+		}
+		file.Versions = append(file.Versions, versionNumber)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating version numbers: %w", err)
+	}
+
+	var gaps []*VersionGaps
+	for _, file := range files {
+		if file.Missing > 0 {
+			gaps = append(gaps, file)
+		}
+	}
+	return gaps, nil
+}
+
+// VersionRenumber is one version moving to a new number
+type VersionRenumber struct {
+	From int
+	To   int
+}
+
+// RenumberResult describes closing the gaps in a file's version numbers
+type RenumberResult struct {
+	FilePath string
+	// Changes lists the versions whose numbers change, oldest first
+	Changes []VersionRenumber
+}
+
+// RenumberVersions closes the gaps in a file's version numbers, keeping its
+// first number and the order of its versions. Only numbers change: stored
+// copies, timestamps and tags stay with their versions. The renumbering
+// happens in one transaction. With dryRun nothing is changed and the result
+// describes what would be.
+func (dm *DatabaseManager) RenumberVersions(filePath string, dryRun bool) (*RenumberResult, error) {
+	relPath := dm.relPath(filePath)
+	result := &RenumberResult{FilePath: relPath}
+
+	rows, err := dm.db.Query(`SELECT id, version_number FROM versions WHERE file_path = ? ORDER BY version_number`, relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query versions: %w", err)
+	}
+	var ids []int64
+	var numbers []int
+	for rows.Next() {
+		var id int64
+		var versionNumber int
+		if err := rows.Scan(&id, &versionNumber); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		ids = append(ids, id)
+		numbers = append(numbers, versionNumber)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating versions: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, mark(ErrNotFound, fmt.Errorf("no versions found for file %s", relPath))
+	}
+
+	var changedIDs []int64
+	for i, number := range numbers {
+		if to := numbers[0] + i; to != number {
+			result.Changes = append(result.Changes, VersionRenumber{From: number, To: to})
+			changedIDs = append(changedIDs, ids[i])
+		}
+	}
+	if dryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Renumber in two steps so that no version ever takes a number another
+	// still holds: out of the way to negative numbers first, then into place
+	for i, id := range changedIDs {
+		if _, err = tx.Exec(`UPDATE versions SET version_number = ? WHERE id = ?`, -result.Changes[i].To, id); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec(`UPDATE versions SET version_number = -version_number WHERE file_path = ? AND version_number < 0`, relPath)
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to renumber versions: %w", err)
+	}
+
+	return result, nil
+}
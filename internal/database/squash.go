@@ -0,0 +1,181 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SquashResult describes collapsing a range of versions of a file into the
+// last of them
+type SquashResult struct {
+	FilePath string
+	From     int
+	To       int
+	// Removed lists the version numbers removed, oldest first
+	Removed []int
+	// MovedTags lists the tags moved from removed versions to the kept one
+	MovedTags []string
+	// Renumbered is how many versions, from the kept one on, move down to
+	// close the gap
+	Renumbered int
+	// ReclaimedBytes is the size of the removed versions' stored copies
+	ReclaimedBytes int64
+}
+
+// SquashVersions collapses versions from to to of a file into version to,
+// which keeps its content and takes the number from. The versions before it
+// in the range are removed, their tags are moved to the kept version, and
+// every later version is renumbered down so no gap is left. Tags, removal
+// and renumbering happen in one transaction. It fails without changing
+// anything if a tag would end up twice on the kept version. With dryRun
+// nothing is changed and the result describes what would be.
+func (dm *DatabaseManager) SquashVersions(filePath string, from, to int, dryRun bool) (*SquashResult, error) {
+	if from < 1 || to <= from {
+		return nil, fmt.Errorf("invalid range %d to %d: --from must be at least 1 and before --to", from, to)
+	}
+
+	relPath := dm.relPath(filePath)
+	result := &SquashResult{FilePath: relPath, From: from, To: to}
+
+	versions, err := dm.GetFileVersions(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept *FileVersion
+	var removedIDs []int64
+	var storagePaths []string
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		switch {
+		case version.VersionNumber == to:
+			kept = version
+		case version.VersionNumber >= from && version.VersionNumber < to:
+			removedIDs = append(removedIDs, version.ID)
+			storagePaths = append(storagePaths, version.StoragePath)
+			result.Removed = append(result.Removed, version.VersionNumber)
+			if !version.IsBaseline() {
+				result.ReclaimedBytes += version.FileSize
+			}
+		}
+		if version.VersionNumber >= to {
+			result.Renumbered++
+		}
+	}
+	if kept == nil {
+		return nil, mark(ErrNotFound, fmt.Errorf("version %d not found for file %s", to, relPath))
+	}
+	if len(removedIDs) == 0 {
+		return nil, mark(ErrNotFound, fmt.Errorf("no versions of %s between %d and %d to squash", relPath, from, to-1))
+	}
+
+	result.MovedTags, err = dm.squashTags(kept, removedIDs)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	// A delta built on a removed version, such as the kept version itself,
+	// must be stored in full first
+	if err := dm.keyframeDependentDeltas(removedIDs); err != nil {
+		return nil, err
+	}
+
+	placeholders, args := versionIDArgs(removedIDs)
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{fmt.Sprintf(`UPDATE tags SET version_id = ? WHERE version_id IN (%s)`, placeholders), append([]interface{}{kept.ID}, args...)},
+		{fmt.Sprintf(`DELETE FROM versions WHERE id IN (%s)`, placeholders), args},
+		// Renumber in two steps so that no version ever takes a number
+		// another still holds: out of the way to negative numbers first,
+		// then down into the gap
+		{`UPDATE versions SET version_number = -version_number WHERE file_path = ? AND version_number >= ?`, []interface{}{relPath, to}},
+		{`UPDATE versions SET version_number = -version_number - ? WHERE file_path = ? AND version_number < 0`, []interface{}{to - from, relPath}},
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement.query, statement.args...); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to squash versions: %w", err)
+	}
+
+	dm.removeUnreferenced(storagePaths)
+	return result, nil
+}
+
+// squashTags returns the names of the tags on the removed versions, which
+// move to kept, failing if kept would end up with a tag twice
+func (dm *DatabaseManager) squashTags(kept *FileVersion, removedIDs []int64) ([]string, error) {
+	placeholders, args := versionIDArgs(append([]int64{kept.ID}, removedIDs...))
+	query := fmt.Sprintf(`
+	SELECT t.tag_name, v.version_number, v.id
+	FROM tags t
+	JOIN versions v ON t.version_id = v.id
+	WHERE v.id IN (%s)
+	ORDER BY v.version_number, t.tag_name
+	`, placeholders)
+
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	tagged := make(map[string][]int)
+	var moved []string
+	for rows.Next() {
+		var name string
+		var versionNumber int
+		var versionID int64
+		if err := rows.Scan(&name, &versionNumber, &versionID); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tagged[name] = append(tagged[name], versionNumber)
+		if versionID != kept.ID {
+			moved = append(moved, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %w", err)
+	}
+
+	var conflicts []string
+	for name, versionNumbers := range tagged {
+		if len(versionNumbers) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("'%s' (versions %s)", name, joinInts(versionNumbers)))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("cannot move tags to version %d, which would carry them twice: %s. Rename the duplicates first with rewind tag --rename",
+			kept.VersionNumber, strings.Join(conflicts, ", "))
+	}
+
+	return moved, nil
+}
+
+// joinInts lists version numbers separated by commas
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// storedCopy is a version's reference to a file in the version store
+type storedCopy struct {
+	id        int64
+	key       string // The file on disk, shared by versions stored as one file
+	inScope   bool   // A live version of a file under the prefix being sized
+	purgeable bool   // Untagged and not the latest version of its file
+}
+
+// storedCopies returns every version that has a file in the store, oldest
+// first, along with the size on disk of each distinct file. Versions sharing
+// a file, as content-addressable objects and reused hardlinks do, share its
+// key so it is counted once. Deltas and compressed versions count at the size
+// they are stored at, and a latest version still hardlinked to the working
+// file takes no space of its own. Only live versions of files whose relative
+// path starts with relPrefix are in scope, but every version holds on to the
+// file it references.
+func (dm *DatabaseManager) storedCopies(relPrefix string) ([]storedCopy, map[string]int64, error) {
+	query := `
+	SELECT v.id, v.file_path, v.storage_path, v.storage_type,
+		v.deleted = 0 AND v.file_path LIKE ? ESCAPE '\',
+		EXISTS (SELECT 1 FROM tags t WHERE t.version_id = v.id),
+		v.version_number = (SELECT MAX(latest.version_number) FROM versions latest WHERE latest.file_path = v.file_path)
+	FROM versions v
+	WHERE v.storage_path != ''
+	ORDER BY v.timestamp ASC, v.id ASC
+	`
+
+	rows, err := dm.db.Query(query, likePrefix(relPrefix))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query stored versions: %w", err)
+	}
+	defer rows.Close()
+
+	var copies []storedCopy
+	sizes := make(map[string]int64)
+	live := make(map[string]bool) // Files that are also a working file
+	for rows.Next() {
+		var c storedCopy
+		var filePath, storagePath, storageType string
+		var tagged, latest bool
+		if err := rows.Scan(&c.id, &filePath, &storagePath, &storageType, &c.inScope, &tagged, &latest); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan stored version: %w", err)
+		}
+		c.purgeable = !tagged && !latest
+		c.key = storagePath
+
+		info, err := os.Stat(filepath.Join(dm.VersionsDir(), filepath.FromSlash(storagePath)))
+		if err == nil {
+			if key := storedFileKey(info); key != "" {
+				c.key = key
+			}
+			sizes[c.key] = info.Size()
+
+			if latest && storageType == StorageTypeFull {
+				working, err := os.Stat(filepath.Join(dm.rootDir, filepath.FromSlash(filePath)))
+				if err == nil && os.SameFile(info, working) {
+					live[c.key] = true
+				}
+			}
+		}
+		copies = append(copies, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating stored versions: %w", err)
+	}
+
+	for key := range live {
+		sizes[key] = 0
+	}
+	return copies, sizes, nil
+}
+
+// storedSize sums the size of each file the in-scope copies reference once
+func storedSize(copies []storedCopy, sizes map[string]int64) int64 {
+	counted := make(map[string]bool)
+	var total int64
+	for _, c := range copies {
+		if c.inScope && !counted[c.key] {
+			counted[c.key] = true
+			total += sizes[c.key]
+		}
+	}
+	return total
+}
+
+// GetStoredSizeWithout returns what GetStoredSize would report once
+// versionIDs were removed. A file shared with a version that is kept still
+// counts.
+func (dm *DatabaseManager) GetStoredSizeWithout(versionIDs []int64, relPrefix string) (int64, error) {
+	copies, sizes, err := dm.storedCopies(relPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current total size: %w", err)
+	}
+
+	removed := make(map[int64]bool, len(versionIDs))
+	for _, id := range versionIDs {
+		removed[id] = true
+	}
+
+	// Files only referenced by removed versions are gone; the rest still
+	// count for the in-scope versions that keep them
+	kept := make(map[string]bool)
+	for _, c := range copies {
+		if !removed[c.id] {
+			kept[c.key] = true
+		}
+	}
+
+	var remaining []storedCopy
+	for _, c := range copies {
+		if kept[c.key] {
+			remaining = append(remaining, c)
+		}
+	}
+	return storedSize(remaining, sizes), nil
+}
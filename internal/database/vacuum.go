@@ -0,0 +1,49 @@
+package database
+
+import (
+	"fmt"
+	"os"
+)
+
+// VacuumResult describes the outcome of compacting a project database
+type VacuumResult struct {
+	SizeBefore int64 `json:"size_before"`
+	SizeAfter  int64 `json:"size_after"`
+}
+
+// Vacuum rebuilds the database file without the free pages left behind by
+// purges, returning them to the filesystem, and refreshes the query
+// planner's statistics. It needs exclusive access to the database, so
+// writers wait (up to the busy timeout) while it runs.
+func (dm *DatabaseManager) Vacuum() (*VacuumResult, error) {
+	before, err := os.Stat(dm.dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+
+	if _, err := dm.db.Exec("VACUUM"); err != nil {
+		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if _, err := dm.db.Exec("PRAGMA optimize"); err != nil {
+		return nil, fmt.Errorf("failed to optimize database: %w", err)
+	}
+
+	after, err := os.Stat(dm.dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database: %w", err)
+	}
+
+	return &VacuumResult{SizeBefore: before.Size(), SizeAfter: after.Size()}, nil
+}
+
+// FreePages returns how many of the database's pages are unused, and how
+// many pages it has in total
+func (dm *DatabaseManager) FreePages() (free, total int64, err error) {
+	if err := dm.db.QueryRow("PRAGMA freelist_count").Scan(&free); err != nil {
+		return 0, 0, fmt.Errorf("failed to count free pages: %w", err)
+	}
+	if err := dm.db.QueryRow("PRAGMA page_count").Scan(&total); err != nil {
+		return 0, 0, fmt.Errorf("failed to count pages: %w", err)
+	}
+	return free, total, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package database
+
+// FileXattrs returns nil: extended attributes are only recorded on Linux and
+// macOS
+func FileXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// SetFileXattrs does nothing: extended attributes are only recorded on Linux
+// and macOS
+func SetFileXattrs(path string, xattrs map[string][]byte) error {
+	return nil
+}
@@ -0,0 +1,95 @@
+//go:build linux || darwin
+
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileXattrs returns the extended attributes of the file at path. A file
+// system without extended attributes gives an empty set.
+func FileXattrs(path string) (map[string][]byte, error) {
+	names, err := xattrNames(path)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte, len(names))
+	for _, name := range names {
+		value, err := xattrValue(path, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read extended attribute %s: %w", name, err)
+		}
+		xattrs[name] = value
+	}
+	return xattrs, nil
+}
+
+// SetFileXattrs sets the extended attributes of the file at path to the
+// recorded values. Attributes the file has that are not in xattrs are left
+// alone. Every attribute is tried, and the ones that could not be set are
+// reported together.
+func SetFileXattrs(path string, xattrs map[string][]byte) error {
+	var errs []error
+	for name, value := range xattrs {
+		if current, err := xattrValue(path, name); err == nil && string(current) == string(value) {
+			continue
+		}
+		if err := unix.Setxattr(path, name, value, 0); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// xattrNames lists the names of the extended attributes of path
+func xattrNames(path string) ([]string, error) {
+	list, err := readXattr(func(dest []byte) (int, error) { return unix.Listxattr(path, dest) })
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extended attributes: %w", err)
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(list), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// xattrValue reads one extended attribute of path
+func xattrValue(path, name string) ([]byte, error) {
+	return readXattr(func(dest []byte) (int, error) { return unix.Getxattr(path, name, dest) })
+}
+
+// readXattr calls read first to size the buffer and then to fill it, trying
+// again if the value grew in between
+func readXattr(read func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := read(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return []byte{}, nil
+		}
+
+		dest := make([]byte, size)
+		size, err = read(dest)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return dest[:size], nil
+	}
+}
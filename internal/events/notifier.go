@@ -54,9 +54,10 @@ func (d *EventDebouncer) ShouldProcess(filePath string, eventType string) bool {
 }
 
 type EventsNotifier struct {
-	Notifier  *fsnotify.Watcher
-	callback  EventCallback
-	debouncer *EventDebouncer
+	Notifier   *fsnotify.Watcher
+	callback   EventCallback
+	onDebounce EventCallback
+	debouncer  *EventDebouncer
 }
 
 func NewEventsNotifier() (*EventsNotifier, error) {
@@ -83,6 +84,12 @@ func (en *EventsNotifier) SetCallback(callback EventCallback) {
 	en.callback = callback
 }
 
+// SetDebounceCallback sets a function told about each event dropped as a
+// duplicate, so they can be traced
+func (en *EventsNotifier) SetDebounceCallback(callback EventCallback) {
+	en.onDebounce = callback
+}
+
 // Start begins listening for file system events
 func (en *EventsNotifier) Start(ctx context.Context) error {
 	app.Logger.Info("Starting events notifier")
@@ -138,6 +145,9 @@ func (en *EventsNotifier) handleEvent(event fsnotify.Event) {
 	// Check if we should process this event (debouncing)
 	if !en.debouncer.ShouldProcess(event.Name, eventTypeStr) {
 		logger.Debug("Event debounced - skipping duplicate")
+		if en.onDebounce != nil {
+			en.onDebounce(event)
+		}
 		return
 	}
 
@@ -3,32 +3,26 @@ package ipc
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/davenicholson-xyz/rewind/internal/ipc/protocol"
 	"github.com/davenicholson-xyz/rewind/internal/watcher"
 	"github.com/davenicholson-xyz/rewind/network"
 	"github.com/sirupsen/logrus"
 )
 
-type Message struct {
-	Action string `json:"action"`
-	Path   string `json:"path"`
-}
-
-type Response struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-}
-
 type Handler struct {
 	ipc          *network.IPCClient
 	WatchManager *watcher.WatchManager
 }
 
-func NewHandler(wm *watcher.WatchManager) (*Handler, error) {
+func NewHandler(wm *watcher.WatchManager, instance string) (*Handler, error) {
 
-	ipc, err := network.NewIPCClient(network.IPCConfig{AppName: "rewind"})
+	ipc, err := network.NewIPCClient(network.IPCConfig{AppName: "rewind", Instance: instance})
 	if err != nil {
 		return nil, err
 	}
@@ -64,10 +58,10 @@ func (h *Handler) Start() {
 	wg.Wait()
 }
 func (h *Handler) processIPCMessage(msg network.IPCMessage) error {
-	var message Message
+	var message protocol.Message
 	if err := json.Unmarshal([]byte(msg.Content), &message); err != nil {
 		app.Logger.WithError(err).Error("Failed to decode IPC message")
-		response := Response{Success: false, Message: "Invalid message format"}
+		response := protocol.Response{Success: false, Message: "Invalid message format"}
 		json.NewEncoder(msg.Connection).Encode(response)
 		return err
 	}
@@ -77,57 +71,85 @@ func (h *Handler) processIPCMessage(msg network.IPCMessage) error {
 		"path":   message.Path,
 	}).Info("Received IPC message")
 
-	var response Response
+	var response protocol.Response
 
 	switch message.Action {
-	case "add":
-		err := h.WatchManager.AddWatch(message.Path)
+	case protocol.ActionAdd:
+		stats, err := h.WatchManager.AddWatch(message.Path)
 		if err != nil {
 			app.Logger.WithError(err).Error("Failed to add watch")
-			response = Response{
+			response = protocol.Response{
 				Success: false,
 				Message: fmt.Sprintf("Failed to add watch for path %s: %v", message.Path, err),
 			}
 		} else {
 			app.Logger.WithField("path", message.Path).Info("Successfully added watch")
-			response = Response{
+			response = protocol.Response{
 				Success: true,
 				Message: fmt.Sprintf("Successfully added watch for path: %s", message.Path),
 			}
+			if statsJSON, err := json.Marshal(stats); err == nil {
+				response.Data = statsJSON
+			}
 		}
-	case "remove":
+	case protocol.ActionRemove:
 		err := h.WatchManager.RemoveWatch(message.Path)
 		if err != nil {
 			app.Logger.WithError(err).Error("Failed to remove watch")
-			response = Response{
+			response = protocol.Response{
 				Success: false,
 				Message: fmt.Sprintf("Failed to add watch for path %s: %v", message.Path, err),
 			}
 		} else {
 			app.Logger.WithField("path", message.Path).Info("Successfully removed watch")
-			response = Response{
+			response = protocol.Response{
 				Success: true,
 				Message: fmt.Sprintf("Successfully removed watch from path: %s", message.Path),
 			}
 		}
-	case "status":
+	case protocol.ActionStatus:
 		status := h.WatchManager.GetStatus()
 		statusJSON, err := json.Marshal(status)
 		if err != nil {
 			app.Logger.WithError(err).Error("Failed to marshal status")
-			response = Response{
+			response = protocol.Response{
 				Success: false,
 				Message: fmt.Sprintf("Failed to get status: %v", err),
 			}
 		} else {
-			response = Response{
+			response = protocol.Response{
 				Success: true,
 				Message: string(statusJSON),
 			}
 		}
-	case "stop":
+	case protocol.ActionMetrics:
+		metrics := h.WatchManager.GetMetrics()
+		metricsJSON, err := json.Marshal(metrics)
+		if err != nil {
+			app.Logger.WithError(err).Error("Failed to marshal metrics")
+			response = protocol.Response{
+				Success: false,
+				Message: fmt.Sprintf("Failed to get metrics: %v", err),
+			}
+		} else {
+			response = protocol.Response{
+				Success: true,
+				Message: string(metricsJSON),
+			}
+		}
+	case protocol.ActionContent:
+		response = h.versionContent(message)
+	case protocol.ActionVacuum:
+		response = h.vacuum(message)
+	case protocol.ActionMigrateStore:
+		response = h.migrateStore(message)
+	case protocol.ActionChanges:
+		response = h.changes(message)
+	case protocol.ActionTrace:
+		response = h.trace(message)
+	case protocol.ActionStop:
 		app.Logger.Info("Received stop command via IPC")
-		response = Response{
+		response = protocol.Response{
 			Success: true,
 			Message: "Stop command received, shutting down...",
 		}
@@ -140,7 +162,7 @@ func (h *Handler) processIPCMessage(msg network.IPCMessage) error {
 		return nil
 	default:
 		app.Logger.WithField("action", message.Action).Error("Unknown action")
-		response = Response{
+		response = protocol.Response{
 			Success: false,
 			Message: fmt.Sprintf("Unknown action: %s", message.Action),
 		}
@@ -158,3 +180,204 @@ func (h *Handler) processIPCMessage(msg network.IPCMessage) error {
 
 	return nil
 }
+
+// vacuum compacts a project's database between captures, so clients need
+// not stop the daemon first
+func (h *Handler) vacuum(message protocol.Message) protocol.Response {
+	if !filepath.IsAbs(message.Path) {
+		return protocol.Response{Success: false, Message: "path must be absolute"}
+	}
+
+	result, err := h.WatchManager.VacuumProject(filepath.Clean(message.Path))
+	if err != nil {
+		app.Logger.WithField("path", message.Path).WithError(err).Warn("Failed to vacuum database")
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to vacuum database of %s: %v", message.Path, err),
+		}
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode vacuum result: %v", err),
+		}
+	}
+
+	return protocol.Response{
+		Success: true,
+		Message: fmt.Sprintf("Vacuumed database of %s", message.Path),
+		Data:    resultJSON,
+	}
+}
+
+// migrateStore moves a project's version store to another layout between
+// captures, so no version is captured into the old layout mid-migration
+func (h *Handler) migrateStore(message protocol.Message) protocol.Response {
+	if !filepath.IsAbs(message.Path) {
+		return protocol.Response{Success: false, Message: "path must be absolute"}
+	}
+
+	result, err := h.WatchManager.MigrateStore(filepath.Clean(message.Path), message.Layout)
+	if err != nil {
+		app.Logger.WithField("path", message.Path).WithError(err).Warn("Failed to migrate version store")
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to migrate version store of %s: %v", message.Path, err),
+		}
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode migration result: %v", err),
+		}
+	}
+
+	return protocol.Response{
+		Success: true,
+		Message: fmt.Sprintf("Migrated version store of %s to %s", message.Path, message.Layout),
+		Data:    resultJSON,
+	}
+}
+
+// changes returns what the daemon captured or recorded as deleted after the
+// sequence number the client last saw, so clients polling for changes need
+// not fetch the full status each time
+func (h *Handler) changes(message protocol.Message) protocol.Response {
+	if message.Path != "" && !filepath.IsAbs(message.Path) {
+		return protocol.Response{Success: false, Message: "path must be absolute"}
+	}
+
+	changes := h.WatchManager.ChangesSince(message.Start, message.Since, message.Path)
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode changes: %v", err),
+		}
+	}
+
+	return protocol.Response{
+		Success: true,
+		Message: fmt.Sprintf("%d changes since %d", len(changes.Changes), message.Since),
+		Data:    changesJSON,
+	}
+}
+
+// trace starts logging the events and capture decisions for a path, to find
+// out why it isn't being versioned without debug logging the whole daemon
+func (h *Handler) trace(message protocol.Message) protocol.Response {
+	if !filepath.IsAbs(message.Path) {
+		return protocol.Response{Success: false, Message: "path must be absolute"}
+	}
+
+	var duration time.Duration
+	if message.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(message.Duration); err != nil || duration <= 0 {
+			return protocol.Response{Success: false, Message: fmt.Sprintf("invalid duration: %s", message.Duration)}
+		}
+	}
+
+	until, err := h.WatchManager.Trace(message.Path, duration)
+	if err != nil {
+		return protocol.Response{Success: false, Message: fmt.Sprintf("Failed to trace %s: %v", message.Path, err)}
+	}
+
+	traceJSON, err := json.Marshal(protocol.Trace{Path: filepath.Clean(message.Path), Until: until})
+	if err != nil {
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode trace: %v", err),
+		}
+	}
+
+	return protocol.Response{
+		Success: true,
+		Message: fmt.Sprintf("Tracing %s until %s", message.Path, until.Format(time.RFC3339)),
+		Data:    traceJSON,
+	}
+}
+
+// versionContent reads a stored version of a file for clients, such as editor
+// plugins, that talk only to the daemon rather than reading .rewind. Version 0
+// means the latest version.
+func (h *Handler) versionContent(message protocol.Message) protocol.Response {
+	content, err := h.readVersion(message.Path, message.Version)
+	if err != nil {
+		app.Logger.WithField("path", message.Path).WithError(err).Warn("Failed to read version content")
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to read version of %s: %v", message.Path, err),
+		}
+	}
+
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return protocol.Response{
+			Success: false,
+			Message: fmt.Sprintf("Failed to encode version content: %v", err),
+		}
+	}
+
+	return protocol.Response{
+		Success: true,
+		Message: fmt.Sprintf("Version %d of %s", content.Version, message.Path),
+		Data:    contentJSON,
+	}
+}
+
+func (h *Handler) readVersion(path string, versionNumber int) (*protocol.VersionContent, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("path must be absolute")
+	}
+	if versionNumber < 0 {
+		return nil, fmt.Errorf("invalid version: %d", versionNumber)
+	}
+
+	filePath := filepath.Clean(path)
+	watch, found := h.WatchManager.WatchList.FindByPath(filePath)
+	if !found {
+		return nil, fmt.Errorf("not in a watched project")
+	}
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var version *database.FileVersion
+	if versionNumber > 0 {
+		version, err = db.GetFileVersion(filePath, versionNumber)
+	} else {
+		version, err = db.GetLatestFileVersion(filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, fmt.Errorf("version not found")
+	}
+	if version.Deleted {
+		return nil, fmt.Errorf("version %d records the file's deletion and has no content", version.VersionNumber)
+	}
+
+	content, err := db.ReadVersionContent(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &protocol.VersionContent{
+		Path:    filePath,
+		Version: version.VersionNumber,
+		Hash:    version.FileHash,
+		Content: content,
+	}, nil
+}
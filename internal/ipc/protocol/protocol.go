@@ -0,0 +1,65 @@
+// Package protocol defines the messages exchanged between the rewind CLI and
+// the daemon over the IPC socket.
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Action identifies the operation a message asks the daemon to perform
+type Action string
+
+const (
+	ActionAdd     Action = "add"
+	ActionRemove  Action = "remove"
+	ActionStatus  Action = "status"
+	ActionMetrics Action = "metrics"
+	ActionStop    Action = "stop"
+	ActionContent Action = "content"
+	ActionVacuum  Action = "vacuum"
+	// ActionMigrateStore moves a project's version store to Message.Layout
+	ActionMigrateStore Action = "migrate-store"
+	// ActionChanges returns the captures and deletions after Message.Since,
+	// numbered by the daemon run Message.Start
+	ActionChanges Action = "changes"
+	// ActionTrace logs every event and capture decision for Message.Path
+	// for Message.Duration
+	ActionTrace Action = "trace"
+)
+
+// Message is a request sent from the CLI to the daemon
+type Message struct {
+	Action  Action `json:"action"`
+	Path    string `json:"path"`
+	Version int    `json:"version,omitempty"`
+	Layout  string `json:"layout,omitempty"`
+	Since   uint64 `json:"since,omitempty"`
+	// Start is the daemon run Since was numbered by
+	Start string `json:"start,omitempty"`
+	// Duration is a Go duration such as "5m"
+	Duration string `json:"duration,omitempty"`
+}
+
+// Response is the daemon's reply to a Message. Actions that return
+// structured results put them in Data.
+type Response struct {
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Trace is the Data of a trace response
+type Trace struct {
+	Path  string    `json:"path"`
+	Until time.Time `json:"until"`
+}
+
+// VersionContent is the Data of a content response. Content is base64 encoded
+// in the JSON.
+type VersionContent struct {
+	Path    string `json:"path"`
+	Version int    `json:"version"`
+	Hash    string `json:"hash"`
+	Content []byte `json:"content"`
+}
@@ -0,0 +1,22 @@
+package watcher
+
+// editorArtifactPatterns match the transient files that editors create while
+// saving, locking or backing up a file. They are never worth versioning, so
+// with IgnoreEditorArtifacts set they are added to every project's ignore
+// patterns, even if its own patterns miss them.
+var editorArtifactPatterns = []string{
+	".#*",              // Emacs lock files
+	"#*#",              // Emacs autosave files
+	".goutputstream-*", // gedit/GIO atomic save
+	".~lock.*",         // LibreOffice lock files
+	"~$*",              // Microsoft Office owner files
+	"*~",               // Emacs, gedit and nano backups
+	"*.swp",            // Vim swap files
+	"*.swo",            // Vim swap files
+	"*.swx",            // Vim swap files
+	"4913",             // Vim's check that a directory is writable
+	"*.kate-swp",       // Kate swap files
+	"*___jb_tmp___",    // JetBrains safe write
+	"*___jb_old___",    // JetBrains safe write
+	"*.crswap",         // Chrome File System Access API
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"io"
+	"testing"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEditorArtifactPatterns(t *testing.T) {
+	watch := &Watch{Path: "/project", IgnorePatterns: editorArtifactPatterns}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/project/main.go~", true},
+		{"/project/.main.go.swp", true},
+		{"/project/.#main.go", true},
+		{"/project/#main.go#", true},
+		{"/project/4913", true},
+		{"/project/main.go___jb_tmp___", true},
+		{"/project/.goutputstream-ABC123", true},
+		{"/project/main.go", false},
+		{"/project/2024", false},
+		{"/project/issue#12.md", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if result := watch.ShouldIgnore(tt.path); result != tt.expected {
+				t.Errorf("ShouldIgnore(%q) = %v, expected %v", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadIgnorePatterns_EditorArtifacts(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	root := t.TempDir()
+
+	for _, enabled := range []bool{true, false} {
+		config := DefaultConfig()
+		config.IgnoreEditorArtifacts = enabled
+		wl := &WatchList{Config: config}
+
+		patterns, err := wl.loadIgnorePatterns(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		watch := &Watch{Path: root, IgnorePatterns: patterns}
+		if ignored := watch.ShouldIgnore(root + "/.main.go.swp"); ignored != enabled {
+			t.Errorf("with ignore_editor_artifacts %v, swap file ignored = %v", enabled, ignored)
+		}
+	}
+}
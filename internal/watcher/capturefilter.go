@@ -0,0 +1,34 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+)
+
+// CaptureFilter decides whether a file that is new or may have changed is
+// captured. It is called before the file is hashed or read, with the file's
+// absolute path and current info. When capture is false the file is skipped
+// and reason is logged.
+type CaptureFilter func(path string, info os.FileInfo) (capture bool, reason string)
+
+// DefaultCaptureFilter applies the ignore and include patterns of the watch
+// holding path and the configured file size range. It is the CaptureFilter a
+// WatchManager starts with; a replacement can call it to add to these rules
+// rather than replace them.
+func (wm *WatchManager) DefaultCaptureFilter(path string, info os.FileInfo) (bool, string) {
+	if containing := wm.WatchList.WatchesContaining(path); len(containing) > 0 {
+		watch := containing[0]
+		if watch.ShouldIgnore(path) {
+			return false, "matches an ignore pattern"
+		}
+		if !watch.ShouldInclude(path) {
+			return false, "not in include list"
+		}
+	}
+
+	if !wm.sizeAllowed(info.Size()) {
+		return false, fmt.Sprintf("size %d outside configured size range", info.Size())
+	}
+
+	return true, ""
+}
@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxChanges is how many captures and deletions are kept for clients asking
+// what changed since they last looked
+const maxChanges = 1000
+
+// Kinds of change kept in the change log
+const (
+	ChangeCapture  = "capture"
+	ChangeBaseline = "baseline"
+	ChangeDelete   = "delete"
+)
+
+// Change is a version the daemon captured or a deletion it recorded. Seq
+// numbers the changes the daemon has recorded since it started, in order.
+type Change struct {
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Project string    `json:"project"`
+	Path    string    `json:"path"`
+	Version int       `json:"version"`
+	Op      string    `json:"op,omitempty"`
+}
+
+// Changes answers a query for the changes after a sequence number
+type Changes struct {
+	// Start identifies the run of the daemon the sequence numbers belong
+	// to, to be passed back with since by the next query
+	Start string `json:"start"`
+
+	// Seq is the sequence number of the latest change, to be passed back
+	// as since by the next query
+	Seq uint64 `json:"seq"`
+
+	// Changes are those after since, oldest first
+	Changes []Change `json:"changes"`
+
+	// Reset is set when some changes after since are no longer kept, or
+	// since came from a daemon that has restarted. The client should fetch
+	// the full state again rather than rely on Changes, which then lists
+	// every change kept.
+	Reset bool `json:"reset"`
+}
+
+// changeLog is a ring buffer of the latest changes, numbered as they are added
+type changeLog struct {
+	mu      sync.Mutex
+	start   string
+	changes []Change
+	next    int
+	seq     uint64
+}
+
+func (l *changeLog) add(change Change) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	change.Seq = l.seq
+	if len(l.changes) < maxChanges {
+		l.changes = append(l.changes, change)
+		return
+	}
+	l.changes[l.next] = change
+	l.next = (l.next + 1) % maxChanges
+}
+
+// newStartID returns an ID for this run of the daemon, telling its sequence
+// numbers apart from those of an earlier run
+func newStartID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// since returns the kept changes after seq under path, or all of them when
+// path is empty. start is the run of the daemon seq was read from, if known.
+func (l *changeLog) since(start string, seq uint64, path string) Changes {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// A sequence number from an earlier run can be behind this one's, so
+	// it can't be told apart by its value alone
+	restarted := start != "" && start != l.start
+	if restarted {
+		seq = 0
+	}
+
+	result := Changes{
+		Start:   l.start,
+		Seq:     l.seq,
+		Changes: []Change{},
+		Reset:   restarted || seq > l.seq || seq < l.seq-uint64(len(l.changes)),
+	}
+	for i := range l.changes {
+		change := l.changes[(l.next+i)%len(l.changes)]
+		if change.Seq > seq && changeUnder(change, path) {
+			result.Changes = append(result.Changes, change)
+		}
+	}
+	return result
+}
+
+func changeUnder(change Change, path string) bool {
+	if path == "" {
+		return true
+	}
+	filePath := filepath.Join(change.Project, change.Path)
+	return filePath == path || strings.HasPrefix(filePath, path+string(filepath.Separator))
+}
+
+// recordChange adds a capture or deletion of relPath in watch to the change log
+func (wm *WatchManager) recordChange(kind, watchPath, relPath string, version int, op string) {
+	wm.changes.add(Change{
+		Time:    time.Now(),
+		Kind:    kind,
+		Project: watchPath,
+		Path:    relPath,
+		Version: version,
+		Op:      op,
+	})
+}
+
+// ChangesSince returns the versions captured and deletions recorded after
+// the change numbered seq, for clients that poll the daemon and want only
+// what is new. start is the Start of the reply seq was read from, or empty
+// if it isn't known. A non-empty path limits them to changes under it.
+func (wm *WatchManager) ChangesSince(start string, seq uint64, path string) Changes {
+	if path != "" {
+		path = filepath.Clean(path)
+	}
+	return wm.changes.since(start, seq, path)
+}
@@ -0,0 +1,78 @@
+package watcher
+
+import "testing"
+
+func TestChangeLogSince(t *testing.T) {
+	var log changeLog
+	log.add(Change{Kind: ChangeCapture, Project: "/home/user/app", Path: "main.go", Version: 1})
+	log.add(Change{Kind: ChangeCapture, Project: "/home/user/app", Path: "docs/readme.md", Version: 1})
+	log.add(Change{Kind: ChangeDelete, Project: "/home/user/site", Path: "index.html", Version: 3})
+
+	all := log.since("", 0, "")
+	if all.Seq != 3 || len(all.Changes) != 3 || all.Reset {
+		t.Fatalf("since(0) = seq %d, %d changes, reset %v; want seq 3, 3 changes", all.Seq, len(all.Changes), all.Reset)
+	}
+	for i, change := range all.Changes {
+		if change.Seq != uint64(i+1) {
+			t.Errorf("change %d has seq %d, want %d", i, change.Seq, i+1)
+		}
+	}
+
+	if newer := log.since("", 2, ""); len(newer.Changes) != 1 || newer.Changes[0].Path != "index.html" {
+		t.Errorf("since(2) = %+v, want only the deletion of index.html", newer.Changes)
+	}
+	if none := log.since("", 3, ""); len(none.Changes) != 0 || none.Reset {
+		t.Errorf("since(3) = %+v, reset %v; want no changes", none.Changes, none.Reset)
+	}
+
+	// Only changes under the path are listed, but the sequence is global
+	under := log.since("", 0, "/home/user/app/docs")
+	if under.Seq != 3 || len(under.Changes) != 1 || under.Changes[0].Path != "docs/readme.md" {
+		t.Errorf("since(0) under docs = seq %d, %+v; want seq 3 and docs/readme.md", under.Seq, under.Changes)
+	}
+	if prefix := log.since("", 0, "/home/user/ap"); len(prefix.Changes) != 0 {
+		t.Errorf("since(0) under a name prefix = %+v, want none", prefix.Changes)
+	}
+
+	// A sequence number from before a restart is ahead of the daemon's
+	if restarted := log.since("", 10, ""); !restarted.Reset {
+		t.Error("since(10) with 3 changes recorded is not a reset")
+	}
+}
+
+func TestChangeLogSinceRestart(t *testing.T) {
+	log := changeLog{start: "run2"}
+	log.add(Change{Kind: ChangeCapture, Project: "/app", Path: "main.go", Version: 4})
+	log.add(Change{Kind: ChangeCapture, Project: "/app", Path: "main.go", Version: 5})
+
+	if same := log.since("run2", 1, ""); same.Reset || len(same.Changes) != 1 || same.Start != "run2" {
+		t.Errorf("since(run2, 1) = %+v; want one change from run2, no reset", same)
+	}
+
+	// Seq 1 from the earlier run is behind this run's seq, but names a
+	// different change
+	restarted := log.since("run1", 1, "")
+	if !restarted.Reset || len(restarted.Changes) != 2 {
+		t.Errorf("since(run1, 1) = %d changes, reset %v; want every kept change and a reset", len(restarted.Changes), restarted.Reset)
+	}
+}
+
+func TestChangeLogDropsOldest(t *testing.T) {
+	var log changeLog
+	for i := 0; i < maxChanges+5; i++ {
+		log.add(Change{Kind: ChangeCapture, Project: "/app", Path: "main.go", Version: i + 1})
+	}
+
+	changes := log.since("", 5, "")
+	if changes.Reset || len(changes.Changes) != maxChanges || changes.Changes[0].Seq != 6 {
+		t.Errorf("since(5) = %d changes from seq %d, reset %v; want %d from seq 6", len(changes.Changes), changes.Changes[0].Seq, changes.Reset, maxChanges)
+	}
+	if last := changes.Changes[len(changes.Changes)-1]; last.Seq != maxChanges+5 {
+		t.Errorf("last change has seq %d, want %d", last.Seq, maxChanges+5)
+	}
+
+	// Change 5 was dropped, so a client that last saw 4 missed it
+	if missed := log.since("", 4, ""); !missed.Reset {
+		t.Error("since(4) after change 5 was dropped is not a reset")
+	}
+}
@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// compressInterval is how often the daemon looks for versions old enough to
+// compress
+const compressInterval = time.Hour
+
+// startCompression compresses versions older than CompressAfter in the
+// background, once at startup and then every compressInterval
+func (wm *WatchManager) startCompression() {
+	if wm.config().CompressAfter <= 0 {
+		return
+	}
+
+	wm.goTracked(func() {
+		ticker := time.NewTicker(compressInterval)
+		defer ticker.Stop()
+
+		for {
+			for _, watch := range wm.WatchList.Snapshot() {
+				wm.compressWatch(watch)
+			}
+
+			select {
+			case <-wm.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// compressWatch compresses one project's versions older than CompressAfter.
+// Versions that would not get smaller are remembered so they are not read
+// again on every pass.
+func (wm *WatchManager) compressWatch(watch *Watch) {
+	logger := app.Logger.WithField("watch", watch.Path)
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		logger.WithError(err).Warn("Could not initialise database for compression")
+		return
+	}
+	if err := db.Connect(); err != nil {
+		logger.WithError(err).Warn("Could not connect to database for compression")
+		return
+	}
+	defer db.Close()
+
+	versions, err := db.GetCompressibleVersions(time.Now().Add(-wm.config().CompressAfter))
+	if err != nil {
+		logger.WithError(err).Warn("Failed to find versions to compress")
+		return
+	}
+
+	var compressed int
+	var saved int64
+	for _, version := range versions {
+		if wm.ctx.Err() != nil {
+			return
+		}
+		if wm.isIncompressible(version.ID) {
+			continue
+		}
+
+		// Hold off captures so a delta is never built on a keyframe that is
+		// being replaced
+		unlock := wm.projects.lock(watch.Path)
+		result, err := db.CompressVersion(version, false)
+		unlock()
+
+		if err != nil {
+			logger.WithField("path", version.FilePath).WithField("version", version.VersionNumber).WithError(err).Warn("Failed to compress version")
+			wm.markIncompressible(version.ID)
+			continue
+		}
+		if result.Skipped {
+			wm.markIncompressible(version.ID)
+			continue
+		}
+
+		compressed++
+		saved += result.OriginalSize - result.CompressedSize
+	}
+
+	if compressed > 0 {
+		logger.WithFields(logrus.Fields{
+			"versions":   compressed,
+			"savedBytes": saved,
+		}).Info("Compressed old versions")
+	}
+}
+
+func (wm *WatchManager) isIncompressible(versionID int64) bool {
+	wm.compressMu.Lock()
+	defer wm.compressMu.Unlock()
+	return wm.incompressible[versionID]
+}
+
+func (wm *WatchManager) markIncompressible(versionID int64) {
+	wm.compressMu.Lock()
+	defer wm.compressMu.Unlock()
+	wm.incompressible[versionID] = true
+}
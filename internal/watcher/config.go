@@ -0,0 +1,194 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// Storage modes for captured versions
+const (
+	StorageModeCopy     = "copy"
+	StorageModeHardlink = "hardlink"
+	StorageModeDelta    = "delta"
+)
+
+// Config holds the tunable behaviour of the watch manager
+type Config struct {
+	// Fsync flushes every stored version to disk as it is written. When
+	// disabled, a single filesystem sync is issued at the end of each scan.
+	Fsync bool `json:"fsync"`
+
+	// AutoIgnoreGit adds ".git/" to a watch's ignore patterns when a .git
+	// directory would otherwise be versioned
+	AutoIgnoreGit bool `json:"auto_ignore_git"`
+
+	// IgnoreEditorArtifacts adds the names of editors' swap, lock and backup
+	// files to every watch's ignore patterns
+	IgnoreEditorArtifacts bool `json:"ignore_editor_artifacts"`
+
+	// StorageMode selects how versions are stored: "copy" duplicates the file,
+	// "hardlink" links it into the store when on the same filesystem and
+	// "delta" stores text files as a patch against their previous version
+	StorageMode string `json:"storage_mode"`
+
+	// DeltaKeyframeInterval is how many versions in a row delta mode may chain
+	// from one full copy before storing another. It bounds how many patches
+	// are applied to rebuild a version.
+	DeltaKeyframeInterval int `json:"delta_keyframe_interval"`
+
+	// HashAlgorithm is what new versions are hashed with to tell whether a
+	// file changed: "sha256", "blake3" or "xxhash". Versions keep the
+	// algorithm they were hashed with, so it can be changed at any time.
+	HashAlgorithm string `json:"hash_algorithm"`
+
+	// Include restricts versioning to files matching these patterns, in
+	// addition to any listed in a project's .rwinclude. Empty means all files.
+	Include []string `json:"include"`
+
+	// NotifyOnDelete shows a desktop notification when a tracked file is
+	// recorded as deleted
+	NotifyOnDelete bool `json:"notify_on_delete"`
+
+	// CreateGracePeriod delays capturing a newly created file. Editors create
+	// and delete temporary files within milliseconds; deleting a file within
+	// this window means it is never versioned. Zero captures immediately.
+	CreateGracePeriod time.Duration `json:"create_grace_period"`
+
+	// CaptureOn selects when a changed file is captured: "write" captures on
+	// every write event, "close" waits until the save looks finished - no
+	// events for CaptureQuietPeriod, a stable size and, on Linux, no process
+	// with the file open for writing
+	CaptureOn          string        `json:"capture_on"`
+	CaptureQuietPeriod time.Duration `json:"capture_quiet_period"`
+
+	// MinFileSize and MaxFileSize limit the sizes of files that are versioned,
+	// in bytes. Zero means no limit.
+	MinFileSize int64 `json:"min_file_size"`
+	MaxFileSize int64 `json:"max_file_size"`
+
+	// BaselineOlderThan makes the initial scan record files last modified
+	// longer ago than this as a baseline: their hash is tracked but no copy is
+	// stored. Zero stores every file.
+	BaselineOlderThan time.Duration `json:"baseline_older_than"`
+
+	// MaxVersionsPerFile caps the untagged versions kept per file. The oldest
+	// are purged as new versions are captured. Zero keeps every version.
+	MaxVersionsPerFile int `json:"max_versions_per_file"`
+
+	// MaxTotalSize caps the stored size of each project, in bytes. Shortly
+	// after captures take a project over it, the oldest untagged versions
+	// are purged until it is back under. Zero means no limit.
+	MaxTotalSize int64 `json:"max_total_size"`
+
+	// TrackDirectories records which directories exist so that directories
+	// emptied by a deletion can be recreated by a bulk restore
+	TrackDirectories bool `json:"track_directories"`
+
+	// LatestLinks keeps a symlink under .rewind/latest for each file,
+	// pointing at the stored copy of its latest version, so scripts can read
+	// it without the database
+	LatestLinks bool `json:"latest_links"`
+
+	// MaxCapturesPerMinute caps how often a single file is captured. A file
+	// changing faster is throttled: its changes are skipped for
+	// CaptureCooldown, then it is captured once. Zero never throttles.
+	MaxCapturesPerMinute int           `json:"max_captures_per_minute"`
+	CaptureCooldown      time.Duration `json:"capture_cooldown"`
+
+	// CaptureOwnership records the uid and gid of each captured file, so
+	// rollback and restore can give the file back to its owner
+	CaptureOwnership bool `json:"capture_ownership"`
+
+	// CaptureXattrs records the extended attributes of each captured file,
+	// such as SELinux contexts or macOS Finder tags, so rollback and restore
+	// can reapply them. Only Linux and macOS have them.
+	CaptureXattrs bool `json:"capture.xattrs"`
+
+	// AuditLog is the file every capture and deletion is appended to as a
+	// JSON line. Empty disables the audit log.
+	AuditLog string `json:"audit_log"`
+
+	// MaxDepth, MaxDirs and MaxFiles are safety limits on the size of a
+	// watch. A watch whose tree is nested deeper, or holds more directories
+	// or files than these, is refused rather than scanned. Zero means no
+	// limit.
+	MaxDepth int `json:"max_depth"`
+	MaxDirs  int `json:"max_dirs"`
+	MaxFiles int `json:"max_files"`
+
+	// CompressAfter makes the daemon gzip versions stored in full once they
+	// are older than this, in a background pass. Zero never compresses.
+	CompressAfter time.Duration `json:"compress_after"`
+
+	// VacuumInterval makes the daemon compact the databases of watched
+	// projects this often, when purges have left enough of them free. Zero
+	// never compacts.
+	VacuumInterval time.Duration `json:"vacuum_interval"`
+
+	// RescanInterval makes the daemon scan every watch this often to capture
+	// changes whose events were missed. Zero only scans at startup.
+	RescanInterval time.Duration `json:"rescan_interval"`
+}
+
+// DefaultConfig returns the configuration used when nothing is overridden
+func DefaultConfig() Config {
+	return Config{
+		Fsync:                 true,
+		AutoIgnoreGit:         true,
+		IgnoreEditorArtifacts: true,
+		StorageMode:           StorageModeCopy,
+		DeltaKeyframeInterval: 10,
+		HashAlgorithm:         database.HashSHA256,
+		CreateGracePeriod:     500 * time.Millisecond,
+		CaptureOn:             CaptureOnWrite,
+		CaptureQuietPeriod:    time.Second,
+		MaxCapturesPerMinute:  60,
+		CaptureCooldown:       5 * time.Minute,
+		MaxDepth:              64,
+		MaxDirs:               20000,
+		MaxFiles:              200000,
+	}
+}
+
+// Settings returns the configuration keyed by the names used in the config
+// file, with durations written out, so the settings a daemon is running with
+// can be compared with the file
+func (c Config) Settings() map[string]any {
+	include := c.Include
+	if include == nil {
+		include = []string{}
+	}
+
+	return map[string]any{
+		"fsync":                   c.Fsync,
+		"auto_ignore_git":         c.AutoIgnoreGit,
+		"ignore_editor_artifacts": c.IgnoreEditorArtifacts,
+		"storage_mode":            c.StorageMode,
+		"delta_keyframe_interval": c.DeltaKeyframeInterval,
+		"hash_algorithm":          c.HashAlgorithm,
+		"include":                 include,
+		"notify_on_delete":        c.NotifyOnDelete,
+		"create_grace_period":     c.CreateGracePeriod.String(),
+		"capture.on":              c.CaptureOn,
+		"capture.quiet_period":    c.CaptureQuietPeriod.String(),
+		"min_file_size":           c.MinFileSize,
+		"max_file_size":           c.MaxFileSize,
+		"baseline_older_than":     c.BaselineOlderThan.String(),
+		"max_versions_per_file":   c.MaxVersionsPerFile,
+		"max_total_size":          c.MaxTotalSize,
+		"track_directories":       c.TrackDirectories,
+		"latest_links":            c.LatestLinks,
+		"max_captures_per_minute": c.MaxCapturesPerMinute,
+		"capture_cooldown":        c.CaptureCooldown.String(),
+		"capture_ownership":       c.CaptureOwnership,
+		"capture.xattrs":          c.CaptureXattrs,
+		"audit_log":               c.AuditLog,
+		"max_depth":               c.MaxDepth,
+		"max_dirs":                c.MaxDirs,
+		"max_files":               c.MaxFiles,
+		"compress_after":          c.CompressAfter.String(),
+		"vacuum_interval":         c.VacuumInterval.String(),
+		"rescan_interval":         c.RescanInterval.String(),
+	}
+}
@@ -0,0 +1,117 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+// WatchConflict is a watched project whose root lies inside another watched
+// project, so both could claim its files. They belong to the inner project:
+// events are routed to the deepest root and the outer project's scans skip
+// the inner one.
+type WatchConflict struct {
+	Path   string `json:"path"`
+	Within string `json:"within"`
+}
+
+// FindWatchConflicts returns every watch root in paths that lies inside
+// another, sorted by path
+func FindWatchConflicts(paths []string) []WatchConflict {
+	var conflicts []WatchConflict
+	for _, inner := range paths {
+		for _, outer := range paths {
+			if inner != outer && withinRoot(inner, outer) {
+				conflicts = append(conflicts, WatchConflict{Path: inner, Within: outer})
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Path != conflicts[j].Path {
+			return conflicts[i].Path < conflicts[j].Path
+		}
+		return conflicts[i].Within < conflicts[j].Within
+	})
+	return conflicts
+}
+
+// withinRoot reports whether path is root or lies under it
+func withinRoot(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Conflicts returns the watches whose roots lie inside another watch
+func (wl *WatchList) Conflicts() []WatchConflict {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	paths := make([]string, len(wl.Watches))
+	for i, watch := range wl.Watches {
+		paths[i] = watch.Path
+	}
+	return FindWatchConflicts(paths)
+}
+
+// WatchesContaining returns the watches whose roots contain path, deepest
+// first
+func (wl *WatchList) WatchesContaining(path string) []*Watch {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	var containing []*Watch
+	for _, watch := range wl.Watches {
+		if withinRoot(path, watch.Path) {
+			containing = append(containing, watch)
+		}
+	}
+
+	sort.Slice(containing, func(i, j int) bool {
+		return len(containing[i].Path) > len(containing[j].Path)
+	})
+	return containing
+}
+
+// isNestedWatchRoot reports whether dir is the root of a watch other than
+// watch, whose files that watch must leave alone
+func (wl *WatchList) isNestedWatchRoot(dir string, watch *Watch) bool {
+	if dir == watch.Path {
+		return false
+	}
+
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	for _, other := range wl.Watches {
+		if other.Path == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// warnWatchConflict logs, once per pair of projects, that a file under
+// several watched roots was routed to the deepest of them
+func (wm *WatchManager) warnWatchConflict(path string, containing []*Watch) {
+	for _, outer := range containing[1:] {
+		key := containing[0].Path + "\x00" + outer.Path
+
+		wm.conflictMu.Lock()
+		warned := wm.warnedConflicts[key]
+		wm.warnedConflicts[key] = true
+		wm.conflictMu.Unlock()
+		if warned {
+			continue
+		}
+
+		app.Logger.WithFields(logrus.Fields{
+			"path":   path,
+			"watch":  containing[0].Path,
+			"within": outer.Path,
+		}).Warn("Watched project lies inside another watched project - its files are captured by the inner project only. Remove one of the watches to resolve the conflict")
+	}
+}
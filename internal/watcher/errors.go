@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+)
+
+// maxRecentErrors is how many capture failures are kept for 'rewind status'
+const maxRecentErrors = 20
+
+// CaptureError is a failure to capture a file, kept so it can be shown to
+// the user rather than only logged
+type CaptureError struct {
+	Time    time.Time `json:"time"`
+	Project string    `json:"project"`
+	Path    string    `json:"path"`
+	Message string    `json:"message"`
+}
+
+// recentErrors is a ring buffer of the latest capture failures
+type recentErrors struct {
+	mu     sync.Mutex
+	errors []CaptureError
+	next   int
+}
+
+func (r *recentErrors) add(captureErr CaptureError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.errors) < maxRecentErrors {
+		r.errors = append(r.errors, captureErr)
+		return
+	}
+	r.errors[r.next] = captureErr
+	r.next = (r.next + 1) % maxRecentErrors
+}
+
+// list returns the kept failures, newest first
+func (r *recentErrors) list() []CaptureError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]CaptureError, 0, len(r.errors))
+	for i := len(r.errors) - 1; i >= 0; i-- {
+		list = append(list, r.errors[(r.next+i)%len(r.errors)])
+	}
+	return list
+}
+
+// recordCaptureError logs a failure to capture a file and keeps it for
+// status. Files that vanished before they could be read are expected, as
+// editors delete temporary files quickly, and are only logged.
+func (wm *WatchManager) recordCaptureError(watch *Watch, relPath string, err error) {
+	logger := app.Logger.WithField("path", relPath).WithError(err)
+	if errors.Is(err, fs.ErrNotExist) {
+		logger.Debug("File disappeared before it could be captured")
+		return
+	}
+
+	logger.Error("Failed to capture file")
+	wm.metrics.fail()
+	wm.recentErrors.add(CaptureError{
+		Time:    time.Now(),
+		Project: watch.Path,
+		Path:    relPath,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// syncLatestLinks creates or removes a project's latest links to match the
+// latest_links setting. While the links exist the database keeps them
+// current as versions are captured, deleted, compressed or moved.
+func (wm *WatchManager) syncLatestLinks(watch *Watch) {
+	if wm.DryRun {
+		return
+	}
+
+	logger := app.Logger.WithField("watch", watch.Path)
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		logger.WithError(err).Warn("Could not initialise database for latest links")
+		return
+	}
+	if err := db.Connect(); err != nil {
+		logger.WithError(err).Warn("Could not connect to database for latest links")
+		return
+	}
+	defer db.Close()
+
+	switch {
+	case wm.config().LatestLinks && !db.HasLatestLinks():
+		if err := db.EnableLatestLinks(); err != nil {
+			logger.WithError(err).Warn("Failed to create latest links")
+			return
+		}
+		logger.WithField("dir", db.LatestDir()).Info("Created latest links")
+	case !wm.config().LatestLinks && db.HasLatestLinks():
+		if err := db.DisableLatestLinks(); err != nil {
+			logger.WithError(err).Warn("Failed to remove latest links")
+			return
+		}
+		logger.WithField("dir", db.LatestDir()).Info("Removed latest links")
+	}
+}
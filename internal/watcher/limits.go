@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TreeSize counts what a watch covers once ignore and include patterns are
+// applied
+type TreeSize struct {
+	Dirs  int `json:"dirs"`
+	Files int `json:"files"`
+	Depth int `json:"depth"`
+}
+
+// checkLimits returns an error once a watch has grown past the configured
+// safety limits, so a misplaced init doesn't try to version a home directory
+func (wl *WatchList) checkLimits(watch *Watch, size TreeSize) error {
+	config := wl.config()
+
+	var exceeded string
+	switch {
+	case config.MaxDepth > 0 && size.Depth > config.MaxDepth:
+		exceeded = fmt.Sprintf("is nested more than %d directories deep (max_depth)", config.MaxDepth)
+	case config.MaxDirs > 0 && size.Dirs > config.MaxDirs:
+		exceeded = fmt.Sprintf("has more than %d directories (max_dirs)", config.MaxDirs)
+	case config.MaxFiles > 0 && size.Files > config.MaxFiles:
+		exceeded = fmt.Sprintf("has more than %d files (max_files)", config.MaxFiles)
+	default:
+		return nil
+	}
+
+	return fmt.Errorf("%s %s - watch a smaller directory, add ignore patterns to .rwignore, or raise the limit with 'rewind config set'", watch.Path, exceeded)
+}
+
+// treeDepth returns how many directories deep path is below the watch root
+func treeDepth(root, path string) int {
+	relPath, err := filepath.Rel(root, path)
+	if err != nil || relPath == "." {
+		return 0
+	}
+	return strings.Count(relPath, string(filepath.Separator)) + 1
+}
+
+// MeasureTree counts the directories and files a watch of path would cover
+// using the project's ignore and include patterns. It stops with the same
+// error the daemon would give once a size limit is exceeded.
+func MeasureTree(path string, config Config) (TreeSize, error) {
+	wl := &WatchList{Config: config}
+
+	if _, err := os.Stat(path); err != nil {
+		return TreeSize{}, err
+	}
+
+	ignorePatterns, err := wl.loadIgnorePatterns(path)
+	if err != nil {
+		return TreeSize{}, err
+	}
+	if config.AutoIgnoreGit {
+		ignorePatterns = append(ignorePatterns, ".git/")
+	}
+
+	includePatterns, err := wl.loadIncludePatterns(path)
+	if err != nil {
+		return TreeSize{}, err
+	}
+
+	watch := &Watch{Path: path, Active: true, IgnorePatterns: ignorePatterns, IncludePatterns: includePatterns}
+	_, size, err := wl.discoverWatchDirectories(watch)
+	return size, err
+}
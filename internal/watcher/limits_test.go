@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMeasureTree_Limits(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	root := t.TempDir()
+	for _, dir := range []string{".rewind", ".git/objects/ab", "a/b/c", "node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".rewind", "ignore"), []byte("node_modules\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"one.txt", "a/two.txt", "a/b/c/three.txt", ".git/objects/ab/cd", "node_modules/pkg/index.js"} {
+		if err := os.WriteFile(filepath.Join(root, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := DefaultConfig()
+	size, err := MeasureTree(root, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (TreeSize{Dirs: 4, Files: 3, Depth: 3}); size != want {
+		t.Errorf("MeasureTree() = %+v, want %+v", size, want)
+	}
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		want   string
+	}{
+		{"depth", func(c *Config) { c.MaxDepth = 2 }, "max_depth"},
+		{"dirs", func(c *Config) { c.MaxDirs = 3 }, "max_dirs"},
+		{"files", func(c *Config) { c.MaxFiles = 2 }, "max_files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited := config
+			tt.config(&limited)
+			_, err := MeasureTree(root, limited)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("MeasureTree() error = %v, want %s exceeded", err, tt.want)
+			}
+		})
+	}
+}
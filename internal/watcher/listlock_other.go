@@ -0,0 +1,11 @@
+//go:build !unix
+
+package watcher
+
+import "os"
+
+// lockFile is a no-op where advisory locks aren't available. Writers in the
+// same process are still serialized by the watchlist mutex.
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Rollback and restore write tracked files themselves. While they do, they
+// hold an advisory lock in .rewind/locks so the daemon does not capture the
+// half-written file (or the rollback itself) as a new version.
+//
+// fsnotify events can be handled after the writer has finished, so a released
+// lock is kept for lockReleaseGrace before the daemon treats the file as
+// unlocked again. A held lock older than lockStaleAfter is assumed to belong to
+// a process that died and is ignored.
+const (
+	lockReleaseGrace = 2 * time.Second
+	lockStaleAfter   = 10 * time.Minute
+)
+
+// FileLock is an advisory lock on a single tracked file
+type FileLock struct {
+	path string
+}
+
+// lockPath returns the lock file for filePath within the project at rootPath
+func lockPath(rootPath, filePath string) string {
+	relPath, err := filepath.Rel(rootPath, filePath)
+	if err != nil {
+		relPath = filePath
+	}
+	sum := sha256.Sum256([]byte(filepath.ToSlash(relPath)))
+	return filepath.Join(rootPath, ".rewind", "locks", hex.EncodeToString(sum[:])+".lock")
+}
+
+// AcquireFileLock takes the advisory lock on filePath. It fails if another
+// process currently holds it.
+func AcquireFileLock(rootPath, filePath string) (*FileLock, error) {
+	path := lockPath(rootPath, filePath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			// A held lock records the owner's pid; a released one is empty
+			_, err = f.WriteString(strconv.Itoa(os.Getpid()))
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %w", err)
+			}
+			return &FileLock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		// Released and stale locks can be taken over
+		if held, _ := lockState(path); held {
+			return nil, fmt.Errorf("file is locked by another rewind process: %s", filePath)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock for %s", filePath)
+}
+
+// Release marks the lock as released. The lock file is left behind for the
+// daemon to clean up once the grace window has passed.
+func (l *FileLock) Release() error {
+	if err := os.Truncate(l.path, 0); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	now := time.Now()
+	if err := os.Chtimes(l.path, now, now); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// IsFileLocked reports whether filePath is locked or was released within the
+// grace window. Expired lock files are removed.
+func IsFileLocked(rootPath, filePath string) bool {
+	held, inGrace := checkFileLock(rootPath, filePath)
+	return held || inGrace
+}
+
+// checkFileLock reports whether filePath is locked, or released but within the
+// grace window. Expired lock files are removed.
+func checkFileLock(rootPath, filePath string) (held bool, inGrace bool) {
+	path := lockPath(rootPath, filePath)
+	held, inGrace = lockState(path)
+	if !held && !inGrace {
+		os.Remove(path)
+	}
+	return held, inGrace
+}
+
+// lockState reports whether the lock file at path is actively held, or
+// released but still within the grace window
+func lockState(path string) (held bool, inGrace bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, false
+	}
+
+	age := time.Since(info.ModTime())
+	if info.Size() > 0 {
+		return age < lockStaleAfter, false
+	}
+	return false, age < lockReleaseGrace
+}
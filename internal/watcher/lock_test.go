@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+func TestFileLock(t *testing.T) {
+	root := t.TempDir()
+	filePath := filepath.Join(root, "file.txt")
+
+	lock, err := AcquireFileLock(root, filePath)
+	if err != nil {
+		t.Fatalf("AcquireFileLock() error = %v", err)
+	}
+
+	if !IsFileLocked(root, filePath) {
+		t.Errorf("IsFileLocked() = false while lock is held")
+	}
+
+	if _, err := AcquireFileLock(root, filePath); err == nil {
+		t.Errorf("AcquireFileLock() succeeded while lock is held")
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	if !IsFileLocked(root, filePath) {
+		t.Errorf("IsFileLocked() = false within release grace window")
+	}
+
+	// Age the released lock past the grace window
+	past := time.Now().Add(-2 * lockReleaseGrace)
+	if err := os.Chtimes(lockPath(root, filePath), past, past); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	if IsFileLocked(root, filePath) {
+		t.Errorf("IsFileLocked() = true after grace window")
+	}
+
+	if _, err := os.Stat(lockPath(root, filePath)); !os.IsNotExist(err) {
+		t.Errorf("expired lock file was not removed")
+	}
+}
+
+func TestWatchManager_IgnoresLockedWrites(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	root := t.TempDir()
+	filePath := filepath.Join(root, "file.txt")
+	if err := os.WriteFile(filePath, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	wl := &WatchList{Config: DefaultConfig()}
+	watch, err := wl.PrepareWatch(&Watch{Path: root, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wl.Watches = []*Watch{watch}
+
+	wm, err := NewWatchManager(wl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer wm.Stop()
+
+	versionCount := func() int {
+		versions, err := db.GetFileVersions(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(versions)
+	}
+
+	if got := versionCount(); got != 1 {
+		t.Fatalf("initial scan captured %d versions, want 1", got)
+	}
+
+	// Writes made under the lock, as a rollback would, are not captured
+	lock, err := AcquireFileLock(root, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte("rolled back"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if got := versionCount(); got != 1 {
+		t.Errorf("locked write captured, have %d versions, want 1", got)
+	}
+
+	// Once the grace window has passed, writes are captured again
+	past := time.Now().Add(-2 * lockReleaseGrace)
+	if err := os.Chtimes(lockPath(root, filePath), past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for versionCount() != 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := versionCount(); got != 2 {
+		t.Errorf("unlocked write not captured, have %d versions, want 2", got)
+	}
+}
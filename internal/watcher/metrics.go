@@ -0,0 +1,172 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the capture latency histogram buckets.
+// Anything slower than the last bound lands in the overflow bucket.
+var latencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Capture stages recorded by the watch manager
+const (
+	StageHash  = "hash"
+	StageCopy  = "copy"
+	StageDB    = "db"
+	StageTotal = "total"
+)
+
+var captureStages = []string{StageHash, StageCopy, StageDB, StageTotal}
+
+// latencyHistogram counts durations into fixed buckets
+type latencyHistogram struct {
+	counts []int64 // one per bucket plus overflow
+	total  int64
+	sum    time.Duration
+	max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile estimates the p-th percentile (0-100) as the upper bound of the
+// bucket containing it, capped at the largest observed value.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := int64(float64(h.total)*p/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i < len(latencyBuckets) && latencyBuckets[i] < h.max {
+				return latencyBuckets[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// StageMetrics summarises the latency of a single capture stage
+type StageMetrics struct {
+	Stage   string  `json:"stage"`
+	Count   int64   `json:"count"`
+	P50Ms   float64 `json:"p50_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	MeanMs  float64 `json:"mean_ms"`
+	MaxMs   float64 `json:"max_ms"`
+	Buckets []int64 `json:"buckets"`
+}
+
+// CaptureMetrics is the snapshot of capture metrics exposed over IPC
+type CaptureMetrics struct {
+	BucketBoundsMs []float64      `json:"bucket_bounds_ms"`
+	Stages         []StageMetrics `json:"stages"`
+	FailedCaptures int64          `json:"failed_captures"`
+}
+
+// captureMetrics aggregates capture latencies across all watches
+type captureMetrics struct {
+	mu       sync.Mutex
+	stages   map[string]*latencyHistogram
+	failures int64
+}
+
+func newCaptureMetrics() *captureMetrics {
+	m := &captureMetrics{stages: make(map[string]*latencyHistogram)}
+	for _, stage := range captureStages {
+		m.stages[stage] = newLatencyHistogram()
+	}
+	return m
+}
+
+func (m *captureMetrics) observe(stage string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.stages[stage]
+	if !ok {
+		h = newLatencyHistogram()
+		m.stages[stage] = h
+	}
+	h.observe(d)
+}
+
+// fail counts a capture that failed
+func (m *captureMetrics) fail() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+}
+
+func (m *captureMetrics) snapshot() CaptureMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := CaptureMetrics{
+		BucketBoundsMs: make([]float64, len(latencyBuckets)),
+		Stages:         make([]StageMetrics, 0, len(captureStages)),
+		FailedCaptures: m.failures,
+	}
+	for i, bound := range latencyBuckets {
+		snapshot.BucketBoundsMs[i] = durationMs(bound)
+	}
+
+	for _, stage := range captureStages {
+		h := m.stages[stage]
+		sm := StageMetrics{
+			Stage:   stage,
+			Count:   h.total,
+			P50Ms:   durationMs(h.percentile(50)),
+			P95Ms:   durationMs(h.percentile(95)),
+			MaxMs:   durationMs(h.max),
+			Buckets: append([]int64(nil), h.counts...),
+		}
+		if h.total > 0 {
+			sm.MeanMs = durationMs(h.sum / time.Duration(h.total))
+		}
+		snapshot.Stages = append(snapshot.Stages, sm)
+	}
+
+	return snapshot
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
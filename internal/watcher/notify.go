@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/davenicholson-xyz/rewind/app"
+)
+
+// notifyDeleted shows a desktop notification that a tracked file was deleted.
+// It is best-effort: it never blocks capture and failures are only logged.
+func notifyDeleted(rootPath, relPath string) {
+	title := "rewind: file deleted"
+	message := fmt.Sprintf("%s was deleted from %s. Run 'rewind restore' to get it back.", relPath, rootPath)
+
+	go func() {
+		if err := sendDesktopNotification(title, message); err != nil {
+			app.Logger.WithField("path", relPath).WithError(err).Debug("Could not send desktop notification")
+		}
+	}()
+}
+
+func sendDesktopNotification(title, message string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "linux":
+		// "--" stops a file name starting with - being read as an option
+		cmd = exec.Command("notify-send", "--app-name=rewind", "--", title, message)
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Path, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,41 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsOpenForWriting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chunked.bin")
+	if err := os.WriteFile(path, []byte("start"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if isOpenForWriting(path) {
+		t.Fatal("isOpenForWriting() = true for a closed file")
+	}
+
+	reader, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if isOpenForWriting(path) {
+		t.Error("isOpenForWriting() = true for a file open only for reading")
+	}
+
+	writer, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isOpenForWriting(path) {
+		t.Error("isOpenForWriting() = false while the file is open for writing")
+	}
+
+	writer.Close()
+	if isOpenForWriting(path) {
+		t.Error("isOpenForWriting() = true after the writer closed it")
+	}
+}
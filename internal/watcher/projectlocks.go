@@ -0,0 +1,35 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// projectLocks serialises the writes to each project's version store:
+// captures from events, scans and delayed creates, and the compression,
+// vacuum and store migration passes. Projects are locked separately, so a
+// long scan of one project doesn't hold up captures in the others.
+type projectLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock locks the project rooted at root and returns the function that
+// unlocks it
+func (p *projectLocks) lock(root string) func() {
+	root = filepath.Clean(root)
+
+	p.mu.Lock()
+	if p.locks == nil {
+		p.locks = make(map[string]*sync.Mutex)
+	}
+	lock, ok := p.locks[root]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.locks[root] = lock
+	}
+	p.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
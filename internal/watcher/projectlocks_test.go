@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProjectLocks(t *testing.T) {
+	var locks projectLocks
+
+	unlockA := locks.lock("/projects/a")
+
+	// Another project isn't held up
+	done := make(chan struct{})
+	go func() {
+		locks.lock("/projects/b")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("locking another project waited on the first")
+	}
+
+	// The same project, however it is written, waits for the unlock
+	locked := make(chan struct{})
+	go func() {
+		locks.lock("/projects/a/")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("project locked twice at once")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockA()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("project still locked after unlock")
+	}
+}
@@ -0,0 +1,149 @@
+package watcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+// captureWindow is the period MaxCapturesPerMinute counts captures over
+const captureWindow = time.Minute
+
+// ThrottledFile is a file whose captures are being skipped because it
+// changed more often than the configured rate
+type ThrottledFile struct {
+	Project string    `json:"project"`
+	Path    string    `json:"path"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+	Skipped int       `json:"skipped"`
+}
+
+// captureLimiter caps how often each file is captured, as a safety valve
+// against a tool rewriting a tracked file in a loop. A file captured more
+// than limit times within a minute is throttled: its changes are skipped
+// until the cooldown has passed.
+type captureLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	cooldown  time.Duration
+	captures  map[string][]time.Time    // Recent capture times per file
+	throttled map[string]*ThrottledFile // Files whose captures are skipped
+	pruned    time.Time                 // When captures was last swept of idle files
+}
+
+func newCaptureLimiter(limit int, cooldown time.Duration) *captureLimiter {
+	return &captureLimiter{
+		limit:     limit,
+		cooldown:  cooldown,
+		captures:  make(map[string][]time.Time),
+		throttled: make(map[string]*ThrottledFile),
+	}
+}
+
+// setLimits changes the capture rate and cooldown of a running limiter.
+// Files already throttled stay throttled until their cooldown ends.
+func (l *captureLimiter) setLimits(limit int, cooldown time.Duration) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	l.cooldown = cooldown
+}
+
+// allow reports whether filePath may be captured now, and counts the capture
+// if so. When it may not, it also returns when the file's throttle lifts.
+// A zero limit allows every capture.
+func (l *captureLimiter) allow(project, filePath, relPath string) (bool, time.Time) {
+	if l == nil || l.limit <= 0 {
+		return true, time.Time{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	if throttle, ok := l.throttled[filePath]; ok {
+		if now.Before(throttle.Until) {
+			throttle.Skipped++
+			return false, throttle.Until
+		}
+
+		app.Logger.WithFields(logrus.Fields{
+			"path":    relPath,
+			"skipped": throttle.Skipped,
+		}).Info("Capture throttle lifted")
+		delete(l.throttled, filePath)
+		delete(l.captures, filePath)
+	}
+
+	recent := l.captures[filePath]
+	for len(recent) > 0 && now.Sub(recent[0]) >= captureWindow {
+		recent = recent[1:]
+	}
+
+	if len(recent) >= l.limit {
+		app.Logger.WithFields(logrus.Fields{
+			"path":     relPath,
+			"captures": len(recent),
+			"cooldown": l.cooldown,
+		}).Warn("File is changing faster than max_captures_per_minute - skipping its captures for the cooldown")
+		throttle := &ThrottledFile{
+			Project: project,
+			Path:    relPath,
+			Since:   now,
+			Until:   now.Add(l.cooldown),
+			Skipped: 1,
+		}
+		l.throttled[filePath] = throttle
+		delete(l.captures, filePath)
+		return false, throttle.Until
+	}
+
+	l.captures[filePath] = append(recent, now)
+	return true, time.Time{}
+}
+
+// prune drops, at most once a window, the files with no captures left in
+// the window, so files captured once don't stay counted for the life of
+// the daemon. Callers hold l.mu.
+func (l *captureLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < captureWindow {
+		return
+	}
+	l.pruned = now
+
+	for filePath, recent := range l.captures {
+		if len(recent) == 0 || now.Sub(recent[len(recent)-1]) >= captureWindow {
+			delete(l.captures, filePath)
+		}
+	}
+}
+
+// list returns the files currently throttled, most recently throttled first
+func (l *captureLimiter) list() []ThrottledFile {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var list []ThrottledFile
+	for _, throttle := range l.throttled {
+		if now.Before(throttle.Until) {
+			list = append(list, *throttle)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Since.After(list[j].Since) })
+	return list
+}
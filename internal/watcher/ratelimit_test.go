@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+func TestCaptureLimiter(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	limiter := newCaptureLimiter(3, 50*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.allow("/project", "/project/a.txt", "a.txt"); !ok {
+			t.Fatalf("capture %d refused, want the first 3 allowed", i+1)
+		}
+	}
+
+	// Over the limit the file is throttled, other files are not
+	ok, until := limiter.allow("/project", "/project/a.txt", "a.txt")
+	if ok {
+		t.Error("capture over the limit allowed")
+	}
+	if wait := time.Until(until); wait <= 0 || wait > 50*time.Millisecond {
+		t.Errorf("throttle lifts in %v, want within the 50ms cooldown", wait)
+	}
+	limiter.allow("/project", "/project/a.txt", "a.txt")
+	if ok, _ := limiter.allow("/project", "/project/b.txt", "b.txt"); !ok {
+		t.Error("another file was throttled")
+	}
+
+	throttled := limiter.list()
+	if len(throttled) != 1 || throttled[0].Path != "a.txt" || throttled[0].Skipped != 2 {
+		t.Fatalf("throttled = %+v, want a.txt with 2 skipped", throttled)
+	}
+
+	// Captures resume once the cooldown has passed
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := limiter.allow("/project", "/project/a.txt", "a.txt"); !ok {
+		t.Error("capture refused after the cooldown")
+	}
+	if len(limiter.list()) != 0 {
+		t.Error("file still listed as throttled after the cooldown")
+	}
+
+	// A zero limit never throttles
+	unlimited := newCaptureLimiter(0, time.Minute)
+	for i := 0; i < 100; i++ {
+		if ok, _ := unlimited.allow("/project", "/project/a.txt", "a.txt"); !ok {
+			t.Fatal("unlimited limiter refused a capture")
+		}
+	}
+}
+
+func TestCaptureLimiter_PrunesIdleFiles(t *testing.T) {
+	limiter := newCaptureLimiter(3, time.Minute)
+	limiter.captures["/project/old.txt"] = []time.Time{time.Now().Add(-2 * captureWindow)}
+
+	limiter.allow("/project", "/project/a.txt", "a.txt")
+
+	if _, ok := limiter.captures["/project/old.txt"]; ok {
+		t.Error("file with no captures in the window still counted")
+	}
+	if _, ok := limiter.captures["/project/a.txt"]; !ok {
+		t.Error("file just captured not counted")
+	}
+}
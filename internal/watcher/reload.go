@@ -0,0 +1,239 @@
+package watcher
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+// RestartSettings are the settings a reload leaves as they were, because the
+// background passes they control are only started when the daemon starts
+var RestartSettings = []string{"compress_after", "vacuum_interval", "rescan_interval"}
+
+// ReloadResult describes what reloading the configuration and watchlist
+// changed
+type ReloadResult struct {
+	// Settings lists the settings whose new values were applied
+	Settings []string `json:"settings,omitempty"`
+	// Restart lists the settings that changed but only take effect once the
+	// daemon is restarted
+	Restart []string `json:"restart,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Updated lists the watches whose ignore or include patterns, or
+	// directories, changed
+	Updated []string       `json:"updated,omitempty"`
+	Skipped []SkippedWatch `json:"skipped,omitempty"`
+}
+
+// Reload applies config and the watchlist file to the running daemon
+// without stopping it: new settings take effect for the next capture,
+// watches added to or removed from the file are started or stopped, and the
+// ignore and include patterns of every watch are read again.
+func (wm *WatchManager) Reload(config Config) (*ReloadResult, error) {
+	result := &ReloadResult{}
+	wm.restartRequired(config, result)
+	wm.applyConfig(config, result)
+
+	if err := wm.reloadWatches(result); err != nil {
+		return result, err
+	}
+
+	// Latest links are otherwise only created or removed by a scan
+	if slices.Contains(result.Settings, "latest_links") {
+		for _, watch := range wm.WatchList.Snapshot() {
+			wm.syncLatestLinks(watch)
+		}
+	}
+
+	app.Logger.WithFields(logrus.Fields{
+		"settings": result.Settings,
+		"restart":  result.Restart,
+		"added":    result.Added,
+		"removed":  result.Removed,
+		"updated":  result.Updated,
+	}).Info("Reloaded configuration and watchlist")
+	return result, nil
+}
+
+// applyConfig switches the daemon to config, keeping the settings that need
+// a restart at their running values
+func (wm *WatchManager) applyConfig(config Config, result *ReloadResult) {
+	old := wm.config()
+	config.CompressAfter = old.CompressAfter
+	config.VacuumInterval = old.VacuumInterval
+	config.RescanInterval = old.RescanInterval
+
+	oldSettings, newSettings := old.Settings(), config.Settings()
+	for key, value := range newSettings {
+		if fmt.Sprint(value) != fmt.Sprint(oldSettings[key]) {
+			result.Settings = append(result.Settings, key)
+		}
+	}
+	slices.Sort(result.Settings)
+
+	wm.configMu.Lock()
+	wm.Config = config
+	if config.AuditLog != old.AuditLog {
+		wm.audit = app.NewAuditLog(config.AuditLog)
+	}
+	wm.configMu.Unlock()
+	wm.WatchList.configMu.Lock()
+	wm.WatchList.Config = config
+	wm.WatchList.configMu.Unlock()
+	wm.limiter.setLimits(config.MaxCapturesPerMinute, config.CaptureCooldown)
+}
+
+// restartRequired adds to result the settings that need a restart whose
+// values in config differ from the running ones
+func (wm *WatchManager) restartRequired(config Config, result *ReloadResult) {
+	running, wanted := wm.config().Settings(), config.Settings()
+	for _, key := range RestartSettings {
+		if fmt.Sprint(running[key]) != fmt.Sprint(wanted[key]) {
+			result.Restart = append(result.Restart, key)
+		}
+	}
+}
+
+// reloadWatches brings the running watches in line with the watchlist file
+func (wm *WatchManager) reloadWatches(result *ReloadResult) error {
+	wl := wm.WatchList
+
+	unlock, err := wl.lock()
+	if err != nil {
+		return err
+	}
+	loaded, err := wl.LoadWatchlist()
+	unlock()
+	if err != nil {
+		return fmt.Errorf("failed to load watchlist: %w", err)
+	}
+
+	listed := make(map[string]bool, len(loaded))
+	for _, watch := range loaded {
+		listed[watch.Path] = true
+	}
+
+	// Watches no longer in the file
+	for _, watch := range wl.Snapshot() {
+		if listed[watch.Path] {
+			continue
+		}
+		wm.stopWatch(watch)
+		result.Removed = append(result.Removed, watch.Path)
+	}
+	wl.mu.Lock()
+	wl.Skipped = slices.DeleteFunc(wl.Skipped, func(skipped SkippedWatch) bool { return !listed[skipped.Path] })
+	wl.mu.Unlock()
+
+	for i := range loaded {
+		if running := wm.findWatch(loaded[i].Path); running != nil {
+			if wm.refreshWatch(running) {
+				result.Updated = append(result.Updated, running.Path)
+			}
+			continue
+		}
+
+		// New, or skipped before and worth another try
+		wl.mu.Lock()
+		wl.removeSkipped(loaded[i].Path)
+		wl.mu.Unlock()
+
+		prepared, err := wl.prepareWatch(&loaded[i])
+		if skipWatch(err) {
+			skipped := SkippedWatch{Path: loaded[i].Path, Error: err.Error()}
+			wl.mu.Lock()
+			wl.Skipped = append(wl.Skipped, skipped)
+			wl.mu.Unlock()
+			result.Skipped = append(result.Skipped, skipped)
+			continue
+		}
+		if err != nil {
+			app.Logger.WithField("path", loaded[i].Path).WithError(err).Warn("Not watching project from reloaded watchlist")
+			continue
+		}
+
+		wl.mu.Lock()
+		wl.Watches = append(wl.Watches, prepared)
+		wl.mu.Unlock()
+		wm.startWatch(prepared)
+		result.Added = append(result.Added, prepared.Path)
+	}
+
+	return nil
+}
+
+// findWatch returns the running watch of the project at path, or nil
+func (wm *WatchManager) findWatch(path string) *Watch {
+	wm.WatchList.mu.RLock()
+	defer wm.WatchList.mu.RUnlock()
+
+	for _, watch := range wm.WatchList.Watches {
+		if watch.Path == path {
+			return watch
+		}
+	}
+	return nil
+}
+
+// stopWatch stops watching a project without changing the watchlist file
+func (wm *WatchManager) stopWatch(watch *Watch) {
+	wm.WatchList.mu.Lock()
+	wm.WatchList.Watches = slices.DeleteFunc(wm.WatchList.Watches, func(running *Watch) bool { return running == watch })
+	wm.WatchList.mu.Unlock()
+
+	for _, dir := range watch.WatchDirs {
+		if err := wm.EventsNotifier.Notifier.Remove(dir); err != nil {
+			app.Logger.WithField("dir", dir).WithError(err).Debug("Failed to remove directory from fsnotify watcher")
+		}
+	}
+	app.Logger.WithField("path", watch.Path).Info("Stopped watching project removed from the watchlist")
+}
+
+// refreshWatch reads a running watch's ignore and include patterns again
+// and watches the directories they now select, reporting whether anything
+// changed. A watch that can no longer be prepared is left as it was.
+func (wm *WatchManager) refreshWatch(watch *Watch) bool {
+	fresh := &Watch{Path: watch.Path, Template: watch.Template, Active: true}
+	if _, err := wm.WatchList.prepareWatch(fresh); err != nil {
+		app.Logger.WithField("path", watch.Path).WithError(err).Warn("Failed to reload watch, keeping its current patterns")
+		return false
+	}
+
+	if slices.Equal(fresh.IgnorePatterns, watch.IgnorePatterns) &&
+		slices.Equal(fresh.IncludePatterns, watch.IncludePatterns) &&
+		slices.Equal(fresh.WatchDirs, watch.WatchDirs) {
+		return false
+	}
+
+	for _, dir := range fresh.WatchDirs {
+		if !slices.Contains(watch.WatchDirs, dir) {
+			if err := wm.EventsNotifier.AddPath(dir); err != nil {
+				app.Logger.WithField("dir", dir).WithError(err).Error("Failed to add directory to event notifier")
+			}
+		}
+	}
+	for _, dir := range watch.WatchDirs {
+		if !slices.Contains(fresh.WatchDirs, dir) {
+			if err := wm.EventsNotifier.Notifier.Remove(dir); err != nil {
+				app.Logger.WithField("dir", dir).WithError(err).Debug("Failed to remove directory from fsnotify watcher")
+			}
+		}
+	}
+
+	// Captures read the patterns, so swap them between the project's
+	// captures, and status reads them under the watchlist lock
+	unlock := wm.projects.lock(watch.Path)
+	wm.WatchList.mu.Lock()
+	watch.IgnorePatterns = fresh.IgnorePatterns
+	watch.IncludePatterns = fresh.IncludePatterns
+	watch.WatchDirs = fresh.WatchDirs
+	watch.Warnings = fresh.Warnings
+	wm.WatchList.mu.Unlock()
+	unlock()
+
+	app.Logger.WithField("path", watch.Path).WithField("directories", len(fresh.WatchDirs)).Info("Reloaded watch patterns")
+	return true
+}
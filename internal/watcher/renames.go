@@ -0,0 +1,104 @@
+package watcher
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+)
+
+// renameWindow is how long after a tracked file is renamed away a new file
+// with the same content is taken to be the renamed file. The new name may be
+// captured only after the create grace period.
+const renameWindow = 2 * time.Second
+
+// pendingRename is a tracked file that was renamed away and has not yet been
+// seen under its new name
+type pendingRename struct {
+	watchPath string
+	relPath   string
+	hash      string
+	at        time.Time
+}
+
+// rememberRename records that a tracked file was renamed away so the file it
+// reappears as can be linked to its history
+func (wm *WatchManager) rememberRename(db *database.DatabaseManager, path, relPath string, watch *Watch) {
+	latestVersion, err := db.GetLatestFileVersion(path)
+	if err != nil || latestVersion == nil || latestVersion.Deleted {
+		return
+	}
+
+	wm.renameMu.Lock()
+	defer wm.renameMu.Unlock()
+
+	wm.pendingRenames = append(wm.pendingRenames, pendingRename{
+		watchPath: watch.Path,
+		relPath:   relPath,
+		hash:      latestVersion.FileHash,
+		at:        time.Now(),
+	})
+}
+
+// hasPendingRename reports whether a file in watch was recently renamed away
+// and may yet reappear under its new name
+func (wm *WatchManager) hasPendingRename(watch *Watch) bool {
+	wm.renameMu.Lock()
+	defer wm.renameMu.Unlock()
+
+	window := wm.config().CreateGracePeriod + renameWindow
+	for _, pending := range wm.pendingRenames {
+		if pending.watchPath == watch.Path && time.Since(pending.at) <= window {
+			return true
+		}
+	}
+	return false
+}
+
+// takePendingRename returns the path of a file recently renamed away whose
+// content matches hash, or "" if there is none. A match is only used once.
+func (wm *WatchManager) takePendingRename(watch *Watch, hash string) string {
+	wm.renameMu.Lock()
+	defer wm.renameMu.Unlock()
+
+	window := wm.config().CreateGracePeriod + renameWindow
+	renamedFrom := ""
+	kept := wm.pendingRenames[:0]
+	for _, pending := range wm.pendingRenames {
+		if time.Since(pending.at) > window {
+			continue
+		}
+		if renamedFrom == "" && pending.watchPath == watch.Path && pending.hash == hash {
+			renamedFrom = pending.relPath
+			continue
+		}
+		kept = append(kept, pending)
+	}
+	wm.pendingRenames = kept
+
+	return renamedFrom
+}
+
+// linkRename records that the newly captured filePath was renamed from
+// renamedFrom, so history can be followed across the rename
+func (wm *WatchManager) linkRename(db *database.DatabaseManager, filePath, relPath, renamedFrom string, watch *Watch) {
+	logger := app.Logger.WithField("path", relPath).WithField("from", renamedFrom)
+	if wm.DryRun {
+		logger.Info("Would link renamed file to previous history")
+		return
+	}
+
+	firstVersion, err := db.GetLatestFileVersion(filePath)
+	if err != nil || firstVersion == nil {
+		logger.WithError(err).Warn("Failed to find renamed file's first version")
+		return
+	}
+
+	if err := db.RecordRename(filePath, filepath.Join(watch.Path, renamedFrom), firstVersion.ID); err != nil {
+		logger.WithError(err).Warn("Failed to record rename")
+		return
+	}
+
+	logger.Info("File renamed - linked to previous history")
+}
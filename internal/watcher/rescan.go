@@ -0,0 +1,116 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// startRescan scans every watch again each RescanInterval, capturing changes
+// whose events were missed, as can happen under heavy load or on filesystems
+// with unreliable notifications
+func (wm *WatchManager) startRescan() {
+	if wm.config().RescanInterval <= 0 {
+		return
+	}
+
+	wm.goTracked(func() {
+		ticker := time.NewTicker(wm.config().RescanInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-wm.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			wm.rescan()
+		}
+	})
+}
+
+// rescan scans every watch and logs how many files it had to capture or
+// record as deleted
+func (wm *WatchManager) rescan() {
+	app.Logger.Debug("Starting periodic rescan")
+
+	var stats ScanStats
+	for _, watch := range wm.WatchList.Snapshot() {
+		if wm.ctx.Err() != nil {
+			return
+		}
+		stats.add(wm.ScanWatch(watch))
+		stats.DeletedFiles += wm.scanDeletions(watch)
+	}
+	wm.finishScan(stats)
+
+	fields := logrus.Fields{
+		"totalFiles":   stats.TotalFiles,
+		"newFiles":     stats.NewFiles,
+		"changedFiles": stats.ChangedFiles,
+		"deletedFiles": stats.DeletedFiles,
+	}
+	if corrected := stats.NewFiles + stats.ChangedFiles + stats.DeletedFiles; corrected > 0 {
+		app.Logger.WithFields(fields).WithField("corrected", corrected).Warn("Periodic rescan captured changes missed by the watcher")
+	} else {
+		app.Logger.WithFields(fields).Info("Periodic rescan found no missed changes")
+	}
+}
+
+// scanDeletions records as deleted the files a watch's database still holds
+// as live but that are gone from disk, and returns how many it recorded
+func (wm *WatchManager) scanDeletions(watch *Watch) int {
+	if !watch.Active {
+		return 0
+	}
+
+	logger := app.Logger.WithField("watch", watch.Path)
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		logger.WithError(err).Warn("Could not initialise database for deletion check")
+		return 0
+	}
+	if err := db.Connect(); err != nil {
+		logger.WithError(err).Warn("Could not connect to database for deletion check")
+		return 0
+	}
+	files, err := db.GetAllLatestFiles()
+	db.Close()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to list files for deletion check")
+		return 0
+	}
+
+	deleted := 0
+	for _, fv := range files {
+		if fv.Deleted {
+			continue
+		}
+
+		path := filepath.Join(watch.Path, filepath.FromSlash(fv.FilePath))
+		if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		// Leave files that are no longer this watch's to look after
+		if watch.ShouldIgnore(path) || !watch.ShouldInclude(path) {
+			continue
+		}
+		if containing := wm.WatchList.WatchesContaining(path); len(containing) > 0 && containing[0] != watch {
+			continue
+		}
+
+		if wm.handleRemove(path, watch) {
+			deleted++
+		}
+	}
+	return deleted
+}
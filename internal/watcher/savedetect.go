@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"os"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+// Capture triggers, chosen with capture.on
+const (
+	CaptureOnWrite = "write"
+	CaptureOnClose = "close"
+)
+
+// maxSaveWait caps how long a file kept open for writing, such as a log, can
+// hold off its capture in close mode
+const maxSaveWait = time.Minute
+
+// saveWait is a changed file waiting for its save to finish before it is
+// captured
+type saveWait struct {
+	timer   *time.Timer
+	op      string
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// scheduleSave captures a changed file once its save looks complete: no
+// events for the quiet period, its size and modification time unchanged
+// since the last check and, on Linux, no process holding it open for
+// writing. Each new event restarts the quiet period.
+func (wm *WatchManager) scheduleSave(path, relPath string, watch *Watch, op string) {
+	wm.saveMu.Lock()
+	defer wm.saveMu.Unlock()
+
+	if pending, ok := wm.pendingSaves[path]; ok {
+		pending.timer.Reset(wm.config().CaptureQuietPeriod)
+		return
+	}
+
+	app.Logger.WithField("path", relPath).Debug("File changed - waiting for the save to finish before capture")
+
+	pending := &saveWait{op: op, since: time.Now()}
+	if info, err := os.Stat(path); err == nil {
+		pending.size, pending.modTime = info.Size(), info.ModTime()
+	}
+	pending.timer = time.AfterFunc(wm.config().CaptureQuietPeriod, func() {
+		wm.settleSave(path, relPath, watch)
+	})
+	wm.pendingSaves[path] = pending
+}
+
+// settleSave captures a pending file if its save has finished, or checks
+// again after another quiet period if it is still being written
+func (wm *WatchManager) settleSave(path, relPath string, watch *Watch) {
+	if wm.ctx.Err() != nil {
+		return
+	}
+
+	wm.saveMu.Lock()
+	pending, ok := wm.pendingSaves[path]
+	if !ok {
+		wm.saveMu.Unlock()
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		// The remove event records the deletion
+		delete(wm.pendingSaves, path)
+		wm.saveMu.Unlock()
+		app.Logger.WithField("path", relPath).Debug("Changed file no longer exists, not capturing")
+		wm.trace(path, "Deleted before its save finished, not capturing", nil)
+		return
+	}
+
+	// Writes can arrive without an event of their own, as events for the
+	// same file close together are debounced
+	changed := info.Size() != pending.size || !info.ModTime().Equal(pending.modTime)
+	if changed || (time.Since(pending.since) < maxSaveWait && isOpenForWriting(path)) {
+		pending.size, pending.modTime = info.Size(), info.ModTime()
+		pending.timer.Reset(wm.config().CaptureQuietPeriod)
+		wm.saveMu.Unlock()
+		wm.trace(path, "Still being written, waiting another quiet period", logrus.Fields{"changed": changed})
+		return
+	}
+
+	delete(wm.pendingSaves, path)
+	wm.saveMu.Unlock()
+
+	app.Logger.WithField("path", relPath).Info("File saved - processing as potential edit")
+	wm.ProcessFile(path, relPath, watch, pending.op)
+}
+
+// cancelPendingSave stops the delayed capture of path, reporting whether one
+// was pending
+func (wm *WatchManager) cancelPendingSave(path string) bool {
+	wm.saveMu.Lock()
+	defer wm.saveMu.Unlock()
+
+	pending, ok := wm.pendingSaves[path]
+	if !ok {
+		return false
+	}
+	pending.timer.Stop()
+	delete(wm.pendingSaves, path)
+	return true
+}
+
+// cancelPendingSaves drops every capture waiting for a save to finish
+func (wm *WatchManager) cancelPendingSaves() {
+	wm.saveMu.Lock()
+	defer wm.saveMu.Unlock()
+
+	for path, pending := range wm.pendingSaves {
+		pending.timer.Stop()
+		delete(wm.pendingSaves, path)
+	}
+}
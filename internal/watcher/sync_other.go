@@ -0,0 +1,6 @@
+//go:build !unix
+
+package watcher
+
+// syncFilesystem is a no-op on platforms without a global sync call
+func syncFilesystem() {}
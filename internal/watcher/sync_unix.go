@@ -0,0 +1,10 @@
+//go:build unix
+
+package watcher
+
+import "syscall"
+
+// syncFilesystem flushes all pending filesystem writes to disk
+func syncFilesystem() {
+	syscall.Sync()
+}
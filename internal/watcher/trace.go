@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTraceDuration is how long a path is traced when no duration is given
+const DefaultTraceDuration = 10 * time.Minute
+
+// tracer holds the paths being traced and when each trace ends
+type tracer struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+func (t *tracer) add(path string, until time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.until == nil {
+		t.until = make(map[string]time.Time)
+	}
+	t.until[path] = until
+}
+
+// traced reports whether path, or a directory above it, is being traced.
+// Traces that have ended are dropped.
+func (t *tracer) traced(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	found := false
+	for traced, until := range t.until {
+		if now.After(until) {
+			delete(t.until, traced)
+			continue
+		}
+		if path == traced || strings.HasPrefix(path, traced+string(filepath.Separator)) {
+			found = true
+		}
+	}
+	return found
+}
+
+// Trace logs every event the daemon receives for path, or for files under it
+// when path is a directory, and each decision about capturing them, for
+// duration. The lines are logged at info level with a trace field, so one
+// file that isn't being versioned can be followed without debug logging the
+// whole daemon. It returns the time the trace ends.
+func (wm *WatchManager) Trace(path string, duration time.Duration) (time.Time, error) {
+	if duration <= 0 {
+		duration = DefaultTraceDuration
+	}
+	path = filepath.Clean(path)
+	if _, found := wm.WatchList.FindByPath(path); !found {
+		return time.Time{}, fmt.Errorf("%s is not in a watched project", path)
+	}
+
+	until := time.Now().Add(duration)
+	wm.tracer.add(path, until)
+	app.Logger.WithField("path", path).WithField("until", until.Format(time.RFC3339)).Info("Tracing path")
+	return until, nil
+}
+
+// trace logs msg about path if it is being traced
+func (wm *WatchManager) trace(path, msg string, fields logrus.Fields) {
+	if !wm.tracer.traced(path) {
+		return
+	}
+
+	app.Logger.WithField("trace", true).WithField("path", path).WithFields(fields).Info(msg)
+}
+
+// traceDebounced logs an event the notifier dropped as a duplicate
+func (wm *WatchManager) traceDebounced(event fsnotify.Event) {
+	wm.trace(event.Name, "Event dropped as a duplicate of one just received", logrus.Fields{"op": event.Op.String()})
+}
+
+// traceHashes logs the hash comparison that decides whether a file changed
+func (wm *WatchManager) traceHashes(path, currentHash, algorithm string, latestVersion *database.FileVersion) {
+	if !wm.tracer.traced(path) {
+		return
+	}
+
+	fields := logrus.Fields{"current_hash": currentHash, "hash_algorithm": algorithm}
+	if currentHash == "" {
+		fields["current_hash"] = "(hashed while storing)"
+	}
+	if latestVersion == nil {
+		wm.trace(path, "No earlier version, capturing as a new file", fields)
+		return
+	}
+
+	fields["latest_version"] = latestVersion.VersionNumber
+	fields["latest_hash"] = latestVersion.FileHash
+	fields["hash_algorithm"] = latestVersion.HashAlgorithm
+	if currentHash == latestVersion.FileHash {
+		wm.trace(path, "Hash matches the latest version, unchanged", fields)
+	} else {
+		wm.trace(path, "Hash differs from the latest version, capturing", fields)
+	}
+}
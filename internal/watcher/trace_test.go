@@ -0,0 +1,36 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracerTraced(t *testing.T) {
+	var tr tracer
+	dir := filepath.Join(string(filepath.Separator), "home", "user", "app")
+	tr.add(filepath.Join(dir, "main.go"), time.Now().Add(time.Minute))
+	tr.add(filepath.Join(dir, "build"), time.Now().Add(time.Minute))
+	tr.add(filepath.Join(dir, "old.txt"), time.Now().Add(-time.Second))
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(dir, "main.go"), true},
+		{filepath.Join(dir, "build", "out", "app.js"), true},
+		{filepath.Join(dir, "main.go.swp"), false},
+		{filepath.Join(dir, "buildinfo"), false},
+		{filepath.Join(dir, "old.txt"), false},
+		{dir, false},
+	}
+	for _, test := range tests {
+		if got := tr.traced(test.path); got != test.want {
+			t.Errorf("traced(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+
+	if len(tr.until) != 2 {
+		t.Errorf("%d traces kept, want the ended one dropped", len(tr.until))
+	}
+}
@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// vacuumMinFreeRatio is the share of a database's pages that must be free
+// before the background pass compacts it. Vacuuming rewrites the whole file,
+// so a database with little to reclaim is left alone.
+const vacuumMinFreeRatio = 0.1
+
+// startVacuum compacts the databases of watched projects every
+// VacuumInterval, skipping those with little free space to reclaim
+func (wm *WatchManager) startVacuum() {
+	if wm.config().VacuumInterval <= 0 {
+		return
+	}
+
+	wm.goTracked(func() {
+		ticker := time.NewTicker(wm.config().VacuumInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-wm.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, watch := range wm.WatchList.Snapshot() {
+				if wm.ctx.Err() != nil {
+					return
+				}
+				wm.vacuumWatch(watch)
+			}
+		}
+	})
+}
+
+// vacuumWatch compacts one watched project's database if enough of it is free
+func (wm *WatchManager) vacuumWatch(watch *Watch) {
+	logger := app.Logger.WithField("watch", watch.Path)
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		logger.WithError(err).Warn("Could not initialise database for vacuum")
+		return
+	}
+	if err := db.Connect(); err != nil {
+		logger.WithError(err).Warn("Could not connect to database for vacuum")
+		return
+	}
+	defer db.Close()
+
+	free, total, err := db.FreePages()
+	if err != nil {
+		logger.WithError(err).Warn("Failed to check database free space")
+		return
+	}
+	if total == 0 || float64(free)/float64(total) < vacuumMinFreeRatio {
+		return
+	}
+
+	result, err := wm.vacuum(db)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to vacuum database")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"sizeBefore": result.SizeBefore,
+		"sizeAfter":  result.SizeAfter,
+	}).Info("Vacuumed project database")
+}
+
+// VacuumProject compacts the database of the rewind project at root on
+// behalf of a client, so that it never runs in the middle of a capture
+func (wm *WatchManager) VacuumProject(root string) (*database.VacuumResult, error) {
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if !db.DatabaseExists() {
+		return nil, fmt.Errorf("%s is not a rewind project", root)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	return wm.vacuum(db)
+}
+
+// vacuum holds off captures while db is compacted
+func (wm *WatchManager) vacuum(db *database.DatabaseManager) (*database.VacuumResult, error) {
+	defer wm.projects.lock(db.RootDir())()
+	return db.Vacuum()
+}
+
+// MigrateStore moves the version store of the rewind project at root to
+// layout on behalf of a client, holding off captures until it is done
+func (wm *WatchManager) MigrateStore(root, layout string) (*database.StoreMigration, error) {
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database manager: %w", err)
+	}
+	if !db.DatabaseExists() {
+		return nil, fmt.Errorf("%s is not a rewind project", root)
+	}
+	if err := db.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	defer wm.projects.lock(db.RootDir())()
+	return db.MigrateStore(layout, false)
+}
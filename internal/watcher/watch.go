@@ -6,10 +6,16 @@ import (
 )
 
 type Watch struct {
-	Path           string   `json:"path"`
-	Active         bool     `json:"active"`
-	IgnorePatterns []string `json:"-"`
-	WatchDirs      []string `json:"-"`
+	Path string `json:"path"`
+	// Template is the path as written, when it uses environment variables
+	// or a leading ~. Path is expanded from it each time the watchlist is
+	// loaded.
+	Template        string   `json:"template,omitempty"`
+	Active          bool     `json:"active"`
+	IgnorePatterns  []string `json:"-"`
+	IncludePatterns []string `json:"-"`
+	WatchDirs       []string `json:"-"`
+	Warnings        []string `json:"-"`
 }
 
 func (w *Watch) ShouldIgnore(path string) bool {
@@ -24,46 +30,81 @@ func (w *Watch) ShouldIgnore(path string) bool {
 
 	// Check each ignore pattern
 	for _, pattern := range w.IgnorePatterns {
-		// Normalize the pattern as well
-		pattern = filepath.ToSlash(pattern)
-
-		// Handle directory patterns (ending with /)
-		if strings.HasSuffix(pattern, "/") {
-			// For directory patterns, check if the path starts with the pattern
-			// or if any parent directory matches
-			dirPattern := strings.TrimSuffix(pattern, "/")
-			pathParts := strings.SplitSeq(relPath, "/")
-
-			for part := range pathParts {
-				if matched, _ := filepath.Match(dirPattern, part); matched {
-					return true
-				}
-			}
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
 
-			// Also check if the full relative path starts with the pattern
-			if strings.HasPrefix(relPath+"/", pattern) {
-				return true
-			}
-		} else {
-			// For file patterns, check the filename and full path
-			filename := filepath.Base(relPath)
+	return false
+}
 
-			// Check if filename matches pattern
-			if matched, _ := filepath.Match(pattern, filename); matched {
-				return true
-			}
+// ShouldInclude reports whether a file is in the watch's include list. An
+// empty list includes everything. Include patterns only narrow which files
+// are versioned; ignore patterns still exclude files within the included set.
+func (w *Watch) ShouldInclude(path string) bool {
+	if len(w.IncludePatterns) == 0 {
+		return true
+	}
+
+	relPath, err := filepath.Rel(w.Path, path)
+	if err != nil {
+		return false
+	}
+
+	// Normalize path separators for consistent matching
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range w.IncludePatterns {
+		if matchPattern(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern reports whether a relative, slash-separated path matches an
+// ignore or include pattern
+func matchPattern(pattern, relPath string) bool {
+	// Normalize the pattern as well
+	pattern = filepath.ToSlash(pattern)
+
+	// Handle directory patterns (ending with /)
+	if strings.HasSuffix(pattern, "/") {
+		// For directory patterns, check if the path starts with the pattern
+		// or if any parent directory matches
+		dirPattern := strings.TrimSuffix(pattern, "/")
+		pathParts := strings.SplitSeq(relPath, "/")
 
-			// Check if full relative path matches pattern
-			if matched, _ := filepath.Match(pattern, relPath); matched {
+		for part := range pathParts {
+			if matched, _ := filepath.Match(dirPattern, part); matched {
 				return true
 			}
+		}
+
+		// Also check if the full relative path starts with the pattern
+		if strings.HasPrefix(relPath+"/", pattern) {
+			return true
+		}
+	} else {
+		// For file patterns, check the filename and full path
+		filename := filepath.Base(relPath)
+
+		// Check if filename matches pattern
+		if matched, _ := filepath.Match(pattern, filename); matched {
+			return true
+		}
+
+		// Check if full relative path matches pattern
+		if matched, _ := filepath.Match(pattern, relPath); matched {
+			return true
+		}
 
-			// Check if any part of the path matches the pattern
-			pathParts := strings.SplitSeq(relPath, "/")
-			for part := range pathParts {
-				if matched, _ := filepath.Match(pattern, part); matched {
-					return true
-				}
+		// Check if any part of the path matches the pattern
+		pathParts := strings.SplitSeq(relPath, "/")
+		for part := range pathParts {
+			if matched, _ := filepath.Match(pattern, part); matched {
+				return true
 			}
 		}
 	}
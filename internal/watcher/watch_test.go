@@ -63,3 +63,50 @@ func TestWatch_ShouldIgnore(t *testing.T) {
 		})
 	}
 }
+
+func TestWatch_ShouldInclude(t *testing.T) {
+	tests := []struct {
+		name            string
+		includePatterns []string
+		testPath        string
+		expected        bool
+	}{
+		{
+			name:            "empty include list includes everything",
+			includePatterns: nil,
+			testPath:        "/home/user/project/notes.txt",
+			expected:        true,
+		},
+		{
+			name:            "include matching extension",
+			includePatterns: []string{"*.go", "*.sql"},
+			testPath:        "/home/user/project/cmd/main.go",
+			expected:        true,
+		},
+		{
+			name:            "exclude non-matching extension",
+			includePatterns: []string{"*.go", "*.sql"},
+			testPath:        "/home/user/project/build/output.bin",
+			expected:        false,
+		},
+		{
+			name:            "include files under directory pattern",
+			includePatterns: []string{"migrations/"},
+			testPath:        "/home/user/project/migrations/001_init.up",
+			expected:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &Watch{
+				Path:            "/home/user/project",
+				IncludePatterns: tt.includePatterns,
+			}
+
+			if result := w.ShouldInclude(tt.testPath); result != tt.expected {
+				t.Errorf("ShouldInclude() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
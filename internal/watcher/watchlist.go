@@ -3,28 +3,100 @@ package watcher
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
 )
 
 type WatchList struct {
 	ListPath string
 	Watches  []*Watch
+	Config   Config
+
+	// Skipped lists projects in the watchlist that are not watched because
+	// their database failed its check when loaded
+	Skipped []SkippedWatch
+
+	// mu serializes changes to the watchlist within the process. Readers of
+	// Watches and Skipped hold it for reading, as a reload changes them while
+	// the daemon runs.
+	mu sync.RWMutex
+	// configMu protects Config, which watches are prepared with while mu is
+	// held
+	configMu sync.RWMutex
 }
 
-func NewWatchList() (*WatchList, error) {
+// SkippedWatch is a watchlist entry left unwatched because its database is
+// damaged, or its path uses an environment variable that isn't set. It stays
+// in the watchlist so the project is watched again when the daemon restarts
+// after it is repaired.
+type SkippedWatch struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// errDatabaseCheck marks a watch whose database could not be opened or
+// failed its quick check
+var errDatabaseCheck = errors.New("database failed its startup check")
+
+// errUnsetVariable marks a watch path template using an environment variable
+// that isn't set
+var errUnsetVariable = errors.New("environment variable is not set")
+
+// skipWatch reports whether a watch that failed preparation with err stays in
+// the watchlist, unwatched, rather than being dropped from it
+func skipWatch(err error) bool {
+	return errors.Is(err, errDatabaseCheck) || errors.Is(err, errUnsetVariable)
+}
+
+// WatchListPath returns the watchlist file for the given daemon instance. The
+// default instance ("") uses watchlist.json, named instances use
+// watchlist-<name>.json.
+func WatchListPath(instance string) (string, error) {
 	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	listName := "watchlist.json"
+	if instance != "" {
+		listName = "watchlist-" + instance + ".json"
+	}
+	return filepath.Join(homeDir, ".config", "rewind", listName), nil
+}
+
+// NewWatchList loads the watchlist for the given daemon instance
+func NewWatchList(instance string, config Config) (*WatchList, error) {
+	return newWatchList(instance, config, true)
+}
+
+// NewDryRunWatchList loads the watchlist like NewWatchList, but leaves the
+// file as it is rather than dropping the watches that can no longer be
+// prepared from it
+func NewDryRunWatchList(instance string, config Config) (*WatchList, error) {
+	return newWatchList(instance, config, false)
+}
+
+func newWatchList(instance string, config Config, save bool) (*WatchList, error) {
+	listPath, err := WatchListPath(instance)
 	if err != nil {
 		return nil, err
 	}
 
-	listPath := filepath.Join(homeDir, ".config", "rewind", "watchlist.json")
+	wl := &WatchList{ListPath: listPath, Config: config}
 
-	wl := &WatchList{ListPath: listPath}
+	unlock, err := wl.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Load existing watches
 	loadedWatches, err := wl.LoadWatchlist()
@@ -34,25 +106,43 @@ func NewWatchList() (*WatchList, error) {
 
 	// Prepare each watch and collect the valid ones
 	var validWatches []*Watch
+	var skippedWatches []Watch
 
 	for i := range loadedWatches {
 		preparedWatch, err := wl.prepareWatch(&loadedWatches[i])
+		if skipWatch(err) {
+			// One damaged project must not stop the others being watched
+			wl.Skipped = append(wl.Skipped, SkippedWatch{Path: loadedWatches[i].Path, Error: err.Error()})
+			skippedWatches = append(skippedWatches, loadedWatches[i])
+			continue
+		}
 		if err != nil {
 			app.Logger.WithField("path", loadedWatches[i].Path).WithError(err).Warn("Dropping watch due to preparation failure")
 			continue
 		}
+		// Entries added through a symlink before roots were resolved may now
+		// name the same project
+		if slices.ContainsFunc(validWatches, func(watch *Watch) bool { return watch.Path == preparedWatch.Path }) {
+			app.Logger.WithField("path", preparedWatch.Path).Warn("Dropping duplicate watch")
+			continue
+		}
 		validWatches = append(validWatches, preparedWatch)
 	}
 
 	// Set the prepared watches
 	wl.Watches = validWatches
+	if !save {
+		return wl, nil
+	}
 
 	watchesToSave := make([]Watch, len(validWatches))
 	for i, watch := range validWatches {
 		watchesToSave[i] = *watch // Dereference the pointer
 	}
+	watchesToSave = append(watchesToSave, skippedWatches...)
 
-	// Save the updated watchlist (this will remove any watches that failed preparation)
+	// Save the updated watchlist (this will remove any watches that failed
+	// preparation, other than those skipped for a damaged database)
 	if err := wl.SaveWatchlist(watchesToSave); err != nil {
 		return nil, fmt.Errorf("failed to save prepared watchlist: %w", err)
 	}
@@ -63,6 +153,9 @@ func NewWatchList() (*WatchList, error) {
 func (wl *WatchList) FindByPath(path string) (*Watch, bool) {
 
 	if root, found := wl.FindRewindRoot(path); found {
+		wl.mu.RLock()
+		defer wl.mu.RUnlock()
+
 		for i := range wl.Watches {
 			if wl.Watches[i].Path == root {
 				return wl.Watches[i], true
@@ -74,6 +167,31 @@ func (wl *WatchList) FindByPath(path string) (*Watch, bool) {
 
 }
 
+// Snapshot returns the running watches. The slice is a copy, so it can be
+// ranged over while a reload adds or removes watches.
+func (wl *WatchList) Snapshot() []*Watch {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	return slices.Clone(wl.Watches)
+}
+
+// SkippedWatches returns a copy of the watches skipped for a damaged database
+func (wl *WatchList) SkippedWatches() []SkippedWatch {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	return slices.Clone(wl.Skipped)
+}
+
+// config returns the settings watches are prepared with
+func (wl *WatchList) config() Config {
+	wl.configMu.RLock()
+	defer wl.configMu.RUnlock()
+
+	return wl.Config
+}
+
 func (wl *WatchList) FindRewindRoot(path string) (string, bool) {
 	if !filepath.IsAbs(path) {
 		return "", false
@@ -127,20 +245,136 @@ func (wl *WatchList) LoadWatchlist() ([]Watch, error) {
 	}
 
 	app.Logger.WithField("count", len(watches)).Info("Successfully loaded watchlist")
-	return watches, nil
+	return dedupeWatches(expandWatchPaths(watches)), nil
+}
+
+// expandWatchPaths resolves watch paths written with environment variables or
+// a leading ~ to absolute paths, keeping what was written as the template
+// they are expanded from on every load
+func expandWatchPaths(watches []Watch) []Watch {
+	for i := range watches {
+		watch := &watches[i]
+		if watch.Template == "" {
+			watch.Template = watchTemplate(watch.Path)
+		}
+		if watch.Template == "" {
+			continue
+		}
+		// Entries that can't be expanded are skipped when prepared
+		if path, err := expandWatchPath(watch.Template); err == nil {
+			watch.Path = path
+		}
+	}
+	return watches
+}
+
+// watchTemplate returns path if it needs expanding, or an empty string. Only
+// a leading ~ or a relative path using environment variables is a template:
+// a $ in an absolute path is part of a directory name.
+func watchTemplate(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path
+	}
+	if !filepath.IsAbs(path) && strings.Contains(path, "$") {
+		return path
+	}
+	return ""
+}
+
+// expandWatchPath expands a watch path template to the absolute, canonical
+// path of the project. A variable that isn't set is an error rather than
+// expanding to nothing, which would name some other directory.
+func expandWatchPath(template string) (string, error) {
+	var unset []string
+	os.Expand(template, func(name string) string {
+		if _, ok := os.LookupEnv(name); !ok {
+			unset = append(unset, "$"+name)
+		}
+		return ""
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("watch path %s uses %s: %w", template, strings.Join(unset, ", "), errUnsetVariable)
+	}
+
+	path := app.ExpandPath(template)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return canonicalPath(path), nil
+}
+
+// dedupeWatches drops repeated entries for the same path, which manual edits
+// or concurrent writes can leave behind and which would otherwise register
+// and capture the project twice. The first entry for a path is kept, unless a
+// later one is active and it isn't.
+func dedupeWatches(watches []Watch) []Watch {
+	deduped := make([]Watch, 0, len(watches))
+	index := make(map[string]int, len(watches))
+
+	for _, watch := range watches {
+		path := filepath.Clean(watch.Path)
+		i, seen := index[path]
+		if !seen {
+			index[path] = len(deduped)
+			deduped = append(deduped, watch)
+			continue
+		}
+
+		app.Logger.WithField("path", watch.Path).Warn("Dropping duplicate watchlist entry")
+		if watch.Active && !deduped[i].Active {
+			deduped[i] = watch
+		}
+	}
+
+	return deduped
 }
 
 // AddWatch adds a new watch to the configuration file
 func (wl *WatchList) AddWatch(path string) (*Watch, error) {
+	template := watchTemplate(path)
+	if template != "" {
+		expanded, err := expandWatchPath(template)
+		if err != nil {
+			return nil, err
+		}
+		path = expanded
+	}
+	path = canonicalPath(path)
 	logger := app.Logger.WithField("path", path)
 	logger.Info("Adding watch to configuration")
 
-	for _, watch := range wl.Watches {
-		if watch.Path == path {
-			logger.Info("Watch already exists in memory")
-			return nil, fmt.Errorf("watch already exists for path: %s", path)
-		}
+	if wl.hasWatch(path) {
+		logger.Info("Watch already exists in memory")
+		return nil, fmt.Errorf("watch already exists for path: %s", path)
+	}
+
+	// Validate that the path exists and is a directory
+	if err := wl.validateWatchPath(path); err != nil {
+		logger.Info("watch path does not exist")
+		return nil, fmt.Errorf("invalid watch path: %w", err)
+	}
+
+	// Create new watch
+	newWatch := Watch{
+		Path:     path,
+		Template: template,
+		Active:   true,
+	}
+
+	// Prepare before saving so a watch that fails, such as one over the size
+	// limits, is never written to the watchlist. Preparing walks the whole
+	// tree, so it is done before taking the lock.
+	preparedWatch, err := wl.prepareWatch(&newWatch)
+	if err != nil {
+		app.Logger.WithField("path", newWatch.Path).WithError(err).Warn("Dropping watch due to preparation failure")
+		return nil, fmt.Errorf("failed to prepare new watch: %w", err)
+	}
+
+	unlock, err := wl.lock()
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
 	// Load existing watchlist
 	watches, err := wl.LoadWatchlist()
@@ -148,24 +382,17 @@ func (wl *WatchList) AddWatch(path string) (*Watch, error) {
 		return nil, fmt.Errorf("failed to load existing watchlist: %w", err)
 	}
 
-	// Check if watch already exists
+	// Check if watch already exists, as it may have been added while this
+	// one was being prepared
 	for _, watch := range watches {
 		if watch.Path == path {
 			logger.Info("Watch already exists in configuration")
 			return nil, fmt.Errorf("watch already exists for path: %s", path)
 		}
 	}
-
-	// Validate that the path exists and is a directory
-	if err := wl.validateWatchPath(path); err != nil {
-		logger.Info("watch path does not exist")
-		return nil, fmt.Errorf("invalid watch path: %w", err)
-	}
-
-	// Create new watch
-	newWatch := Watch{
-		Path:   path,
-		Active: true,
+	if slices.ContainsFunc(wl.Watches, func(watch *Watch) bool { return watch.Path == path }) {
+		logger.Info("Watch already exists in memory")
+		return nil, fmt.Errorf("watch already exists for path: %s", path)
 	}
 
 	// Add to list
@@ -176,22 +403,46 @@ func (wl *WatchList) AddWatch(path string) (*Watch, error) {
 		return nil, fmt.Errorf("failed to save updated watchlist: %w", err)
 	}
 
-	preparedWatch, err := wl.prepareWatch(&newWatch)
-	if err != nil {
-		app.Logger.WithField("path", newWatch.Path).WithError(err).Warn("Dropping watch due to preparation failure")
-		return nil, fmt.Errorf("failed to prepare new watch: %w", err)
-	}
-
 	wl.Watches = append(wl.Watches, preparedWatch)
 
 	logger.Info("Successfully added watch to configuration")
 	return &newWatch, nil
 }
 
+// removeSkipped drops path from the skipped watches and reports whether it
+// was there. The caller holds the lock.
+func (wl *WatchList) removeSkipped(path string) bool {
+	before := len(wl.Skipped)
+	wl.Skipped = slices.DeleteFunc(wl.Skipped, func(skipped SkippedWatch) bool { return skipped.Path == path })
+	return len(wl.Skipped) != before
+}
+
+// hasWatch reports whether a watch for path is already in memory
+func (wl *WatchList) hasWatch(path string) bool {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	return slices.ContainsFunc(wl.Watches, func(watch *Watch) bool { return watch.Path == path })
+}
+
 func (wl *WatchList) RemoveWatch(path string) (*Watch, error) {
+	if template := watchTemplate(path); template != "" {
+		expanded, err := expandWatchPath(template)
+		if err != nil {
+			return nil, err
+		}
+		path = expanded
+	}
+	path = canonicalPath(path)
 	logger := app.Logger.WithField("path", path)
 	logger.Info("Removing watch from configuration")
 
+	unlock, err := wl.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	// Load from file
 	watches, err := wl.LoadWatchlist()
 	if err != nil {
@@ -231,6 +482,11 @@ func (wl *WatchList) RemoveWatch(path string) (*Watch, error) {
 	}
 
 	if foundWatch == nil {
+		// Skipped watches have no directories registered
+		if wl.removeSkipped(path) {
+			logger.Info("Successfully removed skipped watch from configuration")
+			return &Watch{Path: path}, nil
+		}
 		logger.Error("Watch found in file but not in memory")
 		return nil, fmt.Errorf("watch not found in memory for path: %s", path)
 	}
@@ -243,6 +499,37 @@ func (wl *WatchList) RemoveWatch(path string) (*Watch, error) {
 	return foundWatch, nil
 }
 
+// lock serializes reading, changing and saving the watchlist against other
+// goroutines and other rewind processes sharing the file. The returned
+// function releases the lock.
+func (wl *WatchList) lock() (func(), error) {
+	wl.mu.Lock()
+
+	if err := os.MkdirAll(filepath.Dir(wl.ListPath), 0755); err != nil {
+		wl.mu.Unlock()
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	f, err := os.OpenFile(wl.ListPath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		wl.mu.Unlock()
+		return nil, fmt.Errorf("failed to open watchlist lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		wl.mu.Unlock()
+		return nil, fmt.Errorf("failed to lock watchlist: %w", err)
+	}
+
+	return func() {
+		f.Close()
+		wl.mu.Unlock()
+	}, nil
+}
+
+// SaveWatchlist writes the watchlist, replacing the file atomically so a
+// crash or concurrent reader never sees it half-written. Callers changing the
+// list should hold the lock from loading it until it is saved.
 func (wl *WatchList) SaveWatchlist(watches []Watch) error {
 	app.Logger.WithField("configPath", wl.ListPath).WithField("count", len(watches)).Debug("Saving watchlist to configuration")
 
@@ -265,8 +552,28 @@ func (wl *WatchList) SaveWatchlist(watches []Watch) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	// Write to file with appropriate permissions
-	if err := os.WriteFile(wl.ListPath, data, 0644); err != nil {
+	// Write to a temporary file and rename it over the watchlist
+	tmp, err := os.CreateTemp(configDir, filepath.Base(wl.ListPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), wl.ListPath); err != nil {
 		app.Logger.WithError(err).Error("Failed to write watchlist configuration file")
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
@@ -309,9 +616,10 @@ func (wl *WatchList) validateWatchPath(path string) error {
 }
 
 // Replace the discoverWatchDirectories method in your WatchList
-func (wl *WatchList) discoverWatchDirectories(watch *Watch) ([]string, error) {
+func (wl *WatchList) discoverWatchDirectories(watch *Watch) ([]string, TreeSize, error) {
 	app.Logger.WithField("rootDir", watch.Path).WithField("ignorePatterns", len(watch.IgnorePatterns)).Debug("Discovering watch directories")
 	var watchDirs []string
+	var size TreeSize
 
 	err := filepath.WalkDir(watch.Path, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
@@ -320,7 +628,11 @@ func (wl *WatchList) discoverWatchDirectories(watch *Watch) ([]string, error) {
 		}
 
 		if !d.IsDir() {
-			return nil
+			if watch.ShouldIgnore(path) || !watch.ShouldInclude(path) {
+				return nil
+			}
+			size.Files++
+			return wl.checkLimits(watch, size)
 		}
 
 		// Use the Watch's ShouldIgnore method instead of WatchList's
@@ -333,22 +645,52 @@ func (wl *WatchList) discoverWatchDirectories(watch *Watch) ([]string, error) {
 		watchDirs = append(watchDirs, path)
 		relPath, _ := filepath.Rel(watch.Path, path)
 		app.Logger.WithField("path", path).WithField("relPath", relPath).Debug("Added watch directory")
-		return nil
+
+		// checkGitDirectories is about to ignore .git, so its contents must
+		// not count towards the limits
+		if wl.config().AutoIgnoreGit && d.Name() == ".git" && path != watch.Path {
+			return filepath.SkipDir
+		}
+
+		size.Dirs++
+		size.Depth = max(size.Depth, treeDepth(watch.Path, path))
+		return wl.checkLimits(watch, size)
 	})
 
 	if err != nil {
 		app.Logger.WithField("rootDir", watch.Path).WithField("error", err).Error("Error walking directory tree")
-		return nil, fmt.Errorf("error walking directory tree: %w", err)
+		return nil, size, fmt.Errorf("error walking directory tree: %w", err)
 	}
 
-	app.Logger.WithField("totalDirectories", len(watchDirs)).Info("Directory discovery completed")
-	return watchDirs, nil
+	app.Logger.WithField("totalDirectories", len(watchDirs)).WithField("totalFiles", size.Files).Info("Directory discovery completed")
+	return watchDirs, size, nil
+}
+
+// canonicalPath resolves any symlinks in a watch root. fsnotify reports events
+// under the real path, so a root stored as a symlink would never match them.
+// A path that can't be resolved is returned unchanged.
+func canonicalPath(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return resolved
 }
 
 // Update the prepareWatch method to pass the watch instance
 func (wl *WatchList) prepareWatch(watch *Watch) (*Watch, error) {
+	watch.Path = canonicalPath(watch.Path)
 	logger := app.Logger.WithField("path", watch.Path)
 
+	// The path last expanded from the template may no longer be where the
+	// project is
+	if watch.Template != "" {
+		if _, err := expandWatchPath(watch.Template); err != nil {
+			logger.WithError(err).Error("Cannot expand watch path - this project will not be watched until the variable is set")
+			return nil, err
+		}
+	}
+
 	if !watch.Active {
 		logger.Info("Watch not active, skipping preparation")
 		return nil, fmt.Errorf("Watch not active")
@@ -360,6 +702,12 @@ func (wl *WatchList) prepareWatch(watch *Watch) (*Watch, error) {
 		return nil, fmt.Errorf("No .rewind directory found")
 	}
 
+	// A damaged database would only show up as failed captures later
+	if err := checkWatchDatabase(watch.Path); err != nil {
+		logger.WithError(err).Error("DATABASE CHECK FAILED - this project will not be watched until its database is repaired")
+		return nil, err
+	}
+
 	ignorePatterns, err := wl.loadIgnorePatterns(watch.Path)
 	if err != nil {
 		logger.WithError(err).Error("Failed to discover ignore patterns")
@@ -368,23 +716,102 @@ func (wl *WatchList) prepareWatch(watch *Watch) (*Watch, error) {
 
 	watch.IgnorePatterns = ignorePatterns
 
+	includePatterns, err := wl.loadIncludePatterns(watch.Path)
+	if err != nil {
+		logger.WithError(err).Error("Failed to discover include patterns")
+		return nil, fmt.Errorf("failed to discover include patterns")
+	}
+
+	watch.IncludePatterns = includePatterns
+
 	// Now pass the watch instance instead of separate parameters
-	watchDirs, err := wl.discoverWatchDirectories(watch)
+	watchDirs, _, err := wl.discoverWatchDirectories(watch)
 	if err != nil {
 		logger.WithError(err).Error("Failed to discover directories")
-		return nil, fmt.Errorf("failed to discover watch directories")
+		return nil, fmt.Errorf("failed to discover watch directories: %w", err)
 	}
 
 	watch.WatchDirs = watchDirs
 
+	if err := wl.checkGitDirectories(watch); err != nil {
+		logger.WithError(err).Error("Failed to check .git directories")
+		return nil, fmt.Errorf("failed to check .git directories: %w", err)
+	}
+
 	logger.WithField("directoriesFound", len(watchDirs)).WithField("ignorePatterns", len(ignorePatterns)).Info("Watch preparation completed")
 	return watch, nil
 }
 
+// checkWatchDatabase opens a project's database and runs a quick check on it.
+// A project without a database yet has nothing to check.
+func checkWatchDatabase(rootPath string) error {
+	db, err := database.NewDatabaseManager(rootPath)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errDatabaseCheck, err)
+	}
+	if !db.DatabaseExists() {
+		return nil
+	}
+	if err := db.Connect(); err != nil {
+		return fmt.Errorf("%w: %w", errDatabaseCheck, err)
+	}
+	defer db.Close()
+
+	if err := db.QuickCheck(); err != nil {
+		return fmt.Errorf("%w: %w", errDatabaseCheck, err)
+	}
+	return nil
+}
+
+// PrepareWatch loads ignore patterns and discovers directories for a watch
+// without adding it to the watchlist
+func (wl *WatchList) PrepareWatch(watch *Watch) (*Watch, error) {
+	return wl.prepareWatch(watch)
+}
+
+// checkGitDirectories warns about .git directories that the ignore patterns
+// don't cover, as versioning git's object store causes enormous churn. When
+// AutoIgnoreGit is set, ".git/" is added to the patterns and discovery re-run.
+func (wl *WatchList) checkGitDirectories(watch *Watch) error {
+	var gitDirs []string
+	for _, dir := range watch.WatchDirs {
+		if filepath.Base(dir) == ".git" {
+			gitDirs = append(gitDirs, dir)
+		}
+	}
+
+	if len(gitDirs) == 0 {
+		return nil
+	}
+
+	logger := app.Logger.WithField("path", watch.Path).WithField("gitDirs", gitDirs)
+
+	if !wl.config().AutoIgnoreGit {
+		logger.Warn("WARNING: .git directory is not ignored and will be versioned")
+		watch.Warnings = append(watch.Warnings, fmt.Sprintf(".git directory is not covered by ignore patterns and will be versioned (%d found) - add '.git/' to .rwignore", len(gitDirs)))
+		return nil
+	}
+
+	logger.Warn("WARNING: .git directory is not ignored, adding '.git/' to ignore patterns")
+	watch.Warnings = append(watch.Warnings, ".git directory was not covered by ignore patterns - '.git/' was ignored automatically")
+	watch.IgnorePatterns = append(watch.IgnorePatterns, ".git/")
+
+	watchDirs, _, err := wl.discoverWatchDirectories(watch)
+	if err != nil {
+		return err
+	}
+	watch.WatchDirs = watchDirs
+
+	return nil
+}
+
 func (wl *WatchList) loadIgnorePatterns(rootDir string) ([]string, error) {
 	app.Logger.WithField("rootDir", rootDir).Debug("Loading ignore patterns")
 
 	patterns := []string{".rewind", ".rewind/*"}
+	if wl.config().IgnoreEditorArtifacts {
+		patterns = append(patterns, editorArtifactPatterns...)
+	}
 
 	// homeDir, err := os.UserHomeDir()
 	// if err != nil {
@@ -435,6 +862,28 @@ func (wl *WatchList) loadIgnorePatterns(rootDir string) ([]string, error) {
 	return patterns, nil
 }
 
+// loadIncludePatterns combines the configured include patterns with those in
+// the project's .rwinclude file
+func (wl *WatchList) loadIncludePatterns(rootDir string) ([]string, error) {
+	patterns := append([]string(nil), wl.config().Include...)
+
+	rwIncludePath := filepath.Join(rootDir, ".rwinclude")
+	app.Logger.WithField("path", rwIncludePath).Debug("Checking for .rwinclude file")
+
+	if rwPatterns, err := wl.readIgnoreFile(rwIncludePath); err == nil {
+		patterns = append(patterns, rwPatterns...)
+		app.Logger.WithField("count", len(rwPatterns)).Debug("Loaded patterns from .rwinclude")
+	} else if !os.IsNotExist(err) {
+		app.Logger.WithField("path", rwIncludePath).WithField("error", err).Error("Error reading .rwinclude")
+		return nil, fmt.Errorf("error reading .rwinclude: %w", err)
+	}
+
+	if len(patterns) > 0 {
+		app.Logger.WithField("totalCount", len(patterns)).Info("Include patterns loaded")
+	}
+	return patterns, nil
+}
+
 func (wl *WatchList) readIgnoreFile(filePath string) ([]string, error) {
 	app.Logger.WithField("path", filePath).Debug("Reading ignore file")
 
@@ -0,0 +1,322 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewWatchList_DuplicateEntries(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	t.Setenv("HOME", t.TempDir())
+	listPath, err := WatchListPath("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := t.TempDir()
+	if err := os.Mkdir(filepath.Join(project, ".rewind"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	watches := []Watch{
+		{Path: project, Active: false},
+		{Path: project + string(filepath.Separator), Active: true},
+		{Path: project, Active: true},
+	}
+	data, err := json.Marshal(watches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(listPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := NewWatchList("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wl.Watches) != 1 {
+		t.Fatalf("loaded %d watches, want 1", len(wl.Watches))
+	}
+	if !wl.Watches[0].Active {
+		t.Errorf("kept the inactive entry, want the active one")
+	}
+
+	// The cleaned list is written back
+	data, err = os.ReadFile(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw []Watch
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 1 {
+		t.Errorf("watchlist file has %d entries, want 1", len(raw))
+	}
+}
+
+func TestWatchList_ConcurrentAdds(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	listPath := filepath.Join(t.TempDir(), "watchlist.json")
+
+	// Two watch lists sharing a file stand in for separate processes, which
+	// only the file lock keeps apart
+	lists := []*WatchList{
+		{ListPath: listPath, Config: DefaultConfig()},
+		{ListPath: listPath, Config: DefaultConfig()},
+	}
+
+	const projects = 8
+	var wg sync.WaitGroup
+	for i := 0; i < projects; i++ {
+		project := t.TempDir()
+		if err := os.Mkdir(filepath.Join(project, ".rewind"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(wl *WatchList) {
+			defer wg.Done()
+			if _, err := wl.AddWatch(project); err != nil {
+				t.Errorf("AddWatch(%s) error = %v", project, err)
+			}
+		}(lists[i%len(lists)])
+	}
+	wg.Wait()
+
+	watches, err := lists[0].LoadWatchlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watches) != projects {
+		t.Errorf("watchlist has %d entries, want %d", len(watches), projects)
+	}
+	if got := len(lists[0].Watches) + len(lists[1].Watches); got != projects {
+		t.Errorf("%d watches in memory, want %d", got, projects)
+	}
+}
+
+func TestNewWatchList_SkipsDamagedDatabase(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	t.Setenv("HOME", t.TempDir())
+	listPath, err := WatchListPath("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy := t.TempDir()
+	damaged := t.TempDir()
+	for _, project := range []string{healthy, damaged} {
+		if err := os.Mkdir(filepath.Join(project, ".rewind"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	garbage := []byte("this is not an sqlite database, just some bytes that fill the header")
+	if err := os.WriteFile(filepath.Join(damaged, ".rewind", "versions.db"), garbage, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal([]Watch{{Path: damaged, Active: true}, {Path: healthy, Active: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(listPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := NewWatchList("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wl.Watches) != 1 || wl.Watches[0].Path != healthy {
+		t.Fatalf("watching %d projects, want only the healthy one", len(wl.Watches))
+	}
+	if len(wl.Skipped) != 1 || wl.Skipped[0].Path != damaged {
+		t.Fatalf("skipped %v, want the damaged project", wl.Skipped)
+	}
+
+	// The damaged project stays listed so it is watched once repaired
+	data, err = os.ReadFile(listPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw []Watch
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != 2 {
+		t.Errorf("watchlist file has %d entries, want 2", len(raw))
+	}
+}
+
+func TestWatchList_NestedWatches(t *testing.T) {
+	outer := filepath.Join(string(filepath.Separator), "home", "me", "work")
+	inner := filepath.Join(outer, "app")
+	sibling := filepath.Join(string(filepath.Separator), "home", "me", "workshop")
+
+	wl := &WatchList{Watches: []*Watch{{Path: outer}, {Path: inner}, {Path: sibling}}}
+
+	conflicts := wl.Conflicts()
+	want := []WatchConflict{{Path: inner, Within: outer}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("Conflicts() = %v, want %v", conflicts, want)
+	}
+
+	containing := wl.WatchesContaining(filepath.Join(inner, "main.go"))
+	if len(containing) != 2 || containing[0].Path != inner || containing[1].Path != outer {
+		t.Errorf("WatchesContaining() = %v, want the inner watch, then the outer", containing)
+	}
+	if containing := wl.WatchesContaining(filepath.Join(sibling, "notes.txt")); len(containing) != 1 {
+		t.Errorf("WatchesContaining() matched %d watches for a sibling sharing a name prefix, want 1", len(containing))
+	}
+}
+
+func TestWatchList_PathTemplates(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	listPath, err := WatchListPath("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	work := t.TempDir()
+	t.Setenv("REWIND_TEST_WORK", work)
+	projects := map[string]string{
+		"~/app":                    filepath.Join(home, "app"),
+		"$HOME/site":               filepath.Join(home, "site"),
+		"${REWIND_TEST_WORK}/repo": filepath.Join(work, "repo"),
+	}
+	var watches []Watch
+	for template, path := range projects {
+		if err := os.MkdirAll(filepath.Join(path, ".rewind"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		watches = append(watches, Watch{Path: template, Active: true})
+	}
+
+	data, err := json.Marshal(watches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(listPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := NewWatchList("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wl.Watches) != len(projects) {
+		t.Fatalf("loaded %d watches, want %d", len(wl.Watches), len(projects))
+	}
+	for _, watch := range wl.Watches {
+		want, ok := projects[watch.Template]
+		if !ok {
+			t.Errorf("watch %s has template %q, want one of the written paths", watch.Path, watch.Template)
+			continue
+		}
+		if watch.Path != canonicalPath(want) {
+			t.Errorf("template %q expanded to %s, want %s", watch.Template, watch.Path, want)
+		}
+	}
+
+	// The templates are kept when the watchlist is saved, so the paths
+	// follow the environment the next time it is loaded
+	moved := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(moved, "repo", ".rewind"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REWIND_TEST_WORK", moved)
+
+	wl, err = NewWatchList("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := wl.FindByPath(filepath.Join(moved, "repo")); !found {
+		t.Errorf("${REWIND_TEST_WORK}/repo not watched at its new expansion %s", filepath.Join(moved, "repo"))
+	}
+}
+
+func TestWatchList_PathTemplatesNeedSetVariables(t *testing.T) {
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	listPath, err := WatchListPath("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REWIND_TEST_UNSET", "")
+	os.Unsetenv("REWIND_TEST_UNSET")
+
+	// A $ in an absolute path is part of the directory name
+	literal := filepath.Join(t.TempDir(), "price$list")
+	if err := os.MkdirAll(filepath.Join(literal, ".rewind"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	unset := "${REWIND_TEST_UNSET}/repo"
+
+	data, err := json.Marshal([]Watch{{Path: literal, Active: true}, {Path: unset, Active: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(listPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(listPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wl, err := NewWatchList("", DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watch, found := wl.FindByPath(canonicalPath(literal)); !found || watch.Template != "" {
+		t.Errorf("%s not watched as written: found %v", literal, found)
+	}
+	if len(wl.Skipped) != 1 || wl.Skipped[0].Path != unset {
+		t.Errorf("skipped watches = %+v, want only %s", wl.Skipped, unset)
+	}
+
+	// The entry is kept so it is watched once the variable is set
+	saved, err := wl.LoadWatchlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Errorf("watchlist has %d entries after loading, want both kept", len(saved))
+	}
+
+	if _, err := wl.AddWatch("$REWIND_TEST_UNSET/other"); !errors.Is(err, errUnsetVariable) {
+		t.Errorf("AddWatch() with an unset variable = %v, want errUnsetVariable", err)
+	}
+}
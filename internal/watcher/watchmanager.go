@@ -2,12 +2,15 @@ package watcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"slices"
@@ -20,15 +23,49 @@ import (
 )
 
 type WatchManager struct {
-	WatchList      *WatchList
-	EventsNotifier *events.EventsNotifier
-	ctx            context.Context
-	cancel         context.CancelFunc
-	wg             sync.WaitGroup
-	EventChan      chan fsnotify.Event // Exposed channel for consuming events
-	startTime      time.Time           // Track when the manager started
-	mu             sync.RWMutex        // Protect concurrent access to status fields
-	stopped        bool                // Track if Stop() has been called
+	WatchList       *WatchList
+	EventsNotifier  *events.EventsNotifier
+	Config          Config
+	ctx             context.Context
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+	goroutines      atomic.Int32           // Goroutines started with goTracked that are still running
+	EventChan       chan fsnotify.Event    // Exposed channel for consuming events
+	startTime       time.Time              // Track when the manager started
+	mu              sync.RWMutex           // Protect concurrent access to status fields
+	stopped         bool                   // Track if Stop() has been called
+	metrics         *captureMetrics        // Capture latency histograms
+	linkMu          sync.Mutex             // Protects copyOnly
+	copyOnly        map[string]bool        // Files seen modified in place, never hardlinked again
+	projects        projectLocks           // Serialises captures and store maintenance per project
+	configMu        sync.RWMutex           // Protects Config and audit against a reload
+	pendingMu       sync.Mutex             // Protects pendingCreates, pendingRetries and sizeChecks
+	pendingCreates  map[string]*time.Timer // Created files waiting out the create grace period
+	pendingRetries  map[string]*time.Timer // Throttled files waiting for their throttle to lift
+	sizeChecks      map[string]*time.Timer // Projects waiting to be checked against the total size limit
+	saveMu          sync.Mutex             // Protects pendingSaves
+	pendingSaves    map[string]*saveWait   // Changed files waiting for their save to finish (capture.on: close)
+	renameMu        sync.Mutex             // Protects pendingRenames
+	pendingRenames  []pendingRename        // Tracked files renamed away, not yet seen under a new name
+	recentErrors    recentErrors           // Latest capture failures, shown by status
+	changes         changeLog              // Latest captures and deletions, for clients polling for changes
+	tracer          tracer                 // Paths whose events and capture decisions are logged
+	compressMu      sync.Mutex             // Protects incompressible
+	incompressible  map[int64]bool         // Versions the compression pass should not retry
+	limiter         *captureLimiter        // Throttles files captured too often
+	audit           *app.AuditLog          // Records every capture and deletion, when enabled
+	conflictMu      sync.Mutex             // Protects warnedConflicts
+	warnedConflicts map[string]bool        // Nested watch pairs already warned about
+
+	// CaptureFilter decides which files are captured. It starts as
+	// DefaultCaptureFilter and can be replaced before Start when rewind is
+	// embedded as a library.
+	CaptureFilter CaptureFilter
+
+	// DryRun logs the versions, deletions and renames that would be recorded
+	// instead of writing them, so a watch can be tried out before trusting it
+	// with a large or sensitive tree. It is set before Start.
+	DryRun bool
 }
 
 type WatchManagerStatus struct {
@@ -41,14 +78,21 @@ type WatchManagerStatus struct {
 	StartTime        time.Time           `json:"start_time,omitzero"`
 	UptimeDuration   string              `json:"uptime_duration,omitempty"`
 	WatchDetails     []WatchStatusDetail `json:"watch_details"`
+	RecentErrors     []CaptureError      `json:"recent_errors,omitempty"`
+	SkippedWatches   []SkippedWatch      `json:"skipped_watches,omitempty"`
+	ThrottledFiles   []ThrottledFile     `json:"throttled_files,omitempty"`
+	Config           map[string]any      `json:"config"`
+	Conflicts        []WatchConflict     `json:"conflicts,omitempty"`
 }
 
 // WatchStatusDetail provides details about individual watches
 type WatchStatusDetail struct {
 	Path        string   `json:"path"`
+	Template    string   `json:"template,omitempty"`
 	WatchDirs   []string `json:"watch_dirs"`
 	DirCount    int      `json:"dir_count"`
 	IgnoreCount int      `json:"ignore_count"`
+	Warnings    []string `json:"warnings,omitempty"`
 }
 
 func NewWatchManager(wl *WatchList) (*WatchManager, error) {
@@ -59,20 +103,35 @@ func NewWatchManager(wl *WatchList) (*WatchManager, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	wm := &WatchManager{
-		WatchList:      wl,
-		EventsNotifier: en,
-		ctx:            ctx,
-		cancel:         cancel,
-		EventChan:      make(chan fsnotify.Event, 100), // Buffered channel for events
+		WatchList:       wl,
+		EventsNotifier:  en,
+		Config:          wl.Config,
+		ctx:             ctx,
+		cancel:          cancel,
+		EventChan:       make(chan fsnotify.Event, 100), // Buffered channel for events
+		metrics:         newCaptureMetrics(),
+		copyOnly:        make(map[string]bool),
+		pendingCreates:  make(map[string]*time.Timer),
+		pendingRetries:  make(map[string]*time.Timer),
+		sizeChecks:      make(map[string]*time.Timer),
+		pendingSaves:    make(map[string]*saveWait),
+		incompressible:  make(map[int64]bool),
+		changes:         changeLog{start: newStartID()},
+		limiter:         newCaptureLimiter(wl.Config.MaxCapturesPerMinute, wl.Config.CaptureCooldown),
+		audit:           app.NewAuditLog(wl.Config.AuditLog),
+		warnedConflicts: make(map[string]bool),
 	}
+	wm.CaptureFilter = wm.DefaultCaptureFilter
 
 	// Set up the callback so EventsNotifier can send events to WatchManager
 	en.SetCallback(wm.sendEvent)
+	en.SetDebounceCallback(wm.traceDebounced)
 
-	app.Logger.WithField("count", len(wm.WatchList.Watches)).Debug("Retrieved projects")
+	watches := wm.WatchList.Snapshot()
+	app.Logger.WithField("count", len(watches)).Debug("Retrieved projects")
 
 	// Add all paths to the notifier
-	for _, watch := range wm.WatchList.Watches {
+	for _, watch := range watches {
 		for _, path := range watch.WatchDirs {
 			wm.EventsNotifier.AddPath(path)
 		}
@@ -89,30 +148,65 @@ func (wm *WatchManager) Start() error {
 	wm.mu.Unlock()
 
 	// Start the events notifier in a separate goroutine
-	wm.wg.Add(1)
-	go func() {
-		defer wm.wg.Done()
+	wm.goTracked(func() {
 		if err := wm.EventsNotifier.Start(wm.ctx); err != nil && err != context.Canceled {
 			app.Logger.WithError(err).Error("Events notifier stopped with error")
 		}
-	}()
+	})
+
+	// Handle events in their own goroutine, so the notifier keeps reading
+	// while a capture is in progress
+	wm.goTracked(wm.processEvents)
 
-	if err := wm.PerformInitialScan(); err != nil {
+	if _, err := wm.PerformInitialScan(); err != nil {
 		app.Logger.WithError(err).Error("Could not complete initial scan")
 	}
 
+	// Compression and vacuuming only rework what is already stored
+	if !wm.DryRun {
+		wm.startCompression()
+		wm.startVacuum()
+	}
+	wm.startRescan()
+
 	return nil
 }
 
-// sendEvent is the callback that receives events from EventsNotifier
+// goTracked runs fn in a goroutine that Stop waits for and status counts
+func (wm *WatchManager) goTracked(fn func()) {
+	wm.wg.Add(1)
+	wm.goroutines.Add(1)
+	go func() {
+		defer wm.wg.Done()
+		defer wm.goroutines.Add(-1)
+		fn()
+	}()
+}
+
+// sendEvent is the callback that receives events from EventsNotifier. It
+// queues the event for processEvents, waiting while the queue is full rather
+// than dropping it.
 func (wm *WatchManager) sendEvent(event fsnotify.Event) {
+	if len(wm.EventChan) == cap(wm.EventChan) {
+		app.Logger.WithField("path", event.Name).Debug("Event channel full, waiting for captures to catch up")
+	}
+
 	select {
 	case wm.EventChan <- event:
-		wm.handleEvent(event)
 	case <-wm.ctx.Done():
 		// Context cancelled, ignore event
-	default:
-		app.Logger.WithField("path", event.Name).Warn("Event channel full, dropping event")
+	}
+}
+
+// processEvents handles queued events in order until the manager stops
+func (wm *WatchManager) processEvents() {
+	for {
+		select {
+		case event := <-wm.EventChan:
+			wm.handleEvent(event)
+		case <-wm.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -120,15 +214,32 @@ func (wm *WatchManager) handleEvent(event fsnotify.Event) {
 
 	logger := app.Logger.WithField("path", event.Name)
 	logger.Debug("Processing file system event")
+	wm.trace(event.Name, "Event received", logrus.Fields{"op": event.Op.String()})
 
 	if watch, found := wm.WatchList.FindByPath(event.Name); found {
+		// A file under nested projects belongs to the deepest of them
+		if containing := wm.WatchList.WatchesContaining(event.Name); len(containing) > 1 {
+			wm.warnWatchConflict(event.Name, containing)
+			watch = containing[0]
+		}
 		logger.WithField("watch", watch.Path).Debug("Found .rewind watch")
 
 		if watch.ShouldIgnore(event.Name) {
 			logger.Info("Found in ignore list. Ignoring.")
+			wm.trace(event.Name, "Matches an ignore pattern, not capturing", nil)
 			return
 		}
 
+		// A rollback is writing this file; don't capture its writes. Once it
+		// has finished, a file that is really gone is still recorded as deleted.
+		if held, inGrace := checkFileLock(watch.Path, event.Name); held || inGrace {
+			if _, err := os.Stat(event.Name); held || err == nil {
+				logger.Debug("File is locked by a rollback. Ignoring.")
+				wm.trace(event.Name, "Locked by a rollback, not capturing", nil)
+				return
+			}
+		}
+
 		switch {
 		case event.Op&fsnotify.Create == fsnotify.Create:
 			logger.Debug("File created")
@@ -146,6 +257,8 @@ func (wm *WatchManager) handleEvent(event fsnotify.Event) {
 			logger.Debug("File permissions changed")
 			wm.handleChmod(event.Name, watch)
 		}
+	} else {
+		wm.trace(event.Name, "Not in a watched project, not capturing", nil)
 	}
 
 	return
@@ -175,6 +288,10 @@ func (wm *WatchManager) handleCreate(path string, watch *Watch) {
 			app.Logger.WithError(err).Warn("Failed to add new directory to watch")
 		}
 
+		if wm.config().TrackDirectories {
+			wm.recordDirectory(watch, path, relPath)
+		}
+
 		app.Logger.WithField("watch", watch.Path).WithField("directory", relPath).Info("Added folder to watch list")
 	} else {
 		relPath, err := filepath.Rel(watch.Path, path)
@@ -183,11 +300,133 @@ func (wm *WatchManager) handleCreate(path string, watch *Watch) {
 			return
 		}
 
+		if wm.config().CaptureOn == CaptureOnClose {
+			wm.trace(path, "Waiting for the save to finish before capturing", logrus.Fields{"quiet_period": wm.config().CaptureQuietPeriod.String()})
+			wm.scheduleSave(path, relPath, watch, database.EventOpCreate)
+			return
+		}
+
+		if wm.config().CreateGracePeriod > 0 {
+			wm.trace(path, "Waiting out the create grace period before capturing", logrus.Fields{"grace_period": wm.config().CreateGracePeriod.String()})
+			wm.scheduleCreate(path, relPath, watch)
+			return
+		}
+
 		app.Logger.WithField("path", relPath).Info("File created - processing as potential edit")
-		wm.ProcessFile(path, relPath, watch)
+		wm.ProcessFile(path, relPath, watch, database.EventOpCreate)
+	}
+}
+
+// recordDirectory records that a directory exists in the watch's database
+func (wm *WatchManager) recordDirectory(watch *Watch, path, relPath string) {
+	if wm.DryRun {
+		app.Logger.WithField("path", relPath).Debug("Would record directory")
+		return
+	}
+
+	db, err := database.NewDatabaseManager(watch.Path)
+	if err != nil {
+		app.Logger.WithError(err).Warn("Could not initialise database for directory")
+		return
+	}
+
+	if err := db.Connect(); err != nil {
+		app.Logger.WithError(err).Warn("Could not connect to database for directory")
+		return
+	}
+	defer db.Close()
+
+	if err := db.RecordDirectory(path); err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to record directory")
 	}
 }
 
+// scheduleCreate captures a newly created file once the create grace period
+// has passed, unless it has been deleted by then
+func (wm *WatchManager) scheduleCreate(path, relPath string, watch *Watch) {
+	wm.pendingMu.Lock()
+	defer wm.pendingMu.Unlock()
+
+	if _, pending := wm.pendingCreates[path]; pending {
+		return
+	}
+
+	app.Logger.WithField("path", relPath).Debug("File created - waiting for grace period before capture")
+
+	wm.pendingCreates[path] = time.AfterFunc(wm.config().CreateGracePeriod, func() {
+		wm.pendingMu.Lock()
+		delete(wm.pendingCreates, path)
+		wm.pendingMu.Unlock()
+
+		if wm.ctx.Err() != nil {
+			return
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			app.Logger.WithField("path", relPath).Debug("Created file no longer exists, not capturing")
+			wm.trace(path, "Deleted within the create grace period, not capturing", nil)
+			return
+		}
+
+		app.Logger.WithField("path", relPath).Info("File created - processing as potential edit")
+		wm.ProcessFile(path, relPath, watch, database.EventOpCreate)
+	})
+}
+
+// scheduleThrottled captures a throttled file once its throttle lifts at
+// until, so the last change skipped while it was throttled isn't lost. A file
+// throttled several times over gets a single capture.
+func (wm *WatchManager) scheduleThrottled(path, relPath string, watch *Watch, op string, until time.Time) {
+	wm.pendingMu.Lock()
+	defer wm.pendingMu.Unlock()
+
+	if _, pending := wm.pendingRetries[path]; pending {
+		return
+	}
+
+	wm.pendingRetries[path] = time.AfterFunc(time.Until(until), func() {
+		wm.pendingMu.Lock()
+		delete(wm.pendingRetries, path)
+		wm.pendingMu.Unlock()
+
+		if wm.ctx.Err() != nil {
+			return
+		}
+
+		if _, err := os.Stat(path); err != nil {
+			app.Logger.WithField("path", relPath).Debug("Throttled file no longer exists, not capturing")
+			return
+		}
+
+		app.Logger.WithField("path", relPath).Info("Capturing changes skipped while the file was throttled")
+		wm.ProcessFile(path, relPath, watch, op)
+	})
+}
+
+// isPendingCreate reports whether path is waiting out the create grace period
+func (wm *WatchManager) isPendingCreate(path string) bool {
+	wm.pendingMu.Lock()
+	defer wm.pendingMu.Unlock()
+
+	_, pending := wm.pendingCreates[path]
+	return pending
+}
+
+// cancelPendingCreate stops the delayed capture of path, reporting whether
+// one was pending
+func (wm *WatchManager) cancelPendingCreate(path string) bool {
+	wm.pendingMu.Lock()
+	defer wm.pendingMu.Unlock()
+
+	timer, pending := wm.pendingCreates[path]
+	if !pending {
+		return false
+	}
+	timer.Stop()
+	delete(wm.pendingCreates, path)
+	return true
+}
+
 func (wm *WatchManager) handleWrite(path string, watch *Watch) {
 
 	relPath, err := filepath.Rel(watch.Path, path)
@@ -196,26 +435,48 @@ func (wm *WatchManager) handleWrite(path string, watch *Watch) {
 		return
 	}
 
+	// The delayed capture of a new file picks up these writes
+	if wm.isPendingCreate(path) {
+		app.Logger.WithField("path", relPath).Debug("File modified during create grace period")
+		wm.trace(path, "Written during the create grace period, the delayed capture will include it", nil)
+		return
+	}
+
+	if wm.config().CaptureOn == CaptureOnClose {
+		wm.trace(path, "Waiting for the save to finish before capturing", logrus.Fields{"quiet_period": wm.config().CaptureQuietPeriod.String()})
+		wm.scheduleSave(path, relPath, watch, database.EventOpWrite)
+		return
+	}
+
 	app.Logger.WithField("path", relPath).Info("File modified - processing as potential edit")
-	wm.ProcessFile(path, relPath, watch)
+	wm.ProcessFile(path, relPath, watch, database.EventOpWrite)
 }
 
-func (wm *WatchManager) handleRemove(path string, watch *Watch) {
+// handleRemove records the deletion of a tracked file or directory and
+// reports whether a file was marked as deleted
+func (wm *WatchManager) handleRemove(path string, watch *Watch) bool {
 	relPath, err := filepath.Rel(watch.Path, path)
 	if err != nil {
 		app.Logger.WithField("path", path).WithField("error", err).Warn("Failed to get relative path for removed file")
-		return
+		return false
+	}
+
+	if wm.cancelPendingCreate(path) {
+		app.Logger.WithField("path", relPath).Info("File removed within create grace period - capture cancelled")
+	}
+	if wm.cancelPendingSave(path) {
+		app.Logger.WithField("path", relPath).Debug("File removed while waiting for its save to finish - capture cancelled")
 	}
 
 	db, err := database.NewDatabaseManager(watch.Path)
 	if err != nil {
 		app.Logger.WithError(err).Warn("Could not initialise database for removed file")
-		return
+		return false
 	}
 
 	if err := db.Connect(); err != nil {
 		app.Logger.WithError(err).Warn("Could not connect to database for removed file")
-		return
+		return false
 	}
 	defer db.Close()
 
@@ -223,21 +484,44 @@ func (wm *WatchManager) handleRemove(path string, watch *Watch) {
 	latestVersion, err := db.GetLatestFileVersion(path)
 	if err != nil {
 		app.Logger.WithField("path", relPath).WithError(err).Error("Failed to check for existing file in database")
-		return
+		return false
 	}
 
 	if latestVersion == nil {
+		if wm.config().TrackDirectories && !wm.DryRun {
+			if marked, err := db.MarkDirectoryDeleted(path); err != nil {
+				app.Logger.WithField("path", relPath).WithError(err).Error("Failed to mark directory as deleted in database")
+			} else if marked {
+				app.Logger.WithField("path", relPath).Info("Directory marked as deleted in database")
+				return false
+			}
+		}
+
 		app.Logger.WithField("path", relPath).Debug("File not tracked in database, ignoring deletion")
-		return
+		wm.trace(path, "Removed file has no versions, nothing to record", nil)
+		return false
+	}
+
+	if wm.DryRun {
+		app.Logger.WithField("path", relPath).WithField("version", latestVersion.VersionNumber).Info("Would mark file as deleted")
+		return false
 	}
 
 	// Mark the latest version as deleted instead of creating a new entry
 	if err := db.MarkFileDeleted(path); err != nil {
 		app.Logger.WithField("path", relPath).WithError(err).Error("Failed to mark file as deleted in database")
-		return
+		return false
 	}
 
 	app.Logger.WithField("path", relPath).WithField("version", latestVersion.VersionNumber).Info("File marked as deleted in database")
+	wm.trace(path, "Recorded as deleted", logrus.Fields{"version": latestVersion.VersionNumber})
+	wm.auditLog().Record(app.AuditDelete, path, latestVersion.VersionNumber, latestVersion.FileHash, latestVersion.FileSize)
+	wm.recordChange(ChangeDelete, watch.Path, relPath, latestVersion.VersionNumber, "")
+
+	if wm.config().NotifyOnDelete {
+		notifyDeleted(watch.Path, relPath)
+	}
+	return true
 }
 
 func (wm *WatchManager) handleRename(path string, watch *Watch) {
@@ -247,15 +531,28 @@ func (wm *WatchManager) handleRename(path string, watch *Watch) {
 		return
 	}
 
-	// Check if the file still exists at the new location
+	// Check if the file still exists at the new location. If not, this is
+	// the old name; remember it so the new name can be linked to its history.
 	if _, err := os.Stat(path); err != nil {
-		app.Logger.WithField("path", relPath).Debug("Renamed file no longer exists, ignoring")
+		app.Logger.WithField("path", relPath).Debug("Renamed file no longer exists, waiting for its new name")
+		wm.trace(path, "Renamed away, waiting for its new name", nil)
+
+		db, err := database.NewDatabaseManager(watch.Path)
+		if err != nil {
+			return
+		}
+		defer db.Close()
+		if err := db.Connect(); err != nil {
+			return
+		}
+
+		wm.rememberRename(db, path, relPath, watch)
 		return
 	}
 
 	// Treat rename as a new file creation - use existing ProcessFile logic
 	app.Logger.WithField("path", relPath).Info("File renamed - processing as new file")
-	wm.ProcessFile(path, relPath, watch)
+	wm.ProcessFile(path, relPath, watch, database.EventOpRename)
 }
 
 func (wm *WatchManager) handleChmod(path string, watch *Watch) {
@@ -279,11 +576,50 @@ func (wm *WatchManager) handleChmod(path string, watch *Watch) {
 	if latestVersion, err := db.GetLatestFileVersion(path); err == nil && latestVersion != nil {
 		relPath, _ := filepath.Rel(watch.Path, path)
 		app.Logger.WithField("path", relPath).Info("CHMOD on tracked file - checking for changes")
-		wm.ProcessFile(path, relPath, watch)
+		wm.ProcessFile(path, relPath, watch, database.EventOpWrite)
+	} else {
+		wm.trace(path, "Permissions changed on a file with no versions, not capturing", nil)
 	}
 }
 
-func (wm *WatchManager) ProcessFile(filePath, relPath string, watch *Watch) (string, error) {
+// ProcessFile captures a file in response to an event. Failures are logged
+// and kept for status as well as returned.
+func (wm *WatchManager) ProcessFile(filePath, relPath string, watch *Watch, op string) (string, error) {
+	action, err := wm.processFile(filePath, relPath, watch, op, false)
+	if err != nil {
+		wm.recordCaptureError(watch, relPath, err)
+	}
+	if err != nil {
+		wm.trace(filePath, "Capture failed", logrus.Fields{"op": op, "error": err.Error()})
+	} else {
+		wm.trace(filePath, "Capture finished", logrus.Fields{"op": op, "result": action})
+	}
+	return action, err
+}
+
+// processFile captures filePath if it is new or has changed, recording op as
+// the operation that caused the capture. The first version of a file is
+// always recorded as a create unless it arrived by a rename. During a scan,
+// new files older than the baseline age are recorded without storing a copy.
+func (wm *WatchManager) processFile(filePath, relPath string, watch *Watch, op string, scan bool) (string, error) {
+	defer wm.projects.lock(watch.Path)()
+
+	// Files such as build artifacts can be deleted between being found and
+	// being read. That is not a failure: their deletion is handled separately.
+	fileInfo, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		app.Logger.WithField("path", relPath).Debug("File vanished before it could be captured")
+		return "vanished", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if capture, reason := wm.CaptureFilter(filePath, fileInfo); !capture {
+		app.Logger.WithField("path", relPath).WithField("reason", reason).Debug("File excluded by capture filter - skipping")
+		wm.trace(filePath, "Excluded by the capture filter", logrus.Fields{"reason": reason})
+		return "excluded", nil
+	}
 
 	db, err := database.NewDatabaseManager(watch.Path)
 	if err != nil {
@@ -296,28 +632,83 @@ func (wm *WatchManager) ProcessFile(filePath, relPath string, watch *Watch) (str
 		return "", fmt.Errorf("Could not connect to database: %w", err)
 	}
 
-	fileInfo, err := os.Stat(filePath)
+	latestVersion, err := db.GetLatestFileVersion(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to stat file: %w", err)
+		return "", fmt.Errorf("failed to get latest file version: %w", err)
 	}
 
-	currentHash, err := database.CalculateFileHash(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to calculate file hash: %w", err)
+	// A file that will be stored whatever its content is hashed as it is
+	// copied, so it is only read once. Otherwise the hash decides whether
+	// it is stored at all.
+	algorithm := wm.hashAlgorithm(db)
+	currentHash := ""
+	if !wm.hashWhileStoring(watch, fileInfo, latestVersion, scan) {
+		hashStart := time.Now()
+		if latestVersion != nil {
+			currentHash, err = latestVersion.HashFile(filePath)
+		} else {
+			currentHash, err = database.CalculateFileHashWith(filePath, algorithm)
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			app.Logger.WithField("path", relPath).Debug("File vanished before it could be captured")
+			return "vanished", nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to calculate file hash: %w", err)
+		}
+		wm.metrics.observe(StageHash, time.Since(hashStart))
 	}
 
-	latestVersion, err := db.GetLatestFileVersion(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to get latest file version: %w", err)
-	}
+	wm.traceHashes(filePath, currentHash, algorithm, latestVersion)
 
 	if latestVersion == nil {
+		// A new file with the content of a file just renamed away is that file
+		renamedFrom := ""
+		if !scan && currentHash != "" {
+			renamedFrom = wm.takePendingRename(watch, currentHash)
+		}
+		if renamedFrom != "" {
+			op = database.EventOpRename
+		} else if op != database.EventOpRename {
+			op = database.EventOpCreate
+		}
+
+		if wm.isBaseline(fileInfo, scan) {
+			app.Logger.WithField("path", relPath).Info("Old file found during scan - recording baseline")
+
+			if wm.DryRun {
+				app.Logger.WithField("path", relPath).WithField("size", fileInfo.Size()).Info("Would record baseline")
+				return "baseline", nil
+			}
+
+			versionNumber, err := addBaselineToDatabase(db, filePath, relPath, currentHash, algorithm, fileInfo, op)
+			if err != nil {
+				return "", fmt.Errorf("failed to add baseline to database: %w", err)
+			}
+			wm.recordChange(ChangeBaseline, watch.Path, relPath, versionNumber, op)
+
+			return "baseline", nil
+		}
+
+		if ok, until := wm.limiter.allow(watch.Path, filePath, relPath); !ok {
+			wm.scheduleThrottled(filePath, relPath, watch, op, until)
+			return "throttled", nil
+		}
+
 		app.Logger.WithField("path", relPath).Info("New file found during scan")
 
-		if err := wm.addFileToDatabase(db, watch.Path, filePath, relPath, currentHash, fileInfo); err != nil {
+		if err := wm.addFileToDatabase(db, watch.Path, filePath, relPath, currentHash, fileInfo, op, nil); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				app.Logger.WithField("path", relPath).Debug("File vanished before it could be captured")
+				return "vanished", nil
+			}
 			return "", fmt.Errorf("failed to add new file to database: %w", err)
 		}
 
+		if renamedFrom != "" {
+			wm.linkRename(db, filePath, relPath, renamedFrom, watch)
+		}
+
 		return "new", nil
 	}
 
@@ -327,36 +718,172 @@ func (wm *WatchManager) ProcessFile(filePath, relPath string, watch *Watch) (str
 		return "unchanged", nil
 	}
 
+	wm.checkHardlinkModifiedInPlace(watch.Path, filePath, relPath, fileInfo, latestVersion)
+
+	// A change of hash algorithm takes effect with the file's next version,
+	// which is hashed again as it is stored
+	if latestVersion.HashAlgorithm != algorithm {
+		currentHash = ""
+	}
+
+	// Editors often save by creating a new file over the old one, which is
+	// still an edit of a tracked file
+	if op == database.EventOpCreate {
+		op = database.EventOpWrite
+	}
+
+	if ok, until := wm.limiter.allow(watch.Path, filePath, relPath); !ok {
+		wm.scheduleThrottled(filePath, relPath, watch, op, until)
+		return "throttled", nil
+	}
+
+	var match *database.FileVersion
+	if currentHash != "" {
+		match = findEarlierVersion(db, filePath, relPath, currentHash)
+	}
+
 	// File has changed - add new version
 	app.Logger.WithField("path", relPath).Info("File changed - adding new version")
-	if err := wm.addFileToDatabase(db, watch.Path, filePath, relPath, currentHash, fileInfo); err != nil {
+	if err := wm.addFileToDatabase(db, watch.Path, filePath, relPath, currentHash, fileInfo, op, match); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			app.Logger.WithField("path", relPath).Debug("File vanished before it could be captured")
+			return "vanished", nil
+		}
 		return "", fmt.Errorf("failed to add updated file to database: %w", err)
 	}
 
 	return "updated", nil
 }
 
-func (wm *WatchManager) addFileToDatabase(db *database.DatabaseManager, rootPath, filePath, relPath, fileHash string, fileInfo os.FileInfo) error {
+// hashAlgorithm returns the algorithm new versions in db are hashed with.
+// Objects in the content-addressable layout are named by their SHA256 hash,
+// so that layout always uses it.
+func (wm *WatchManager) hashAlgorithm(db *database.DatabaseManager) string {
+	if layout, err := db.StorageLayout(); err == nil && layout == database.LayoutContentAddressable {
+		return database.HashSHA256
+	}
+	if wm.config().HashAlgorithm == "" {
+		return database.HashSHA256
+	}
+	return wm.config().HashAlgorithm
+}
+
+// isBaseline reports whether a new file found by a scan is old enough to be
+// recorded as a baseline rather than stored
+func (wm *WatchManager) isBaseline(fileInfo os.FileInfo, scan bool) bool {
+	return scan && wm.config().BaselineOlderThan > 0 && time.Since(fileInfo.ModTime()) > wm.config().BaselineOlderThan
+}
+
+// hashWhileStoring reports whether a file can be hashed as it is copied into
+// storage rather than read once for its hash and again to store it. That
+// needs copy storage, and a file certain to be stored: a new file that is not
+// a baseline and cannot be matched to a rename, or one whose size changed.
+func (wm *WatchManager) hashWhileStoring(watch *Watch, fileInfo os.FileInfo, latestVersion *database.FileVersion, scan bool) bool {
+	if wm.config().StorageMode != StorageModeCopy {
+		return false
+	}
+	if latestVersion != nil {
+		return fileInfo.Size() != latestVersion.FileSize
+	}
+	if wm.isBaseline(fileInfo, scan) {
+		return false
+	}
+	return scan || !wm.hasPendingRename(watch)
+}
+
+// findEarlierVersion returns the latest version of a file with the given
+// content, if any. A file changed back to earlier content, such as a manual
+// revert, is still a new version, but worth pointing out.
+func findEarlierVersion(db *database.DatabaseManager, filePath, relPath, fileHash string) *database.FileVersion {
+	match, err := db.FindVersionByHash(filePath, fileHash)
+	if err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to look for an earlier version with the same content")
+	}
+	if match != nil {
+		app.Logger.WithFields(logrus.Fields{
+			"path":            relPath,
+			"matches_version": match.VersionNumber,
+		}).Info("File changed back to the content of an earlier version")
+	}
+	return match
+}
+
+// addFileToDatabase stores the file as a new version. match is an earlier
+// version with the same content, if any, whose stored copy may be reused. An
+// empty fileHash means the file has not been hashed yet: it is copied into
+// storage and hashed in the same read.
+func (wm *WatchManager) addFileToDatabase(db *database.DatabaseManager, rootPath, filePath, relPath, fileHash string, fileInfo os.FileInfo, op string, match *database.FileVersion) error {
+	captureStart := time.Now()
 
 	versionNumber, err := db.GetNextVersionNumber(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to get next version number: %w", err)
 	}
 
+	if wm.DryRun {
+		app.Logger.WithFields(logrus.Fields{
+			"path":    relPath,
+			"version": versionNumber,
+			"size":    fileInfo.Size(),
+			"op":      op,
+		}).Info("Would capture file")
+		return nil
+	}
+
+	layout, err := db.StorageLayout()
+	if err != nil {
+		return err
+	}
+	objects := layout == database.LayoutContentAddressable
+	algorithm := wm.hashAlgorithm(db)
+
 	// Create storage path
 	storagePath := db.CreateStoragePath(filePath, versionNumber)
 	fullStoragePath := filepath.Join(rootPath, ".rewind", "versions", storagePath)
 
 	// Create storage directory if it doesn't exist (this now creates the full directory structure)
-	storageDir := filepath.Dir(fullStoragePath)
-	if err := os.MkdirAll(storageDir, 0755); err != nil {
-		return fmt.Errorf("failed to create storage directory: %w", err)
+	if !objects || wm.config().StorageMode == StorageModeDelta {
+		storageDir := filepath.Dir(fullStoragePath)
+		if err := os.MkdirAll(storageDir, 0755); err != nil {
+			return fmt.Errorf("failed to create storage directory: %w", err)
+		}
 	}
 
-	// Copy file to storage location
-	if err := wm.copyFile(filePath, fullStoragePath); err != nil {
-		return fmt.Errorf("failed to copy file to storage: %w", err)
+	// Store a patch in delta mode, otherwise copy (or link) the file, or
+	// store it as an object in the content-addressable layout
+	copyStart := time.Now()
+	storageType := database.StorageTypeFull
+	switch {
+	case objects && wm.storeDelta(db, filePath, relPath, fileHash, algorithm, fullStoragePath):
+		storageType = database.StorageTypeDelta
+	case objects:
+		hashed := fileHash != ""
+		storagePath, fileHash, err = wm.storeObject(db, filePath, fileHash)
+		if err != nil {
+			return fmt.Errorf("failed to copy file to storage: %w", err)
+		}
+		fullStoragePath = filepath.Join(db.VersionsDir(), storagePath)
+		if !hashed {
+			findEarlierVersion(db, filePath, relPath, fileHash)
+		}
+	case fileHash == "":
+		hash, err := wm.copyFileHashing(filePath, fullStoragePath, algorithm)
+		if err != nil {
+			os.Remove(fullStoragePath)
+			return fmt.Errorf("failed to copy file to storage: %w", err)
+		}
+		fileHash = hash
+		findEarlierVersion(db, filePath, relPath, fileHash)
+	case wm.reuseStoredVersion(db, relPath, match, fullStoragePath):
+		// Shares the stored copy of the matching version
+	case wm.storeDelta(db, filePath, relPath, fileHash, algorithm, fullStoragePath):
+		storageType = database.StorageTypeDelta
+	default:
+		if err := wm.storeFile(filePath, fullStoragePath); err != nil {
+			return fmt.Errorf("failed to copy file to storage: %w", err)
+		}
 	}
+	wm.metrics.observe(StageCopy, time.Since(copyStart))
 
 	// Create file version record
 	fileVersion := &database.FileVersion{
@@ -364,69 +891,501 @@ func (wm *WatchManager) addFileToDatabase(db *database.DatabaseManager, rootPath
 		VersionNumber: versionNumber,
 		Timestamp:     time.Now(),
 		FileHash:      fileHash,
+		HashAlgorithm: algorithm,
 		FileSize:      fileInfo.Size(),
 		StoragePath:   storagePath,
+		StorageType:   storageType,
+		EventOp:       op,
+	}
+	if wm.config().CaptureOwnership {
+		fileVersion.Owner = database.FileOwner(fileInfo)
+	}
+	if wm.config().CaptureXattrs {
+		xattrs, err := database.FileXattrs(filePath)
+		if err != nil {
+			app.Logger.WithField("path", filePath).WithError(err).Warn("Failed to read extended attributes, capturing without them")
+		}
+		fileVersion.Xattrs = xattrs
 	}
 
 	// Add to database
+	dbStart := time.Now()
 	if err := db.AddFileVersion(fileVersion); err != nil {
-		// Clean up the file if database insertion fails
-		os.Remove(fullStoragePath)
+		// Clean up the file if database insertion fails, unless it is an
+		// object other versions may share
+		if !database.IsObjectPath(storagePath) {
+			os.Remove(fullStoragePath)
+		}
 		return fmt.Errorf("failed to add file version to database: %w", err)
 	}
+	wm.metrics.observe(StageDB, time.Since(dbStart))
+	wm.metrics.observe(StageTotal, time.Since(captureStart))
 
 	app.Logger.WithFields(logrus.Fields{
 		"path":        relPath,
 		"version":     versionNumber,
 		"size":        fileInfo.Size(),
 		"storagePath": storagePath,
+		"storageType": storageType,
+		"op":          op,
 	}).Info("File version added to database")
+	wm.auditLog().Record(app.AuditCapture, filePath, versionNumber, fileHash, fileInfo.Size())
+	wm.recordChange(ChangeCapture, rootPath, relPath, versionNumber, op)
+
+	wm.enforceVersionCap(db, filePath, relPath)
+	wm.scheduleSizeCheck(db.RootDir())
 
 	return nil
 }
 
+// enforceVersionCap purges the oldest untagged versions of a file beyond the
+// configured maximum. Failures are logged; the capture itself has succeeded.
+func (wm *WatchManager) enforceVersionCap(db *database.DatabaseManager, filePath, relPath string) {
+	if wm.config().MaxVersionsPerFile <= 0 {
+		return
+	}
+
+	versionIDs, err := db.GetVersionsForPurgeForFile(filePath, wm.config().MaxVersionsPerFile)
+	if err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to find versions over the per-file cap")
+		return
+	}
+	if len(versionIDs) == 0 {
+		return
+	}
+
+	if err := db.RemoveVersions(versionIDs); err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to purge versions over the per-file cap")
+		return
+	}
+
+	app.Logger.WithFields(logrus.Fields{
+		"path":   relPath,
+		"purged": len(versionIDs),
+		"cap":    wm.config().MaxVersionsPerFile,
+	}).Info("Purged oldest versions over the per-file cap")
+}
+
+// sizeCheckDelay is how long after a capture its project is checked against
+// the total size limit
+const sizeCheckDelay = 2 * time.Second
+
+// scheduleSizeCheck checks the project at root against the total size limit
+// once sizeCheckDelay has passed. Captures made meanwhile, such as the rest of
+// a scan, share the one check, as measuring the store reads every version.
+func (wm *WatchManager) scheduleSizeCheck(root string) {
+	if wm.config().MaxTotalSize <= 0 {
+		return
+	}
+
+	wm.pendingMu.Lock()
+	defer wm.pendingMu.Unlock()
+
+	if _, pending := wm.sizeChecks[root]; pending {
+		return
+	}
+
+	wm.sizeChecks[root] = time.AfterFunc(sizeCheckDelay, func() {
+		wm.pendingMu.Lock()
+		delete(wm.sizeChecks, root)
+		wm.pendingMu.Unlock()
+
+		if wm.ctx.Err() != nil {
+			return
+		}
+		wm.checkTotalSize(root)
+	})
+}
+
+// checkTotalSize holds off the project's captures while it enforces the
+// total size limit
+func (wm *WatchManager) checkTotalSize(root string) {
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		app.Logger.WithError(err).Warn("Could not initialise database for total size limit")
+		return
+	}
+	if err := db.Connect(); err != nil {
+		app.Logger.WithError(err).Warn("Could not connect to database for total size limit")
+		return
+	}
+	defer db.Close()
+
+	defer wm.projects.lock(root)()
+	wm.enforceTotalSize(db)
+}
+
+// enforceTotalSize purges the project's oldest untagged versions while its
+// stored size is over the configured maximum, always leaving each file at
+// least one version. Failures are logged; the capture itself has succeeded.
+func (wm *WatchManager) enforceTotalSize(db *database.DatabaseManager) {
+	if wm.config().MaxTotalSize <= 0 {
+		return
+	}
+
+	logger := app.Logger.WithField("project", db.RootDir())
+	before, err := db.GetStoredSize("")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to measure the project against the total size limit")
+		return
+	}
+	if before <= wm.config().MaxTotalSize {
+		return
+	}
+
+	versionIDs, err := db.GetVersionsForPurgeBySize(wm.config().MaxTotalSize, "")
+	if err != nil {
+		logger.WithError(err).Warn("Failed to find versions over the total size limit")
+		return
+	}
+	if len(versionIDs) == 0 {
+		return
+	}
+
+	if err := db.RemoveVersions(versionIDs); err != nil {
+		logger.WithError(err).Warn("Failed to purge versions over the total size limit")
+		return
+	}
+
+	after, err := db.GetStoredSize("")
+	if err != nil {
+		after = before
+	}
+
+	logger.WithFields(logrus.Fields{
+		"purged":    len(versionIDs),
+		"reclaimed": before - after,
+		"limit":     wm.config().MaxTotalSize,
+	}).Info("Purged oldest versions over the total size limit")
+}
+
+// addBaselineToDatabase records the first version of a file without storing a
+// copy of it, and returns its version number
+func addBaselineToDatabase(db *database.DatabaseManager, filePath, relPath, fileHash, algorithm string, fileInfo os.FileInfo, op string) (int, error) {
+	versionNumber, err := db.GetNextVersionNumber(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next version number: %w", err)
+	}
+
+	fileVersion := &database.FileVersion{
+		FilePath:      relPath,
+		VersionNumber: versionNumber,
+		Timestamp:     time.Now(),
+		FileHash:      fileHash,
+		HashAlgorithm: algorithm,
+		FileSize:      fileInfo.Size(),
+		EventOp:       op,
+	}
+
+	if err := db.AddFileVersion(fileVersion); err != nil {
+		return 0, fmt.Errorf("failed to add file version to database: %w", err)
+	}
+
+	return versionNumber, nil
+}
+
+// sizeAllowed reports whether a file of size bytes is within the configured
+// size range
+func (wm *WatchManager) sizeAllowed(size int64) bool {
+	if size < wm.config().MinFileSize {
+		return false
+	}
+	return wm.config().MaxFileSize <= 0 || size <= wm.config().MaxFileSize
+}
+
+// reuseStoredVersion links dst to the stored copy of match, an earlier
+// version with the same content, rather than storing the file again. Only
+// hardlink mode shares stored files, and only an intact full copy is reused.
+func (wm *WatchManager) reuseStoredVersion(db *database.DatabaseManager, relPath string, match *database.FileVersion, dst string) bool {
+	if wm.config().StorageMode != StorageModeHardlink || match == nil {
+		return false
+	}
+	if match.IsBaseline() || match.IsDelta() || match.IsCompressed() {
+		return false
+	}
+
+	src := filepath.Join(db.VersionsDir(), match.StoragePath)
+	if hash, err := match.HashFile(src); err != nil || hash != match.FileHash {
+		return false
+	}
+	if err := os.Link(src, dst); err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Debug("Failed to link to the matching version, storing the file")
+		return false
+	}
+
+	app.Logger.WithFields(logrus.Fields{
+		"path":            relPath,
+		"matches_version": match.VersionNumber,
+	}).Debug("Reused the stored copy of the matching version")
+	return true
+}
+
+// storeObject stores filePath as a content-addressable object named by its
+// hash, unless an object with the same content is already stored, and returns
+// the object's storage path and hash. With no fileHash the file is hashed as
+// it is copied. Objects are shared, so they are always copies rather than
+// hardlinks that a later edit of the file would change.
+func (wm *WatchManager) storeObject(db *database.DatabaseManager, filePath, fileHash string) (string, string, error) {
+	if fileHash != "" {
+		storagePath := database.ObjectPath(fileHash)
+		if _, err := os.Stat(filepath.Join(db.VersionsDir(), storagePath)); err == nil {
+			return storagePath, fileHash, nil
+		}
+	}
+
+	if err := os.MkdirAll(db.ObjectsDir(), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	temp, err := os.CreateTemp(db.ObjectsDir(), "incoming-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create storage file: %w", err)
+	}
+	temp.Close()
+	tempPath := temp.Name()
+
+	// The file may have changed since it was hashed, so the object is named
+	// by the content actually copied
+	hash, err := wm.copyFileHashing(filePath, tempPath, database.HashSHA256)
+	if err != nil {
+		os.Remove(tempPath)
+		return "", "", err
+	}
+
+	storagePath := database.ObjectPath(hash)
+	fullPath := filepath.Join(db.VersionsDir(), storagePath)
+	if _, err := os.Stat(fullPath); err == nil {
+		os.Remove(tempPath)
+		return storagePath, hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		os.Remove(tempPath)
+		return "", "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := os.Rename(tempPath, fullPath); err != nil {
+		os.Remove(tempPath)
+		return "", "", fmt.Errorf("failed to move object into place: %w", err)
+	}
+
+	return storagePath, hash, nil
+}
+
+// storeFile places a version of src at dst, hardlinking when the storage mode
+// allows it and falling back to a copy across devices or on any link failure
+func (wm *WatchManager) storeFile(src, dst string) error {
+	if wm.config().StorageMode != StorageModeHardlink || wm.isCopyOnly(src) {
+		return wm.copyFile(src, dst)
+	}
+
+	err := os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+
+	logger := app.Logger.WithField("path", src).WithError(err)
+	if errors.Is(err, syscall.EXDEV) {
+		logger.Debug("Store is on a different device, falling back to copy")
+	} else {
+		logger.Warn("Failed to hardlink version, falling back to copy")
+	}
+
+	return wm.copyFile(src, dst)
+}
+
+// storeDelta writes a text file to dst as a patch against its latest version
+// when delta storage is enabled. It reports false when the version should be
+// stored in full instead: the file or its previous version is not text, the
+// chain since the last keyframe is full, or the patch would not be smaller.
+func (wm *WatchManager) storeDelta(db *database.DatabaseManager, filePath, relPath, fileHash, algorithm, dst string) bool {
+	if wm.config().StorageMode != StorageModeDelta {
+		return false
+	}
+
+	latestVersion, err := db.GetLatestFileVersion(filePath)
+	if err != nil || latestVersion == nil || latestVersion.IsBaseline() {
+		return false
+	}
+
+	chain, err := db.GetDeltaChain(latestVersion)
+	if err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Cannot rebuild previous version, storing in full")
+		return false
+	}
+	if len(chain) >= wm.config().DeltaKeyframeInterval {
+		return false
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil || !database.IsText(content) {
+		return false
+	}
+	// The file changed since it was hashed; a patch of it would not match
+	if hash, err := database.HashBytes(algorithm, content); err != nil || hash != fileHash {
+		return false
+	}
+
+	base, err := db.ReadVersionContent(latestVersion)
+	if err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Cannot rebuild previous version, storing in full")
+		return false
+	}
+	if !database.IsText(base) {
+		return false
+	}
+
+	patch, err := database.CreatePatch(string(base), string(content))
+	if err != nil || len(patch) >= len(content) {
+		return false
+	}
+
+	if err := wm.writeStoredFile(dst, patch); err != nil {
+		app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to store delta, storing in full")
+		os.Remove(dst)
+		return false
+	}
+
+	return true
+}
+
+// writeStoredFile writes data to dst, syncing it unless deferred to the end
+// of a scan
+func (wm *WatchManager) writeStoredFile(dst string, data []byte) error {
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer destFile.Close()
+
+	if _, err := destFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write destination file: %w", err)
+	}
+
+	if wm.config().Fsync {
+		if err := destFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync destination file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// checkHardlinkModifiedInPlace detects a working file that still shares an
+// inode with its latest stored version. Editors normally replace files on
+// save, which breaks the link, but an in-place write also rewrites the stored
+// version. The file is switched to copy mode so later versions stay intact.
+func (wm *WatchManager) checkHardlinkModifiedInPlace(rootPath, filePath, relPath string, fileInfo os.FileInfo, latestVersion *database.FileVersion) {
+	if latestVersion.IsBaseline() {
+		return
+	}
+
+	storedPath := filepath.Join(rootPath, ".rewind", "versions", latestVersion.StoragePath)
+	storedInfo, err := os.Stat(storedPath)
+	if err != nil || !os.SameFile(fileInfo, storedInfo) {
+		return
+	}
+
+	app.Logger.WithFields(logrus.Fields{
+		"path":    relPath,
+		"version": latestVersion.VersionNumber,
+	}).Error("Hardlinked file was modified in place - the stored version now holds the new content. Using copy mode for this file from now on")
+
+	wm.linkMu.Lock()
+	wm.copyOnly[filePath] = true
+	wm.linkMu.Unlock()
+}
+
+func (wm *WatchManager) isCopyOnly(filePath string) bool {
+	wm.linkMu.Lock()
+	defer wm.linkMu.Unlock()
+	return wm.copyOnly[filePath]
+}
+
 // copyFile copies a file from src to dst
 func (wm *WatchManager) copyFile(src, dst string) error {
+	_, err := wm.copyFileHashing(src, dst, database.HashSHA256)
+	return err
+}
+
+// copyFileHashing copies a file from src to dst, returning the hash of the
+// content copied calculated with algorithm
+func (wm *WatchManager) copyFileHashing(src, dst, algorithm string) (string, error) {
 	sourceFile, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
+		return "", fmt.Errorf("failed to open source file: %w", err)
 	}
 	defer sourceFile.Close()
 
 	destFile, err := os.Create(dst)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return "", fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		return fmt.Errorf("failed to copy file contents: %w", err)
+	hash, err := database.CopyAndHashWith(destFile, sourceFile, algorithm)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy file contents: %w", err)
 	}
 
-	// Sync to ensure data is written to disk
-	if err := destFile.Sync(); err != nil {
-		return fmt.Errorf("failed to sync destination file: %w", err)
+	// Sync to ensure data is written to disk, unless deferred to the end of a scan
+	if wm.config().Fsync {
+		if err := destFile.Sync(); err != nil {
+			return "", fmt.Errorf("failed to sync destination file: %w", err)
+		}
 	}
 
-	return nil
+	return hash, nil
 }
 
-func (wm *WatchManager) AddWatch(path string) error {
+// AddWatch starts watching a project and scans it, returning the scan results
+func (wm *WatchManager) AddWatch(path string) (ScanStats, error) {
 	watch, err := wm.WatchList.AddWatch(path)
 	if err != nil {
-		return err
+		return ScanStats{}, err
+	}
+
+	stats := wm.startWatch(watch)
+
+	app.Logger.WithFields(logrus.Fields{
+		"path":           path,
+		"totalFiles":     stats.TotalFiles,
+		"newFiles":       stats.NewFiles,
+		"changedFiles":   stats.ChangedFiles,
+		"unchangedFiles": stats.UnchangedFiles,
+	}).Info("Scan of added watch completed")
+
+	return stats, nil
+}
+
+// startWatch registers a newly added watch's directories and scans it. The scan
+// also registers any directory created after the watch was prepared, such as
+// one made while init was running, which would otherwise never be watched.
+func (wm *WatchManager) startWatch(watch *Watch) ScanStats {
+	var failed int
+	var firstErr error
+	for _, dir := range watch.WatchDirs {
+		if err := wm.EventsNotifier.AddPath(dir); err != nil {
+			app.Logger.WithField("dir", dir).WithError(err).Error("Failed to add directory to event notifier")
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
 	}
-	for _, path := range watch.WatchDirs {
-		wm.EventsNotifier.AddPath(path)
+
+	stats := wm.ScanWatch(watch)
+	wm.finishScan(stats)
+	stats.Warnings = append(stats.Warnings, watch.Warnings...)
+	if failed > 0 {
+		stats.Warnings = append(stats.Warnings, fmt.Sprintf("%d directories could not be watched and changes in them won't be captured: %v", failed, firstErr))
 	}
-	return nil
+
+	return stats
 }
 
 func (wm *WatchManager) RemoveWatch(path string) error {
 	app.Logger.WithField("path", path).Info("Removing watch from manager")
 
 	// Log current state before removal
-	app.Logger.WithField("currentWatches", len(wm.WatchList.Watches)).Debug("Current watches before removal")
+	app.Logger.WithField("currentWatches", len(wm.WatchList.Snapshot())).Debug("Current watches before removal")
 
 	watch, err := wm.WatchList.RemoveWatch(path)
 	if err != nil {
@@ -450,7 +1409,7 @@ func (wm *WatchManager) RemoveWatch(path string) error {
 	app.Logger.WithField("path", path).WithField("removedDirs", removedCount).WithField("totalDirs", len(watch.WatchDirs)).Info("Watch removal completed")
 
 	// Log current state after removal
-	app.Logger.WithField("currentWatches", len(wm.WatchList.Watches)).Debug("Current watches after removal")
+	app.Logger.WithField("currentWatches", len(wm.WatchList.Snapshot())).Debug("Current watches after removal")
 
 	return nil
 }
@@ -471,14 +1430,29 @@ func (wm *WatchManager) Stop() error {
 	// Cancel context to stop all goroutines
 	wm.cancel()
 
+	// Drop captures still waiting out the create grace period or a throttle,
+	// and size checks not yet run
+	wm.pendingMu.Lock()
+	for path, timer := range wm.pendingCreates {
+		timer.Stop()
+		delete(wm.pendingCreates, path)
+	}
+	for path, timer := range wm.pendingRetries {
+		timer.Stop()
+		delete(wm.pendingRetries, path)
+	}
+	for root, timer := range wm.sizeChecks {
+		timer.Stop()
+		delete(wm.sizeChecks, root)
+	}
+	wm.pendingMu.Unlock()
+	wm.cancelPendingSaves()
+
 	// Close the events notifier
 	if err := wm.EventsNotifier.Close(); err != nil {
 		app.Logger.WithError(err).Error("Error closing events notifier")
 	}
 
-	// Close event channel
-	close(wm.EventChan)
-
 	// Mark as stopped
 	wm.stopped = true
 
@@ -489,86 +1463,183 @@ func (wm *WatchManager) Stop() error {
 	return nil
 }
 
-func (wm *WatchManager) PerformInitialScan() error {
+// ScanStats summarises the outcome of scanning one or more watches
+type ScanStats struct {
+	TotalFiles     int      `json:"total_files"`
+	NewFiles       int      `json:"new_files"`
+	ChangedFiles   int      `json:"changed_files"`
+	UnchangedFiles int      `json:"unchanged_files"`
+	BaselineFiles  int      `json:"baseline_files"`
+	SkippedFiles   int      `json:"skipped_files"`
+	VanishedFiles  int      `json:"vanished_files"`
+	DeletedFiles   int      `json:"deleted_files"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+func (s *ScanStats) add(other ScanStats) {
+	s.TotalFiles += other.TotalFiles
+	s.NewFiles += other.NewFiles
+	s.ChangedFiles += other.ChangedFiles
+	s.UnchangedFiles += other.UnchangedFiles
+	s.BaselineFiles += other.BaselineFiles
+	s.SkippedFiles += other.SkippedFiles
+	s.VanishedFiles += other.VanishedFiles
+	s.DeletedFiles += other.DeletedFiles
+	s.Warnings = append(s.Warnings, other.Warnings...)
+}
+
+// PerformInitialScan captures new and changed files in every watch and
+// returns the combined results
+func (wm *WatchManager) PerformInitialScan() (ScanStats, error) {
 	app.Logger.Info("Starting initial file system scan")
 
-	var totalFiles int
-	var newFiles int
-	var changedFiles int
-	var unchangedFiles int
+	var stats ScanStats
 
 	// Scan each watch in the watch list
-	for _, watch := range wm.WatchList.Watches {
-		if !watch.Active {
-			app.Logger.WithField("path", watch.Path).Debug("Skipping inactive watch during scan")
-			continue
-		}
+	for _, watch := range wm.WatchList.Snapshot() {
+		stats.add(wm.ScanWatch(watch))
+	}
 
-		app.Logger.WithField("watch", watch.Path).Debug("Scanning watch directory")
+	wm.finishScan(stats)
 
-		err := filepath.WalkDir(watch.Path, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				app.Logger.WithField("path", path).WithField("error", err).Warn("Error accessing file during scan")
-				return nil // Continue with other files
-			}
+	app.Logger.WithFields(logrus.Fields{
+		"totalFiles":     stats.TotalFiles,
+		"newFiles":       stats.NewFiles,
+		"changedFiles":   stats.ChangedFiles,
+		"unchangedFiles": stats.UnchangedFiles,
+		"vanishedFiles":  stats.VanishedFiles,
+	}).Info("Initial scan completed")
 
-			// Check if file should be ignored using the watch's ignore logic
-			if watch.ShouldIgnore(path) {
-				if d.IsDir() {
-					app.Logger.WithField("path", path).Debug("Skipping ignored directory and all its contents during scan")
-					return filepath.SkipDir
-				} else {
-					app.Logger.WithField("path", path).Debug("Ignoring file during scan")
-					return nil
-				}
-			}
+	return stats, nil
+}
+
+// ScanWatch captures every file in a single watch that is new or has changed
+// since its latest stored version
+func (wm *WatchManager) ScanWatch(watch *Watch) ScanStats {
+	var stats ScanStats
 
-			// Skip directories (we only process files)
+	if !watch.Active {
+		app.Logger.WithField("path", watch.Path).Debug("Skipping inactive watch during scan")
+		return stats
+	}
+
+	app.Logger.WithField("watch", watch.Path).Debug("Scanning watch directory")
+	wm.syncLatestLinks(watch)
+
+	watched := make(map[string]bool, len(watch.WatchDirs))
+	for _, dir := range watch.WatchDirs {
+		watched[dir] = true
+	}
+
+	err := filepath.WalkDir(watch.Path, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			app.Logger.WithField("path", path).WithField("error", err).Warn("Error accessing file during scan")
+			return nil // Continue with other files
+		}
+
+		// Check if file should be ignored using the watch's ignore logic
+		if watch.ShouldIgnore(path) {
 			if d.IsDir() {
+				app.Logger.WithField("path", path).Debug("Skipping ignored directory and all its contents during scan")
+				return filepath.SkipDir
+			} else {
+				app.Logger.WithField("path", path).Debug("Ignoring file during scan")
 				return nil
 			}
+		}
 
-			totalFiles++
+		// Files of a project nested inside this one are its own
+		if d.IsDir() && wm.WatchList.isNestedWatchRoot(path, watch) {
+			app.Logger.WithField("path", path).Debug("Skipping nested watched project during scan")
+			return filepath.SkipDir
+		}
 
-			// Get relative path for processing
+		// Skip directories (we only process files), recording them if enabled.
+		// Directories created since the watch was prepared are watched now.
+		if d.IsDir() {
 			relPath, err := filepath.Rel(watch.Path, path)
 			if err != nil {
-				app.Logger.WithField("path", path).WithField("error", err).Warn("Failed to get relative path")
-				relPath = path
+				return nil
 			}
-
-			// Process the file using the existing ProcessFile method
-			action, err := wm.ProcessFile(path, relPath, watch)
-			if err != nil {
-				app.Logger.WithField("path", path).WithField("error", err).Error("Failed to process file during scan")
-				return nil // Continue with other files
+			if !watched[path] {
+				if err := wm.AddWatchDirectory(watch, relPath); err != nil {
+					app.Logger.WithField("path", relPath).WithError(err).Warn("Failed to watch directory found during scan")
+				}
 			}
-
-			switch action {
-			case "new":
-				newFiles++
-			case "updated":
-				changedFiles++
-			case "unchanged":
-				unchangedFiles++
+			if wm.config().TrackDirectories && path != watch.Path {
+				wm.recordDirectory(watch, path, relPath)
 			}
+			return nil
+		}
 
+		// Skip files outside the include list
+		if !watch.ShouldInclude(path) {
+			app.Logger.WithField("path", path).Debug("File not in include list during scan")
 			return nil
-		})
+		}
 
+		stats.TotalFiles++
+
+		// Get relative path for processing
+		relPath, err := filepath.Rel(watch.Path, path)
 		if err != nil {
-			app.Logger.WithField("watch", watch.Path).WithField("error", err).Error("Error walking directory during scan")
+			app.Logger.WithField("path", path).WithField("error", err).Warn("Failed to get relative path")
+			relPath = path
 		}
+
+		action, err := wm.processFile(path, relPath, watch, database.EventOpWrite, true)
+		if err != nil {
+			wm.recordCaptureError(watch, relPath, err)
+			return nil // Continue with other files
+		}
+
+		switch action {
+		case "new":
+			stats.NewFiles++
+		case "updated":
+			stats.ChangedFiles++
+		case "unchanged":
+			stats.UnchangedFiles++
+		case "baseline":
+			stats.BaselineFiles++
+		case "excluded", "throttled":
+			stats.SkippedFiles++
+		case "vanished":
+			stats.VanishedFiles++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		app.Logger.WithField("watch", watch.Path).WithField("error", err).Error("Error walking directory during scan")
 	}
 
-	app.Logger.WithFields(logrus.Fields{
-		"totalFiles":     totalFiles,
-		"newFiles":       newFiles,
-		"changedFiles":   changedFiles,
-		"unchangedFiles": unchangedFiles,
-	}).Info("Initial scan completed")
+	return stats
+}
 
-	return nil
+// finishScan completes a scan batch
+func (wm *WatchManager) finishScan(stats ScanStats) {
+	// Per-file fsync is disabled, so flush the whole batch at once
+	if !wm.config().Fsync && stats.NewFiles+stats.ChangedFiles > 0 {
+		syncFilesystem()
+	}
+}
+
+// config returns the running configuration, which a reload may replace
+func (wm *WatchManager) config() Config {
+	wm.configMu.RLock()
+	defer wm.configMu.RUnlock()
+
+	return wm.Config
+}
+
+// auditLog returns the running audit log, which a reload can replace
+func (wm *WatchManager) auditLog() *app.AuditLog {
+	wm.configMu.RLock()
+	defer wm.configMu.RUnlock()
+
+	return wm.audit
 }
 
 func (wm *WatchManager) GetStatus() WatchManagerStatus {
@@ -577,19 +1648,23 @@ func (wm *WatchManager) GetStatus() WatchManagerStatus {
 
 	status := WatchManagerStatus{
 		IsRunning:        wm.isRunning(),
-		TotalWatches:     len(wm.WatchList.Watches),
 		EventChannelSize: len(wm.EventChan),
 		EventChannelCap:  cap(wm.EventChan),
 		ActiveGoroutines: wm.getActiveGoroutineCount(),
+		Config:           wm.config().Settings(),
 	}
 
 	// Calculate uptime if running
 	if !wm.startTime.IsZero() {
 		status.StartTime = wm.startTime
+	}
+	if status.IsRunning {
 		status.UptimeDuration = time.Since(wm.startTime).Round(time.Second).String()
 	}
 
-	// Count total watched directories and collect watch details
+	// Count total watched directories and collect watch details. A reload
+	// swaps a watch's patterns and directories under the watchlist lock.
+	wm.WatchList.mu.RLock()
 	totalDirs := 0
 	watchDetails := make([]WatchStatusDetail, 0, len(wm.WatchList.Watches))
 
@@ -605,19 +1680,32 @@ func (wm *WatchManager) GetStatus() WatchManagerStatus {
 
 		detail := WatchStatusDetail{
 			Path:        watch.Path,
+			Template:    watch.Template,
 			WatchDirs:   watch.WatchDirs,
 			DirCount:    dirCount,
 			IgnoreCount: ignoreCount,
+			Warnings:    watch.Warnings,
 		}
 		watchDetails = append(watchDetails, detail)
 	}
+	wm.WatchList.mu.RUnlock()
 
+	status.TotalWatches = len(watchDetails)
 	status.TotalWatchedDirs = totalDirs
 	status.WatchDetails = watchDetails
+	status.RecentErrors = wm.recentErrors.list()
+	status.SkippedWatches = wm.WatchList.SkippedWatches()
+	status.ThrottledFiles = wm.limiter.list()
+	status.Conflicts = wm.WatchList.Conflicts()
 
 	return status
 }
 
+// GetMetrics returns a snapshot of the capture latency metrics
+func (wm *WatchManager) GetMetrics() CaptureMetrics {
+	return wm.metrics.snapshot()
+}
+
 // isRunning checks if the WatchManager is currently running
 func (wm *WatchManager) isRunning() bool {
 	select {
@@ -628,14 +1716,10 @@ func (wm *WatchManager) isRunning() bool {
 	}
 }
 
-// getActiveGoroutineCount returns the number of active goroutines managed by WatchManager
+// getActiveGoroutineCount returns the number of goroutines the WatchManager
+// has started that are still running
 func (wm *WatchManager) getActiveGoroutineCount() int {
-	// This is a simple count based on what we know about our goroutines
-	// In a more complex implementation, you might want to track this more precisely
-	if wm.isRunning() {
-		return 1 // The main EventsNotifier goroutine
-	}
-	return 0
+	return int(wm.goroutines.Load())
 }
 
 // Context returns the WatchManager's context for external monitoring
@@ -0,0 +1,619 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davenicholson-xyz/rewind/app"
+	"github.com/davenicholson-xyz/rewind/internal/database"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestManager returns a watch manager running with config, which is not
+// started, over an empty watchlist. It is stopped when the test ends.
+func newTestManager(t *testing.T, config Config) (*WatchManager, *WatchList) {
+	t.Helper()
+
+	app.Logger = logrus.New()
+	app.Logger.SetOutput(io.Discard)
+
+	wl := &WatchList{ListPath: filepath.Join(t.TempDir(), "watchlist.json"), Config: config}
+	wm, err := NewWatchManager(wl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { wm.Stop() })
+	return wm, wl
+}
+
+// newTestProject returns the root of a project in a temporary directory and
+// its initialised database, which is closed when the test ends
+func newTestProject(t *testing.T) (string, *database.DatabaseManager) {
+	t.Helper()
+
+	root := t.TempDir()
+	db, err := database.NewDatabaseManager(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return root, db
+}
+
+// newTestWatchManager returns a watch manager from newTestManager with a
+// project from newTestProject added to its watchlist
+func newTestWatchManager(t *testing.T, config Config) (*WatchManager, *Watch, *database.DatabaseManager) {
+	t.Helper()
+
+	wm, wl := newTestManager(t, config)
+	root, db := newTestProject(t)
+	watch, err := wl.AddWatch(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wm, watch, db
+}
+
+func TestWatchManager_AddWatchAfterInit(t *testing.T) {
+	config := DefaultConfig()
+	config.CreateGracePeriod = 0
+
+	// The daemon is already running with nothing to watch
+	wm, wl := newTestManager(t, config)
+	if err := wm.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewind init creates the project database before notifying the daemon
+	root, db := newTestProject(t)
+	if err := os.Mkdir(filepath.Join(root, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "src", "main.go"), []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory created after the project is prepared but before its
+	// directories are registered must still be watched
+	watch, err := wl.AddWatch(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	late := filepath.Join(root, "late")
+	if err := os.Mkdir(late, 0755); err != nil {
+		t.Fatal(err)
+	}
+	stats := wm.startWatch(watch)
+	if stats.NewFiles != 1 {
+		t.Errorf("scan captured %d new files, want 1", stats.NewFiles)
+	}
+
+	versionCount := func(path string) int {
+		versions, err := db.GetFileVersions(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(versions)
+	}
+
+	for _, path := range []string{filepath.Join(root, "src", "main.go"), filepath.Join(late, "new.txt")} {
+		before := versionCount(path)
+		if err := os.WriteFile(path, []byte("edited"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(3 * time.Second)
+		for versionCount(path) == before && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if versionCount(path) == before {
+			t.Errorf("write to %s was not captured", path)
+		}
+	}
+}
+
+func TestWatchManager_SymlinkedRoot(t *testing.T) {
+	config := DefaultConfig()
+	config.CreateGracePeriod = 0
+	wm, wl := newTestManager(t, config)
+	if err := wm.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(realRoot, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "project")
+	if err := os.Symlink(realRoot, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	db, err := database.NewDatabaseManager(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	watch, err := wl.AddWatch(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watch.Path != realRoot {
+		t.Fatalf("watch path = %s, want %s", watch.Path, realRoot)
+	}
+	wm.startWatch(watch)
+
+	// A file written through the symlink is captured, and commands run in
+	// the symlinked project find it
+	filePath := filepath.Join(link, "src", "main.go")
+	if err := os.WriteFile(filePath, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if latest, err := db.GetLatestFileVersion(filePath); err == nil && latest != nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if latest, err := db.GetLatestFileVersion(filePath); err != nil || latest == nil {
+		t.Errorf("write through symlinked root was not captured: %v", err)
+	}
+
+	// The symlink path still names the watch
+	if _, err := wl.AddWatch(link); err == nil {
+		t.Errorf("AddWatch() through the symlink again succeeded, want already exists")
+	}
+	if err := wm.RemoveWatch(link); err != nil {
+		t.Errorf("RemoveWatch() through the symlink error = %v", err)
+	}
+}
+
+func TestWatchManager_StatusAfterAdd(t *testing.T) {
+	config := DefaultConfig()
+	config.CreateGracePeriod = 0
+	wm, wl := newTestManager(t, config)
+	if err := wm.Start(); err != nil {
+		t.Fatal(err)
+	}
+	started := wm.GetStatus()
+
+	// Simulate rewind init notifying the running daemon
+	root, _ := newTestProject(t)
+	watch, err := wl.AddWatch(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wm.startWatch(watch)
+
+	status := wm.GetStatus()
+	if !status.IsRunning {
+		t.Error("IsRunning = false, want true")
+	}
+	if status.StartTime.IsZero() || !status.StartTime.Equal(started.StartTime) {
+		t.Errorf("StartTime = %v, want %v", status.StartTime, started.StartTime)
+	}
+	if status.UptimeDuration == "" {
+		t.Error("UptimeDuration is empty")
+	}
+	if status.TotalWatches != 1 {
+		t.Errorf("TotalWatches = %d, want 1", status.TotalWatches)
+	}
+	// The events notifier and the event handler
+	if status.ActiveGoroutines != 2 {
+		t.Errorf("ActiveGoroutines = %d, want 2", status.ActiveGoroutines)
+	}
+
+	if err := wm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	status = wm.GetStatus()
+	if status.IsRunning {
+		t.Error("IsRunning = true after Stop")
+	}
+	if status.ActiveGoroutines != 0 {
+		t.Errorf("ActiveGoroutines = %d after Stop, want 0", status.ActiveGoroutines)
+	}
+	if status.UptimeDuration != "" {
+		t.Errorf("UptimeDuration = %q after Stop, want empty", status.UptimeDuration)
+	}
+}
+
+func TestWatchManager_RescanCapturesMissedChanges(t *testing.T) {
+	wm, watch, db := newTestWatchManager(t, DefaultConfig())
+	root := watch.Path
+
+	path := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watch is scanned but its files aren't watched, so every later
+	// change is one the watcher missed
+	wm.ScanWatch(watch)
+
+	if err := os.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "new.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wm.rescan()
+
+	for file, want := range map[string]int{"notes.txt": 2, "new.txt": 1} {
+		versions, err := db.GetFileVersions(filepath.Join(root, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) != want {
+			t.Errorf("%s has %d versions after rescan, want %d", file, len(versions), want)
+		}
+	}
+}
+
+func TestWatchManager_RescanRecordsMissedDeletions(t *testing.T) {
+	wm, watch, db := newTestWatchManager(t, DefaultConfig())
+
+	path := filepath.Join(watch.Path, "notes.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wm.ScanWatch(watch)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if deleted := wm.scanDeletions(watch); deleted != 1 {
+		t.Errorf("scanDeletions() = %d, want 1", deleted)
+	}
+
+	latest, err := db.GetLatestFileVersion(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest == nil || !latest.Deleted {
+		t.Fatalf("latest version = %+v, want it marked deleted", latest)
+	}
+
+	// A deletion already recorded isn't recorded again
+	if deleted := wm.scanDeletions(watch); deleted != 0 {
+		t.Errorf("second scanDeletions() = %d, want 0", deleted)
+	}
+}
+
+func TestWatchManager_ThrottledFileCapturedWhenThrottleLifts(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxCapturesPerMinute = 1
+	config.CaptureCooldown = 50 * time.Millisecond
+	wm, watch, db := newTestWatchManager(t, config)
+
+	path := filepath.Join(watch.Path, "notes.txt")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if action, err := wm.ProcessFile(path, "notes.txt", watch, database.EventOpWrite); err != nil || action != "new" {
+		t.Fatalf("ProcessFile() = %q, %v, want new", action, err)
+	}
+
+	if err := os.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if action, err := wm.ProcessFile(path, "notes.txt", watch, database.EventOpWrite); err != nil || action != "throttled" {
+		t.Fatalf("ProcessFile() = %q, %v, want throttled", action, err)
+	}
+
+	// The skipped change is captured once the cooldown has passed
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		versions, err := db.GetFileVersions(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(versions) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("notes.txt has %d versions after the cooldown, want 2", len(versions))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchManager_TotalSizeCheckedAfterCaptures(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxTotalSize = 1
+	wm, watch, db := newTestWatchManager(t, config)
+	root := watch.Path
+
+	path := filepath.Join(root, "notes.txt")
+	for _, content := range []string{"v1", "v2", "v3"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wm.ProcessFile(path, "notes.txt", watch, database.EventOpWrite); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The captures share one pending check rather than each running it
+	wm.pendingMu.Lock()
+	pending := len(wm.sizeChecks)
+	wm.pendingMu.Unlock()
+	if pending != 1 {
+		t.Fatalf("%d size checks pending after the captures, want 1", pending)
+	}
+	if versions, err := db.GetFileVersions(path); err != nil || len(versions) != 3 {
+		t.Fatalf("GetFileVersions() before the check = %d versions, %v, want 3", len(versions), err)
+	}
+
+	wm.checkTotalSize(root)
+
+	versions, err := db.GetFileVersions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 || versions[0].VersionNumber != 3 {
+		t.Errorf("versions after the size check = %+v, want only the latest", versions)
+	}
+}
+
+func TestWatchManager_FileVanishedBeforeCapture(t *testing.T) {
+	wm, watch, _ := newTestWatchManager(t, DefaultConfig())
+
+	// Found by the scan or an event, then deleted before it was read
+	path := filepath.Join(watch.Path, "build.tmp")
+	action, err := wm.ProcessFile(path, "build.tmp", watch, database.EventOpCreate)
+	if err != nil {
+		t.Fatalf("ProcessFile() error = %v, want none", err)
+	}
+	if action != "vanished" {
+		t.Errorf("ProcessFile() action = %q, want vanished", action)
+	}
+	if errs := wm.GetStatus().RecentErrors; len(errs) != 0 {
+		t.Errorf("recent errors = %v, want none", errs)
+	}
+}
+
+func TestWatchManager_HashWhileStoring(t *testing.T) {
+	wm, watch, db := newTestWatchManager(t, DefaultConfig())
+
+	path := filepath.Join(watch.Path, "notes.txt")
+	steps := []struct {
+		content string
+		want    string
+	}{
+		{"first", "new"},
+		{"first, longer", "updated"},
+		{"second, same", "updated"},
+		{"second, same", "unchanged"},
+	}
+	for i, step := range steps {
+		if err := os.WriteFile(path, []byte(step.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		action, err := wm.ProcessFile(path, "notes.txt", watch, database.EventOpWrite)
+		if err != nil {
+			t.Fatalf("step %d: ProcessFile() error = %v", i, err)
+		}
+		if action != step.want {
+			t.Errorf("step %d: ProcessFile() action = %q, want %q", i, action, step.want)
+		}
+
+		latest, err := db.GetLatestFileVersion(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantHash, err := database.CalculateFileHash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest.FileHash != wantHash {
+			t.Errorf("step %d: stored hash = %s, want %s", i, latest.FileHash, wantHash)
+		}
+		stored, err := os.ReadFile(filepath.Join(db.VersionsDir(), latest.StoragePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stored) != step.content {
+			t.Errorf("step %d: stored content = %q, want %q", i, stored, step.content)
+		}
+	}
+}
+
+func TestWatchManager_CaptureFilter(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxFileSize = 10
+	wm, watch, _ := newTestWatchManager(t, config)
+
+	// Skip secrets, keeping the default rules for everything else
+	wm.CaptureFilter = func(path string, info os.FileInfo) (bool, string) {
+		if strings.HasSuffix(path, ".secret") {
+			return false, "secret"
+		}
+		return wm.DefaultCaptureFilter(path, info)
+	}
+
+	for _, file := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"notes.txt", "short", "new"},
+		{"key.secret", "short", "excluded"},
+		{"large.txt", "longer than ten bytes", "excluded"},
+	} {
+		path := filepath.Join(watch.Path, file.name)
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		action, err := wm.ProcessFile(path, file.name, watch, database.EventOpCreate)
+		if err != nil {
+			t.Fatalf("%s: ProcessFile() error = %v", file.name, err)
+		}
+		if action != file.want {
+			t.Errorf("%s: ProcessFile() action = %q, want %q", file.name, action, file.want)
+		}
+	}
+}
+
+func TestWatchManager_Reload(t *testing.T) {
+	wm, wl := newTestManager(t, DefaultConfig())
+
+	newProject := func() string {
+		root, _ := newTestProject(t)
+		return root
+	}
+	kept, dropped, added := newProject(), newProject(), newProject()
+	for _, root := range []string{kept, dropped} {
+		if _, err := wl.AddWatch(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Edit the watchlist and a project's ignore patterns behind the daemon's back
+	if err := wl.SaveWatchlist([]Watch{{Path: kept, Active: true}, {Path: added, Active: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(kept, ".rwignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.MaxVersionsPerFile = 5
+	config.RescanInterval = time.Minute
+	result, err := wm.Reload(config)
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	if !slices.Equal(result.Added, []string{added}) || !slices.Equal(result.Removed, []string{dropped}) || !slices.Equal(result.Updated, []string{kept}) {
+		t.Errorf("Reload() watches added %v, removed %v, updated %v", result.Added, result.Removed, result.Updated)
+	}
+	if !slices.Equal(result.Settings, []string{"max_versions_per_file"}) || !slices.Equal(result.Restart, []string{"rescan_interval"}) {
+		t.Errorf("Reload() settings %v, restart %v", result.Settings, result.Restart)
+	}
+	if wm.Config.MaxVersionsPerFile != 5 || wm.Config.RescanInterval != 0 {
+		t.Errorf("running config max_versions_per_file %d, rescan_interval %v", wm.Config.MaxVersionsPerFile, wm.Config.RescanInterval)
+	}
+
+	if watch := wm.findWatch(kept); watch == nil || !watch.ShouldIgnore(filepath.Join(kept, "debug.log")) {
+		t.Error("reloaded watch does not ignore *.log")
+	}
+	if wm.findWatch(dropped) != nil || wm.findWatch(added) == nil {
+		t.Error("running watches do not match the watchlist")
+	}
+}
+
+// Run with -race: reloads replace the config and watches that status,
+// event routing and the HTTP API read concurrently
+func TestWatchManager_ReloadWhileReading(t *testing.T) {
+	wm, wl := newTestManager(t, DefaultConfig())
+
+	var roots []string
+	for i := 0; i < 2; i++ {
+		root, _ := newTestProject(t)
+		if _, err := wl.AddWatch(root); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		file := filepath.Join(roots[0], "main.go")
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			wm.GetStatus()
+			wm.WatchList.FindByPath(file)
+			wm.WatchList.WatchesContaining(file)
+			wm.WatchList.Snapshot()
+			_ = wm.config().MaxVersionsPerFile
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		// Alternate between dropping and re-adding the second project, with
+		// changed patterns and settings each time
+		watches := []Watch{{Path: roots[0], Active: true}}
+		if i%2 == 1 {
+			watches = append(watches, Watch{Path: roots[1], Active: true})
+		}
+		if err := wl.SaveWatchlist(watches); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(roots[0], ".rwignore"), []byte(fmt.Sprintf("*.tmp%d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		config := DefaultConfig()
+		config.MaxVersionsPerFile = i + 1
+		if _, err := wm.Reload(config); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	if got := wm.config().MaxVersionsPerFile; got != 20 {
+		t.Errorf("running config max_versions_per_file %d, want 20", got)
+	}
+}
+
+func TestWatchManager_DryRun(t *testing.T) {
+	wm, watch, db := newTestWatchManager(t, DefaultConfig())
+	wm.DryRun = true
+
+	path := filepath.Join(watch.Path, "notes.txt")
+	if err := os.WriteFile(path, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The scan and events still decide what would be captured
+	if stats := wm.ScanWatch(watch); stats.NewFiles != 1 {
+		t.Errorf("ScanWatch() found %d new files, want 1", stats.NewFiles)
+	}
+	if action, err := wm.ProcessFile(path, "notes.txt", watch, database.EventOpWrite); err != nil || action != "new" {
+		t.Errorf("ProcessFile() = %q, %v, want new", action, err)
+	}
+	wm.handleRemove(path, watch)
+
+	// ...but nothing is stored
+	files, err := db.GetAllLatestFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("dry run recorded %d files, want none", len(files))
+	}
+	entries, err := os.ReadDir(db.VersionsDir())
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("dry run stored %d copies, want none", len(entries))
+	}
+}
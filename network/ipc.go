@@ -15,6 +15,14 @@ const (
 	DefaultChannelBuffer = 100
 )
 
+// Connecting is retried so a command run while the daemon is starting or
+// restarting doesn't fail outright. Attempts back off by dialRetryDelay each
+// time, giving up after about two seconds.
+const (
+	dialAttempts   = 3
+	dialRetryDelay = 500 * time.Millisecond
+)
+
 type IPCMessage struct {
 	Content    string
 	Connection net.Conn
@@ -34,6 +42,7 @@ type IPCClient struct {
 
 type IPCConfig struct {
 	AppName       string
+	Instance      string
 	Path          string
 	BufferSize    int
 	ChannelBuffer int
@@ -190,14 +199,21 @@ func determinePath(config IPCConfig) string {
 		return config.Path
 	}
 
-	var defaultName string
-	if config.AppName != "" {
-		defaultName = config.AppName
-	} else {
-		defaultName = "app"
+	return SocketPath(config.AppName, config.Instance)
+}
+
+// SocketPath returns the default socket path for an app, namespaced by
+// instance when one is given so several daemons can run side by side
+func SocketPath(appName, instance string) string {
+	if appName == "" {
+		appName = "app"
+	}
+
+	if instance != "" {
+		return "/tmp/" + appName + "-" + instance + ".sock"
 	}
 
-	return "/tmp/" + defaultName + ".sock"
+	return "/tmp/" + appName + ".sock"
 }
 
 func createListener(path string) (net.Listener, error) {
@@ -205,8 +221,25 @@ func createListener(path string) (net.Listener, error) {
 	return net.Listen("unix", path)
 }
 
+// DialIPC connects to the socket at path, retrying a few times before giving
+// up. Only the connection is retried, so a message is never sent twice.
+func DialIPC(path string, timeout time.Duration) (net.Conn, error) {
+	var err error
+	for attempt := 1; attempt <= dialAttempts; attempt++ {
+		var conn net.Conn
+		conn, err = net.DialTimeout("unix", path, timeout)
+		if err == nil {
+			return conn, nil
+		}
+		if attempt < dialAttempts {
+			time.Sleep(time.Duration(attempt) * dialRetryDelay)
+		}
+	}
+	return nil, fmt.Errorf("no daemon answered on %s after %d attempts: %w", path, dialAttempts, err)
+}
+
 func SendToIPC(path, message string) (string, error) {
-	conn, err := net.Dial("unix", path)
+	conn, err := DialIPC(path, 5*time.Second)
 	if err != nil {
 		return "", fmt.Errorf("failed to connect to IPC: %v", err)
 	}